@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// completeExecutionNames completes --name with the executions defined in the configuration file.
+// inputFile is the command's deprecated --input flag, which is already parsed when completing.
+func completeExecutionNames(inputFile *string) cobra.CompletionFunc {
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		config, err := readConfig(configFilePath(*inputFile))
+		if err != nil {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+
+		var names []string
+		for _, execution := range config.Executions {
+			if strings.HasPrefix(execution.Name, toComplete) {
+				names = append(names, execution.Name)
+			}
+		}
+		return names, cobra.ShellCompDirectiveNoFileComp
+	}
+}
+
+// completeContexts completes --context with the contexts defined in the configuration file
+func completeContexts(inputFile *string) cobra.CompletionFunc {
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		config, err := readConfig(configFilePath(*inputFile))
+		if err != nil {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+
+		var contexts []string
+		for _, context := range config.Contexts {
+			if strings.HasPrefix(context, toComplete) {
+				contexts = append(contexts, context)
+			}
+		}
+		return contexts, cobra.ShellCompDirectiveNoFileComp
+	}
+}
+
+// completeProfiles completes --profile with the profiles defined in the configuration file
+func completeProfiles(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	config, err := readConfigIncludes(configFilePath(""), nil)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	var profiles []string
+	for name := range config.Profiles {
+		if strings.HasPrefix(name, toComplete) {
+			profiles = append(profiles, name)
+		}
+	}
+	slices.Sort(profiles)
+	return profiles, cobra.ShellCompDirectiveNoFileComp
+}
@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestCompleteExecutionNamesAndContexts(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	config := `contexts:
+  - dev
+  - prod
+sources:
+  - type: Vars
+    name: inline
+    vars:
+      - name: HOST
+        value: localhost
+executions:
+  - name: local
+  - name: staging
+  - name: stable
+profiles:
+  staging:
+    namespace: staging
+  prod:
+    namespace: prod
+`
+	if err := os.WriteFile(".enver.yaml", []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		args     []string
+		expected []string
+	}{
+		{args: []string{"execute", "--name", ""}, expected: []string{"local", "staging", "stable"}},
+		{args: []string{"diff", "--name", "sta"}, expected: []string{"staging", "stable"}},
+		{args: []string{"generate", "--context", ""}, expected: []string{"dev", "prod"}},
+		{args: []string{"list", "-c", "p"}, expected: []string{"prod"}},
+		{args: []string{"execute", "--profile", ""}, expected: []string{"prod", "staging"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(strings.Join(tc.args, " "), func(t *testing.T) {
+			var out bytes.Buffer
+			rootCmd.SetOut(&out)
+			defer rootCmd.SetOut(nil)
+
+			rootCmd.SetArgs(append([]string{"__complete"}, tc.args...))
+			if err := rootCmd.Execute(); err != nil {
+				t.Fatalf("completion returned error: %v", err)
+			}
+
+			// The completions are followed by a line with the directive, e.g. ":4"
+			lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+			completions := lines[:len(lines)-1]
+			if strings.Join(completions, ",") != strings.Join(tc.expected, ",") {
+				t.Errorf("expected %v, got %v", tc.expected, completions)
+			}
+		})
+	}
+}
@@ -0,0 +1,476 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"enver/sources"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// stdinConfig as configuration file reads the configuration from stdin
+const stdinConfig = "-"
+
+// configStdin is where the configuration is read from with --config -
+var configStdin io.Reader = os.Stdin
+
+// stdinConfigContent holds the configuration once read from stdin, which can only be read once
+var stdinConfigContent []byte
+
+// configFilePath returns the configuration file to use: the command's deprecated --input flag if
+// given, otherwise the persistent --config flag (default .enver.yaml)
+func configFilePath(inputFile string) string {
+	if inputFile != "" {
+		return inputFile
+	}
+	if rootConfigFile == "" {
+		return ".enver.yaml"
+	}
+	return rootConfigFile
+}
+
+// addDeprecatedInputFlag registers the per-command --input/-i flag that --config replaces
+func addDeprecatedInputFlag(cmd *cobra.Command, inputFile *string) {
+	cmd.Flags().StringVarP(inputFile, "input", "i", "", "input configuration file")
+	cmd.Flags().MarkDeprecated("input", "use --config instead")
+}
+
+// readConfig reads and parses the configuration file and the files it includes without checking
+// their contents
+func readConfig(configFile string) (*ExecuteConfig, error) {
+	config, err := readConfigIncludes(configFile, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := applyProfile(config, profileName); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", configFile, err)
+	}
+	inheritTransformations(config)
+	return config, nil
+}
+
+// inheritTransformations prepends the top-level transformations to the transformations of every
+// source that doesn't opt out with inheritTransformations: false
+func inheritTransformations(config *ExecuteConfig) {
+	if len(config.Transformations) == 0 {
+		return
+	}
+	for i, source := range config.Sources {
+		if source.InheritTransformations != nil && !*source.InheritTransformations {
+			continue
+		}
+		config.Sources[i].Transformations = append(slices.Clone(config.Transformations), source.Transformations...)
+	}
+}
+
+// applyProfile applies the named profile over the configuration; an empty name applies none. The
+// command line wins over the profile: --namespace, --kube-context and generate's --output-directory
+// are applied after it.
+func applyProfile(config *ExecuteConfig, name string) error {
+	if name == "" {
+		return nil
+	}
+	profile, ok := config.Profiles[name]
+	if !ok {
+		available := slices.Sorted(maps.Keys(config.Profiles))
+		if len(available) == 0 {
+			return fmt.Errorf("unknown profile %q: no profiles defined", name)
+		}
+		return fmt.Errorf("unknown profile %q (available profiles: %s)", name, strings.Join(available, ", "))
+	}
+
+	if profile.Namespace != "" {
+		for i := range config.Sources {
+			config.Sources[i].Namespace = profile.Namespace
+		}
+	}
+	for i := range config.Executions {
+		if profile.KubeContext != "" {
+			config.Executions[i].KubeContext = profile.KubeContext
+		}
+		if profile.Directory != "" && config.Executions[i].Output.Directory != stdoutOutput {
+			config.Executions[i].Output.Directory = profile.Directory
+		}
+	}
+	return nil
+}
+
+// selectedProfile returns the profile selected with --profile, empty when none is selected
+func selectedProfile(config *ExecuteConfig) Profile {
+	return config.Profiles[profileName]
+}
+
+// readConfigIncludes reads a configuration file and merges the files it includes into it, in order.
+// Include paths are relative to the including file. chain holds the files that are being read, to
+// detect circular includes.
+func readConfigIncludes(configFile string, chain []string) (*ExecuteConfig, error) {
+	absPath, err := filepath.Abs(configFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve %s: %w", configFile, err)
+	}
+	if i := slices.Index(chain, absPath); i >= 0 {
+		cycle := append(slices.Clone(chain[i:]), absPath)
+		for j := range cycle {
+			cycle[j] = filepath.Base(cycle[j])
+		}
+		return nil, fmt.Errorf("circular include: %s", strings.Join(cycle, " -> "))
+	}
+	chain = append(chain, absPath)
+
+	content, err := readConfigFile(configFile)
+	if err != nil {
+		return nil, err
+	}
+
+	var config ExecuteConfig
+	if err := unmarshalConfig(content, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", configFile, err)
+	}
+
+	for _, include := range config.Includes {
+		if !filepath.IsAbs(include) {
+			include = filepath.Join(filepath.Dir(configFile), include)
+		}
+		included, err := readConfigIncludes(include, chain)
+		if err != nil {
+			return nil, err
+		}
+		mergeConfig(&config, included)
+	}
+	return &config, nil
+}
+
+// readConfigFile returns the content of a configuration file, or of stdin for -
+func readConfigFile(configFile string) ([]byte, error) {
+	if configFile != stdinConfig {
+		content, err := os.ReadFile(configFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", configFile, err)
+		}
+		return content, nil
+	}
+
+	if stdinConfigContent == nil {
+		content, err := io.ReadAll(configStdin)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read configuration from stdin: %w", err)
+		}
+		stdinConfigContent = content
+	}
+	return stdinConfigContent, nil
+}
+
+// unmarshalConfig parses a configuration file into out, expanding environment variables in its
+// scalars with expandConfigEnv. Expanding after parsing keeps substituted values from changing the
+// structure of the file and leaves references in comments alone.
+func unmarshalConfig(content []byte, out any) error {
+	var document yaml.Node
+	if err := yaml.Unmarshal(content, &document); err != nil {
+		return err
+	}
+	if document.Kind == 0 {
+		return nil
+	}
+	expandConfigNode(&document)
+	return document.Decode(out)
+}
+
+// expandConfigNode expands environment variables in the scalars below node. Plain scalars that
+// contained a reference are resolved again, so ${REPLICAS} can still fill in a number or a bool,
+// while quoted ones stay strings.
+func expandConfigNode(node *yaml.Node) {
+	if node.Kind == yaml.ScalarNode {
+		expanded := expandConfigEnv(node.Value)
+		if expanded != node.Value && node.Style&(yaml.SingleQuotedStyle|yaml.DoubleQuotedStyle|yaml.LiteralStyle|yaml.FoldedStyle) == 0 && node.Tag == "!!str" {
+			node.Tag = ""
+		}
+		node.Value = expanded
+		return
+	}
+	for _, child := range node.Content {
+		expandConfigNode(child)
+	}
+}
+
+// expandConfigEnv replaces ${VAR} and ${VAR:-default} with the value of the environment variable,
+// using the default when it is unset or empty. Unset variables without a default are replaced by an
+// empty string. $$ is a literal $, other $ are kept as-is so values such as passwords are unchanged.
+func expandConfigEnv(content string) string {
+	var sb strings.Builder
+	for i := 0; i < len(content); i++ {
+		if content[i] != '$' || i+1 == len(content) {
+			sb.WriteByte(content[i])
+			continue
+		}
+
+		switch content[i+1] {
+		case '$':
+			sb.WriteByte('$')
+			i++
+		case '{':
+			end := strings.IndexByte(content[i+2:], '}')
+			if end == -1 {
+				sb.WriteByte('$')
+				continue
+			}
+			reference := content[i+2 : i+2+end]
+			name, fallback, hasFallback := strings.Cut(reference, ":-")
+			value := os.Getenv(name)
+			if value == "" && hasFallback {
+				value = fallback
+			}
+			sb.WriteString(value)
+			i += len(reference) + 2
+		default:
+			sb.WriteByte('$')
+		}
+	}
+	return sb.String()
+}
+
+// mergeConfig merges an included configuration into config. Executions with the same name, and
+// sources described the same (type, namespace and name or path), replace the earlier ones in place;
+// new ones are appended. Contexts are added when missing and validations override by variable.
+func mergeConfig(config *ExecuteConfig, included *ExecuteConfig) {
+	for _, context := range included.Contexts {
+		if !slices.Contains(config.Contexts, context) {
+			config.Contexts = append(config.Contexts, context)
+		}
+	}
+
+	// A source may be defined several times, e.g. with other key mappings per context, so all
+	// definitions of a source are replaced by all definitions in the included file
+	overrides := make(map[string][]sources.Source)
+	for _, source := range included.Sources {
+		overrides[describeSource(source)] = append(overrides[describeSource(source)], source)
+	}
+	replaced := make(map[string]bool)
+	var merged []sources.Source
+	for _, source := range config.Sources {
+		key := describeSource(source)
+		override, ok := overrides[key]
+		switch {
+		case !ok:
+			merged = append(merged, source)
+		case !replaced[key]:
+			merged = append(merged, override...)
+			replaced[key] = true
+		}
+	}
+	var added []sources.Source
+	for _, source := range included.Sources {
+		if !replaced[describeSource(source)] {
+			added = append(added, source)
+		}
+	}
+	config.Sources = append(merged, added...)
+
+	for _, execution := range included.Executions {
+		i := slices.IndexFunc(config.Executions, func(existing Execution) bool { return existing.Name == execution.Name })
+		if i >= 0 {
+			config.Executions[i] = execution
+		} else {
+			config.Executions = append(config.Executions, execution)
+		}
+	}
+
+	for name, profile := range included.Profiles {
+		if config.Profiles == nil {
+			config.Profiles = make(map[string]Profile)
+		}
+		config.Profiles[name] = profile
+	}
+
+	if len(included.Transformations) > 0 {
+		config.Transformations = included.Transformations
+	}
+
+	for name, validation := range included.Validations {
+		if config.Validations == nil {
+			config.Validations = make(map[string]ValueValidation)
+		}
+		config.Validations[name] = validation
+	}
+}
+
+// describeSource returns a short human readable identifier for a source, e.g. "ConfigMap default/app"
+func describeSource(source sources.Source) string {
+	switch {
+	case source.Path == "" && source.Name == "":
+		return source.Type
+	case source.Path != "":
+		return fmt.Sprintf("%s %s", source.Type, source.Path)
+	case source.NeedsKubernetes():
+		return fmt.Sprintf("%s %s/%s", source.Type, source.GetNamespace(), source.Name)
+	default:
+		return fmt.Sprintf("%s %s", source.Type, source.Name)
+	}
+}
+
+// validateSources runs every source's preflight validation and returns an error listing all invalid sources
+func validateSources(configSources []sources.Source) error {
+	var problems []string
+	for i, source := range configSources {
+		if err := source.Validate(); err != nil {
+			problems = append(problems, fmt.Sprintf("source %d (%s): %v", i+1, describeSource(source), err))
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid sources:\n  %s", strings.Join(problems, "\n  "))
+	}
+	return nil
+}
+
+// renderSourceNames returns a copy of the sources with templates such as "{{ .Context }}-config"
+// in their names expanded, so one source definition can target a different object per context.
+// The namespace of every source is replaced by --namespace when it is given.
+func renderSourceNames(configSources []sources.Source, data outputPathData) ([]sources.Source, error) {
+	rendered := make([]sources.Source, len(configSources))
+	for i, source := range configSources {
+		name, err := renderTemplate("source name", source.Name, data)
+		if err != nil {
+			return nil, err
+		}
+		source.Name = name
+		if namespaceOverride != "" {
+			source.Namespace = namespaceOverride
+		}
+		rendered[i] = source
+	}
+	return rendered, nil
+}
+
+// checkDuplicates returns an error listing execution names that are used more than once and
+// sources that are defined more than once, see sourceIdentity. The same resource may appear
+// several times in different contexts or namespaces, not with different filters in the same ones.
+func checkDuplicates(executions []Execution, configSources []sources.Source) error {
+	var problems []string
+
+	seenNames := make(map[string]int)
+	var duplicateNames []string
+	for _, execution := range executions {
+		seenNames[execution.Name]++
+		if seenNames[execution.Name] == 2 {
+			duplicateNames = append(duplicateNames, execution.Name)
+		}
+	}
+	if len(duplicateNames) > 0 {
+		problems = append(problems, fmt.Sprintf("duplicate execution names: %s", strings.Join(duplicateNames, ", ")))
+	}
+
+	var duplicateSources []string
+	for i := range configSources {
+		// Unnamed sources such as inline Vars are identified by their content only
+		if configSources[i].Name == "" && configSources[i].Path == "" {
+			continue
+		}
+		for j := 0; j < i; j++ {
+			if sourceIdentity(configSources[i]) == sourceIdentity(configSources[j]) {
+				duplicateSources = append(duplicateSources, fmt.Sprintf("%s (sources %d and %d)", describeSource(configSources[i]), j+1, i+1))
+				break
+			}
+		}
+	}
+	if len(duplicateSources) > 0 {
+		problems = append(problems, fmt.Sprintf("duplicate sources: %s", strings.Join(duplicateSources, ", ")))
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("%s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// sourceIdentity returns what identifies the data a source reads: its type and kind, the object
+// (namespace and name, or path), the cluster, the pod and containers or metadata it selects and the
+// contexts it is included in. Sources with the same identity are duplicates even when they filter or
+// transform the variables differently.
+func sourceIdentity(source sources.Source) string {
+	include := slices.Sorted(slices.Values(source.Contexts.Include))
+	exclude := slices.Sorted(slices.Values(source.Contexts.Exclude))
+	return strings.Join([]string{
+		source.Kind,
+		describeSource(source),
+		source.Kubeconfig,
+		source.KubeContext,
+		source.Pod,
+		strings.Join(slices.Sorted(slices.Values(source.Containers)), ","),
+		source.Metadata,
+		describeSourceContexts(include, exclude),
+	}, "\x00")
+}
+
+// checkOutputOptions returns an error when the output options of an execution can't be combined
+func checkOutputOptions(execution Execution) error {
+	if execution.Output.Directory != stdoutOutput {
+		return nil
+	}
+	if execution.Output.PerSource {
+		return fmt.Errorf("output.perSource cannot be combined with writing to stdout")
+	}
+	if execution.Output.Encrypt {
+		return fmt.Errorf("output.encrypt cannot be combined with writing to stdout")
+	}
+	return nil
+}
+
+// checkDependencies returns an error when an execution depends on an unknown execution or when
+// dependsOn forms a cycle
+func checkDependencies(executions []Execution) error {
+	byName := make(map[string]Execution, len(executions))
+	for _, execution := range executions {
+		byName[execution.Name] = execution
+	}
+
+	for _, execution := range executions {
+		for _, dependency := range execution.DependsOn {
+			if _, ok := byName[dependency]; !ok {
+				return fmt.Errorf("execution %q depends on unknown execution %q", execution.Name, dependency)
+			}
+		}
+	}
+
+	// Depth-first search, an execution that is reached again while it is on the path closes a cycle
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make(map[string]int, len(executions))
+	var path []string
+	var visit func(name string) error
+	visit = func(name string) error {
+		switch state[name] {
+		case visiting:
+			start := slices.Index(path, name)
+			return fmt.Errorf("executions depend on each other: %s", strings.Join(append(path[start:], name), " -> "))
+		case visited:
+			return nil
+		}
+
+		state[name] = visiting
+		path = append(path, name)
+		for _, dependency := range byName[name].DependsOn {
+			if err := visit(dependency); err != nil {
+				return err
+			}
+		}
+		path = path[:len(path)-1]
+		state[name] = visited
+		return nil
+	}
+
+	for _, execution := range executions {
+		if err := visit(execution.Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
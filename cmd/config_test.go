@@ -0,0 +1,669 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"enver/engine"
+	"enver/sources"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestLoadExecuteConfigRejectsDuplicateExecutionNames(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), ".enver.yaml")
+	content := `sources:
+  - type: Vars
+    name: inline
+    vars:
+      - name: HOST
+        value: localhost
+executions:
+  - name: dev
+    output:
+      name: dev.env
+  - name: prod
+  - name: dev
+    output:
+      name: other.env
+`
+	if err := os.WriteFile(configFile, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+
+	_, err := loadExecuteConfig(configFile)
+	if err == nil {
+		t.Fatal("expected an error for duplicate execution names")
+	}
+	if !strings.Contains(err.Error(), "duplicate execution names: dev") {
+		t.Errorf("expected error to list the duplicate name, got: %v", err)
+	}
+}
+
+func TestLoadExecuteConfigRejectsDuplicateSources(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), ".enver.yaml")
+	content := `sources:
+  - type: ConfigMap
+    name: app
+  - type: ConfigMap
+    name: app
+    namespace: other
+  - type: ConfigMap
+    name: app
+    contexts:
+      include:
+        - dev
+  - type: Secret
+    name: app
+  - type: Vars
+    vars:
+      - name: A
+        value: a
+  - type: Vars
+    vars:
+      - name: B
+        value: b
+  - type: ConfigMap
+    name: app
+    variables:
+      include:
+        - ^APP_.*
+executions:
+  - name: dev
+`
+	if err := os.WriteFile(configFile, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+
+	// Other namespaces, contexts and types are different sources, a filter doesn't make the last one different
+	_, err := loadExecuteConfig(configFile)
+	if err == nil {
+		t.Fatal("expected an error for duplicate sources")
+	}
+	if !strings.HasSuffix(err.Error(), "duplicate sources: ConfigMap default/app (sources 1 and 7)") {
+		t.Errorf("expected error to list only the duplicate source, got: %v", err)
+	}
+}
+
+func TestLoadExecuteConfigRejectsPerSourceToStdout(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), ".enver.yaml")
+	content := `sources:
+  - type: Vars
+    name: inline
+    vars:
+      - name: HOST
+        value: localhost
+executions:
+  - name: dev
+    output:
+      directory: "-"
+      perSource: true
+`
+	if err := os.WriteFile(configFile, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+
+	_, err := loadExecuteConfig(configFile)
+	if err == nil {
+		t.Fatal("expected an error for output.perSource with stdout")
+	}
+	if !strings.Contains(err.Error(), `execution "dev": output.perSource cannot be combined with writing to stdout`) {
+		t.Errorf("expected error to name the execution and the options, got: %v", err)
+	}
+}
+
+func TestLoadExecuteConfigRejectsInvalidTransformations(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), ".enver.yaml")
+	content := `sources:
+  - type: Vars
+    name: inline
+    vars:
+      - name: HOST
+        value: localhost
+  - type: ConfigMap
+    name: app
+    transformations:
+      - type: absolute_path
+        target: key
+executions:
+  - name: dev
+`
+	if err := os.WriteFile(configFile, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+
+	_, err := loadExecuteConfig(configFile)
+	if err == nil {
+		t.Fatal("expected an error for an invalid transformation")
+	}
+	if !strings.Contains(err.Error(), "source 2 (ConfigMap default/app): invalid transformation 1: absolute_path transformation can only be applied to values") {
+		t.Errorf("expected error to point at the transformation, got: %v", err)
+	}
+}
+
+func TestConfigFilePath(t *testing.T) {
+	defer func(original string) { rootConfigFile = original }(rootConfigFile)
+
+	rootConfigFile = ".enver.yaml"
+	if path := configFilePath(""); path != ".enver.yaml" {
+		t.Errorf("expected the default .enver.yaml, got %q", path)
+	}
+
+	rootConfigFile = "envs/prod.yaml"
+	if path := configFilePath(""); path != "envs/prod.yaml" {
+		t.Errorf("expected --config to be used, got %q", path)
+	}
+
+	// The deprecated --input flag still takes precedence when given
+	if path := configFilePath("legacy.yaml"); path != "legacy.yaml" {
+		t.Errorf("expected --input to take precedence, got %q", path)
+	}
+}
+
+func TestConfigFromStdin(t *testing.T) {
+	t.Chdir(t.TempDir())
+	config := `contexts: [dev, prod]
+sources:
+  - type: Vars
+    name: common
+    vars:
+      - name: HOST
+        value: localhost
+  - type: Vars
+    name: dev
+    contexts:
+      include: [dev]
+    vars:
+      - name: DEBUG
+        value: "true"
+  - type: Vars
+    name: prod
+    contexts:
+      include: [prod]
+    vars:
+      - name: REPLICAS
+        value: "3"
+executions:
+  - name: dev
+    contexts: [dev]
+    output:
+      directory: "-"
+`
+	defer func(original string) { rootConfigFile = original }(rootConfigFile)
+	defer func() {
+		configStdin = os.Stdin
+		stdinConfigContent = nil
+		outputDirectory = "generated"
+		contextFlags = []string{}
+		executeNames = []string{}
+	}()
+
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{name: "generate", args: []string{"generate", "--config", "-", "--context", "dev", "--output-directory", "-"}},
+		{name: "execute", args: []string{"execute", "--config", "-", "--name", "dev"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			configStdin = strings.NewReader(config)
+			stdinConfigContent = nil
+			contextFlags = []string{}
+			executeNames = []string{}
+
+			output := captureStdout(t, func() {
+				rootCmd.SetArgs(tt.args)
+				if err := rootCmd.Execute(); err != nil {
+					t.Fatalf("%s returned error: %v", tt.name, err)
+				}
+			})
+
+			expected := "# Vars common\nHOST=localhost\n\n# Vars dev\nDEBUG=true\n"
+			if output != expected {
+				t.Errorf("expected %q, got %q", expected, output)
+			}
+		})
+	}
+}
+
+func TestRenderSourceNamesPerContext(t *testing.T) {
+	configSources := []sources.Source{
+		{Type: "ConfigMap", Name: "{{ .Context }}-config", Namespace: "default"},
+		{Type: "Vars", Name: "inline"},
+	}
+
+	rendered, err := renderSourceNames(configSources, newOutputPathData([]string{"tenant-a"}, "kind-kind"))
+	if err != nil {
+		t.Fatalf("renderSourceNames returned error: %v", err)
+	}
+	if rendered[0].Name != "tenant-a-config" {
+		t.Errorf("expected tenant-a-config, got %q", rendered[0].Name)
+	}
+	if rendered[1].Name != "inline" {
+		t.Errorf("expected plain names to be kept, got %q", rendered[1].Name)
+	}
+	if configSources[0].Name != "{{ .Context }}-config" {
+		t.Errorf("expected the configured sources to be left unchanged, got %q", configSources[0].Name)
+	}
+
+	// The rendered name is the object that gets fetched
+	clientset := fake.NewClientset(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "tenant-a-config", Namespace: "default"},
+		Data:       map[string]string{"TENANT": "a"},
+	})
+	entries, err := (&sources.ConfigMapFetcher{}).Fetch(t.Context(), clientset, rendered[0], t.TempDir())
+	if err != nil {
+		t.Fatalf("Fetch returned error: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Key != "TENANT" || entries[0].Value != "a" || entries[0].Name != "tenant-a-config" {
+		t.Errorf("unexpected entries: %+v", entries)
+	}
+
+	if _, err := renderSourceNames([]sources.Source{{Name: "{{ .Tenant }}-config"}}, outputPathData{}); err == nil {
+		t.Error("expected an error for an unknown template field")
+	}
+}
+
+func TestNamespaceOverrideWinsOverConfig(t *testing.T) {
+	namespaceOverride = "staging"
+	defer func() { namespaceOverride = "" }()
+
+	configSources := []sources.Source{
+		{Type: "ConfigMap", Name: "app-config", Namespace: "dev"},
+		{Type: "Secret", Name: "app-secret"},
+	}
+	rendered, err := renderSourceNames(configSources, outputPathData{})
+	if err != nil {
+		t.Fatalf("renderSourceNames returned error: %v", err)
+	}
+
+	clientset := fake.NewClientset(
+		&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "app-config", Namespace: "dev"}, Data: map[string]string{"ENV": "dev"}},
+		&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "app-config", Namespace: "staging"}, Data: map[string]string{"ENV": "staging"}},
+	)
+	entries, err := (&sources.ConfigMapFetcher{}).Fetch(t.Context(), clientset, rendered[0], t.TempDir())
+	if err != nil {
+		t.Fatalf("Fetch returned error: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Value != "staging" || entries[0].Namespace != "staging" {
+		t.Errorf("expected the ConfigMap from the staging namespace, got %+v", entries)
+	}
+	if rendered[1].GetNamespace() != "staging" {
+		t.Errorf("expected sources without a namespace to use the override, got %q", rendered[1].GetNamespace())
+	}
+	if configSources[0].Namespace != "dev" {
+		t.Errorf("expected the configured sources to be left unchanged, got %q", configSources[0].Namespace)
+	}
+}
+
+func TestCheckDependencies(t *testing.T) {
+	testCases := []struct {
+		name       string
+		executions []Execution
+		expected   string
+	}{
+		{
+			name: "graph",
+			executions: []Execution{
+				{Name: "a"},
+				{Name: "b", DependsOn: []string{"a"}},
+				{Name: "c", DependsOn: []string{"a", "b"}},
+				{Name: "d"},
+			},
+		},
+		{
+			name:       "unknown execution",
+			executions: []Execution{{Name: "a", DependsOn: []string{"missing"}}},
+			expected:   `execution "a" depends on unknown execution "missing"`,
+		},
+		{
+			name: "cycle",
+			executions: []Execution{
+				{Name: "a", DependsOn: []string{"b"}},
+				{Name: "b", DependsOn: []string{"c"}},
+				{Name: "c", DependsOn: []string{"b"}},
+			},
+			expected: "executions depend on each other: b -> c -> b",
+		},
+		{
+			name:       "self",
+			executions: []Execution{{Name: "a", DependsOn: []string{"a"}}},
+			expected:   "executions depend on each other: a -> a",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := checkDependencies(tc.executions)
+			if tc.expected == "" {
+				if err != nil {
+					t.Errorf("expected no error, got %v", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tc.expected {
+				t.Errorf("expected error %q, got %v", tc.expected, err)
+			}
+		})
+	}
+}
+
+func TestTopLevelTransformationsApplyToEverySource(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), ".enver.yaml")
+	content := `transformations:
+  - type: base64_decode
+    target: value
+sources:
+  - type: Vars
+    name: encoded
+    vars:
+      - name: TOKEN
+        value: czNjcjN0
+  - type: Vars
+    name: prefixed
+    vars:
+      - name: GREETING
+        value: aGVsbG8=
+    transformations:
+      - type: prefix
+        target: key
+        value: APP_
+  - type: Vars
+    name: plain
+    inheritTransformations: false
+    vars:
+      - name: HOST
+        value: localhost
+`
+	if err := os.WriteFile(configFile, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := readConfig(configFile)
+	if err != nil {
+		t.Fatalf("readConfig returned error: %v", err)
+	}
+	envData, _, err := fetchSources(t.Context(), config.Sources, engine.Selection{OutputDirectory: t.TempDir()}, false)
+	if err != nil {
+		t.Fatalf("fetchSources returned error: %v", err)
+	}
+
+	var entries []string
+	for _, entry := range envData {
+		entries = append(entries, entry.Key+"="+entry.Value)
+	}
+	expected := "TOKEN=s3cr3t,APP_GREETING=hello,HOST=localhost"
+	if got := strings.Join(entries, ","); got != expected {
+		t.Errorf("expected %s, got %s", expected, got)
+	}
+}
+
+func TestReadConfigMergesIncludes(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		".enver.yaml": `includes:
+  - config/base.yaml
+  - config/override.yaml
+contexts:
+  - dev
+executions:
+  - name: local
+`,
+		"config/base.yaml": `contexts:
+  - prod
+sources:
+  - type: Vars
+    name: app
+    vars:
+      - name: HOST
+        value: localhost
+  - type: Vars
+    name: db
+    vars:
+      - name: DB_HOST
+        value: db
+executions:
+  - name: prod
+    contexts: [prod]
+`,
+		"config/override.yaml": `sources:
+  - type: Vars
+    name: app
+    vars:
+      - name: HOST
+        value: example.com
+  - type: Vars
+    name: cache
+    vars:
+      - name: CACHE_HOST
+        value: redis
+executions:
+  - name: prod
+    contexts: [prod, dev]
+`,
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	config, err := loadExecuteConfig(filepath.Join(dir, ".enver.yaml"))
+	if err != nil {
+		t.Fatalf("loadExecuteConfig returned error: %v", err)
+	}
+
+	if strings.Join(config.Contexts, ",") != "dev,prod" {
+		t.Errorf("expected contexts dev,prod, got %v", config.Contexts)
+	}
+
+	// The override replaces app in place and adds cache
+	var sourceValues []string
+	for _, source := range config.Sources {
+		sourceValues = append(sourceValues, source.Name+"="+source.Vars[0].Value)
+	}
+	if expected := "app=example.com,db=db,cache=redis"; strings.Join(sourceValues, ",") != expected {
+		t.Errorf("expected sources %s, got %v", expected, sourceValues)
+	}
+
+	if len(config.Executions) != 2 || config.Executions[0].Name != "local" || config.Executions[1].Name != "prod" {
+		t.Fatalf("expected executions local and prod, got %+v", config.Executions)
+	}
+	if strings.Join(config.Executions[1].Contexts, ",") != "prod,dev" {
+		t.Errorf("expected the overridden prod contexts, got %v", config.Executions[1].Contexts)
+	}
+}
+
+func TestReadConfigRejectsCircularIncludes(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"a.yaml": "includes: [b.yaml]\n",
+		"b.yaml": "includes: [c.yaml]\n",
+		"c.yaml": "includes: [a.yaml]\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, err := readConfig(filepath.Join(dir, "a.yaml"))
+	if err == nil {
+		t.Fatal("expected an error for circular includes")
+	}
+	if !strings.Contains(err.Error(), "circular include: a.yaml -> b.yaml -> c.yaml -> a.yaml") {
+		t.Errorf("expected the cycle in the error, got: %v", err)
+	}
+}
+
+func TestProfileOverridesNamespaceAndOutput(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	config := `sources:
+  - type: ConfigMap
+    name: app-config
+    namespace: default
+executions:
+  - name: app
+    kube-context: local
+    output:
+      directory: generated
+profiles:
+  staging:
+    namespace: staging
+    kube-context: staging-cluster
+    directory: out/staging
+`
+	if err := os.WriteFile(".enver.yaml", []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	profileName = "staging"
+	defer func() { profileName = "" }()
+
+	loaded, err := loadExecuteConfig("")
+	if err != nil {
+		t.Fatalf("loadExecuteConfig returned error: %v", err)
+	}
+	execution := loaded.Executions[0]
+	if execution.KubeContext != "staging-cluster" {
+		t.Errorf("expected the profile's kube-context, got %q", execution.KubeContext)
+	}
+	outputDirectory, outputName, err := executionOutput(execution, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path := filepath.Join(outputDirectory, outputName); path != filepath.Join("out", "staging", ".env") {
+		t.Errorf("expected the profile's output path, got %q", path)
+	}
+
+	clientset := fake.NewClientset(
+		&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "app-config", Namespace: "default"}, Data: map[string]string{"ENV": "default"}},
+		&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "app-config", Namespace: "staging"}, Data: map[string]string{"ENV": "staging"}},
+	)
+	rendered, err := renderSourceNames(loaded.Sources, outputPathData{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries, err := (&sources.ConfigMapFetcher{}).Fetch(t.Context(), clientset, rendered[0], t.TempDir())
+	if err != nil {
+		t.Fatalf("Fetch returned error: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Value != "staging" {
+		t.Errorf("expected the ConfigMap from the profile's namespace, got %+v", entries)
+	}
+
+	// --namespace wins over the profile
+	namespaceOverride = "prod"
+	defer func() { namespaceOverride = "" }()
+	if rendered, _ := renderSourceNames(loaded.Sources, outputPathData{}); rendered[0].Namespace != "prod" {
+		t.Errorf("expected --namespace to win over the profile, got %q", rendered[0].Namespace)
+	}
+
+	profileName = "unknown"
+	if _, err := loadExecuteConfig(""); err == nil || !strings.Contains(err.Error(), "available profiles: staging") {
+		t.Errorf("expected an error listing the profiles, got %v", err)
+	}
+}
+
+func TestExpandConfigEnv(t *testing.T) {
+	t.Setenv("NAMESPACE", "staging")
+	t.Setenv("EMPTY", "")
+	os.Unsetenv("ENVER_TEST_UNSET")
+
+	testCases := []struct {
+		content  string
+		expected string
+	}{
+		{"namespace: ${NAMESPACE}", "namespace: staging"},
+		{"namespace: ${ENVER_TEST_UNSET}", "namespace: "},
+		{"namespace: ${ENVER_TEST_UNSET:-default}", "namespace: default"},
+		{"namespace: ${EMPTY:-default}", "namespace: default"},
+		{"namespace: ${NAMESPACE:-default}", "namespace: staging"},
+		{"value: $$NAMESPACE and $${NAMESPACE}", "value: $NAMESPACE and ${NAMESPACE}"},
+		{"value: pa$word $NAMESPACE ^[0-9]+$", "value: pa$word $NAMESPACE ^[0-9]+$"},
+		{"value: ${unterminated", "value: ${unterminated"},
+	}
+	for _, tc := range testCases {
+		if got := expandConfigEnv(tc.content); got != tc.expected {
+			t.Errorf("expandConfigEnv(%q): expected %q, got %q", tc.content, tc.expected, got)
+		}
+	}
+}
+
+func TestReadConfigExpandsEnvironmentVariables(t *testing.T) {
+	t.Setenv("NAMESPACE", "staging")
+	os.Unsetenv("ENVER_TEST_KUBE_CONTEXT")
+
+	configFile := filepath.Join(t.TempDir(), ".enver.yaml")
+	content := `sources:
+  - type: ConfigMap
+    name: app-config
+    namespace: ${NAMESPACE}
+executions:
+  - name: app
+    kube-context: ${ENVER_TEST_KUBE_CONTEXT:-kind-kind}
+`
+	if err := os.WriteFile(configFile, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := readConfig(configFile)
+	if err != nil {
+		t.Fatalf("readConfig returned error: %v", err)
+	}
+	if config.Sources[0].Namespace != "staging" {
+		t.Errorf("expected namespace staging, got %q", config.Sources[0].Namespace)
+	}
+	if config.Executions[0].KubeContext != "kind-kind" {
+		t.Errorf("expected the default kube-context, got %q", config.Executions[0].KubeContext)
+	}
+}
+
+func TestReadConfigExpandsEnvironmentVariablesAfterParsing(t *testing.T) {
+	t.Setenv("INJECTED", "x\n  - type: Secret\n    name: stolen # not a comment")
+	t.Setenv("OPTIONAL", "true")
+
+	configFile := filepath.Join(t.TempDir(), ".enver.yaml")
+	content := `# ${INJECTED} in a comment is not expanded
+sources:
+  - type: ConfigMap
+    name: ${INJECTED}
+    optional: ${OPTIONAL}
+  - type: Vars
+    name: inline
+    vars:
+      - name: QUOTED
+        value: "${OPTIONAL}"
+executions:
+  - name: app
+`
+	if err := os.WriteFile(configFile, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := readConfig(configFile)
+	if err != nil {
+		t.Fatalf("readConfig returned error: %v", err)
+	}
+	if len(config.Sources) != 2 {
+		t.Fatalf("expected the substituted value not to add sources, got %+v", config.Sources)
+	}
+	if config.Sources[0].Name != os.Getenv("INJECTED") {
+		t.Errorf("expected the value to be substituted as-is, got %q", config.Sources[0].Name)
+	}
+	if !config.Sources[0].Optional {
+		t.Error("expected a plain reference to fill in a bool")
+	}
+	if config.Sources[1].Vars[0].Value != "true" {
+		t.Errorf("expected the quoted reference to stay a string, got %q", config.Sources[1].Vars[0].Value)
+	}
+}
@@ -0,0 +1,216 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"enver/cryptutil"
+	"enver/output"
+	"enver/transformations"
+
+	"github.com/spf13/cobra"
+)
+
+var diffNames []string
+var diffAll bool
+var diffInputFile string
+var diffPerContextDir bool
+var diffOnConflict string
+
+var diffCmd = &cobra.Command{
+	Use:   "diff",
+	Short: "Show what executions would change in their generated files",
+	Long:  `Reads the .enver.yaml file, collects the environment variables of the selected executions and compares them with the existing output files without writing them. Prints the added, removed and changed keys and exits with a non-zero code when there are differences.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := validateConflictStrategy(diffOnConflict); err != nil {
+			return err
+		}
+
+		config, err := loadExecuteConfig(diffInputFile)
+		if err != nil {
+			return err
+		}
+
+		selectedExecutions, err := selectExecutions(config, diffNames, diffAll)
+		if err != nil {
+			return err
+		}
+
+		// Nothing is written while fetching, file transformations return the path they would write
+		transformations.SetDryRun(true)
+		defer transformations.SetDryRun(false)
+
+		// Use default loading rules (respects KUBECONFIG env var)
+		clients := newKubeClientCache(kubeconfigLoadingRules())
+
+		// Cancelled on Ctrl+C or when --timeout expires
+		ctx := cmd.Context()
+
+		differences := 0
+		for _, execution := range selectedExecutions {
+			outputDirectory, outputName, err := executionOutput(execution, diffPerContextDir)
+			if err != nil {
+				return fmt.Errorf("%s: %w", execution.Name, err)
+			}
+			if outputDirectory == stdoutOutput {
+				fmt.Printf("[%s] writes to stdout, there is no file to compare\n", execution.Name)
+				continue
+			}
+			if execution.Output.PerSource {
+				fmt.Printf("[%s] writes one file per source, there is no combined file to compare\n", execution.Name)
+				continue
+			}
+			if format := output.ResolveFormat(execution.Output.Format, outputName); format != output.FormatEnv {
+				fmt.Printf("[%s] writes %s, only env files can be compared\n", execution.Name, format)
+				continue
+			}
+
+			envData, _, err := collectExecution(ctx, execution, config.Sources, clients, outputDirectory, false, false)
+			if err != nil {
+				return fmt.Errorf("%s: %w", execution.Name, err)
+			}
+			// Deduplicated like execute does, otherwise a file written with another strategy always differs
+			if envData, _, err = resolveConflicts(envData, diffOnConflict); err != nil {
+				return fmt.Errorf("%s: %w", execution.Name, err)
+			}
+
+			outputPath := filepath.Join(outputDirectory, outputName)
+			rendered, _ := output.Render(envData, output.FormatEnv, output.RenderOptions{Export: execution.Output.Export})
+
+			existing, err := os.ReadFile(outputPath)
+			if err != nil && !errors.Is(err, os.ErrNotExist) {
+				return fmt.Errorf("failed to read %s: %w", outputPath, err)
+			}
+			if err == nil && execution.Output.Encrypt && cryptutil.IsEncrypted(existing) {
+				passphrase, err := readPassphrase()
+				if err != nil {
+					return err
+				}
+				if existing, err = cryptutil.Decrypt(existing, passphrase); err != nil {
+					return fmt.Errorf("failed to decrypt %s: %w", outputPath, err)
+				}
+			}
+			if string(existing) == rendered {
+				fmt.Printf("[%s] %s is up to date\n", execution.Name, outputPath)
+				continue
+			}
+			differences++
+
+			if errors.Is(err, os.ErrNotExist) {
+				fmt.Printf("[%s] %s does not exist, %d variables would be written\n", execution.Name, outputPath, len(envData))
+				continue
+			}
+
+			diff := diffEnv(parseEnv(string(existing)), parseEnv(rendered))
+			fmt.Printf("[%s] %s would change:\n", execution.Name, outputPath)
+			for _, line := range diff.lines() {
+				fmt.Printf("  %s\n", line)
+			}
+		}
+
+		if differences > 0 {
+			// The differences are the report, there is no error to print
+			cmd.SilenceErrors = true
+			cmd.SilenceUsage = true
+			return &exitCodeError{code: 1}
+		}
+		return nil
+	},
+}
+
+// envDiff holds the keys that differ between two .env files
+type envDiff struct {
+	Added   []string
+	Removed []string
+	Changed []string
+}
+
+// lines formats the diff as one "+ KEY", "- KEY" or "~ KEY" line per key
+// Values are not printed as they are often secrets
+func (d envDiff) lines() []string {
+	var lines []string
+	for _, key := range d.Added {
+		lines = append(lines, "+ "+key)
+	}
+	for _, key := range d.Removed {
+		lines = append(lines, "- "+key)
+	}
+	for _, key := range d.Changed {
+		lines = append(lines, "~ "+key)
+	}
+	if len(lines) == 0 {
+		lines = append(lines, "(only ordering, comments or formatting differ)")
+	}
+	return lines
+}
+
+// diffEnv compares the variables of an existing and a new .env file
+func diffEnv(existing, updated map[string]string) envDiff {
+	var diff envDiff
+	for key, value := range updated {
+		oldValue, ok := existing[key]
+		switch {
+		case !ok:
+			diff.Added = append(diff.Added, key)
+		case oldValue != value:
+			diff.Changed = append(diff.Changed, key)
+		}
+	}
+	for key := range existing {
+		if _, ok := updated[key]; !ok {
+			diff.Removed = append(diff.Removed, key)
+		}
+	}
+	sort.Strings(diff.Added)
+	sort.Strings(diff.Removed)
+	sort.Strings(diff.Changed)
+	return diff
+}
+
+// envValueUnescaper reverses envValueEscaper
+var envValueUnescaper = strings.NewReplacer(
+	`\\`, `\`,
+	`\"`, `"`,
+	`\$`, "$",
+	"\\`", "`",
+	`\n`, "\n",
+	`\r`, "\r",
+)
+
+// parseEnv parses .env content as written by output.Render into a map of variables
+// Comments and blank lines are ignored, and the last occurrence of a key wins
+func parseEnv(content string) map[string]string {
+	vars := make(map[string]string)
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		if len(value) >= 2 && strings.HasPrefix(value, `"`) && strings.HasSuffix(value, `"`) {
+			value = envValueUnescaper.Replace(value[1 : len(value)-1])
+		}
+		vars[strings.TrimSpace(key)] = value
+	}
+	return vars
+}
+
+func init() {
+	addDeprecatedInputFlag(diffCmd, &diffInputFile)
+	diffCmd.Flags().StringArrayVar(&diffNames, "name", []string{}, "execution name to diff (can be repeated)")
+	diffCmd.RegisterFlagCompletionFunc("name", completeExecutionNames(&diffInputFile))
+	diffCmd.Flags().BoolVar(&diffAll, "all", false, "diff all executions")
+	diffCmd.Flags().StringVar(&diffOnConflict, "on-conflict", conflictKeepAll, "how to handle keys emitted by more than one source: keep-all, last-wins, first-wins or error")
+	diffCmd.Flags().BoolVar(&diffPerContextDir, "per-context-dir", false, "compare with output directories nested under the execution's context name")
+	diffCmd.Flags().StringVar(&passphraseFile, "passphrase-file", "", "file with the passphrase to decrypt the files of executions with output.encrypt (default $"+passphraseEnv+")")
+	rootCmd.AddCommand(diffCmd)
+}
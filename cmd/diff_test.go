@@ -0,0 +1,150 @@
+package cmd
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+
+	"enver/output"
+	"enver/sources"
+)
+
+func TestParseEnvReadsRenderedOutput(t *testing.T) {
+	entries := []sources.EnvEntry{
+		{Key: "HOST", Value: "localhost", SourceType: "ConfigMap", Name: "app", Namespace: "default"},
+		{Key: "CERT", Value: "line1\nline2 \"quoted\" \\", SourceType: "Secret", Name: "app", Namespace: "default"},
+		{Key: "EMPTY", Value: "", SourceType: "Vars", Name: "inline"},
+		{Key: "SHELL", Value: "$HOME `id`", SourceType: "Vars", Name: "inline"},
+	}
+
+	for _, export := range []bool{false, true} {
+		rendered, _ := output.Render(entries, output.FormatEnv, output.RenderOptions{Export: export})
+		parsed := parseEnv(rendered)
+		expected := map[string]string{
+			"HOST":  "localhost",
+			"CERT":  "line1\nline2 \"quoted\" \\",
+			"EMPTY": "",
+			"SHELL": "$HOME `id`",
+		}
+		if !reflect.DeepEqual(parsed, expected) {
+			t.Errorf("export=%v: expected %q, got %q", export, expected, parsed)
+		}
+	}
+}
+
+func TestDiffEnv(t *testing.T) {
+	existing := map[string]string{"HOST": "localhost", "PORT": "8080", "OLD": "x"}
+	updated := map[string]string{"HOST": "remote", "PORT": "8080", "NEW": "y"}
+
+	diff := diffEnv(existing, updated)
+	expected := envDiff{
+		Added:   []string{"NEW"},
+		Removed: []string{"OLD"},
+		Changed: []string{"HOST"},
+	}
+	if !reflect.DeepEqual(diff, expected) {
+		t.Errorf("expected %+v, got %+v", expected, diff)
+	}
+
+	expectedLines := []string{"+ NEW", "- OLD", "~ HOST"}
+	if lines := diff.lines(); !reflect.DeepEqual(lines, expectedLines) {
+		t.Errorf("expected %v, got %v", expectedLines, lines)
+	}
+}
+
+func TestDiffWritesNoFiles(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	config := `sources:
+  - type: Vars
+    name: inline
+    vars:
+      - name: CERTIFICATE
+        value: "-----BEGIN CERTIFICATE-----"
+    transformations:
+      - type: file
+        output: cert.pem
+        key: CERTIFICATE_FILE
+executions:
+  - name: local
+`
+	if err := os.WriteFile(".enver.yaml", []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { diffAll = false }()
+
+	rootCmd.SetArgs([]string{"diff", "--all"})
+	output := captureStdout(t, func() {
+		var exitErr *exitCodeError
+		if err := rootCmd.Execute(); !errors.As(err, &exitErr) {
+			t.Errorf("expected diff to report a difference, got %v", err)
+		}
+	})
+
+	if expected := "[local] generated/.env does not exist, 1 variables would be written\n"; output != expected {
+		t.Errorf("expected %q, got %q", expected, output)
+	}
+	entries, err := os.ReadDir(".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("expected only .enver.yaml, got %v", entries)
+	}
+}
+
+func TestDiffUsesConflictStrategy(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	config := `sources:
+  - type: Vars
+    name: app
+    vars:
+      - name: PORT
+        value: "8080"
+  - type: Vars
+    name: override
+    vars:
+      - name: PORT
+        value: "9090"
+executions:
+  - name: local
+`
+	if err := os.WriteFile(".enver.yaml", []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		executeAll = false
+		executeOnConflict = conflictKeepAll
+		diffAll = false
+		diffOnConflict = conflictKeepAll
+	}()
+
+	rootCmd.SetArgs([]string{"execute", "--all", "--on-conflict", "last-wins"})
+	captureStdout(t, func() {
+		if err := rootCmd.Execute(); err != nil {
+			t.Fatalf("execute returned error: %v", err)
+		}
+	})
+
+	rootCmd.SetArgs([]string{"diff", "--all", "--on-conflict", "last-wins"})
+	output := captureStdout(t, func() {
+		if err := rootCmd.Execute(); err != nil {
+			t.Errorf("expected no differences with the strategy the file was written with, got %v", err)
+		}
+	})
+	if expected := "[local] generated/.env is up to date\n"; output != expected {
+		t.Errorf("expected %q, got %q", expected, output)
+	}
+
+	// With the default keep-all both occurrences would be written
+	diffOnConflict = conflictKeepAll
+	rootCmd.SetArgs([]string{"diff", "--all"})
+	captureStdout(t, func() {
+		var exitErr *exitCodeError
+		if err := rootCmd.Execute(); !errors.As(err, &exitErr) {
+			t.Errorf("expected diff to report a difference with keep-all, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,165 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// doctorTimeout limits how long the reachability check waits for the API server
+const doctorTimeout = 5 * time.Second
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Check the environment enver runs in",
+	Long:  `Checks that the configuration file can be read, git is available for the .gitignore protection, the kubeconfig can be loaded, the current context's API server is reachable and the kube-context of every execution exists. Prints a checklist with hints for the failed checks. Nothing is modified.`,
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if failed := runDoctor(os.Stdout); failed > 0 {
+			cmd.SilenceUsage = true
+			return fmt.Errorf("%d check(s) failed", failed)
+		}
+		return nil
+	},
+}
+
+// doctorCheck is the result of a single check
+type doctorCheck struct {
+	name string
+	err  error
+	hint string // what to do when the check failed
+}
+
+// runDoctor runs all checks, prints them to w and returns the number of failed checks
+func runDoctor(w io.Writer) int {
+	var checks []doctorCheck
+	config, configCheck := checkConfigFile()
+	checks = append(checks, configCheck, checkGit())
+	checks = append(checks, checkKubernetes(config)...)
+
+	failed := 0
+	for _, check := range checks {
+		if check.err == nil {
+			fmt.Fprintf(w, "[ok]   %s\n", check.name)
+			continue
+		}
+		failed++
+		fmt.Fprintf(w, "[fail] %s: %v\n", check.name, check.err)
+		if check.hint != "" {
+			fmt.Fprintf(w, "       %s\n", check.hint)
+		}
+	}
+	return failed
+}
+
+// checkConfigFile reads and validates the configuration file, returning it when it could be parsed
+func checkConfigFile() (*ExecuteConfig, doctorCheck) {
+	configFile := configFilePath("")
+	check := doctorCheck{name: "configuration file " + configFile}
+
+	config, err := readConfig(configFile)
+	if err != nil {
+		check.err = err
+		check.hint = "run enver init to create one, or point --config at it"
+		return nil, check
+	}
+	if problems := validateConfig(config); len(problems) > 0 {
+		check.err = fmt.Errorf("%d problem(s)", len(problems))
+		check.hint = "run enver validate to list them"
+	}
+	return config, check
+}
+
+// checkGit checks that git is installed, which the .gitignore protection of written files uses
+func checkGit() doctorCheck {
+	check := doctorCheck{name: "git"}
+	if _, err := exec.LookPath("git"); err != nil {
+		check.err = fmt.Errorf("not found in PATH")
+		check.hint = "install git so written files can be added to .gitignore, or pass --gitignore skip"
+	}
+	return check
+}
+
+// checkKubernetes loads the kubeconfig, checks that the current context's API server responds and
+// that the kube-context of every execution exists. Later checks are left out when the kubeconfig
+// can't be loaded.
+func checkKubernetes(config *ExecuteConfig) []doctorCheck {
+	if inCluster {
+		check := doctorCheck{name: "in-cluster API server reachable"}
+		check.err = checkServerVersion("")
+		return []doctorCheck{check}
+	}
+
+	loadingRules := kubeconfigLoadingRules()
+	kubeconfigCheck := doctorCheck{name: "kubeconfig"}
+	kubeConfig, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, &clientcmd.ConfigOverrides{}).RawConfig()
+	if err == nil && len(kubeConfig.Contexts) == 0 {
+		err = fmt.Errorf("no contexts defined")
+	}
+	if err != nil {
+		kubeconfigCheck.err = err
+		kubeconfigCheck.hint = "set KUBECONFIG or pass --kubeconfig; only needed for ConfigMap, Secret, workload and Container sources"
+		return []doctorCheck{kubeconfigCheck}
+	}
+	checks := []doctorCheck{kubeconfigCheck}
+
+	if kubeConfig.CurrentContext != "" {
+		check := doctorCheck{name: fmt.Sprintf("current context %s reachable", kubeConfig.CurrentContext)}
+		if check.err = checkServerVersion(""); check.err != nil {
+			check.hint = "check that the cluster is running and your credentials are not expired, e.g. with kubectl version"
+		}
+		checks = append(checks, check)
+	}
+
+	if config == nil {
+		return checks
+	}
+	var contextNames []string
+	for name := range kubeConfig.Contexts {
+		contextNames = append(contextNames, name)
+	}
+	slices.Sort(contextNames)
+	for _, execution := range config.Executions {
+		if execution.KubeContext == "" {
+			continue
+		}
+		check := doctorCheck{name: fmt.Sprintf("execution %s: kube context %s", execution.Name, execution.KubeContext)}
+		if _, ok := kubeConfig.Contexts[execution.KubeContext]; !ok {
+			check.err = fmt.Errorf("not found in the kubeconfig")
+			check.hint = "available contexts: " + strings.Join(contextNames, ", ")
+		}
+		checks = append(checks, check)
+	}
+	return checks
+}
+
+// checkServerVersion requests the version of the API server of the kube context, a request every
+// authenticated user may make
+func checkServerVersion(kubeContext string) error {
+	restConfig, err := loadRESTConfig(kubeconfigLoadingRules(), kubeContext)
+	if err != nil {
+		return err
+	}
+	restConfig.Timeout = doctorTimeout
+
+	clientset, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return fmt.Errorf("failed to create kubernetes client: %w", err)
+	}
+	if _, err := clientset.Discovery().ServerVersion(); err != nil {
+		return fmt.Errorf("failed to reach the API server: %w", err)
+	}
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(doctorCmd)
+}
@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestDoctorReportsChecks(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/version" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"major":"1","minor":"35","gitVersion":"v1.35.0"}`))
+	}))
+	defer server.Close()
+
+	kubeconfig := `apiVersion: v1
+kind: Config
+clusters:
+  - name: fake
+    cluster:
+      server: ` + server.URL + `
+contexts:
+  - name: fake
+    context:
+      cluster: fake
+      user: fake
+current-context: fake
+users:
+  - name: fake
+    user:
+      token: fake
+`
+	if err := os.WriteFile("kubeconfig", []byte(kubeconfig), 0600); err != nil {
+		t.Fatal(err)
+	}
+	config := `sources:
+  - type: ConfigMap
+    name: settings
+executions:
+  - name: dev
+    kube-context: fake
+  - name: prod
+    kube-context: production
+`
+	if err := os.WriteFile(".enver.yaml", []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	kubeconfigPath = "kubeconfig"
+	defer func() { kubeconfigPath = "" }()
+
+	var out bytes.Buffer
+	failed := runDoctor(&out)
+
+	for _, expected := range []string{
+		"[ok]   configuration file .enver.yaml\n",
+		"[ok]   kubeconfig\n",
+		"[ok]   current context fake reachable\n",
+		"[ok]   execution dev: kube context fake\n",
+		"[fail] execution prod: kube context production: not found in the kubeconfig\n       available contexts: fake\n",
+	} {
+		if !strings.Contains(out.String(), expected) {
+			t.Errorf("expected output to contain %q, got:\n%s", expected, out.String())
+		}
+	}
+	if strings.Contains(out.String(), "[fail] git") {
+		failed--
+	}
+	if failed != 1 {
+		t.Errorf("expected 1 failed check, got %d:\n%s", failed, out.String())
+	}
+}
@@ -0,0 +1,122 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"enver/cryptutil"
+	"enver/gitutil"
+	"enver/output"
+
+	"github.com/spf13/cobra"
+)
+
+// passphraseEnv is the environment variable the passphrase is read from without --passphrase-file
+const passphraseEnv = "ENVER_PASSPHRASE"
+
+var passphraseFile string
+var encryptOutputFile string
+var decryptOutputFile string
+
+var encryptCmd = &cobra.Command{
+	Use:   "encrypt [-o <output>] <file>",
+	Short: "Encrypt an env file so it can be committed",
+	Long:  `Encrypts a file with AES-256-GCM using a key derived from a passphrase, read from --passphrase-file or the ENVER_PASSPHRASE environment variable. The encrypted file is text, so it can be committed; decrypt it with enver decrypt.`,
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		passphrase, err := readPassphrase()
+		if err != nil {
+			return err
+		}
+		content, err := os.ReadFile(args[0])
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", args[0], err)
+		}
+		if cryptutil.IsEncrypted(content) {
+			return fmt.Errorf("%s is already encrypted", args[0])
+		}
+
+		encrypted, err := cryptutil.Encrypt(content, passphrase)
+		if err != nil {
+			return fmt.Errorf("failed to encrypt %s: %w", args[0], err)
+		}
+		outputPath := encryptOutputFile
+		if outputPath == "" {
+			outputPath = args[0] + ".enc"
+		}
+		if err := os.MkdirAll(filepath.Dir(outputPath), 0755); err != nil {
+			return fmt.Errorf("failed to create output directory: %w", err)
+		}
+		if err := output.WriteFile(outputPath, encrypted, 0644, failIfExists); err != nil {
+			return fmt.Errorf("failed to write output file: %w", err)
+		}
+		infof("Encrypted %s to %s\n", args[0], outputPath)
+		return nil
+	},
+}
+
+var decryptCmd = &cobra.Command{
+	Use:   "decrypt [-o <output>] <file>",
+	Short: "Decrypt a file written by enver encrypt",
+	Long:  `Decrypts a file written by enver encrypt or by an execution with output.encrypt, using the passphrase from --passphrase-file or the ENVER_PASSPHRASE environment variable. Without --output the content is printed to stdout. A written file is only readable by the owner and added to .gitignore.`,
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		passphrase, err := readPassphrase()
+		if err != nil {
+			return err
+		}
+		content, err := os.ReadFile(args[0])
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", args[0], err)
+		}
+
+		decrypted, err := cryptutil.Decrypt(content, passphrase)
+		if err != nil {
+			return fmt.Errorf("failed to decrypt %s: %w", args[0], err)
+		}
+		if decryptOutputFile == "" {
+			_, err := cmd.OutOrStdout().Write(decrypted)
+			return err
+		}
+
+		if err := os.MkdirAll(filepath.Dir(decryptOutputFile), 0755); err != nil {
+			return fmt.Errorf("failed to create output directory: %w", err)
+		}
+		if err := output.WriteFile(decryptOutputFile, decrypted, 0600, failIfExists); err != nil {
+			return fmt.Errorf("failed to write output file: %w", err)
+		}
+		infof("Decrypted %s to %s\n", args[0], decryptOutputFile)
+		return gitutil.EnsureGitignored(decryptOutputFile)
+	},
+}
+
+// readPassphrase returns the passphrase from --passphrase-file, or from ENVER_PASSPHRASE. It is not
+// accepted as a flag value, which would show up in the process list and shell history.
+func readPassphrase() (string, error) {
+	if passphraseFile != "" {
+		content, err := os.ReadFile(passphraseFile)
+		if err != nil {
+			return "", fmt.Errorf("failed to read passphrase file: %w", err)
+		}
+		passphrase := strings.TrimRight(string(content), "\r\n")
+		if passphrase == "" {
+			return "", fmt.Errorf("passphrase file %s is empty", passphraseFile)
+		}
+		return passphrase, nil
+	}
+	if passphrase := os.Getenv(passphraseEnv); passphrase != "" {
+		return passphrase, nil
+	}
+	return "", fmt.Errorf("no passphrase: set %s or use --passphrase-file", passphraseEnv)
+}
+
+func init() {
+	encryptCmd.Flags().StringVarP(&encryptOutputFile, "output", "o", "", "encrypted file to write (default <file>.enc)")
+	encryptCmd.Flags().StringVar(&passphraseFile, "passphrase-file", "", "file with the passphrase (default $"+passphraseEnv+")")
+	decryptCmd.Flags().StringVarP(&decryptOutputFile, "output", "o", "", "decrypted file to write (default stdout)")
+	decryptCmd.Flags().StringVar(&passphraseFile, "passphrase-file", "", "file with the passphrase (default $"+passphraseEnv+")")
+	rootCmd.AddCommand(encryptCmd)
+	rootCmd.AddCommand(decryptCmd)
+}
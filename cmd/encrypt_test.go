@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"enver/cryptutil"
+)
+
+func TestExecuteEncryptedOutputRoundTrip(t *testing.T) {
+	t.Chdir(t.TempDir())
+	t.Setenv(passphraseEnv, "correct horse")
+
+	config := `sources:
+  - type: Vars
+    name: inline
+    vars:
+      - name: DB_PASSWORD
+        value: s3cr3t
+executions:
+  - name: local
+    output:
+      name: app.env.enc
+      encrypt: true
+`
+	if err := os.WriteFile(".enver.yaml", []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { executeAll = false; decryptOutputFile = "" }()
+
+	rootCmd.SetArgs([]string{"execute", "--all"})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("execute returned error: %v", err)
+	}
+
+	encryptedPath := filepath.Join("generated", "app.env.enc")
+	encrypted, err := os.ReadFile(encryptedPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cryptutil.IsEncrypted(encrypted) || bytes.Contains(encrypted, []byte("s3cr3t")) {
+		t.Fatalf("expected an encrypted file, got %q", encrypted)
+	}
+
+	rootCmd.SetArgs([]string{"decrypt", "-o", "app.env", encryptedPath})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("decrypt returned error: %v", err)
+	}
+	decrypted, err := os.ReadFile("app.env")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "# Vars inline\nDB_PASSWORD=s3cr3t\n"; string(decrypted) != expected {
+		t.Errorf("expected %q, got %q", expected, string(decrypted))
+	}
+
+	// A wrong passphrase fails instead of writing garbage
+	t.Setenv(passphraseEnv, "battery staple")
+	rootCmd.SetArgs([]string{"decrypt", "-o", "wrong.env", encryptedPath})
+	if err := rootCmd.Execute(); err == nil || !strings.Contains(err.Error(), "wrong passphrase") {
+		t.Errorf("expected a wrong passphrase error, got %v", err)
+	}
+	if _, err := os.Stat("wrong.env"); !os.IsNotExist(err) {
+		t.Errorf("expected no file for a wrong passphrase, got %v", err)
+	}
+}
+
+func TestEncryptCommandRoundTrip(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	if err := os.WriteFile("passphrase", []byte("correct horse\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile("app.env", []byte("HOST=localhost\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { passphraseFile = ""; encryptOutputFile = "" }()
+
+	rootCmd.SetArgs([]string{"encrypt", "--passphrase-file", "passphrase", "-o", "app.env.enc", "app.env"})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("encrypt returned error: %v", err)
+	}
+
+	var out bytes.Buffer
+	rootCmd.SetOut(&out)
+	defer rootCmd.SetOut(nil)
+	rootCmd.SetArgs([]string{"decrypt", "--passphrase-file", "passphrase", "app.env.enc"})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("decrypt returned error: %v", err)
+	}
+	if out.String() != "HOST=localhost\n" {
+		t.Errorf("expected the original content, got %q", out.String())
+	}
+}
@@ -1,26 +1,35 @@
 package cmd
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"log/slog"
 	"os"
 	"path/filepath"
+	"runtime"
 	"strings"
 	"sync"
+	"text/tabwriter"
 
 	"enver/gitutil"
+	"enver/output"
 	"enver/sources"
+	"enver/transformations"
 
 	"github.com/AlecAivazis/survey/v2"
 	"github.com/spf13/cobra"
-	"gopkg.in/yaml.v3"
-	"k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/rest"
-	"k8s.io/client-go/tools/clientcmd"
 )
 
 type ExecutionOutput struct {
 	Name      string `yaml:"name"`
 	Directory string `yaml:"directory"`
+	Export    bool   `yaml:"export"`
+	Mode      string `yaml:"mode"`      // octal permissions of the written files, e.g. "0600"
+	Format    string `yaml:"format"`    // env (default) or tfvars
+	PerSource bool   `yaml:"perSource"` // write one file per source instead of the combined file
+	Encrypt   bool   `yaml:"encrypt"`   // encrypt the file with the passphrase, so it can be committed
 }
 
 type Execution struct {
@@ -28,135 +37,227 @@ type Execution struct {
 	Output      ExecutionOutput `yaml:"output"`
 	Contexts    []string        `yaml:"contexts"`
 	KubeContext string          `yaml:"kube-context"`
+	DependsOn   []string        `yaml:"dependsOn"` // executions that must finish before this one starts
 }
 
 type ExecuteConfig struct {
+	// Includes are configuration files merged into this one, relative to it
+	Includes   []string         `yaml:"includes"`
 	Contexts   []string         `yaml:"contexts"`
 	Sources    []sources.Source `yaml:"sources"`
 	Executions []Execution      `yaml:"executions"`
+	// Transformations are applied to every source before its own, unless it sets inheritTransformations: false
+	Transformations []sources.TransformationConfig `yaml:"transformations"`
+	// Validations are checked against the resolved values of every execution, keyed by variable name
+	Validations map[string]ValueValidation `yaml:"validations"`
+	// Profiles override parts of the configuration per environment, selected with --profile
+	Profiles map[string]Profile `yaml:"profiles"`
+}
+
+// Profile overrides the configuration for one environment. Empty fields leave the configuration as is.
+type Profile struct {
+	Namespace   string `yaml:"namespace"`    // namespace of all sources
+	KubeContext string `yaml:"kube-context"` // kube-context of all executions
+	Directory   string `yaml:"directory"`    // output directory of all executions that don't write to stdout
 }
 
 type executionResult struct {
-	name string
-	err  error
+	name    string
+	script  string
+	summary executionSummary
+	err     error
 }
 
-type kubeClientEntry struct {
-	clientset  *kubernetes.Clientset
-	restConfig *rest.Config
+// executionSummary is what runExecution did, reported in the table printed after all executions
+type executionSummary struct {
+	changed   bool     // an output file was written
+	variables int      // variables in the output
+	sources   int      // sources that were fetched
+	files     []string // paths of the written files
+	warnings  []string
 }
 
+// exitCodeChanged is returned by execute --only-diff-write when at least one output file was written
+const exitCodeChanged = 2
+
 var executeNames []string
 var executeAll bool
 var executeInputFile string
+var executeRBACCheck bool
+var executeExport bool
+var executeExplode bool
+var executeOnConflict string
+var executePerContextDir bool
+var executeExportScript bool
+var executeOnlyDiffWrite bool
+var executeWriteLock string
+var executeVerifyLock string
+var executeOutputMode string
+var executeDryRun bool
+var executeWatch bool
+var executeContinueOnError bool
+var executeMask bool
+var executeMaskPattern string
+var executeFormat string
+var executeKubeContexts []string
+var executeParallelism int
+
+// executeMasker masks values written to stdout, set from --mask and --mask-pattern
+var executeMasker *masker
+
+var executeLogFormat string
+
+// executeLog receives the progress messages of execute; they go to stderr when stdout carries output
+var executeLog = &eventLog{out: os.Stdout}
 
 var executeCmd = &cobra.Command{
 	Use:   "execute",
 	Short: "Execute predefined .env generation tasks",
 	Long:  `Reads the .enver.yaml file and executes all predefined generation tasks defined in the executions field.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		configFile := executeInputFile
-		if configFile == "" {
-			configFile = ".enver.yaml"
+		if err := validateConflictStrategy(executeOnConflict); err != nil {
+			return err
 		}
-		content, err := os.ReadFile(configFile)
-		if err != nil {
-			return fmt.Errorf("failed to read %s: %w", configFile, err)
+		if err := output.ValidateFormat(executeFormat); err != nil {
+			return err
 		}
-
-		var config ExecuteConfig
-		if err := yaml.Unmarshal(content, &config); err != nil {
-			return fmt.Errorf("failed to parse %s: %w", configFile, err)
+		if executeOnlyDiffWrite && executeExportScript {
+			return fmt.Errorf("--only-diff-write cannot be combined with --export-script")
 		}
-
-		if len(config.Executions) == 0 {
-			return fmt.Errorf("no executions found in %s", configFile)
+		if executeOutputMode != "" {
+			if _, err := output.ParseMode(executeOutputMode); err != nil {
+				return err
+			}
+		}
+		if executeDryRun && (executeExportScript || executeWriteLock != "") {
+			return fmt.Errorf("--dry-run cannot be combined with --export-script or --write-lock")
+		}
+		if executeContinueOnError && executeExportScript {
+			return fmt.Errorf("--continue-on-error cannot be combined with --export-script")
 		}
+		if executeWatch && (executeExportScript || executeDryRun) {
+			return fmt.Errorf("--watch cannot be combined with --export-script or --dry-run")
+		}
+		if executeWatch && failIfExists {
+			return fmt.Errorf("--watch cannot be combined with --fail-if-exists, the first run creates the files it regenerates")
+		}
+		var err error
+		if executeMasker, err = newMasker(executeMask, executeMaskPattern); err != nil {
+			return err
+		}
+		transformations.SetDryRun(executeDryRun)
+		defer transformations.SetDryRun(false)
 
-		if len(config.Sources) == 0 {
-			return fmt.Errorf("no sources found in %s", configFile)
+		config, err := loadExecuteConfig(executeInputFile)
+		if err != nil {
+			return err
 		}
 
 		// Determine which executions to run
-		var selectedExecutions []Execution
-
-		if executeAll {
-			// Run all executions
-			selectedExecutions = config.Executions
-		} else if len(executeNames) > 0 {
-			// Run specified executions
-			executionMap := make(map[string]Execution)
-			for _, exec := range config.Executions {
-				executionMap[exec.Name] = exec
-			}
+		selectedExecutions, err := selectExecutions(config, executeNames, executeAll)
+		if err != nil {
+			return err
+		}
 
-			for _, name := range executeNames {
-				exec, ok := executionMap[name]
-				if !ok {
-					return fmt.Errorf("execution %q not found in .enver.yaml", name)
-				}
-				selectedExecutions = append(selectedExecutions, exec)
-			}
-		} else {
-			// Prompt user to select executions
-			var executionNames []string
-			for _, exec := range config.Executions {
-				executionNames = append(executionNames, exec.Name)
-			}
+		selectedExecutions, err = overrideKubeContexts(selectedExecutions, config.Executions, executeKubeContexts)
+		if err != nil {
+			return err
+		}
 
-			var selectedNames []string
-			prompt := &survey.MultiSelect{
-				Message: "Select executions to run:",
-				Options: executionNames,
-			}
+		if executeExplode && streamsToStdout(selectedExecutions) {
+			return fmt.Errorf("--explode cannot be combined with executions that write to stdout")
+		}
 
-			err := survey.AskOne(prompt, &selectedNames)
+		// Verify the resolved values against an existing lockfile
+		var locked *lockFile
+		if executeVerifyLock != "" {
+			locked, err = readLockFile(executeVerifyLock)
 			if err != nil {
-				return fmt.Errorf("execution selection failed: %w", err)
-			}
-
-			if len(selectedNames) == 0 {
-				return fmt.Errorf("no executions selected")
-			}
-
-			executionMap := make(map[string]Execution)
-			for _, exec := range config.Executions {
-				executionMap[exec.Name] = exec
-			}
-
-			for _, name := range selectedNames {
-				selectedExecutions = append(selectedExecutions, executionMap[name])
+				return err
 			}
 		}
-
-		// Use default loading rules (respects KUBECONFIG env var)
-		loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+		locks := newLockRecorder(locked)
 
 		// Thread-safe cache for kubernetes clients by context
-		var clientCache sync.Map
-		var clientCacheMu sync.Mutex
+		// Uses default loading rules (respects KUBECONFIG env var)
+		clients := newKubeClientCache(kubeconfigLoadingRules())
+
+		// Cancelled on Ctrl+C or when --timeout expires
+		ctx := cmd.Context()
 
 		// Mutex for synchronized console output
 		var outputMu sync.Mutex
 
+		// The export script and env files streamed to stdout are written to stdout, so status
+		// messages go to stderr
+		var statusOut io.Writer = os.Stdout
+		if executeExportScript || streamsToStdout(selectedExecutions) {
+			statusOut = os.Stderr
+		}
+		if executeLog, err = newEventLog(executeLogFormat, statusOut); err != nil {
+			return err
+		}
+
+		// Watch before the first run, so changes made while it runs are picked up afterwards
+		var watches []executionWatch
+		if executeWatch {
+			watchCtx, stopWatches := context.WithCancel(ctx)
+			defer stopWatches()
+			if watches, err = startWatches(watchCtx, selectedExecutions, config); err != nil {
+				return err
+			}
+		}
+
 		// Channel to collect results
 		results := make(chan executionResult, len(selectedExecutions))
 
 		// WaitGroup to wait for all executions
 		var wg sync.WaitGroup
 
-		// Execute each selected execution concurrently
+		// Bounds the number of executions running at the same time, nil means unlimited
+		var slots chan struct{}
+		if executeParallelism > 0 {
+			slots = make(chan struct{}, executeParallelism)
+		}
+
+		// Files written by all executions are checked against .gitignore at once afterwards
+		flushGitignore := gitutil.Defer()
+
+		// Execute each selected execution concurrently, after the executions it depends on
+		stages := newExecutionStages(selectedExecutions)
 		for _, execution := range selectedExecutions {
 			wg.Add(1)
 			go func(execution Execution) {
 				defer wg.Done()
+				stage := stages[execution.Name]
+				defer close(stage.done)
+
+				if err := stages.wait(execution); err != nil {
+					stage.failed = true
+					results <- executionResult{name: execution.Name, err: err}
+					return
+				}
+
+				// Take a slot after the dependencies finished, so waiting executions don't hold one
+				if slots != nil {
+					slots <- struct{}{}
+					defer func() { <-slots }()
+				}
 
 				outputMu.Lock()
-				fmt.Printf("Executing: %s\n", execution.Name)
+				executeLog.info("execution_started", "Executing: "+execution.Name, "execution", execution.Name)
 				outputMu.Unlock()
 
-				err := runExecution(execution, config.Sources, loadingRules, &clientCache, &clientCacheMu, &outputMu)
-				results <- executionResult{name: execution.Name, err: err}
+				if executeExportScript {
+					script, err := renderExecutionScript(ctx, execution, config, clients, locks, &outputMu)
+					stage.failed = err != nil
+					results <- executionResult{name: execution.Name, script: script, err: err}
+					return
+				}
+
+				summary, err := runExecution(ctx, execution, config, clients, locks, &outputMu)
+				stage.failed = err != nil
+				results <- executionResult{name: execution.Name, summary: summary, err: err}
 			}(execution)
 		}
 
@@ -164,182 +265,587 @@ var executeCmd = &cobra.Command{
 		wg.Wait()
 		close(results)
 
-		// Collect errors
+		// Collect errors and scripts
 		var errors []string
+		scripts := make(map[string]string)
+		summaries := make(map[string]executionSummary)
+		changed := false
 		for result := range results {
 			if result.err != nil {
 				errors = append(errors, fmt.Sprintf("%s: %v", result.name, result.err))
+				executeLog.log(slog.LevelError, "execution_failed", "", "execution", result.name, "error", result.err.Error())
 			}
+			scripts[result.name] = result.script
+			summaries[result.name] = result.summary
+			changed = changed || result.summary.changed
+		}
+		if err := flushGitignore(); err != nil {
+			errors = append(errors, err.Error())
+		}
+
+		// The export script and a dry run write no files, they report on their own
+		if !executeExportScript && !executeDryRun {
+			printExecutionSummary(statusOut, selectedExecutions, summaries)
 		}
 
 		if len(errors) > 0 {
 			return fmt.Errorf("execution errors:\n  %s", strings.Join(errors, "\n  "))
 		}
 
+		if executeWriteLock != "" {
+			if err := updateLockFile(executeWriteLock, locks); err != nil {
+				return err
+			}
+			executeLog.info("lockfile_written", "Wrote lockfile "+executeWriteLock, "path", executeWriteLock)
+		}
+
+		// Keep regenerating until interrupted or --timeout expires
+		if executeWatch {
+			return watchExecutions(ctx, watches, config, &outputMu)
+		}
+
+		// Print the scripts in selection order so the output is stable
+		if executeExportScript {
+			for _, execution := range selectedExecutions {
+				fmt.Print(scripts[execution.Name])
+			}
+		}
+
+		// Signal drift to the caller, the written files were already reported
+		if executeOnlyDiffWrite && changed {
+			cmd.SilenceErrors = true
+			cmd.SilenceUsage = true
+			return &exitCodeError{code: exitCodeChanged}
+		}
+
 		return nil
 	},
 }
 
-func runExecution(execution Execution, configSources []sources.Source, loadingRules *clientcmd.ClientConfigLoadingRules, clientCache *sync.Map, clientCacheMu *sync.Mutex, outputMu *sync.Mutex) error {
-	// Check if this execution needs Kubernetes
-	executionNeedsKubernetes := false
-	for _, source := range configSources {
-		if !source.ShouldInclude(execution.Contexts) {
+// executionStage tracks a running execution so the executions depending on it can wait for it
+type executionStage struct {
+	done   chan struct{} // closed when the execution finished
+	failed bool          // set before done is closed
+}
+
+// executionStages holds the stage of every selected execution by name
+type executionStages map[string]*executionStage
+
+func newExecutionStages(executions []Execution) executionStages {
+	stages := make(executionStages, len(executions))
+	for _, execution := range executions {
+		stages[execution.Name] = &executionStage{done: make(chan struct{})}
+	}
+	return stages
+}
+
+// wait blocks until the dependencies of the execution finished and returns an error if one of them
+// failed. Dependencies that were not selected are not run and not waited for.
+func (s executionStages) wait(execution Execution) error {
+	for _, dependency := range execution.DependsOn {
+		stage, ok := s[dependency]
+		if !ok {
 			continue
 		}
-		if source.Type == "ConfigMap" || source.Type == "Secret" || source.Type == "Deployment" || source.Type == "StatefulSet" || source.Type == "DaemonSet" || source.Type == "Container" {
-			executionNeedsKubernetes = true
-			break
+		<-stage.done
+		if stage.failed {
+			return fmt.Errorf("skipped because execution %q failed", dependency)
+		}
+	}
+	return nil
+}
+
+// streamsToStdout returns true if any of the executions writes its env file to stdout
+func streamsToStdout(executions []Execution) bool {
+	for _, execution := range executions {
+		if execution.Output.Directory == stdoutOutput {
+			return true
 		}
 	}
+	return false
+}
 
-	var clientset *kubernetes.Clientset
-	var restConfig *rest.Config
+// loadExecuteConfig reads and parses the configuration file (default .enver.yaml) and checks
+// that it defines executions and sources
+func loadExecuteConfig(configFile string) (*ExecuteConfig, error) {
+	configFile = configFilePath(configFile)
+	config, err := readConfig(configFile)
+	if err != nil {
+		return nil, err
+	}
 
-	if executionNeedsKubernetes {
-		selectedKubeContext := execution.KubeContext
-		if selectedKubeContext == "" {
-			return fmt.Errorf("execution %q requires Kubernetes sources but no kube-context is specified", execution.Name)
-		}
-
-		// Check cache first
-		if cached, ok := clientCache.Load(selectedKubeContext); ok {
-			entry := cached.(*kubeClientEntry)
-			clientset = entry.clientset
-			restConfig = entry.restConfig
-		} else {
-			// Use mutex to prevent duplicate client creation
-			clientCacheMu.Lock()
-			// Double-check after acquiring lock
-			if cached, ok := clientCache.Load(selectedKubeContext); ok {
-				clientCacheMu.Unlock()
-				entry := cached.(*kubeClientEntry)
-				clientset = entry.clientset
-				restConfig = entry.restConfig
-			} else {
-				// Load kubeconfig with the selected context
-				var err error
-				restConfig, err = clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
-					loadingRules,
-					&clientcmd.ConfigOverrides{CurrentContext: selectedKubeContext},
-				).ClientConfig()
-				if err != nil {
-					clientCacheMu.Unlock()
-					return fmt.Errorf("failed to load kubeconfig: %w", err)
-				}
+	if len(config.Executions) == 0 {
+		return nil, fmt.Errorf("no executions found in %s", configFile)
+	}
 
-				// Create Kubernetes client
-				clientset, err = kubernetes.NewForConfig(restConfig)
-				if err != nil {
-					clientCacheMu.Unlock()
-					return fmt.Errorf("failed to create kubernetes client: %w", err)
-				}
+	if len(config.Sources) == 0 {
+		return nil, fmt.Errorf("no sources found in %s", configFile)
+	}
+
+	if err := checkDuplicates(config.Executions, config.Sources); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", configFile, err)
+	}
+
+	if err := checkDependencies(config.Executions); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", configFile, err)
+	}
+
+	for _, execution := range config.Executions {
+		if err := checkOutputOptions(execution); err != nil {
+			return nil, fmt.Errorf("invalid %s: execution %q: %w", configFile, execution.Name, err)
+		}
+	}
+
+	// Catch transformation errors before any source is fetched
+	if err := validateSources(config.Sources); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", configFile, err)
+	}
+
+	if err := checkValidationRules(config.Validations); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", configFile, err)
+	}
+
+	return config, nil
+}
+
+// overrideKubeContexts returns a copy of the executions with the kube contexts of --kube-context
+// applied. A plain context applies to every execution, name=context to the named one only and
+// takes precedence.
+func overrideKubeContexts(executions []Execution, configExecutions []Execution, overrides []string) ([]Execution, error) {
+	if len(overrides) == 0 {
+		return executions, nil
+	}
+
+	known := make(map[string]bool, len(configExecutions))
+	for _, execution := range configExecutions {
+		known[execution.Name] = true
+	}
+
+	var global string
+	perExecution := make(map[string]string)
+	for _, override := range overrides {
+		name, kubeContext, ok := strings.Cut(override, "=")
+		if !ok {
+			if global != "" {
+				return nil, fmt.Errorf("--kube-context without an execution name can only be given once")
+			}
+			global = override
+			continue
+		}
+		if !known[name] {
+			return nil, fmt.Errorf("--kube-context %s: execution %q not found", override, name)
+		}
+		perExecution[name] = kubeContext
+	}
+
+	overridden := make([]Execution, len(executions))
+	for i, execution := range executions {
+		if kubeContext, ok := perExecution[execution.Name]; ok {
+			execution.KubeContext = kubeContext
+		} else if global != "" {
+			execution.KubeContext = global
+		}
+		overridden[i] = execution
+	}
+	return overridden, nil
+}
+
+// selectExecutions returns all executions, the named ones, or prompts the user to pick them
+func selectExecutions(config *ExecuteConfig, names []string, all bool) ([]Execution, error) {
+	if all {
+		// Run all executions
+		return config.Executions, nil
+	}
 
-				// Cache both clientset and restConfig
-				clientCache.Store(selectedKubeContext, &kubeClientEntry{
-					clientset:  clientset,
-					restConfig: restConfig,
-				})
-				clientCacheMu.Unlock()
+	executionMap := make(map[string]Execution)
+	for _, exec := range config.Executions {
+		executionMap[exec.Name] = exec
+	}
+
+	var selectedExecutions []Execution
+	if len(names) > 0 {
+		// Run specified executions
+		for _, name := range names {
+			exec, ok := executionMap[name]
+			if !ok {
+				return nil, fmt.Errorf("execution %q not found in .enver.yaml", name)
 			}
+			selectedExecutions = append(selectedExecutions, exec)
 		}
+		return selectedExecutions, nil
 	}
 
-	// Map of source types to their fetchers
-	fetchers := map[string]sources.Fetcher{
-		"ConfigMap":   &sources.ConfigMapFetcher{},
-		"Secret":      &sources.SecretFetcher{},
-		"EnvFile":     &sources.EnvFileFetcher{},
-		"Vars":        &sources.VarsFetcher{},
-		"Deployment":  &sources.DeploymentFetcher{},
-		"StatefulSet": &sources.StatefulSetFetcher{},
-		"DaemonSet":   &sources.DaemonSetFetcher{},
-		"Container":   sources.NewContainerFetcher(restConfig),
+	if nonInteractive() {
+		return nil, fmt.Errorf("no executions selected: use --name or --all (prompts are disabled by --no-input or CI)")
 	}
 
-	// Apply defaults for output
-	outputName := execution.Output.Name
-	if outputName == "" {
-		outputName = ".env"
+	// Prompt user to select executions
+	var executionNames []string
+	for _, exec := range config.Executions {
+		executionNames = append(executionNames, exec.Name)
+	}
+
+	var selectedNames []string
+	prompt := &survey.MultiSelect{
+		Message: "Select executions to run:",
+		Options: executionNames,
+	}
+
+	if err := survey.AskOne(prompt, &selectedNames, multiSelectOptions(executionNames)...); err != nil {
+		return nil, fmt.Errorf("execution selection failed: %w", err)
+	}
+
+	if len(selectedNames) == 0 {
+		return nil, fmt.Errorf("no executions selected")
+	}
+
+	for _, name := range selectedNames {
+		selectedExecutions = append(selectedExecutions, executionMap[name])
+	}
+	return selectedExecutions, nil
+}
+
+// executionOutput returns the output directory and file name of an execution with defaults applied
+// and templates rendered
+func executionOutput(execution Execution, perContextDir bool) (string, string, error) {
+	if execution.Output.Directory == stdoutOutput {
+		return stdoutOutput, "", nil
 	}
+
+	data := newOutputPathData(execution.Contexts, execution.KubeContext)
+
 	outputDirectory := execution.Output.Directory
 	if outputDirectory == "" {
 		outputDirectory = "generated"
 	}
+	outputDirectory, err := resolveOutputDirectory(outputDirectory, data, perContextDir)
+	if err != nil {
+		return "", "", err
+	}
+
+	outputName := execution.Output.Name
+	if outputName == "" {
+		outputName = ".env"
+	}
+	outputName, err = renderOutputPath(outputName, data)
+	if err != nil {
+		return "", "", err
+	}
 
-	// Collect all env vars with their source info
-	var envData []sources.EnvEntry
+	return outputDirectory, outputName, nil
+}
 
-	// Get each source and collect its data
+// executionOutputMode returns the output mode of an execution, --output-mode overrides output.mode
+func executionOutputMode(execution Execution) string {
+	if executeOutputMode != "" {
+		return executeOutputMode
+	}
+	return execution.Output.Mode
+}
+
+// collectExecution fetches the entries of all sources included in the execution's contexts
+// Files written by transformations are placed in outputDirectory, with the execution's output mode
+func collectExecution(ctx context.Context, execution Execution, configSources []sources.Source, clients *kubeClientCache, outputDirectory string, rbacCheck, continueOnError bool) ([]sources.EnvEntry, []sourceOutput, error) {
+	executionSources, client, err := resolveExecutionSources(execution, configSources, clients)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ctx, err = withOutputMode(ctx, executionOutputMode(execution))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	selection := sourceSelection(execution.Contexts, client, clients, outputDirectory, rbacCheck)
+	return fetchSources(ctx, executionSources, selection, continueOnError)
+}
+
+// resolveExecutionSources returns the sources included in the execution's contexts, with their names
+// expanded, and the Kubernetes client of the execution, nil if none of the sources needs it
+func resolveExecutionSources(execution Execution, configSources []sources.Source, clients *kubeClientCache) ([]sources.Source, *kubeClientEntry, error) {
+	// Check if this execution needs Kubernetes, sources with their own cluster don't use the execution's
+	var executionSources []sources.Source
+	executionNeedsKubernetes := false
 	for _, source := range configSources {
-		// Check if source should be included based on contexts
 		if !source.ShouldInclude(execution.Contexts) {
 			continue
 		}
-
-		if source.Type == "" {
-			return fmt.Errorf("type is required for source %q in namespace %q", source.Name, source.GetNamespace())
+		executionSources = append(executionSources, source)
+		if source.NeedsKubernetes() && !source.UsesOwnCluster() {
+			executionNeedsKubernetes = true
 		}
+	}
 
-		fetcher, ok := fetchers[source.Type]
-		if !ok {
-			return fmt.Errorf("unknown source type %q for %s/%s", source.Type, source.GetNamespace(), source.Name)
+	// Expand templated source names for this execution
+	executionSources, err := renderSourceNames(executionSources, newOutputPathData(execution.Contexts, execution.KubeContext))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var client *kubeClientEntry
+	if executionNeedsKubernetes {
+		if execution.KubeContext == "" && !inCluster {
+			return nil, nil, fmt.Errorf("execution %q requires Kubernetes sources but no kube-context is specified", execution.Name)
 		}
 
-		entries, err := fetcher.Fetch(clientset, source, outputDirectory)
+		client, err = clients.get(execution.KubeContext)
 		if err != nil {
-			return err
+			return nil, nil, err
 		}
+	}
+	return executionSources, client, nil
+}
 
-		envData = append(envData, entries...)
+// renderExecutionScript collects the execution's entries and renders them as a shell script
+// instead of writing an env file. Files written by transformations are still written.
+func renderExecutionScript(ctx context.Context, execution Execution, config *ExecuteConfig, clients *kubeClientCache, locks *lockRecorder, outputMu *sync.Mutex) (string, error) {
+	outputDirectory, _, err := executionOutput(execution, executePerContextDir)
+	if err != nil {
+		return "", err
 	}
 
-	// Build output path from directory and name
-	outputPath := filepath.Join(outputDirectory, outputName)
+	envData, _, err := collectExecution(ctx, execution, config.Sources, clients, fileDirectory(outputDirectory), executeRBACCheck, false)
+	if err != nil {
+		return "", err
+	}
+
+	// Handle keys emitted by more than one source
+	envData, conflicts, err := resolveConflicts(envData, executeOnConflict)
+	if err != nil {
+		return "", err
+	}
+	if len(conflicts) > 0 {
+		outputMu.Lock()
+		executeLog.warn("conflicting_keys", fmt.Sprintf("  [%s] Warning: keys emitted by more than one source (%s): %s", execution.Name, executeOnConflict, strings.Join(conflicts, ", ")),
+			"execution", execution.Name, "strategy", executeOnConflict, "keys", conflicts)
+		outputMu.Unlock()
+	}
+
+	if err := validateValues(envData, config.Validations); err != nil {
+		return "", err
+	}
 
-	// Create output directory if it doesn't exist
-	if err := os.MkdirAll(outputDirectory, 0755); err != nil {
-		return fmt.Errorf("failed to create output directory: %w", err)
+	// Record the resolved values for the lockfile, failing before anything is written if they don't match
+	if err := locks.record(execution.Name, envData); err != nil {
+		return "", err
 	}
 
-	// Write to output file with comments (one comment per source)
-	var sb strings.Builder
-	var lastSource string
-	for _, entry := range envData {
-		var currentSource string
-		if entry.Namespace != "" {
-			currentSource = fmt.Sprintf("%s %s/%s", entry.SourceType, entry.Namespace, entry.Name)
-		} else {
-			currentSource = fmt.Sprintf("%s %s", entry.SourceType, entry.Name)
+	script, skipped := output.RenderExportScript(executeMasker.mask(envData))
+	if len(skipped) > 0 {
+		outputMu.Lock()
+		executeLog.warn("skipped_keys", fmt.Sprintf("  [%s] Warning: skipped keys that are not valid shell variable names: %s", execution.Name, strings.Join(skipped, ", ")),
+			"execution", execution.Name, "format", "shell", "keys", skipped)
+		outputMu.Unlock()
+	}
+	return script, nil
+}
+
+// runExecution writes the execution's env file and returns a summary of what was written. With
+// --only-diff-write an output file whose content would not change is left untouched.
+func runExecution(ctx context.Context, execution Execution, config *ExecuteConfig, clients *kubeClientCache, locks *lockRecorder, outputMu *sync.Mutex) (executionSummary, error) {
+	var summary executionSummary
+	outputDirectory, outputName, err := executionOutput(execution, executePerContextDir)
+	if err != nil {
+		return summary, err
+	}
+
+	// Fail before fetching when there is no passphrase to encrypt with
+	var passphrase string
+	if execution.Output.Encrypt && !executeDryRun {
+		if passphrase, err = readPassphrase(); err != nil {
+			return summary, err
 		}
-		if currentSource != lastSource {
-			if lastSource != "" {
-				sb.WriteString("\n")
-			}
-			fmt.Fprintf(&sb, "# %s\n", currentSource)
-			lastSource = currentSource
+	}
+
+	// With --continue-on-error the fetched sources are written and the failed ones reported afterwards
+	envData, sourceOutputs, err := collectExecution(ctx, execution, config.Sources, clients, fileDirectory(outputDirectory), executeRBACCheck, executeContinueOnError)
+	var fetchErr error
+	var partialErr *partialFetchError
+	if errors.As(err, &partialErr) {
+		fetchErr = err
+		summary.warnings = append(summary.warnings, fmt.Sprintf("%d of %d sources failed", len(partialErr.failures), partialErr.total))
+	} else if err != nil {
+		return summary, err
+	}
+	summary.sources = len(sourceOutputs)
+	outputMu.Lock()
+	for _, output := range sourceOutputs {
+		var text string
+		if verbose {
+			text = fmt.Sprintf("  [%s] Fetched %d variables from %s", execution.Name, len(output.Entries), describeSource(output.Source))
 		}
-		fmt.Fprintf(&sb, "%s=%s\n", entry.Key, entry.Value)
+		executeLog.info("source_fetched", text, "execution", execution.Name, "source", describeSource(output.Source), "variables", len(output.Entries))
+	}
+	outputMu.Unlock()
+
+	// Variables that are missing because their value is empty would otherwise go unnoticed
+	if skippedEmpty, keys := describeSkippedEmpty(sourceOutputs); len(keys) > 0 {
+		summary.warnings = append(summary.warnings, "skipped empty values: "+strings.Join(keys, ", "))
+		outputMu.Lock()
+		executeLog.warn("skipped_empty_values", fmt.Sprintf("  [%s] Warning: skipped variables with an empty value (set includeEmpty: true to keep them): %s", execution.Name, strings.Join(skippedEmpty, "; ")),
+			"execution", execution.Name, "keys", keys)
+		outputMu.Unlock()
+	}
+
+	// Handle keys emitted by more than one source
+	envData, conflicts, err := resolveConflicts(envData, executeOnConflict)
+	if err != nil {
+		return summary, err
+	}
+	summary.variables = len(envData)
+	if len(conflicts) > 0 {
+		summary.warnings = append(summary.warnings, "conflicting keys: "+strings.Join(conflicts, ", "))
+		outputMu.Lock()
+		executeLog.warn("conflicting_keys", fmt.Sprintf("  [%s] Warning: keys emitted by more than one source (%s): %s", execution.Name, executeOnConflict, strings.Join(conflicts, ", ")),
+			"execution", execution.Name, "strategy", executeOnConflict, "keys", conflicts)
+		outputMu.Unlock()
 	}
-	if err := os.WriteFile(outputPath, []byte(sb.String()), 0644); err != nil {
-		return fmt.Errorf("failed to write output file: %w", err)
+
+	if err := validateValues(envData, config.Validations); err != nil {
+		return summary, err
+	}
+
+	// Record the resolved values for the lockfile, failing before anything is written if they don't match
+	if err := locks.record(execution.Name, envData); err != nil {
+		return summary, err
+	}
+
+	// Build output path from directory and name
+	outputPath := filepath.Join(outputDirectory, outputName)
+
+	// Only report what would be written
+	if executeDryRun {
+		report := renderDryRunSummary(fmt.Sprintf("  [%s] ", execution.Name), outputPath, envData, sourceOutputs)
+		outputMu.Lock()
+		executeLog.info("dry_run", strings.TrimSuffix(report, "\n"), "execution", execution.Name, "path", outputPath, "variables", len(envData))
+		outputMu.Unlock()
+		return summary, fetchErr
+	}
+
+	writeOptions := output.RenderOptions{Export: executeExport || execution.Output.Export}
+	format := execution.Output.Format
+	if executeFormat != "" {
+		format = executeFormat
+	}
+	format = output.ResolveFormat(format, outputName)
+
+	// Stream to stdout without creating a directory or touching .gitignore
+	if outputDirectory == stdoutOutput {
+		masked, skipped := output.Render(executeMasker.mask(envData), format, writeOptions)
+		warnSkippedKeys(&summary, execution.Name, format, skipped, outputMu)
+		outputMu.Lock()
+		fmt.Print(masked)
+		outputMu.Unlock()
+		return summary, fetchErr
 	}
 
+	// Write to output file with comments (one comment per source), or one file per source
+	result, err := output.Write(envData, sourceEntries(sourceOutputs), output.Options{
+		Directory:     outputDirectory,
+		Name:          outputName,
+		Format:        format,
+		RenderOptions: writeOptions,
+		Mode:          executionOutputMode(execution),
+		PerSource:     execution.Output.PerSource,
+		Explode:       executeExplode,
+		Encrypt:       execution.Output.Encrypt,
+		Passphrase:    passphrase,
+		OnlyDiffWrite: executeOnlyDiffWrite,
+		FailIfExists:  failIfExists,
+	})
+	warnSkippedKeys(&summary, execution.Name, format, result.Skipped, outputMu)
+
 	outputMu.Lock()
-	fmt.Printf("  [%s] Wrote %d environment variables to %s\n", execution.Name, len(envData), outputPath)
+	switch {
+	case result.Unchanged:
+		executeLog.info("output_unchanged", fmt.Sprintf("  [%s] %s is up to date", execution.Name, result.Path), "execution", execution.Name, "path", result.Path)
+	case result.Path != "":
+		executeLog.info("output_written", fmt.Sprintf("  [%s] Wrote %d environment variables to %s", execution.Name, len(envData), result.Path),
+			"execution", execution.Name, "path", result.Path, "variables", len(envData))
+		summary.changed = true
+		summary.files = append(summary.files, result.Path)
+	}
+	for _, sourcePath := range result.SourcePaths {
+		executeLog.info("source_file_written", fmt.Sprintf("  [%s] Wrote source file %s", execution.Name, sourcePath), "execution", execution.Name, "path", sourcePath)
+	}
 	outputMu.Unlock()
+	summary.files = append(summary.files, result.SourcePaths...)
+	if execution.Output.PerSource && len(result.SourcePaths) > 0 {
+		summary.changed = true
+	}
+
+	return summary, errors.Join(err, fetchErr)
+}
 
-	// Check if output file should be added to .gitignore
-	if err := gitutil.EnsureGitignored(outputPath); err != nil {
-		return err
+// warnSkippedKeys reports the keys the output format can't represent in the log and the summary
+func warnSkippedKeys(summary *executionSummary, name, format string, skipped []string, outputMu *sync.Mutex) {
+	if len(skipped) == 0 {
+		return
 	}
+	summary.warnings = append(summary.warnings, "skipped keys: "+strings.Join(skipped, ", "))
+	outputMu.Lock()
+	executeLog.warn("skipped_keys", fmt.Sprintf("  [%s] Warning: skipped keys that are not valid %s variable names: %s", name, format, strings.Join(skipped, ", ")),
+		"execution", name, "format", format, "keys", skipped)
+	outputMu.Unlock()
+}
 
-	return nil
+// printExecutionSummary prints a table with what every execution wrote, in selection order. With
+// --log-format json every row is an execution_summary event.
+func printExecutionSummary(w io.Writer, executions []Execution, summaries map[string]executionSummary) {
+	if executeLog.json != nil {
+		for _, execution := range executions {
+			summary := summaries[execution.Name]
+			executeLog.info("execution_summary", "", "execution", execution.Name, "variables", summary.variables,
+				"files", summary.files, "sources", summary.sources, "warnings", summary.warnings)
+		}
+		return
+	}
+	if quiet {
+		return
+	}
+
+	fmt.Fprintln(w)
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "EXECUTION\tVARIABLES\tFILES\tSOURCES\tWARNINGS")
+	for _, execution := range executions {
+		summary := summaries[execution.Name]
+		warnings := "-"
+		if len(summary.warnings) > 0 {
+			warnings = strings.Join(summary.warnings, "; ")
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%s\n", execution.Name, summary.variables, len(summary.files), summary.sources, warnings)
+	}
+	tw.Flush()
 }
 
 func init() {
-	executeCmd.Flags().StringVarP(&executeInputFile, "input", "i", "", "input configuration file (default .enver.yaml)")
+	addDeprecatedInputFlag(executeCmd, &executeInputFile)
 	executeCmd.Flags().StringArrayVar(&executeNames, "name", []string{}, "execution name to run (can be repeated)")
+	executeCmd.RegisterFlagCompletionFunc("name", completeExecutionNames(&executeInputFile))
 	executeCmd.Flags().BoolVar(&executeAll, "all", false, "run all executions")
+	executeCmd.Flags().IntVar(&executeParallelism, "parallelism", runtime.NumCPU(), "maximum number of executions that run at the same time (0 = unlimited)")
+	executeCmd.Flags().BoolVar(&executeExport, "export", false, "prefix each variable with \"export \" (for all executions)")
+	executeCmd.Flags().BoolVar(&executeExportScript, "export-script", false, "print a shell script with export statements to stdout instead of writing env files")
+	executeCmd.Flags().BoolVar(&executeExplode, "explode", false, "also write one file per source (<sourceType>-<name>.env) to the output directory")
+	executeCmd.Flags().StringVar(&executeWriteLock, "write-lock", "", "write a lockfile with hashes of the resolved values of the executions")
+	executeCmd.Flags().StringVar(&executeVerifyLock, "verify-lock", "", "fail if the resolved values differ from this lockfile")
+	executeCmd.Flags().StringArrayVar(&includeVars, "include-var", []string{}, "only keep variables matching this pattern, in addition to the sources' own filters (can be repeated)")
+	executeCmd.Flags().StringArrayVar(&excludeVars, "exclude-var", []string{}, "drop variables matching this pattern, in addition to the sources' own filters (can be repeated)")
+	executeCmd.Flags().StringVar(&passphraseFile, "passphrase-file", "", "file with the passphrase for executions with output.encrypt (default $"+passphraseEnv+")")
+	executeCmd.Flags().BoolVar(&failIfExists, "fail-if-exists", false, "fail instead of overwriting output files that already exist")
+	executeCmd.Flags().BoolVar(&executeDryRun, "dry-run", false, "fetch all sources and print how many variables each contributes without writing any file")
+	executeCmd.Flags().BoolVar(&executeContinueOnError, "continue-on-error", false, "write the variables of the sources that could be fetched and report all failed sources at the end")
+	executeCmd.Flags().StringVar(&executeFormat, "format", "", "format of the written files for all executions, overriding output.format: env or tfvars (default detected from the file extension)")
+	executeCmd.Flags().BoolVar(&executeMask, "mask", false, "replace the values of Secrets with **** in env files and scripts written to stdout")
+	executeCmd.Flags().StringVar(&executeMaskPattern, "mask-pattern", "", "also mask the values of keys matching this regex (implies --mask)")
+	executeCmd.Flags().StringArrayVar(&executeKubeContexts, "kube-context", []string{}, "kube context for all executions, or name=context for a single execution, overriding kube-context in the configuration (can be repeated)")
+	executeCmd.Flags().StringVar(&executeLogFormat, "log-format", logFormatText, "format of the progress messages: text or json (one object per event)")
+	executeCmd.Flags().BoolVar(&executeWatch, "watch", false, "keep running and regenerate an execution when a ConfigMap or Secret it reads changes")
+	executeCmd.Flags().BoolVar(&executeOnlyDiffWrite, "only-diff-write", false, "only write output files whose content changed and exit with code 2 if any was written")
+	executeCmd.Flags().StringVar(&executeOutputMode, "output-mode", "", "octal permissions of the written files for all executions (default 0600 if a Secret is included, 0644 otherwise)")
+	executeCmd.Flags().BoolVar(&executePerContextDir, "per-context-dir", false, "nest each output directory under the execution's context name")
+	executeCmd.Flags().StringVar(&executeOnConflict, "on-conflict", conflictKeepAll, "how to handle keys emitted by more than one source: keep-all, last-wins, first-wins or error")
+	executeCmd.Flags().BoolVar(&executeRBACCheck, "rbac-check", false, "check RBAC permissions for all sources before fetching")
+	executeCmd.Flags().BoolVar(&checkNamespacesFlag, "check-namespaces", false, "check that the namespaces of all sources exist before fetching (needs get access on namespaces)")
 	rootCmd.AddCommand(executeCmd)
 }
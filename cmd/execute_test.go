@@ -0,0 +1,717 @@
+package cmd
+
+import (
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"enver/gitutil"
+	"enver/sources"
+
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+func TestRunExecutionPerContextDir(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	configSources := []sources.Source{
+		{Type: "Vars", Name: "prod", Vars: []sources.VarEntry{{Name: "ENVIRONMENT", Value: "prod"}}, Contexts: sources.SourceContexts{Include: []string{"prod"}}},
+		{Type: "Vars", Name: "staging", Vars: []sources.VarEntry{{Name: "ENVIRONMENT", Value: "staging"}}, Contexts: sources.SourceContexts{Include: []string{"staging"}}},
+	}
+	clients := newKubeClientCache(clientcmd.NewDefaultClientConfigLoadingRules())
+
+	executePerContextDir = true
+	defer func() { executePerContextDir = false }()
+
+	var outputMu sync.Mutex
+	for _, context := range []string{"prod", "staging"} {
+		execution := Execution{Name: context, Contexts: []string{context}}
+		if _, err := runExecution(t.Context(), execution, &ExecuteConfig{Sources: configSources}, clients, newLockRecorder(nil), &outputMu); err != nil {
+			t.Fatalf("runExecution(t.Context(), %s) returned error: %v", context, err)
+		}
+	}
+
+	for _, context := range []string{"prod", "staging"} {
+		content, err := os.ReadFile(filepath.Join("generated", context, ".env"))
+		if err != nil {
+			t.Fatalf("expected generated/%s/.env: %v", context, err)
+		}
+		expected := "# Vars " + context + "\nENVIRONMENT=" + context + "\n"
+		if string(content) != expected {
+			t.Errorf("generated/%s/.env: expected %q, got %q", context, expected, string(content))
+		}
+	}
+}
+
+func TestExecuteOnlyDiffWrite(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	config := `sources:
+  - type: Vars
+    name: inline
+    vars:
+      - name: HOST
+        value: localhost
+executions:
+  - name: local
+`
+	if err := os.WriteFile(".enver.yaml", []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { executeOnlyDiffWrite = false; executeAll = false }()
+
+	execute := func() error {
+		rootCmd.SetArgs([]string{"execute", "--all", "--only-diff-write"})
+		return rootCmd.Execute()
+	}
+	outputPath := filepath.Join("generated", ".env")
+
+	// The first run creates the file, which counts as a change
+	var exitErr *exitCodeError
+	if err := execute(); !errors.As(err, &exitErr) || exitErr.code != exitCodeChanged {
+		t.Fatalf("first run: expected exit code %d, got %v", exitCodeChanged, err)
+	}
+
+	// Backdate the file so a rewrite would be visible in its modification time
+	past := time.Now().Add(-time.Hour).Truncate(time.Second)
+	if err := os.Chtimes(outputPath, past, past); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := execute(); err != nil {
+		t.Fatalf("unchanged run: expected exit code 0, got %v", err)
+	}
+	info, err := os.Stat(outputPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.ModTime().Equal(past) {
+		t.Errorf("unchanged run rewrote %s", outputPath)
+	}
+
+	// Drift in the output file is corrected and reported
+	if err := os.WriteFile(outputPath, []byte("HOST=remote\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := execute(); !errors.As(err, &exitErr) || exitErr.code != exitCodeChanged {
+		t.Fatalf("changed run: expected exit code %d, got %v", exitCodeChanged, err)
+	}
+	content, err := os.ReadFile(outputPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "# Vars inline\nHOST=localhost\n"; string(content) != expected {
+		t.Errorf("expected %q, got %q", expected, string(content))
+	}
+}
+
+func TestSelectExecutionsWithoutInput(t *testing.T) {
+	config := &ExecuteConfig{Executions: []Execution{{Name: "local"}, {Name: "dev"}}}
+
+	t.Setenv("CI", "true")
+	if _, err := selectExecutions(config, nil, false); err == nil || !strings.Contains(err.Error(), "use --name or --all") {
+		t.Errorf("expected an error instead of a prompt in CI, got %v", err)
+	}
+
+	t.Setenv("CI", "")
+	noInput = true
+	defer func() { noInput = false }()
+	if _, err := selectExecutions(config, nil, false); err == nil || !strings.Contains(err.Error(), "use --name or --all") {
+		t.Errorf("expected an error instead of a prompt with --no-input, got %v", err)
+	}
+
+	// Explicit selections still work
+	selected, err := selectExecutions(config, []string{"dev"}, false)
+	if err != nil || len(selected) != 1 || selected[0].Name != "dev" {
+		t.Errorf("expected dev to be selected, got %v (%v)", selected, err)
+	}
+}
+
+func TestDryRunWritesNoFiles(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+
+	config := `sources:
+  - type: Vars
+    name: inline
+    vars:
+      - name: HOST
+        value: localhost
+      - name: CERTIFICATE
+        value: "-----BEGIN CERTIFICATE-----"
+    transformations:
+      - type: file
+        output: cert.pem
+        key: CERTIFICATE_FILE
+        variables:
+          - CERTIFICATE
+executions:
+  - name: local
+`
+	if err := os.WriteFile(".enver.yaml", []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { executeDryRun = false; executeAll = false; dryRun = false }()
+
+	for _, args := range [][]string{
+		{"execute", "--all", "--dry-run"},
+		{"generate", "--dry-run"},
+	} {
+		rootCmd.SetArgs(args)
+		if err := rootCmd.Execute(); err != nil {
+			t.Fatalf("%v returned error: %v", args, err)
+		}
+
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, entry := range entries {
+			if entry.Name() != ".enver.yaml" {
+				t.Errorf("%v: expected no files to be written, found %s", args, entry.Name())
+			}
+		}
+	}
+}
+
+func TestExecuteContinueOnErrorWritesPartialOutput(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	config := `sources:
+  - type: Vars
+    name: first
+    vars:
+      - name: HOST
+        value: localhost
+  - type: EnvFile
+    path: missing.env
+  - type: Vars
+    name: third
+    vars:
+      - name: PORT
+        value: "8080"
+executions:
+  - name: local
+`
+	if err := os.WriteFile(".enver.yaml", []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { executeContinueOnError = false; executeAll = false }()
+
+	// Without the flag the failing source aborts the execution
+	rootCmd.SetArgs([]string{"execute", "--all"})
+	if err := rootCmd.Execute(); err == nil {
+		t.Fatal("expected the missing env file to fail the execution")
+	}
+	outputPath := filepath.Join("generated", ".env")
+	if _, err := os.Stat(outputPath); !os.IsNotExist(err) {
+		t.Fatalf("expected no output without --continue-on-error, got %v", err)
+	}
+
+	rootCmd.SetArgs([]string{"execute", "--all", "--continue-on-error"})
+	err := rootCmd.Execute()
+	if err == nil || !strings.Contains(err.Error(), "1 of 3 sources failed") || !strings.Contains(err.Error(), "EnvFile missing.env") {
+		t.Fatalf("expected the failed source to be reported, got %v", err)
+	}
+
+	content, err := os.ReadFile(outputPath)
+	if err != nil {
+		t.Fatalf("expected a partial output: %v", err)
+	}
+	expected := "# Vars first\nHOST=localhost\n\n# Vars third\nPORT=8080\n"
+	if string(content) != expected {
+		t.Errorf("expected %q, got %q", expected, string(content))
+	}
+}
+
+// captureStdout returns what fn writes to os.Stdout
+func captureStdout(t *testing.T, fn func()) string {
+	t.Helper()
+	return captureFile(t, &os.Stdout, fn)
+}
+
+// captureStderr returns what fn writes to stderr
+func captureStderr(t *testing.T, fn func()) string {
+	t.Helper()
+	return captureFile(t, &os.Stderr, fn)
+}
+
+// captureFile returns what fn writes to the file, which is replaced by a pipe while fn runs
+func captureFile(t *testing.T, file **os.File, fn func()) string {
+	t.Helper()
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	original := *file
+	*file = writer
+	defer func() { *file = original }()
+
+	output := make(chan string)
+	go func() {
+		content, _ := io.ReadAll(reader)
+		output <- string(content)
+	}()
+
+	fn()
+	writer.Close()
+	return <-output
+}
+
+func TestExecuteWritesToStdout(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	config := `sources:
+  - type: Vars
+    name: inline
+    vars:
+      - name: HOST
+        value: localhost
+executions:
+  - name: local
+    output:
+      directory: "-"
+`
+	if err := os.WriteFile(".enver.yaml", []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { executeAll = false }()
+
+	var err error
+	stdout := captureStdout(t, func() {
+		rootCmd.SetArgs([]string{"execute", "--all"})
+		err = rootCmd.Execute()
+	})
+	if err != nil {
+		t.Fatalf("execute returned error: %v", err)
+	}
+
+	// Only the env file is on stdout, the progress messages go to stderr
+	expected := "# Vars inline\nHOST=localhost\n"
+	if stdout != expected {
+		t.Errorf("expected stdout %q, got %q", expected, stdout)
+	}
+
+	entries, err := os.ReadDir(".")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name() != ".enver.yaml" {
+		t.Errorf("expected no files to be created, got %v", entries)
+	}
+}
+
+func TestExecuteMaskOnlyMasksStdout(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	config := `sources:
+  - type: Vars
+    name: inline
+    vars:
+      - name: DB_PASSWORD
+        value: s3cr3t
+      - name: HOST
+        value: localhost
+executions:
+  - name: file
+  - name: console
+    output:
+      directory: "-"
+`
+	if err := os.WriteFile(".enver.yaml", []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { executeAll = false; executeMaskPattern = "" }()
+
+	var err error
+	stdout := captureStdout(t, func() {
+		rootCmd.SetArgs([]string{"execute", "--all", "--mask-pattern", "PASSWORD"})
+		err = rootCmd.Execute()
+	})
+	if err != nil {
+		t.Fatalf("execute returned error: %v", err)
+	}
+
+	expected := "# Vars inline\nDB_PASSWORD=****\nHOST=localhost\n"
+	if stdout != expected {
+		t.Errorf("expected masked stdout %q, got %q", expected, stdout)
+	}
+
+	content, err := os.ReadFile(filepath.Join("generated", ".env"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(content), "DB_PASSWORD=s3cr3t\n") {
+		t.Errorf("expected the real value in the file, got %q", string(content))
+	}
+}
+
+func TestRunExecutionPerSource(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	configSources := []sources.Source{
+		{Type: "Vars", Name: "app", Vars: []sources.VarEntry{{Name: "HOST", Value: "localhost"}}},
+		{Type: "Vars", Name: "db", Vars: []sources.VarEntry{{Name: "DB_PORT", Value: "5432"}}},
+	}
+	clients := newKubeClientCache(clientcmd.NewDefaultClientConfigLoadingRules())
+
+	var outputMu sync.Mutex
+	execution := Execution{Name: "local", Output: ExecutionOutput{PerSource: true}}
+	if _, err := runExecution(t.Context(), execution, &ExecuteConfig{Sources: configSources}, clients, newLockRecorder(nil), &outputMu); err != nil {
+		t.Fatalf("runExecution returned error: %v", err)
+	}
+
+	expected := map[string]string{
+		"Vars-app.env": "# Vars app\nHOST=localhost\n",
+		"Vars-db.env":  "# Vars db\nDB_PORT=5432\n",
+	}
+	for fileName, expectedContent := range expected {
+		content, err := os.ReadFile(filepath.Join("generated", fileName))
+		if err != nil {
+			t.Fatalf("expected generated/%s: %v", fileName, err)
+		}
+		if string(content) != expectedContent {
+			t.Errorf("generated/%s: expected %q, got %q", fileName, expectedContent, string(content))
+		}
+	}
+	if _, err := os.Stat(filepath.Join("generated", ".env")); !os.IsNotExist(err) {
+		t.Errorf("expected no combined file, got %v", err)
+	}
+}
+
+func TestExecuteCLIVariableFiltersCombineWithConfig(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	config := `sources:
+  - type: Vars
+    name: inline
+    vars:
+      - name: HOST
+        value: localhost
+      - name: PORT
+        value: "8080"
+      - name: DEBUG
+        value: "true"
+    variables:
+      exclude:
+        - DEBUG
+executions:
+  - name: local
+`
+	if err := os.WriteFile(".enver.yaml", []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { executeAll = false; includeVars = nil; excludeVars = nil }()
+
+	rootCmd.SetArgs([]string{"execute", "--all", "--exclude-var", "^PORT$"})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("execute returned error: %v", err)
+	}
+
+	content, err := os.ReadFile(filepath.Join("generated", ".env"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// PORT is dropped by the command line, DEBUG is still dropped by the configuration
+	expected := "# Vars inline\nHOST=localhost\n"
+	if string(content) != expected {
+		t.Errorf("expected %q, got %q", expected, string(content))
+	}
+}
+
+func TestExecuteCLIVariableFiltersApplyBeforeTransformations(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	// The command line pattern is a regex even though the source matches exactly
+	config := `sources:
+  - type: Vars
+    name: inline
+    vars:
+      - name: HOST
+        value: localhost
+      - name: CERTIFICATE
+        value: certificate
+    variables:
+      mode: exact
+      exclude:
+        - DEBUG
+    transformations:
+      - type: file
+        output: cert.pem
+        key: CERTIFICATE_FILE
+        variables:
+          - CERTIFICATE
+executions:
+  - name: local
+`
+	if err := os.WriteFile(".enver.yaml", []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { executeAll = false; includeVars = nil; excludeVars = nil }()
+
+	rootCmd.SetArgs([]string{"execute", "--all", "--include-var", "^HO"})
+	captureStdout(t, func() {
+		if err := rootCmd.Execute(); err != nil {
+			t.Fatalf("execute returned error: %v", err)
+		}
+	})
+
+	content, err := os.ReadFile(filepath.Join("generated", ".env"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "# Vars inline\nHOST=localhost\n"; string(content) != expected {
+		t.Errorf("expected %q, got %q", expected, string(content))
+	}
+	// The filtered variable is dropped before its file is written
+	if _, err := os.Stat(filepath.Join("generated", "cert.pem")); !os.IsNotExist(err) {
+		t.Errorf("expected no generated/cert.pem, got %v", err)
+	}
+}
+
+func TestExecuteRunsDependenciesFirst(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	// derived reads the file written by base, and combined reads both
+	config := `contexts:
+  - base
+  - derived
+  - combined
+sources:
+  - type: Vars
+    name: base
+    vars:
+      - name: HOST
+        value: localhost
+    contexts:
+      include: [base]
+  - type: EnvFile
+    path: generated/base.env
+    contexts:
+      include: [derived, combined]
+  - type: Vars
+    name: derived
+    vars:
+      - name: PORT
+        value: "8080"
+    contexts:
+      include: [derived]
+  - type: EnvFile
+    path: generated/derived.env
+    contexts:
+      include: [combined]
+executions:
+  - name: combined
+    output:
+      name: combined.env
+    contexts: [combined]
+    dependsOn: [base, derived]
+  - name: derived
+    output:
+      name: derived.env
+    contexts: [derived]
+    dependsOn: [base]
+  - name: base
+    output:
+      name: base.env
+    contexts: [base]
+`
+	if err := os.WriteFile(".enver.yaml", []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { executeAll = false }()
+
+	rootCmd.SetArgs([]string{"execute", "--all"})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("execute returned error: %v", err)
+	}
+
+	content, err := os.ReadFile(filepath.Join("generated", "combined.env"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed := parseEnv(string(content))
+	if parsed["HOST"] != "localhost" || parsed["PORT"] != "8080" {
+		t.Errorf("expected HOST and PORT from the dependencies, got:\n%s", content)
+	}
+}
+
+func TestExecutionStagesSkipAfterFailedDependency(t *testing.T) {
+	stages := newExecutionStages([]Execution{{Name: "base"}, {Name: "derived"}})
+	stages["base"].failed = true
+	close(stages["base"].done)
+
+	// Dependencies that were not selected are not waited for
+	err := stages.wait(Execution{Name: "derived", DependsOn: []string{"unselected", "base"}})
+	if err == nil || !strings.Contains(err.Error(), `"base" failed`) {
+		t.Errorf("expected an error about the failed dependency, got %v", err)
+	}
+}
+
+func TestExecuteSummaryMatchesWrittenFiles(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	config := `sources:
+  - type: Vars
+    name: app
+    vars:
+      - name: HOST
+        value: localhost
+      - name: PORT
+        value: "8080"
+  - type: Vars
+    name: override
+    vars:
+      - name: PORT
+        value: "9090"
+executions:
+  - name: local
+`
+	if err := os.WriteFile(".enver.yaml", []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { executeAll = false; executeExplode = false; executeOnConflict = conflictKeepAll }()
+
+	rootCmd.SetArgs([]string{"execute", "--all", "--explode", "--on-conflict", "last-wins"})
+	output := captureStdout(t, func() {
+		if err := rootCmd.Execute(); err != nil {
+			t.Fatalf("execute returned error: %v", err)
+		}
+	})
+
+	var row []string
+	for _, line := range strings.Split(output, "\n") {
+		if fields := strings.Fields(line); len(fields) > 0 && fields[0] == "local" {
+			row = fields
+		}
+	}
+	if len(row) < 5 {
+		t.Fatalf("expected a summary row for local, got output %q", output)
+	}
+
+	content, err := os.ReadFile(filepath.Join("generated", ".env"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	variables := 0
+	for _, line := range strings.Split(string(content), "\n") {
+		if strings.Contains(line, "=") && !strings.HasPrefix(line, "#") {
+			variables++
+		}
+	}
+	files, err := os.ReadDir("generated")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The combined file and one file per source
+	expected := []string{"local", strconv.Itoa(variables), strconv.Itoa(len(files)), "2"}
+	if strings.Join(row[:4], " ") != strings.Join(expected, " ") {
+		t.Errorf("expected summary %v, got %v", expected, row[:4])
+	}
+	if !strings.Contains(strings.Join(row[4:], " "), "conflicting keys: PORT") {
+		t.Errorf("expected the conflict in the warnings, got %v", row[4:])
+	}
+}
+
+func TestExecuteFailIfExists(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	config := `sources:
+  - type: Vars
+    name: inline
+    vars:
+      - name: HOST
+        value: localhost
+executions:
+  - name: local
+`
+	if err := os.WriteFile(".enver.yaml", []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { executeAll = false; failIfExists = false }()
+
+	rootCmd.SetArgs([]string{"execute", "--all", "--fail-if-exists"})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("execute returned error for a new file: %v", err)
+	}
+
+	// A hand-edited file is left alone
+	outputPath := filepath.Join("generated", ".env")
+	if err := os.WriteFile(outputPath, []byte("HOST=edited\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	rootCmd.SetArgs([]string{"execute", "--all", "--fail-if-exists"})
+	err := rootCmd.Execute()
+	if err == nil || !strings.Contains(err.Error(), outputPath+" already exists") {
+		t.Fatalf("expected an error for the existing file, got %v", err)
+	}
+	content, err := os.ReadFile(outputPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "HOST=edited\n" {
+		t.Errorf("expected the existing file to be kept, got %q", string(content))
+	}
+}
+
+func TestExecuteExportScriptWithFileTransformationIsSourceable(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	t.Chdir(t.TempDir())
+	if output, err := exec.Command("git", "init", "-q").CombinedOutput(); err != nil {
+		t.Fatalf("git init failed: %v\n%s", err, output)
+	}
+
+	config := `sources:
+  - type: Vars
+    name: inline
+    vars:
+      - name: CERTIFICATE
+        value: "-----BEGIN CERTIFICATE-----"
+    transformations:
+      - type: file
+        output: cert.pem
+        key: CERTIFICATE_FILE
+executions:
+  - name: local
+`
+	if err := os.WriteFile(".enver.yaml", []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { executeAll = false; executeExportScript = false; gitignoreMode = gitutil.ModeAuto }()
+
+	// The file transformation writes cert.pem and adds it to .gitignore while the script is printed
+	rootCmd.SetArgs([]string{"execute", "--all", "--export-script", "--gitignore", "file"})
+	script := captureStdout(t, func() {
+		if err := rootCmd.Execute(); err != nil {
+			t.Errorf("execute returned error: %v", err)
+		}
+	})
+	if err := os.WriteFile("env.sh", []byte(script), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	output, err := exec.Command("sh", "-e", "-c", `. ./env.sh; printenv CERTIFICATE_FILE`).CombinedOutput()
+	if err != nil {
+		t.Fatalf("failed to source the script: %v\n%s\nscript:\n%s", err, output, script)
+	}
+	if expected := filepath.Join("generated", "cert.pem") + "\n"; string(output) != expected {
+		t.Errorf("expected CERTIFICATE_FILE=%q, got %q", expected, output)
+	}
+	content, err := os.ReadFile(".gitignore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(content), "cert.pem") {
+		t.Errorf("expected cert.pem to be added to .gitignore, got %q", content)
+	}
+}
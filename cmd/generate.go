@@ -7,60 +7,96 @@ import (
 	"strings"
 
 	"enver/gitutil"
+	"enver/output"
 	"enver/sources"
+	"enver/transformations"
 
 	"github.com/AlecAivazis/survey/v2"
-	"github.com/manifoldco/promptui"
 	"github.com/spf13/cobra"
-	"gopkg.in/yaml.v3"
-	"k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/rest"
-	"k8s.io/client-go/tools/clientcmd"
 )
 
-type Config struct {
-	Contexts []string         `yaml:"contexts"`
-	Sources  []sources.Source `yaml:"sources"`
-}
-
 var kubeContext string
 var outputName string
 var outputDirectory string
 var contextFlags []string
 var inputFile string
+var rbacCheck bool
+var exportVars bool
+var explode bool
+var onConflict string
+var perContextDir bool
+var outputMode string
+var dryRun bool
+var mask bool
+var maskPattern string
+var outputFormat string
 
 var generateCmd = &cobra.Command{
 	Use:   "generate",
 	Short: "Generate .env file from ConfigMaps, Secrets and EnvFiles",
 	Long:  `Reads the .enver.yaml file, selects a kubectl context if needed, and generates a .env file from ConfigMaps, Secrets and EnvFiles defined in sources.`,
-	RunE: func(cmd *cobra.Command, args []string) error {
-		configFile := inputFile
-		if configFile == "" {
-			configFile = ".enver.yaml"
+	RunE: func(cmd *cobra.Command, args []string) (err error) {
+		if err := validateConflictStrategy(onConflict); err != nil {
+			return err
+		}
+		if outputMode != "" {
+			if _, err := output.ParseMode(outputMode); err != nil {
+				return err
+			}
+		}
+		if err := output.ValidateFormat(outputFormat); err != nil {
+			return err
 		}
-		content, err := os.ReadFile(configFile)
+		consoleMasker, err := newMasker(mask, maskPattern)
 		if err != nil {
-			return fmt.Errorf("failed to read %s: %w", configFile, err)
+			return err
+		}
+		if explode && outputDirectory == stdoutOutput {
+			return fmt.Errorf("--explode cannot be combined with writing to stdout")
 		}
+		transformations.SetDryRun(dryRun)
+		defer transformations.SetDryRun(false)
+
+		// Files written while generating are checked against .gitignore at once, also when it fails
+		flushGitignore := gitutil.Defer()
+		defer func() {
+			if flushErr := flushGitignore(); err == nil {
+				err = flushErr
+			}
+		}()
 
-		var config Config
-		if err := yaml.Unmarshal(content, &config); err != nil {
-			return fmt.Errorf("failed to parse %s: %w", configFile, err)
+		configFile := configFilePath(inputFile)
+		config, err := readConfig(configFile)
+		if err != nil {
+			return err
 		}
 
 		if len(config.Sources) == 0 {
 			return fmt.Errorf("no sources found in %s", configFile)
 		}
 
+		if err := checkDuplicates(nil, config.Sources); err != nil {
+			return fmt.Errorf("invalid %s: %w", configFile, err)
+		}
+
+		// Catch transformation errors before any source is fetched
+		if err := validateSources(config.Sources); err != nil {
+			return fmt.Errorf("invalid %s: %w", configFile, err)
+		}
+		if err := checkValidationRules(config.Validations); err != nil {
+			return fmt.Errorf("invalid %s: %w", configFile, err)
+		}
+
 		// Select contexts for filtering sources
 		selectedContexts := contextFlags
-		if len(selectedContexts) == 0 && len(config.Contexts) > 0 {
+		// Without prompts no context is selected unless given with --context
+		if len(selectedContexts) == 0 && len(config.Contexts) > 0 && !nonInteractive() {
 			prompt := &survey.MultiSelect{
 				Message: "Select contexts (press Enter for none, Space to select):",
 				Options: config.Contexts,
 			}
 
-			err := survey.AskOne(prompt, &selectedContexts)
+			err := survey.AskOne(prompt, &selectedContexts, multiSelectOptions(config.Contexts)...)
 			if err != nil {
 				return fmt.Errorf("context selection failed: %w", err)
 			}
@@ -74,148 +110,186 @@ var generateCmd = &cobra.Command{
 				continue
 			}
 			filteredSources = append(filteredSources, source)
-			if source.Type == "ConfigMap" || source.Type == "Secret" || source.Type == "Deployment" || source.Type == "StatefulSet" || source.Type == "DaemonSet" || source.Type == "Container" {
+			// Sources with their own kubeconfig or kube context don't need the selected cluster
+			if source.NeedsKubernetes() && !source.UsesOwnCluster() {
 				needsKubernetes = true
 			}
 		}
 
-		// Use default loading rules (respects KUBECONFIG env var)
-		loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+		// Respects --kubeconfig and the KUBECONFIG env var
+		loadingRules := kubeconfigLoadingRules()
+		clients := newKubeClientCache(loadingRules)
 
-		var clientset *kubernetes.Clientset
-		var restConfig *rest.Config
+		var client *kubeClientEntry
+		selectedKubeContext := kubeContext
+		if selectedKubeContext == "" {
+			selectedKubeContext = selectedProfile(config).KubeContext
+		}
 
 		// Only set up Kubernetes client if needed
 		if needsKubernetes {
-			selectedKubeContext := kubeContext
-			if selectedKubeContext == "" {
-				// Load kubeconfig to get available contexts
-				kubeConfig, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
-					loadingRules,
-					&clientcmd.ConfigOverrides{},
-				).RawConfig()
-				if err != nil {
-					return fmt.Errorf("failed to load kubeconfig: %w", err)
+			// In a cluster there is no kubeconfig to pick a context from
+			if selectedKubeContext == "" && !inCluster {
+				if nonInteractive() {
+					return fmt.Errorf("no kube context selected: use --kube-context (prompts are disabled by --no-input or CI)")
 				}
 
-				// Get list of context names
-				var contextNames []string
-				for name := range kubeConfig.Contexts {
-					contextNames = append(contextNames, name)
+				// Load kubeconfig to get available contexts
+				contextNames, err := kubeContextNames(loadingRules)
+				if err != nil {
+					return err
 				}
 
 				if len(contextNames) == 0 {
 					return fmt.Errorf("no kubectl contexts found in kubeconfig")
 				}
 
-				prompt := promptui.Select{
-					Label: "Select kubectl context",
-					Items: contextNames,
-				}
-
+				prompt := newSelectPrompt("Select kubectl context", contextNames)
 				_, selectedKubeContext, err = prompt.Run()
 				if err != nil {
 					return fmt.Errorf("kubectl context selection failed: %w", err)
 				}
 			}
 
-			// Load kubeconfig with the selected context
-			var err error
-			restConfig, err = clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
-				loadingRules,
-				&clientcmd.ConfigOverrides{CurrentContext: selectedKubeContext},
-			).ClientConfig()
+			// Load kubeconfig with the selected context and create the Kubernetes clients
+			client, err = clients.get(selectedKubeContext)
 			if err != nil {
-				return fmt.Errorf("failed to load kubeconfig: %w", err)
+				return err
 			}
+		}
+
+		// Expand templated source names now that the contexts are known
+		pathData := newOutputPathData(selectedContexts, selectedKubeContext)
+		filteredSources, err = renderSourceNames(filteredSources, pathData)
+		if err != nil {
+			return err
+		}
 
-			// Create Kubernetes client
-			clientset, err = kubernetes.NewForConfig(restConfig)
+		// Cancelled on Ctrl+C or when --timeout expires
+		ctx := cmd.Context()
+
+		// Render the output directory template and nest it under the context name if requested
+		outputDirectory := outputDirectory
+		if directory := selectedProfile(config).Directory; directory != "" && !cmd.Flags().Changed("output-directory") {
+			outputDirectory = directory
+		}
+		if outputDirectory != stdoutOutput {
+			outputDirectory, err = resolveOutputDirectory(outputDirectory, pathData, perContextDir)
 			if err != nil {
-				return fmt.Errorf("failed to create kubernetes client: %w", err)
+				return err
 			}
 		}
-
-		// Map of source types to their fetchers
-		fetchers := map[string]sources.Fetcher{
-			"ConfigMap":   &sources.ConfigMapFetcher{},
-			"Secret":      &sources.SecretFetcher{},
-			"EnvFile":     &sources.EnvFileFetcher{},
-			"Vars":        &sources.VarsFetcher{},
-			"Deployment":  &sources.DeploymentFetcher{},
-			"StatefulSet": &sources.StatefulSetFetcher{},
-			"DaemonSet":   &sources.DaemonSetFetcher{},
-			"Container":   sources.NewContainerFetcher(restConfig),
+		outputName, err := renderOutputPath(outputName, pathData)
+		if err != nil {
+			return err
 		}
 
-		// Collect all env vars with their source info
-		var envData []sources.EnvEntry
+		// Collect all env vars with their source info, files written while fetching get the output mode
+		fetchCtx, err := withOutputMode(ctx, outputMode)
+		if err != nil {
+			return err
+		}
+		selection := sourceSelection(selectedContexts, client, clients, fileDirectory(outputDirectory), rbacCheck)
+		envData, sourceOutputs, err := fetchSources(fetchCtx, filteredSources, selection, false)
+		if err != nil {
+			return err
+		}
 
-		// Get each source and collect its data
-		for _, source := range filteredSources {
-			if source.Type == "" {
-				return fmt.Errorf("type is required for source %q in namespace %q", source.Name, source.GetNamespace())
+		// An env file streamed to stdout keeps stdout to itself
+		if verbose {
+			statusOut := os.Stdout
+			if outputDirectory == stdoutOutput {
+				statusOut = os.Stderr
 			}
-
-			fetcher, ok := fetchers[source.Type]
-			if !ok {
-				return fmt.Errorf("unknown source type %q for %s/%s", source.Type, source.GetNamespace(), source.Name)
+			for _, output := range sourceOutputs {
+				fmt.Fprintf(statusOut, "Fetched %d variables from %s\n", len(output.Entries), describeSource(output.Source))
 			}
+		}
 
-			entries, err := fetcher.Fetch(clientset, source, outputDirectory)
-			if err != nil {
-				return err
-			}
+		// Variables that are missing because their value is empty would otherwise go unnoticed
+		if skippedEmpty, _ := describeSkippedEmpty(sourceOutputs); len(skippedEmpty) > 0 {
+			warnf("Warning: skipped variables with an empty value (set includeEmpty: true to keep them): %s\n", strings.Join(skippedEmpty, "; "))
+		}
+
+		// Handle keys emitted by more than one source
+		envData, conflicts, err := resolveConflicts(envData, onConflict)
+		if err != nil {
+			return err
+		}
+		if len(conflicts) > 0 {
+			warnf("Warning: keys emitted by more than one source (%s): %s\n", onConflict, strings.Join(conflicts, ", "))
+		}
 
-			envData = append(envData, entries...)
+		// Fail before anything is written if a value doesn't satisfy its validation
+		if err := validateValues(envData, config.Validations); err != nil {
+			return err
 		}
 
 		// Build output path from directory and name
 		outputPath := filepath.Join(outputDirectory, outputName)
 
-		// Create output directory if it doesn't exist
-		if err := os.MkdirAll(outputDirectory, 0755); err != nil {
-			return fmt.Errorf("failed to create output directory: %w", err)
+		// Only report what would be written
+		if dryRun {
+			fmt.Print(renderDryRunSummary("", outputPath, envData, sourceOutputs))
+			return nil
 		}
 
-		// Write to output file with comments (one comment per source)
-		var sb strings.Builder
-		var lastSource string
-		for _, entry := range envData {
-			var currentSource string
-			if entry.Namespace != "" {
-				currentSource = fmt.Sprintf("%s %s/%s", entry.SourceType, entry.Namespace, entry.Name)
-			} else {
-				currentSource = fmt.Sprintf("%s %s", entry.SourceType, entry.Name)
+		// Stream to stdout without creating a directory or touching .gitignore
+		format := output.ResolveFormat(outputFormat, outputName)
+		writeOptions := output.RenderOptions{Export: exportVars}
+		if outputDirectory == stdoutOutput {
+			masked, skipped := output.Render(consoleMasker.mask(envData), format, writeOptions)
+			if len(skipped) > 0 {
+				warnf("Warning: skipped keys that are not valid %s variable names: %s\n", format, strings.Join(skipped, ", "))
 			}
-			if currentSource != lastSource {
-				if lastSource != "" {
-					sb.WriteString("\n")
-				}
-				fmt.Fprintf(&sb, "# %s\n", currentSource)
-				lastSource = currentSource
-			}
-			fmt.Fprintf(&sb, "%s=%s\n", entry.Key, entry.Value)
+			fmt.Print(masked)
+			return nil
 		}
-		if err := os.WriteFile(outputPath, []byte(sb.String()), 0644); err != nil {
-			return fmt.Errorf("failed to write output file: %w", err)
-		}
-
-		fmt.Printf("Wrote %d environment variables to %s\n", len(envData), outputPath)
 
-		// Check if output file should be added to .gitignore
-		if err := gitutil.EnsureGitignored(outputPath); err != nil {
-			return err
+		// Write to output file with comments (one comment per source), and with --explode one
+		// additional file per source for debugging
+		result, err := output.Write(envData, sourceEntries(sourceOutputs), output.Options{
+			Directory:     outputDirectory,
+			Name:          outputName,
+			Format:        format,
+			RenderOptions: writeOptions,
+			Mode:          outputMode,
+			Explode:       explode,
+			FailIfExists:  failIfExists,
+		})
+		if len(result.Skipped) > 0 {
+			warnf("Warning: skipped keys that are not valid %s variable names: %s\n", format, strings.Join(result.Skipped, ", "))
+		}
+		if result.Path != "" {
+			infof("Wrote %d environment variables to %s\n", len(envData), result.Path)
+		}
+		for _, sourcePath := range result.SourcePaths {
+			infof("Wrote source file %s\n", sourcePath)
 		}
-		return nil
+		return err
 	},
 }
 
 func init() {
-	generateCmd.Flags().StringVarP(&inputFile, "input", "i", "", "input configuration file (default .enver.yaml)")
+	addDeprecatedInputFlag(generateCmd, &inputFile)
 	generateCmd.Flags().StringVar(&kubeContext, "kube-context", "", "kubectl context to use (prompts if needed and not provided)")
 	generateCmd.Flags().StringVar(&outputName, "output-name", ".env", "output file name")
-	generateCmd.Flags().StringVar(&outputDirectory, "output-directory", "generated", "output directory for the .env file")
+	generateCmd.Flags().StringVar(&outputDirectory, "output-directory", "generated", "output directory for the .env file (supports {{ .Context }} and {{ .KubeContext }}, - writes to stdout)")
+	generateCmd.Flags().StringVar(&outputFormat, "format", "", "format of the output file: env or tfvars (default detected from the output name's extension)")
+	generateCmd.Flags().BoolVar(&exportVars, "export", false, "prefix each variable with \"export \"")
+	generateCmd.Flags().BoolVar(&explode, "explode", false, "also write one file per source (<sourceType>-<name>.env) to the output directory")
+	generateCmd.Flags().StringVar(&outputMode, "output-mode", "", "octal permissions of the written files (default 0600 if a Secret is included, 0644 otherwise)")
+	generateCmd.Flags().BoolVar(&perContextDir, "per-context-dir", false, "nest the output directory under the selected context name")
+	generateCmd.Flags().StringVar(&onConflict, "on-conflict", conflictKeepAll, "how to handle keys emitted by more than one source: keep-all, last-wins, first-wins or error")
+	generateCmd.Flags().BoolVar(&mask, "mask", false, "replace the values of Secrets with **** when writing to stdout")
+	generateCmd.Flags().StringVar(&maskPattern, "mask-pattern", "", "also mask the values of keys matching this regex (implies --mask)")
+	generateCmd.Flags().StringArrayVar(&includeVars, "include-var", []string{}, "only keep variables matching this pattern, in addition to the sources' own filters (can be repeated)")
+	generateCmd.Flags().StringArrayVar(&excludeVars, "exclude-var", []string{}, "drop variables matching this pattern, in addition to the sources' own filters (can be repeated)")
+	generateCmd.Flags().BoolVar(&failIfExists, "fail-if-exists", false, "fail instead of overwriting output files that already exist")
+	generateCmd.Flags().BoolVar(&dryRun, "dry-run", false, "fetch all sources and print how many variables each contributes without writing any file")
+	generateCmd.Flags().BoolVar(&rbacCheck, "rbac-check", false, "check RBAC permissions for all sources before fetching")
+	generateCmd.Flags().BoolVar(&checkNamespacesFlag, "check-namespaces", false, "check that the namespaces of all sources exist before fetching (needs get access on namespaces)")
 	generateCmd.Flags().StringArrayVarP(&contextFlags, "context", "c", []string{}, "context for filtering sources (can be repeated, prompts if not provided and contexts are defined)")
+	generateCmd.RegisterFlagCompletionFunc("context", completeContexts(&inputFile))
 	rootCmd.AddCommand(generateCmd)
 }
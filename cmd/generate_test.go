@@ -0,0 +1,285 @@
+package cmd
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"enver/gitutil"
+)
+
+func TestGenerateOutputNameAndDirectory(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	config := `sources:
+  - type: Vars
+    name: inline
+    vars:
+      - name: HOST
+        value: localhost
+`
+	if err := os.WriteFile(".enver.yaml", []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { outputName = ".env"; outputDirectory = "generated" }()
+
+	rootCmd.SetArgs([]string{"generate", "--output-name", "app.env", "--output-directory", "out"})
+	captureStdout(t, func() {
+		if err := rootCmd.Execute(); err != nil {
+			t.Fatalf("generate returned error: %v", err)
+		}
+	})
+
+	content, err := os.ReadFile(filepath.Join("out", "app.env"))
+	if err != nil {
+		t.Fatalf("expected out/app.env: %v", err)
+	}
+	if expected := "# Vars inline\nHOST=localhost\n"; string(content) != expected {
+		t.Errorf("expected %q, got %q", expected, string(content))
+	}
+}
+
+func TestGenerateAndExecuteWriteIdenticalFiles(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	config := `sources:
+  - type: Vars
+    name: app
+    vars:
+      - name: PORT
+        value: "8080"
+      - name: HOST
+        value: localhost
+  - type: Vars
+    name: db
+    vars:
+      - name: DB_URL
+        value: postgres://db:5432/app?sslmode=disable
+      - name: GREETING
+        value: hello world
+executions:
+  - name: local
+    output:
+      directory: executed
+`
+	if err := os.WriteFile(".enver.yaml", []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { outputDirectory = "generated"; executeAll = false }()
+
+	captureStdout(t, func() {
+		rootCmd.SetArgs([]string{"generate", "--output-directory", "generated"})
+		if err := rootCmd.Execute(); err != nil {
+			t.Fatalf("generate returned error: %v", err)
+		}
+		rootCmd.SetArgs([]string{"execute", "--all"})
+		if err := rootCmd.Execute(); err != nil {
+			t.Fatalf("execute returned error: %v", err)
+		}
+	})
+
+	generated, err := os.ReadFile(filepath.Join("generated", ".env"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	executed, err := os.ReadFile(filepath.Join("executed", ".env"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "# Vars app\nHOST=localhost\nPORT=8080\n\n# Vars db\nDB_URL=postgres://db:5432/app?sslmode=disable\nGREETING=\"hello world\"\n"
+	if string(generated) != expected {
+		t.Errorf("generate: expected %q, got %q", expected, string(generated))
+	}
+	if string(executed) != string(generated) {
+		t.Errorf("expected execute to write the same file as generate, got %q and %q", string(executed), string(generated))
+	}
+}
+
+func TestQuietAndVerboseStatusMessages(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	config := `sources:
+  - type: Vars
+    name: inline
+    vars:
+      - name: HOST
+        value: localhost
+executions:
+  - name: local
+`
+	if err := os.WriteFile(".enver.yaml", []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// Cobra doesn't reset which flags were set between runs, which the mutual exclusion checks
+	reset := func() {
+		quiet, verbose = false, false
+		rootCmd.PersistentFlags().Lookup("quiet").Changed = false
+		rootCmd.PersistentFlags().Lookup("verbose").Changed = false
+	}
+	defer func() { reset(); executeAll = false }()
+
+	tests := []struct {
+		args     []string
+		expected string
+	}{
+		{args: []string{"generate", "--quiet"}, expected: ""},
+		{args: []string{"execute", "--all", "--quiet"}, expected: ""},
+		{args: []string{"generate", "--verbose"}, expected: "Fetched 1 variables from Vars inline\nWrote 1 environment variables to generated/.env\n"},
+	}
+	for _, tt := range tests {
+		reset()
+		rootCmd.SetArgs(tt.args)
+		output := captureStdout(t, func() {
+			if err := rootCmd.Execute(); err != nil {
+				t.Errorf("%v returned error: %v", tt.args, err)
+			}
+		})
+		if output != tt.expected {
+			t.Errorf("%v: expected %q, got %q", tt.args, tt.expected, output)
+		}
+		if _, err := os.Stat(filepath.Join("generated", ".env")); err != nil {
+			t.Errorf("%v: expected generated/.env: %v", tt.args, err)
+		}
+		os.RemoveAll("generated")
+	}
+
+	// Both at once is rejected
+	reset()
+	rootCmd.SetArgs([]string{"generate", "--quiet", "--verbose"})
+	captureStdout(t, func() {
+		if err := rootCmd.Execute(); err == nil || !strings.Contains(err.Error(), "none of the others can be") {
+			t.Errorf("expected --quiet and --verbose to be mutually exclusive, got %v", err)
+		}
+	})
+}
+
+func TestQuietSilencesConflictWarnings(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	config := `sources:
+  - type: Vars
+    name: first
+    vars:
+      - name: HOST
+        value: localhost
+  - type: Vars
+    name: second
+    vars:
+      - name: HOST
+        value: example.com
+`
+	if err := os.WriteFile(".enver.yaml", []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	reset := func() {
+		quiet = false
+		rootCmd.PersistentFlags().Lookup("quiet").Changed = false
+	}
+	defer reset()
+
+	for _, quietFlag := range []bool{false, true} {
+		reset()
+		args := []string{"generate"}
+		if quietFlag {
+			args = append(args, "--quiet")
+		}
+		rootCmd.SetArgs(args)
+		warned := captureStderr(t, func() {
+			captureStdout(t, func() {
+				if err := rootCmd.Execute(); err != nil {
+					t.Errorf("%v returned error: %v", args, err)
+				}
+			})
+		})
+		if quietFlag && warned != "" {
+			t.Errorf("expected no warnings with --quiet, got %q", warned)
+		}
+		if !quietFlag && !strings.Contains(warned, "keys emitted by more than one source (keep-all): HOST") {
+			t.Errorf("expected a conflict warning, got %q", warned)
+		}
+	}
+}
+
+func TestGenerateFileTransformationUsesOutputMode(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	config := `sources:
+  - type: Vars
+    name: inline
+    vars:
+      - name: CERTIFICATE
+        value: certificate
+    transformations:
+      - type: file
+        output: cert.pem
+        key: CERTIFICATE_FILE
+`
+	if err := os.WriteFile(".enver.yaml", []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { outputMode = "" }()
+
+	rootCmd.SetArgs([]string{"generate", "--output-mode", "0640"})
+	captureStdout(t, func() {
+		if err := rootCmd.Execute(); err != nil {
+			t.Fatalf("generate returned error: %v", err)
+		}
+	})
+
+	for _, path := range []string{filepath.Join("generated", ".env"), filepath.Join("generated", "cert.pem")} {
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Mode().Perm() != 0640 {
+			t.Errorf("expected %s to have mode 640, got %o", path, info.Mode().Perm())
+		}
+	}
+}
+
+func TestGenerateToStdoutKeepsGitignoreMessagesOffStdout(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	t.Chdir(t.TempDir())
+	if output, err := exec.Command("git", "init", "-q").CombinedOutput(); err != nil {
+		t.Fatalf("git init failed: %v\n%s", err, output)
+	}
+
+	config := `sources:
+  - type: Vars
+    name: inline
+    vars:
+      - name: CERTIFICATE
+        value: "-----BEGIN CERTIFICATE-----"
+    transformations:
+      - type: file
+        output: cert.pem
+        key: CERTIFICATE_FILE
+`
+	if err := os.WriteFile(".enver.yaml", []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { outputDirectory = "generated"; gitignoreMode = gitutil.ModeAuto }()
+
+	rootCmd.SetArgs([]string{"generate", "--output-directory", "-", "--gitignore", "file"})
+	output := captureStdout(t, func() {
+		if err := rootCmd.Execute(); err != nil {
+			t.Errorf("generate returned error: %v", err)
+		}
+	})
+
+	// Only the env file is on stdout, the .gitignore entry is reported on stderr
+	if expected := "# Vars inline\nCERTIFICATE_FILE=cert.pem\n"; output != expected {
+		t.Errorf("expected %q, got %q", expected, output)
+	}
+	content, err := os.ReadFile(".gitignore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "cert.pem\n" {
+		t.Errorf("expected cert.pem to be added to .gitignore, got %q", content)
+	}
+}
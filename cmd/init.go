@@ -0,0 +1,215 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+
+	"github.com/spf13/cobra"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+var initOutputFile string
+var initForce bool
+var initWithKubeContext bool
+
+// initTemplate is the starter configuration written by enver init
+// It must stay in sync with the Source and Execution structs; TestInitTemplate parses it strictly.
+const initTemplate = `# yaml-language-server: $schema=https://raw.githubusercontent.com/kroonprins/enver/main/enver.schema.json
+
+# Contexts group sources so a subset can be selected with -c/--context or per execution
+contexts:
+  - local
+  - dev
+
+sources:
+  # Inline variables
+  - type: Vars
+    name: defaults
+    vars:
+      - name: LOG_LEVEL
+        value: info
+
+  # A local .env file, only used in the "local" context
+  - type: EnvFile
+    path: local.env
+    contexts:
+      include:
+        - local
+
+  # All keys of a ConfigMap
+  - type: ConfigMap
+    name: my-app-config
+    namespace: default
+    contexts:
+      include:
+        - dev
+
+  # All keys of a Secret, with filtering and a transformation
+  - type: Secret
+    name: my-app-secrets
+    namespace: default
+    variables:
+      exclude:
+        - ^INTERNAL_.*
+    transformations:
+      - type: prefix
+        target: key
+        value: APP_
+    contexts:
+      include:
+        - dev
+
+  # The env, envFrom and mounted ConfigMaps/Secrets of a workload
+  - type: Deployment    # or StatefulSet, DaemonSet
+    name: my-app
+    namespace: default
+    containers:
+      - app
+    contexts:
+      include:
+        - dev
+
+  - type: StatefulSet
+    name: my-database
+    namespace: default
+    contexts:
+      include:
+        - dev
+
+  - type: DaemonSet
+    name: my-agent
+    namespace: default
+    contexts:
+      include:
+        - dev
+
+  # The latest ready revision of a Knative Service
+  - type: KnativeService
+    name: my-service
+    namespace: default
+    contexts:
+      include:
+        - dev
+
+  # Annotations of a Deployment, e.g. app.version becomes APP_APP_VERSION
+  - type: Metadata
+    kind: Deployment    # or Pod, StatefulSet, DaemonSet, ConfigMap, Secret
+    name: my-app
+    namespace: default
+    keyPrefix: APP_
+    variables:
+      include:
+        - app.version
+    contexts:
+      include:
+        - dev
+
+  # Whichever ConfigMap, Secret, Deployment, StatefulSet or DaemonSet has this name
+  - type: auto
+    name: my-worker
+    namespace: default
+    contexts:
+      include:
+        - dev
+
+  # The live environment of a running container
+  - type: Container
+    kind: Deployment    # or Pod, StatefulSet, DaemonSet
+    name: my-app
+    namespace: default
+    containers:
+      - app
+    variables:
+      exclude:
+        - ^KUBERNETES_.*
+        - PATH
+        - HOSTNAME
+        - HOME
+    contexts:
+      include:
+        - dev
+
+# Predefined generation tasks, run with: enver execute --name <name>
+executions:
+  - name: local
+    output:
+      name: .env
+      directory: generated
+    contexts:
+      - local
+
+  - name: dev
+    output:
+      name: dev.env
+      directory: generated
+    kube-context: {{ printf "%q" .KubeContext }}
+    contexts:
+      - dev
+`
+
+var initCmd = &cobra.Command{
+	Use:   "init",
+	Short: "Create a starter .enver.yaml",
+	Long:  `Writes a commented starter .enver.yaml with an example of each source type and two executions. An existing file is not overwritten unless --force is given.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		configFile := configFilePath(initOutputFile)
+
+		if !initForce {
+			if _, err := os.Stat(configFile); err == nil {
+				return fmt.Errorf("%s already exists (use --force to overwrite)", configFile)
+			} else if !errors.Is(err, os.ErrNotExist) {
+				return fmt.Errorf("failed to check %s: %w", configFile, err)
+			}
+		}
+
+		kubeContext := "my-cluster"
+		if initWithKubeContext {
+			kubeConfig, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+				kubeconfigLoadingRules(),
+				&clientcmd.ConfigOverrides{},
+			).RawConfig()
+			if err != nil {
+				return fmt.Errorf("failed to load kubeconfig: %w", err)
+			}
+			if kubeConfig.CurrentContext == "" {
+				return fmt.Errorf("no current kubectl context set in kubeconfig")
+			}
+			kubeContext = kubeConfig.CurrentContext
+		}
+
+		content, err := renderInitTemplate(kubeContext)
+		if err != nil {
+			return err
+		}
+
+		if err := os.WriteFile(configFile, []byte(content), 0644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", configFile, err)
+		}
+
+		infof("Wrote %s\n", configFile)
+		return nil
+	},
+}
+
+// renderInitTemplate renders the starter configuration with the given kube context
+func renderInitTemplate(kubeContext string) (string, error) {
+	tmpl, err := template.New("init").Parse(initTemplate)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse init template: %w", err)
+	}
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, struct{ KubeContext string }{kubeContext}); err != nil {
+		return "", fmt.Errorf("failed to render init template: %w", err)
+	}
+	return sb.String(), nil
+}
+
+func init() {
+	initCmd.Flags().StringVarP(&initOutputFile, "output", "o", "", "configuration file to create (default the --config file)")
+	initCmd.Flags().BoolVar(&initForce, "force", false, "overwrite an existing file")
+	initCmd.Flags().BoolVar(&initWithKubeContext, "with-kube-context", false, "use the current kubectl context for the dev execution")
+	rootCmd.AddCommand(initCmd)
+}
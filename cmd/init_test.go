@@ -0,0 +1,45 @@
+package cmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"enver/engine"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestInitTemplate(t *testing.T) {
+	content, err := renderInitTemplate("kind-kind")
+	if err != nil {
+		t.Fatalf("renderInitTemplate returned error: %v", err)
+	}
+
+	// Strict decoding fails on fields that don't exist in the config structs
+	var config ExecuteConfig
+	decoder := yaml.NewDecoder(bytes.NewReader([]byte(content)))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&config); err != nil {
+		t.Fatalf("init template does not match the config structs: %v", err)
+	}
+
+	if problems := validateConfig(&config); len(problems) > 0 {
+		t.Errorf("expected the init template to be valid, got:\n%s", strings.Join(problems, "\n"))
+	}
+
+	// Every supported source type has an example
+	types := make(map[string]bool)
+	for _, source := range config.Sources {
+		types[source.Type] = true
+	}
+	for sourceType := range engine.Fetchers(nil) {
+		if !types[sourceType] {
+			t.Errorf("init template has no example of source type %s", sourceType)
+		}
+	}
+
+	if config.Executions[1].KubeContext != "kind-kind" {
+		t.Errorf("expected the kube context to be filled in, got %q", config.Executions[1].KubeContext)
+	}
+}
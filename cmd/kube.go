@@ -0,0 +1,273 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"enver/engine"
+	"enver/output"
+	"enver/sources"
+
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+type kubeClientEntry struct {
+	engine.Client          // the clientset caches ConfigMap and Secret GETs for the lifetime of the entry
+	namespaces    sync.Map // namespace -> error of its existence check, nil if it exists
+}
+
+// newKubeClient creates the typed and dynamic Kubernetes clients for a rest config
+// Entries are created once per run and kube context, which scopes the ConfigMap and Secret cache
+func newKubeClient(restConfig *rest.Config) (*kubeClientEntry, error) {
+	// client-go's defaults of 5 QPS and a burst of 10 throttle large executions
+	restConfig.QPS = clientQPS
+	restConfig.Burst = clientBurst
+
+	clientset, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create kubernetes client: %w", err)
+	}
+
+	dynamicClient, err := dynamic.NewForConfig(restConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create dynamic kubernetes client: %w", err)
+	}
+
+	return &kubeClientEntry{Client: engine.Client{
+		Clientset:  sources.NewCachingClientset(clientset),
+		Dynamic:    dynamicClient,
+		RESTConfig: restConfig,
+	}}, nil
+}
+
+// kubeconfigLoadingRules returns the rules for finding the kubeconfig: the --kubeconfig flag if set,
+// otherwise the KUBECONFIG environment variable or ~/.kube/config
+func kubeconfigLoadingRules() *clientcmd.ClientConfigLoadingRules {
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	loadingRules.ExplicitPath = kubeconfigPath
+	return loadingRules
+}
+
+// kubeContextNames returns the sorted names of the contexts in the kubeconfig
+func kubeContextNames(loadingRules *clientcmd.ClientConfigLoadingRules) ([]string, error) {
+	kubeConfig, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+		loadingRules,
+		&clientcmd.ConfigOverrides{},
+	).RawConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load kubeconfig: %w", err)
+	}
+
+	var contextNames []string
+	for name := range kubeConfig.Contexts {
+		contextNames = append(contextNames, name)
+	}
+	sort.Strings(contextNames)
+	return contextNames, nil
+}
+
+// loadRESTConfig loads the kubeconfig with the given context, or the in-cluster config of the pod
+// enver runs in when --in-cluster is set, in which case the context is ignored
+func loadRESTConfig(loadingRules *clientcmd.ClientConfigLoadingRules, kubeContext string) (*rest.Config, error) {
+	if inCluster {
+		restConfig, err := rest.InClusterConfig()
+		if err != nil {
+			return nil, fmt.Errorf("failed to load in-cluster config: %w", err)
+		}
+		return restConfig, nil
+	}
+	return loadKubeconfig(loadingRules, kubeContext)
+}
+
+// loadKubeconfig loads the kubeconfig with the given context, the current context if empty
+func loadKubeconfig(loadingRules *clientcmd.ClientConfigLoadingRules, kubeContext string) (*rest.Config, error) {
+	restConfig, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+		loadingRules,
+		&clientcmd.ConfigOverrides{CurrentContext: kubeContext},
+	).ClientConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load kubeconfig: %w", err)
+	}
+	return restConfig, nil
+}
+
+// kubeClientCache creates Kubernetes clients once per cluster and shares them between concurrent executions
+type kubeClientCache struct {
+	loadingRules *clientcmd.ClientConfigLoadingRules
+	clients      sync.Map
+	mu           sync.Mutex
+}
+
+func newKubeClientCache(loadingRules *clientcmd.ClientConfigLoadingRules) *kubeClientCache {
+	return &kubeClientCache{loadingRules: loadingRules}
+}
+
+// get returns the cached clients for the kube context of an execution, creating them if needed
+func (c *kubeClientCache) get(kubeContext string) (*kubeClientEntry, error) {
+	return c.load("execution\x00"+kubeContext, func() (*rest.Config, error) {
+		return loadRESTConfig(c.loadingRules, kubeContext)
+	})
+}
+
+// getForSource returns the cached clients for a source with its own kubeconfig and/or kube context.
+// An explicit kubeconfig replaces the default loading rules and is used even with --in-cluster.
+func (c *kubeClientCache) getForSource(kubeconfig, kubeContext string) (*kubeClientEntry, error) {
+	return c.load("source\x00"+kubeconfig+"\x00"+kubeContext, func() (*rest.Config, error) {
+		loadingRules := c.loadingRules
+		if kubeconfig != "" {
+			loadingRules = &clientcmd.ClientConfigLoadingRules{ExplicitPath: kubeconfig}
+		}
+		return loadKubeconfig(loadingRules, kubeContext)
+	})
+}
+
+// load returns the cached clients for the key, creating them from the loaded rest config if needed
+func (c *kubeClientCache) load(key string, loadConfig func() (*rest.Config, error)) (*kubeClientEntry, error) {
+	// Check cache first
+	if cached, ok := c.clients.Load(key); ok {
+		return cached.(*kubeClientEntry), nil
+	}
+
+	// Use mutex to prevent duplicate client creation
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// Double-check after acquiring lock
+	if cached, ok := c.clients.Load(key); ok {
+		return cached.(*kubeClientEntry), nil
+	}
+
+	restConfig, err := loadConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	// Create Kubernetes clients
+	client, err := newKubeClient(restConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	// Cache the clients together with their restConfig
+	c.clients.Store(key, client)
+	return client, nil
+}
+
+// sourceClient returns the clients of a source with its own kubeconfig or kube context, see
+// engine.Selection.SourceClient
+func (c *kubeClientCache) sourceClient(source sources.Source) (*engine.Client, error) {
+	client, err := c.getForSource(source.Kubeconfig, source.KubeContext)
+	if err != nil {
+		return nil, err
+	}
+	return &client.Client, nil
+}
+
+// entries returns the cached entry of each engine client, nil for a nil client
+func (c *kubeClientCache) entries(clients []*engine.Client) []*kubeClientEntry {
+	byClient := make(map[*engine.Client]*kubeClientEntry)
+	c.clients.Range(func(_, value any) bool {
+		entry := value.(*kubeClientEntry)
+		byClient[&entry.Client] = entry
+		return true
+	})
+
+	entries := make([]*kubeClientEntry, len(clients))
+	for i, client := range clients {
+		entries[i] = byClient[client]
+	}
+	return entries
+}
+
+// sourceSelection returns the engine selection of the sources included in contexts. Sources without
+// their own cluster are fetched with client, nil when none of them needs Kubernetes, the others with
+// clients from the cache. Before fetching, the RBAC preflight runs if rbacCheck is set and the
+// namespaces are checked with --check-namespaces.
+func sourceSelection(contexts []string, client *kubeClientEntry, clients *kubeClientCache, outputDirectory string, rbacCheck bool) engine.Selection {
+	selection := engine.Selection{
+		Contexts:        contexts,
+		SourceClient:    clients.sourceClient,
+		Variables:       variableFilter(),
+		OutputDirectory: outputDirectory,
+		Concurrency:     fetchConcurrency,
+	}
+	if client != nil {
+		selection.Client = &client.Client
+	}
+
+	if rbacCheck || checkNamespacesFlag {
+		selection.Preflight = func(ctx context.Context, configSources []sources.Source, engineClients []*engine.Client) error {
+			sourceClients := clients.entries(engineClients)
+			if rbacCheck {
+				if err := checkSourceAccessPerCluster(ctx, configSources, sourceClients); err != nil {
+					return err
+				}
+			}
+			if checkNamespacesFlag {
+				return checkNamespaces(ctx, configSources, sourceClients)
+			}
+			return nil
+		}
+	}
+	return selection
+}
+
+// fetchSources resolves the selected sources with the engine and returns all entries plus the
+// entries per source
+// Sources are fetched concurrently, at most selection.Concurrency at a time, but the entries keep
+// the declaration order of the sources and the error of the first failing source is returned.
+// With continueOnError the entries of the sources that could be fetched are returned together with
+// a *partialFetchError listing every source that failed.
+func fetchSources(ctx context.Context, configSources []sources.Source, selection engine.Selection, continueOnError bool) ([]sources.EnvEntry, []sourceOutput, error) {
+	results, err := engine.ResolveSources(ctx, configSources, selection)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var envData []sources.EnvEntry
+	var sourceOutputs []sourceOutput
+	var failures []string
+	for _, result := range results {
+		if result.Err != nil {
+			if !continueOnError {
+				return nil, nil, result.Err
+			}
+			failures = append(failures, fmt.Sprintf("%s: %v", describeSource(result.Source), result.Err))
+			continue
+		}
+		envData = append(envData, result.Entries...)
+		sourceOutputs = append(sourceOutputs, sourceOutput{SourceEntries: output.SourceEntries{Source: result.Source, Entries: result.Entries}, SkippedEmpty: result.SkippedEmpty})
+	}
+
+	if len(failures) > 0 {
+		return envData, sourceOutputs, &partialFetchError{failures: failures, total: len(results)}
+	}
+	return envData, sourceOutputs, nil
+}
+
+// variableFilter returns the filter of --include-var and --exclude-var, nil when neither is set.
+// The patterns match like a source's variables.include and variables.exclude and narrow down what
+// the sources themselves keep. They are checked while fetching, so transformations don't write
+// files for filtered variables.
+func variableFilter() *sources.SourceVariables {
+	if len(includeVars) == 0 && len(excludeVars) == 0 {
+		return nil
+	}
+	return &sources.SourceVariables{Include: includeVars, Exclude: excludeVars}
+}
+
+// partialFetchError reports the sources that failed while the others were fetched
+type partialFetchError struct {
+	failures []string
+	total    int
+}
+
+func (e *partialFetchError) Error() string {
+	return fmt.Sprintf("%d of %d sources failed:\n    %s", len(e.failures), e.total, strings.Join(e.failures, "\n    "))
+}
@@ -0,0 +1,498 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"enver/engine"
+	"enver/output"
+	"enver/sources"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// newFakeCluster starts an API server serving a single ConfigMap and returns a kubeconfig file pointing at it
+func newFakeCluster(t *testing.T, configMap *corev1.ConfigMap) string {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/namespaces/"+configMap.Namespace+"/configmaps/"+configMap.Name {
+			http.NotFound(w, r)
+			return
+		}
+		configMap.APIVersion, configMap.Kind = "v1", "ConfigMap"
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(configMap)
+	}))
+	t.Cleanup(server.Close)
+
+	kubeconfig := filepath.Join(t.TempDir(), "kubeconfig")
+	content := `apiVersion: v1
+kind: Config
+clusters:
+  - name: fake
+    cluster:
+      server: ` + server.URL + `
+contexts:
+  - name: fake
+    context:
+      cluster: fake
+      user: fake
+current-context: fake
+users:
+  - name: fake
+    user:
+      token: fake
+`
+	if err := os.WriteFile(kubeconfig, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return kubeconfig
+}
+
+func TestCollectExecutionFetchesSourcesFromTheirOwnKubeconfig(t *testing.T) {
+	clusterA := newFakeCluster(t, &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "settings", Namespace: "default"},
+		Data:       map[string]string{"REGION": "eu"},
+	})
+	clusterB := newFakeCluster(t, &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "settings", Namespace: "default"},
+		Data:       map[string]string{"FEATURE_FLAGS": "beta"},
+	})
+
+	configSources := []sources.Source{
+		{Type: "ConfigMap", Name: "settings", Kubeconfig: clusterA},
+		{Type: "ConfigMap", Name: "settings", Kubeconfig: clusterB},
+	}
+	clients := newKubeClientCache(clientcmd.NewDefaultClientConfigLoadingRules())
+
+	// No kube-context is needed on the execution when every Kubernetes source has its own cluster
+	execution := Execution{Name: "multi-cluster"}
+	envData, _, err := collectExecution(t.Context(), execution, configSources, clients, t.TempDir(), false, false)
+	if err != nil {
+		t.Fatalf("collectExecution returned error: %v", err)
+	}
+
+	rendered, _ := output.Render(envData, output.FormatEnv, output.RenderOptions{})
+	expected := "# ConfigMap default/settings\nFEATURE_FLAGS=beta\nREGION=eu\n"
+	if rendered != expected {
+		t.Errorf("expected %q, got %q", expected, rendered)
+	}
+
+	// Each kubeconfig gets its own cached client
+	clientA, err := clients.getForSource(clusterA, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientB, err := clients.getForSource(clusterB, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if clientA == clientB {
+		t.Error("expected different clients for different kubeconfig files")
+	}
+}
+
+func TestKubeContextNamesHonorsKubeconfig(t *testing.T) {
+	writeKubeconfig := func(contexts ...string) string {
+		content := "apiVersion: v1\nkind: Config\nclusters:\n  - name: fake\n    cluster:\n      server: https://127.0.0.1:6443\ncontexts:\n"
+		for _, context := range contexts {
+			content += "  - name: " + context + "\n    context:\n      cluster: fake\n"
+		}
+		path := filepath.Join(t.TempDir(), "kubeconfig")
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	t.Setenv("KUBECONFIG", writeKubeconfig("staging", "dev"))
+	names, err := kubeContextNames(kubeconfigLoadingRules())
+	if err != nil {
+		t.Fatalf("kubeContextNames returned error: %v", err)
+	}
+	if strings.Join(names, ",") != "dev,staging" {
+		t.Errorf("expected the contexts of KUBECONFIG, got %v", names)
+	}
+
+	// --kubeconfig takes precedence over KUBECONFIG
+	kubeconfigPath = writeKubeconfig("prod")
+	defer func() { kubeconfigPath = "" }()
+	names, err = kubeContextNames(kubeconfigLoadingRules())
+	if err != nil {
+		t.Fatalf("kubeContextNames returned error: %v", err)
+	}
+	if strings.Join(names, ",") != "prod" {
+		t.Errorf("expected the contexts of --kubeconfig, got %v", names)
+	}
+}
+
+func TestFetchSourcesKeepsDeclarationOrder(t *testing.T) {
+	// Earlier ConfigMaps answer slower, so concurrent fetches finish in reverse order
+	const count = 6
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := filepath.Base(r.URL.Path)
+		var index int
+		fmt.Sscanf(name, "config-%d", &index)
+		time.Sleep(time.Duration(count-index) * 10 * time.Millisecond)
+
+		configMap := &corev1.ConfigMap{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "ConfigMap"},
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Data:       map[string]string{fmt.Sprintf("KEY_%d", index): name},
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(configMap)
+	}))
+	defer server.Close()
+
+	client, err := newKubeClient(&rest.Config{Host: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var configSources []sources.Source
+	var expected []string
+	for i := 0; i < count; i++ {
+		configSources = append(configSources, sources.Source{Type: "ConfigMap", Name: fmt.Sprintf("config-%d", i)})
+		expected = append(expected, fmt.Sprintf("KEY_%d", i))
+	}
+
+	selection := engine.Selection{Client: &client.Client, OutputDirectory: t.TempDir(), Concurrency: 3}
+	envData, sourceOutputs, err := fetchSources(t.Context(), configSources, selection, false)
+	if err != nil {
+		t.Fatalf("fetchSources returned error: %v", err)
+	}
+
+	var keys []string
+	for _, entry := range envData {
+		keys = append(keys, entry.Key)
+	}
+	if strings.Join(keys, ",") != strings.Join(expected, ",") {
+		t.Errorf("expected keys in declaration order %v, got %v", expected, keys)
+	}
+	for i, output := range sourceOutputs {
+		if output.Source.Name != configSources[i].Name {
+			t.Errorf("source output %d: expected %s, got %s", i, configSources[i].Name, output.Source.Name)
+		}
+	}
+}
+
+func TestNewKubeClientAppliesRateLimits(t *testing.T) {
+	defer func(qps float32, burst int) { clientQPS, clientBurst = qps, burst }(clientQPS, clientBurst)
+	clientQPS, clientBurst = 50, 100
+
+	client, err := newKubeClient(&rest.Config{Host: "https://127.0.0.1:6443"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if client.RESTConfig.QPS != 50 || client.RESTConfig.Burst != 100 {
+		t.Errorf("expected QPS 50 and burst 100, got %v and %d", client.RESTConfig.QPS, client.RESTConfig.Burst)
+	}
+}
+
+func TestExecuteTimeoutAbortsHangingRequests(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	// An API server that never answers
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	kubeconfig := `apiVersion: v1
+kind: Config
+clusters:
+  - name: hanging
+    cluster:
+      server: ` + server.URL + `
+contexts:
+  - name: hanging
+    context:
+      cluster: hanging
+      user: hanging
+current-context: hanging
+users:
+  - name: hanging
+    user:
+      token: fake
+`
+	if err := os.WriteFile("kubeconfig", []byte(kubeconfig), 0600); err != nil {
+		t.Fatal(err)
+	}
+	config := `sources:
+  - type: ConfigMap
+    name: settings
+    kubeconfig: kubeconfig
+executions:
+  - name: hanging
+`
+	if err := os.WriteFile(".enver.yaml", []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// The deadline stays on the command, later tests must start without it
+	defer func() { timeout = 0; executeAll = false; executeCmd.SetContext(nil) }()
+
+	start := time.Now()
+	rootCmd.SetArgs([]string{"execute", "--all", "--timeout", "200ms"})
+	err := rootCmd.Execute()
+	if err == nil || !strings.Contains(err.Error(), "context deadline exceeded") {
+		t.Fatalf("expected the execution to time out, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("expected the timeout to abort quickly, took %s", elapsed)
+	}
+}
+
+func TestExecuteKubeContextOverride(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	// One kubeconfig with a context per fake cluster
+	serverURL := func(region string) string {
+		kubeconfig := newFakeCluster(t, &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "settings", Namespace: "default"},
+			Data:       map[string]string{"REGION": region},
+		})
+		loaded, err := clientcmd.LoadFromFile(kubeconfig)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return loaded.Clusters["fake"].Server
+	}
+	kubeconfig := fmt.Sprintf(`apiVersion: v1
+kind: Config
+clusters:
+  - name: dev
+    cluster:
+      server: %s
+  - name: prod
+    cluster:
+      server: %s
+contexts:
+  - name: dev
+    context:
+      cluster: dev
+      user: fake
+  - name: prod
+    context:
+      cluster: prod
+      user: fake
+current-context: dev
+users:
+  - name: fake
+    user:
+      token: fake
+`, serverURL("dev"), serverURL("prod"))
+	if err := os.WriteFile("kubeconfig", []byte(kubeconfig), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	config := `sources:
+  - type: ConfigMap
+    name: settings
+executions:
+  - name: app
+    output:
+      name: app.env
+    kube-context: dev
+  - name: worker
+    output:
+      name: worker.env
+    kube-context: dev
+`
+	if err := os.WriteFile(".enver.yaml", []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { executeAll = false; executeKubeContexts = nil; kubeconfigPath = "" }()
+
+	testCases := []struct {
+		overrides []string
+		expected  map[string]string
+	}{
+		{overrides: nil, expected: map[string]string{"app.env": "dev", "worker.env": "dev"}},
+		{overrides: []string{"prod"}, expected: map[string]string{"app.env": "prod", "worker.env": "prod"}},
+		{overrides: []string{"worker=prod"}, expected: map[string]string{"app.env": "dev", "worker.env": "prod"}},
+	}
+
+	for _, tc := range testCases {
+		executeKubeContexts = nil
+		args := []string{"execute", "--all", "--kubeconfig", "kubeconfig"}
+		for _, override := range tc.overrides {
+			args = append(args, "--kube-context", override)
+		}
+		rootCmd.SetArgs(args)
+		if err := rootCmd.Execute(); err != nil {
+			t.Fatalf("%v returned error: %v", tc.overrides, err)
+		}
+
+		for fileName, region := range tc.expected {
+			content, err := os.ReadFile(filepath.Join("generated", fileName))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(string(content), "REGION="+region+"\n") {
+				t.Errorf("%v: expected %s to be read from %s, got:\n%s", tc.overrides, fileName, region, content)
+			}
+		}
+	}
+
+	if _, err := overrideKubeContexts(nil, nil, []string{"missing=prod"}); err == nil {
+		t.Error("expected an error for an unknown execution")
+	}
+}
+
+func TestExecuteParallelismBoundsConcurrentExecutions(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	// Every execution fetches its own ConfigMap, which answers slowly so executions overlap
+	var mu sync.Mutex
+	inFlight, maxInFlight := 0, 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		inFlight++
+		maxInFlight = max(maxInFlight, inFlight)
+		mu.Unlock()
+		defer func() {
+			mu.Lock()
+			inFlight--
+			mu.Unlock()
+		}()
+		time.Sleep(30 * time.Millisecond)
+
+		name := filepath.Base(r.URL.Path)
+		configMap := &corev1.ConfigMap{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "ConfigMap"},
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+			Data:       map[string]string{"NAME": name},
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(configMap)
+	}))
+	defer server.Close()
+
+	kubeconfig := `apiVersion: v1
+kind: Config
+clusters:
+  - name: fake
+    cluster:
+      server: ` + server.URL + `
+contexts:
+  - name: fake
+    context:
+      cluster: fake
+      user: fake
+current-context: fake
+users:
+  - name: fake
+    user:
+      token: fake
+`
+	if err := os.WriteFile("kubeconfig", []byte(kubeconfig), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	const count = 6
+	var config strings.Builder
+	config.WriteString("sources:\n")
+	for i := 0; i < count; i++ {
+		fmt.Fprintf(&config, "  - type: ConfigMap\n    name: config-%d\n    contexts:\n      include: [c%d]\n", i, i)
+	}
+	config.WriteString("executions:\n")
+	for i := 0; i < count; i++ {
+		fmt.Fprintf(&config, "  - name: e%d\n    kube-context: fake\n    contexts: [c%d]\n    output:\n      name: e%d.env\n", i, i, i)
+	}
+	if err := os.WriteFile(".enver.yaml", []byte(config.String()), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { executeAll = false; executeParallelism = runtime.NumCPU(); kubeconfigPath = "" }()
+
+	captureStdout(t, func() {
+		rootCmd.SetArgs([]string{"execute", "--all", "--parallelism", "2", "--kubeconfig", "kubeconfig"})
+		if err := rootCmd.Execute(); err != nil {
+			t.Fatalf("execute returned error: %v", err)
+		}
+	})
+
+	if maxInFlight > 2 {
+		t.Errorf("expected at most 2 executions at the same time, got %d", maxInFlight)
+	}
+	for i := 0; i < count; i++ {
+		content, err := os.ReadFile(filepath.Join("generated", fmt.Sprintf("e%d.env", i)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(content), fmt.Sprintf("NAME=config-%d", i)) {
+			t.Errorf("e%d: expected config-%d, got %q", i, i, content)
+		}
+	}
+}
+
+func TestExecuteWarnsAboutSkippedEmptyValues(t *testing.T) {
+	t.Chdir(t.TempDir())
+	kubeconfig := newFakeCluster(t, &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "settings", Namespace: "default"},
+		Data:       map[string]string{"REGION": "eu", "FEATURE_FLAGS": "", "DEBUG": ""},
+	})
+
+	config := `sources:
+  - type: ConfigMap
+    name: settings
+    variables:
+      exclude:
+        - DEBUG
+executions:
+  - name: local
+    kube-context: fake
+`
+	if err := os.WriteFile(".enver.yaml", []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		executeAll, kubeconfigPath, quiet = false, "", false
+		rootCmd.PersistentFlags().Lookup("quiet").Changed = false
+	}()
+
+	for _, quietFlag := range []bool{false, true} {
+		quiet = false
+		args := []string{"execute", "--all", "--kubeconfig", kubeconfig}
+		if quietFlag {
+			args = append(args, "--quiet")
+		}
+		rootCmd.SetArgs(args)
+
+		// Warnings go to stderr
+		var output string
+		warned := captureStderr(t, func() {
+			output = captureStdout(t, func() {
+				if err := rootCmd.Execute(); err != nil {
+					t.Errorf("execute returned error: %v", err)
+				}
+			})
+		})
+
+		// Excluded variables are not reported, they are not expected in the output
+		warning := "[local] Warning: skipped variables with an empty value (set includeEmpty: true to keep them): ConfigMap default/settings: FEATURE_FLAGS\n"
+		if strings.Contains(string(warned), warning) == quietFlag {
+			t.Errorf("quiet=%v: unexpected warnings %q", quietFlag, warned)
+		}
+		if strings.Contains(string(warned)+output, "DEBUG") {
+			t.Errorf("quiet=%v: expected the excluded DEBUG not to be reported, got %q and %q", quietFlag, warned, output)
+		}
+		// The summary lists them, it is left out with --quiet
+		if strings.Contains(output, "skipped empty values: FEATURE_FLAGS") == quietFlag {
+			t.Errorf("quiet=%v: unexpected summary in output %q", quietFlag, output)
+		}
+	}
+}
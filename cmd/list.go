@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+var listInputFile string
+var listSources bool
+var listExecutions bool
+var listContexts []string
+
+var listCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the sources and executions defined in .enver.yaml",
+	Long:  `Prints the sources and executions defined in the .enver.yaml file. With --context only the sources selected by those contexts are shown, which helps to debug context filtering without running a generation. No cluster is contacted.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		config, err := readConfig(configFilePath(listInputFile))
+		if err != nil {
+			return err
+		}
+
+		// Show both sections unless one is selected explicitly
+		showSources, showExecutions := listSources, listExecutions
+		if !showSources && !showExecutions {
+			showSources, showExecutions = true, true
+		}
+
+		if showSources {
+			writeSourceList(os.Stdout, config, listContexts)
+		}
+		if showSources && showExecutions {
+			fmt.Println()
+		}
+		if showExecutions {
+			writeExecutionList(os.Stdout, config)
+		}
+		return nil
+	},
+}
+
+// writeSourceList prints the sources selected by the given contexts as a table
+func writeSourceList(out io.Writer, config *ExecuteConfig, contexts []string) {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tTYPE\tNAMESPACE\tCONTEXTS")
+	for _, source := range config.Sources {
+		if !source.ShouldInclude(contexts) {
+			continue
+		}
+
+		name := source.Name
+		if source.Path != "" {
+			name = source.Path
+		}
+		namespace := "-"
+		if source.NeedsKubernetes() {
+			namespace = source.GetNamespace()
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", orDash(name), source.Type, namespace, describeSourceContexts(source.Contexts.Include, source.Contexts.Exclude))
+	}
+	w.Flush()
+}
+
+// writeExecutionList prints the executions with their output path and kube-context as a table
+func writeExecutionList(out io.Writer, config *ExecuteConfig) {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tOUTPUT\tKUBE-CONTEXT\tCONTEXTS")
+	for _, execution := range config.Executions {
+		output := "-"
+		if outputDirectory, outputName, err := executionOutput(execution, false); err == nil {
+			output = filepath.Join(outputDirectory, outputName)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", execution.Name, output, orDash(execution.KubeContext), orDash(strings.Join(execution.Contexts, ",")))
+	}
+	w.Flush()
+}
+
+// describeSourceContexts formats a source's context filter, e.g. "dev,prod !local"
+func describeSourceContexts(include, exclude []string) string {
+	var parts []string
+	if len(include) > 0 {
+		parts = append(parts, strings.Join(include, ","))
+	}
+	for _, c := range exclude {
+		parts = append(parts, "!"+c)
+	}
+	if len(parts) == 0 {
+		return "(all)"
+	}
+	return strings.Join(parts, " ")
+}
+
+// orDash returns "-" for empty table cells
+func orDash(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
+
+func init() {
+	addDeprecatedInputFlag(listCmd, &listInputFile)
+	listCmd.Flags().BoolVar(&listSources, "sources", false, "only list sources")
+	listCmd.Flags().BoolVar(&listExecutions, "executions", false, "only list executions")
+	listCmd.Flags().StringArrayVarP(&listContexts, "context", "c", []string{}, "only list sources selected by this context (can be repeated)")
+	listCmd.RegisterFlagCompletionFunc("context", completeContexts(&listInputFile))
+	rootCmd.AddCommand(listCmd)
+}
@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"enver/sources"
+)
+
+func TestWriteSourceListFiltersByContext(t *testing.T) {
+	config := &ExecuteConfig{
+		Sources: []sources.Source{
+			{Type: "ConfigMap", Name: "app-config", Contexts: sources.SourceContexts{Include: []string{"dev", "prod"}}},
+			{Type: "Secret", Name: "prod-secret", Namespace: "prod", Contexts: sources.SourceContexts{Include: []string{"prod"}}},
+			{Type: "EnvFile", Path: "local.env", Contexts: sources.SourceContexts{Exclude: []string{"prod"}}},
+			{Type: "Vars", Name: "inline"},
+		},
+	}
+
+	var out bytes.Buffer
+	writeSourceList(&out, config, []string{"dev"})
+
+	expected := []string{
+		"NAME        TYPE       NAMESPACE  CONTEXTS",
+		"app-config  ConfigMap  default    dev,prod",
+		"local.env   EnvFile    -          !prod",
+		"inline      Vars       -          (all)",
+	}
+	if got := strings.TrimRight(out.String(), "\n"); got != strings.Join(expected, "\n") {
+		t.Errorf("expected:\n%s\ngot:\n%s", strings.Join(expected, "\n"), got)
+	}
+}
+
+func TestWriteExecutionList(t *testing.T) {
+	config := &ExecuteConfig{
+		Executions: []Execution{
+			{Name: "local", Contexts: []string{"local"}},
+			{Name: "prod", Output: ExecutionOutput{Name: "prod.env", Directory: "out"}, KubeContext: "prod-cluster", Contexts: []string{"prod", "eu"}},
+		},
+	}
+
+	var out bytes.Buffer
+	writeExecutionList(&out, config)
+
+	expected := []string{
+		"NAME   OUTPUT          KUBE-CONTEXT  CONTEXTS",
+		"local  generated/.env  -             local",
+		"prod   out/prod.env    prod-cluster  prod,eu",
+	}
+	if got := strings.TrimRight(out.String(), "\n"); got != strings.Join(expected, "\n") {
+		t.Errorf("expected:\n%s\ngot:\n%s", strings.Join(expected, "\n"), got)
+	}
+}
@@ -0,0 +1,186 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"enver/output"
+	"enver/sources"
+
+	"gopkg.in/yaml.v3"
+)
+
+// lockFile records the resolved variables of executions so a later run can be verified against it
+type lockFile struct {
+	Executions map[string]lockExecution `yaml:"executions"`
+}
+
+// lockExecution is the locked state of a single execution
+type lockExecution struct {
+	Entries          []lockEntry       `yaml:"entries"`
+	ResourceVersions map[string]string `yaml:"resourceVersions,omitempty"` // source -> resourceVersion
+}
+
+// lockEntry is a single resolved variable. Only a hash of the value is stored so the lockfile
+// never contains secrets.
+type lockEntry struct {
+	Key    string `yaml:"key"`
+	Source string `yaml:"source"`
+	SHA256 string `yaml:"sha256"`
+}
+
+// newLockExecution builds the locked state of the resolved entries of an execution
+func newLockExecution(envData []sources.EnvEntry) lockExecution {
+	locked := lockExecution{Entries: []lockEntry{}}
+	for _, entry := range output.SortWithinSources(envData) {
+		// The owner is informational and not part of the locked state
+		entry.Owner = ""
+		source := output.EntrySource(entry)
+
+		hash := sha256.Sum256([]byte(entry.Value))
+		locked.Entries = append(locked.Entries, lockEntry{
+			Key:    entry.Key,
+			Source: source,
+			SHA256: hex.EncodeToString(hash[:]),
+		})
+
+		if entry.ResourceVersion != "" {
+			if locked.ResourceVersions == nil {
+				locked.ResourceVersions = make(map[string]string)
+			}
+			locked.ResourceVersions[source] = entry.ResourceVersion
+		}
+	}
+	return locked
+}
+
+// diffLockExecution returns a line per difference between the locked and the current state
+func diffLockExecution(locked, current lockExecution) []string {
+	lockedValues := make(map[string]string)
+	for _, entry := range locked.Entries {
+		lockedValues[entry.Source+" "+entry.Key] = entry.SHA256
+	}
+	currentValues := make(map[string]string)
+	for _, entry := range current.Entries {
+		currentValues[entry.Source+" "+entry.Key] = entry.SHA256
+	}
+
+	var differences []string
+	for _, entry := range current.Entries {
+		id := entry.Source + " " + entry.Key
+		lockedHash, ok := lockedValues[id]
+		switch {
+		case !ok:
+			differences = append(differences, fmt.Sprintf("+ %s (%s)", entry.Key, entry.Source))
+		case lockedHash != entry.SHA256:
+			differences = append(differences, fmt.Sprintf("~ %s (%s)", entry.Key, entry.Source))
+		}
+	}
+	for _, entry := range locked.Entries {
+		if _, ok := currentValues[entry.Source+" "+entry.Key]; !ok {
+			differences = append(differences, fmt.Sprintf("- %s (%s)", entry.Key, entry.Source))
+		}
+	}
+
+	var changedVersions []string
+	for source, version := range current.ResourceVersions {
+		if lockedVersion, ok := locked.ResourceVersions[source]; ok && lockedVersion != version {
+			changedVersions = append(changedVersions, fmt.Sprintf("~ %s resourceVersion %s -> %s", source, lockedVersion, version))
+		}
+	}
+	sort.Strings(changedVersions)
+
+	return append(differences, changedVersions...)
+}
+
+// readLockFile reads a lockfile written with --write-lock
+func readLockFile(path string) (*lockFile, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read lockfile: %w", err)
+	}
+
+	var lock lockFile
+	if err := yaml.Unmarshal(content, &lock); err != nil {
+		return nil, fmt.Errorf("failed to parse lockfile %s: %w", path, err)
+	}
+	return &lock, nil
+}
+
+// writeLockFile writes the lockfile
+func writeLockFile(path string, lock *lockFile) error {
+	var content bytes.Buffer
+	encoder := yaml.NewEncoder(&content)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(lock); err != nil {
+		return fmt.Errorf("failed to render lockfile: %w", err)
+	}
+	if err := os.WriteFile(path, content.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to write lockfile: %w", err)
+	}
+	return nil
+}
+
+// updateLockFile writes the recorded executions to the lockfile, keeping executions of an existing
+// lockfile that were not run
+func updateLockFile(path string, locks *lockRecorder) error {
+	lock := &lockFile{Executions: make(map[string]lockExecution)}
+	if _, err := os.Stat(path); err == nil {
+		if lock, err = readLockFile(path); err != nil {
+			return err
+		}
+		if lock.Executions == nil {
+			lock.Executions = make(map[string]lockExecution)
+		}
+	}
+
+	locks.mu.Lock()
+	defer locks.mu.Unlock()
+	for name, execution := range locks.recorded.Executions {
+		lock.Executions[name] = execution
+	}
+	return writeLockFile(path, lock)
+}
+
+// lockRecorder records the resolved entries of concurrent executions and, when a lockfile is being
+// verified, checks them against it
+type lockRecorder struct {
+	mu       sync.Mutex
+	locked   *lockFile // nil unless verifying
+	recorded lockFile
+}
+
+// newLockRecorder returns a recorder that verifies against locked, which may be nil
+func newLockRecorder(locked *lockFile) *lockRecorder {
+	return &lockRecorder{
+		locked:   locked,
+		recorded: lockFile{Executions: make(map[string]lockExecution)},
+	}
+}
+
+// record stores the execution's entries and returns an error if they differ from the lockfile
+func (r *lockRecorder) record(name string, envData []sources.EnvEntry) error {
+	current := newLockExecution(envData)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.recorded.Executions[name] = current
+
+	if r.locked == nil {
+		return nil
+	}
+	locked, ok := r.locked.Executions[name]
+	if !ok {
+		return fmt.Errorf("execution %q is not in the lockfile", name)
+	}
+	if differences := diffLockExecution(locked, current); len(differences) > 0 {
+		return fmt.Errorf("resolved values differ from the lockfile:\n    %s", strings.Join(differences, "\n    "))
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package cmd
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"enver/sources"
+)
+
+func TestExecuteWriteAndVerifyLock(t *testing.T) {
+	t.Chdir(t.TempDir())
+	defer func() { executeWriteLock, executeVerifyLock, executeAll = "", "", false }()
+
+	writeConfig := func(password string) {
+		config := `sources:
+  - type: Vars
+    name: inline
+    vars:
+      - name: HOST
+        value: localhost
+      - name: PASSWORD
+        value: ` + password + `
+executions:
+  - name: local
+`
+		if err := os.WriteFile(".enver.yaml", []byte(config), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	execute := func(args ...string) error {
+		rootCmd.SetArgs(append([]string{"execute", "--all"}, args...))
+		return rootCmd.Execute()
+	}
+
+	writeConfig("s3cret")
+	if err := execute("--write-lock", "enver.lock"); err != nil {
+		t.Fatalf("write-lock run returned error: %v", err)
+	}
+	executeWriteLock = ""
+
+	lock, err := os.ReadFile("enver.lock")
+	if err != nil {
+		t.Fatalf("expected enver.lock to be written: %v", err)
+	}
+	if strings.Contains(string(lock), "s3cret") {
+		t.Errorf("expected the lockfile to contain hashes only, got:\n%s", lock)
+	}
+
+	if err := execute("--verify-lock", "enver.lock"); err != nil {
+		t.Errorf("expected an unchanged run to match the lockfile, got: %v", err)
+	}
+
+	writeConfig("rotated")
+	err = execute("--verify-lock", "enver.lock")
+	if err == nil {
+		t.Fatal("expected a changed value to fail verification")
+	}
+	if !strings.Contains(err.Error(), "~ PASSWORD (Vars inline)") || strings.Contains(err.Error(), "HOST") {
+		t.Errorf("expected only PASSWORD to be reported as changed, got: %v", err)
+	}
+}
+
+func TestDiffLockExecutionReportsResourceVersions(t *testing.T) {
+	locked := newLockExecution([]sources.EnvEntry{
+		{Key: "HOST", Value: "localhost", SourceType: "ConfigMap", Name: "app", Namespace: "default", ResourceVersion: "100"},
+		{Key: "OLD", Value: "x", SourceType: "ConfigMap", Name: "app", Namespace: "default", ResourceVersion: "100"},
+	})
+	current := newLockExecution([]sources.EnvEntry{
+		{Key: "HOST", Value: "localhost", SourceType: "ConfigMap", Name: "app", Namespace: "default", ResourceVersion: "105"},
+		{Key: "NEW", Value: "y", SourceType: "ConfigMap", Name: "app", Namespace: "default", ResourceVersion: "105"},
+	})
+
+	expected := []string{
+		"+ NEW (ConfigMap default/app)",
+		"- OLD (ConfigMap default/app)",
+		"~ ConfigMap default/app resourceVersion 100 -> 105",
+	}
+	if differences := diffLockExecution(locked, current); strings.Join(differences, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("expected:\n%s\ngot:\n%s", strings.Join(expected, "\n"), strings.Join(differences, "\n"))
+	}
+}
@@ -0,0 +1,60 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+)
+
+// Formats of the progress messages of execute
+const (
+	logFormatText = "text" // human readable lines
+	logFormatJSON = "json" // one JSON object per event
+)
+
+// eventLog reports the progress of execute as text lines or as JSON events with an event name and
+// fields, e.g. {"time":"...","level":"INFO","msg":"output_written","execution":"local","variables":3}
+type eventLog struct {
+	out  io.Writer
+	json *slog.Logger // nil for text
+}
+
+// newEventLog returns an event log in the given format writing to out
+func newEventLog(format string, out io.Writer) (*eventLog, error) {
+	switch format {
+	case "", logFormatText:
+		return &eventLog{out: out}, nil
+	case logFormatJSON:
+		return &eventLog{out: out, json: slog.New(slog.NewJSONHandler(out, nil))}, nil
+	default:
+		return nil, fmt.Errorf("invalid log format %q (expected %s or %s)", format, logFormatText, logFormatJSON)
+	}
+}
+
+// log reports an event. The text format prints text, to stderr for warnings and errors so they
+// aren't mixed with output, and only errors with --quiet; events without text only appear as JSON.
+// fields are alternating keys and values.
+func (l *eventLog) log(level slog.Level, event, text string, fields ...any) {
+	if l.json != nil {
+		l.json.Log(context.Background(), level, event, fields...)
+		return
+	}
+	if text == "" || (quiet && level < slog.LevelError) {
+		return
+	}
+	if level >= slog.LevelWarn {
+		fmt.Fprintln(os.Stderr, text)
+		return
+	}
+	fmt.Fprintln(l.out, text)
+}
+
+func (l *eventLog) info(event, text string, fields ...any) {
+	l.log(slog.LevelInfo, event, text, fields...)
+}
+
+func (l *eventLog) warn(event, text string, fields ...any) {
+	l.log(slog.LevelWarn, event, text, fields...)
+}
@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestExecuteLogsJSONEvents(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	config := `sources:
+  - type: Vars
+    name: inline
+    vars:
+      - name: HOST
+        value: localhost
+      - name: PORT
+        value: "8080"
+executions:
+  - name: local
+`
+	if err := os.WriteFile(".enver.yaml", []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { executeAll = false; executeLogFormat = logFormatText; executeLog = &eventLog{out: os.Stdout} }()
+
+	stdout := captureStdout(t, func() {
+		rootCmd.SetArgs([]string{"execute", "--all", "--log-format", "json"})
+		if err := rootCmd.Execute(); err != nil {
+			t.Fatalf("execute returned error: %v", err)
+		}
+	})
+
+	var events []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(stdout), "\n") {
+		var event map[string]any
+		if err := json.Unmarshal([]byte(line), &event); err != nil {
+			t.Fatalf("expected a JSON object per line, got %q: %v", line, err)
+		}
+		events = append(events, event)
+	}
+
+	expected := []map[string]any{
+		{"msg": "execution_started", "execution": "local"},
+		{"msg": "source_fetched", "execution": "local", "source": "Vars inline", "variables": 2.0},
+		{"msg": "output_written", "execution": "local", "path": "generated/.env", "variables": 2.0},
+		{"msg": "execution_summary", "execution": "local", "variables": 2.0, "sources": 1.0},
+	}
+	if len(events) != len(expected) {
+		t.Fatalf("expected %d events, got %d:\n%s", len(expected), len(events), stdout)
+	}
+	for i, fields := range expected {
+		for key, value := range fields {
+			if events[i][key] != value {
+				t.Errorf("event %d: expected %s=%v, got %v", i, key, value, events[i][key])
+			}
+		}
+		if events[i]["level"] != "INFO" || events[i]["time"] == nil {
+			t.Errorf("event %d: expected level and time, got %v", i, events[i])
+		}
+	}
+}
+
+func TestNewEventLogRejectsUnknownFormats(t *testing.T) {
+	if _, err := newEventLog("xml", os.Stdout); err == nil {
+		t.Error("expected an error for an unknown log format")
+	}
+}
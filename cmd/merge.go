@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"enver/gitutil"
+	"enver/output"
+	"enver/sources"
+
+	"github.com/spf13/cobra"
+)
+
+var mergeOutputFile string
+var mergeOnConflict string
+var mergeNoComments bool
+
+var mergeCmd = &cobra.Command{
+	Use:   "merge -o <output> <file>...",
+	Short: "Combine env files into a single file",
+	Long:  `Reads the given env files in order and writes their variables to one file. Keys defined in more than one file are resolved with --on-conflict, the last file winning by default. The comment above each group of variables is kept, so the sources of generated files stay visible. Kubernetes is not contacted.`,
+	Args:  cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if mergeOutputFile == "" {
+			return fmt.Errorf("--output is required")
+		}
+		if err := validateConflictStrategy(mergeOnConflict); err != nil {
+			return err
+		}
+
+		var envData []sources.EnvEntry
+		perm := os.FileMode(0644)
+		for _, path := range args {
+			entries, err := (&sources.EnvFileFetcher{}).Fetch(cmd.Context(), nil, sources.Source{Type: "EnvFile", Path: path}, "")
+			if err != nil {
+				return err
+			}
+			envData = append(envData, entries...)
+
+			// The result is as restrictive as the most restrictive input, so merged secrets stay private
+			info, err := os.Stat(path)
+			if err != nil {
+				return fmt.Errorf("failed to read %s: %w", path, err)
+			}
+			perm &= info.Mode().Perm()
+		}
+
+		envData, conflicts, err := resolveConflicts(envData, mergeOnConflict)
+		if err != nil {
+			return err
+		}
+		if len(conflicts) > 0 {
+			warnf("Warning: keys defined in more than one file (%s): %s\n", mergeOnConflict, strings.Join(conflicts, ", "))
+		}
+
+		if err := os.MkdirAll(filepath.Dir(mergeOutputFile), 0755); err != nil {
+			return fmt.Errorf("failed to create output directory: %w", err)
+		}
+		if err := output.WriteFile(mergeOutputFile, []byte(renderMergedEnv(envData, !mergeNoComments)), perm, failIfExists); err != nil {
+			return fmt.Errorf("failed to write output file: %w", err)
+		}
+
+		infof("Wrote %d environment variables to %s\n", len(envData), mergeOutputFile)
+
+		return gitutil.EnsureGitignored(mergeOutputFile)
+	},
+}
+
+// mergeHeader returns the comment written above a merged entry: the comment it had in its file,
+// or the file it came from
+func mergeHeader(entry sources.EnvEntry) string {
+	if entry.Comment != "" {
+		return entry.Comment
+	}
+	return output.EntrySource(entry)
+}
+
+// renderMergedEnv renders merged entries in their original order, with a comment line above each
+// group of entries that shared a comment in their file
+func renderMergedEnv(envData []sources.EnvEntry, comments bool) string {
+	var sb strings.Builder
+	var lastGroup string
+	for _, entry := range envData {
+		// A group ends at another comment or another file
+		header := mergeHeader(entry)
+		if group := entry.Name + "\n" + header; comments && group != lastGroup {
+			if lastGroup != "" {
+				sb.WriteString("\n")
+			}
+			fmt.Fprintf(&sb, "# %s\n", header)
+			lastGroup = group
+		}
+		fmt.Fprintf(&sb, "%s=%s\n", entry.Key, output.QuoteEnvValue(entry.Value))
+	}
+	return sb.String()
+}
+
+func init() {
+	mergeCmd.Flags().StringVarP(&mergeOutputFile, "output", "o", "", "file to write the merged variables to")
+	mergeCmd.Flags().StringVar(&mergeOnConflict, "on-conflict", conflictLastWins, "how to handle keys defined in more than one file: keep-all, last-wins, first-wins or error")
+	mergeCmd.Flags().BoolVar(&mergeNoComments, "no-comments", false, "don't write a comment above each group of variables")
+	rootCmd.AddCommand(mergeCmd)
+}
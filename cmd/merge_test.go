@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestMergeCombinesEnvFiles(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	first := "# ConfigMap default/app\nHOST=localhost\nPORT=8080\n\n# Secret default/app\nPASSWORD=\"s3cr3t #1\"\n"
+	second := "PORT=9090\nDEBUG=true\n"
+	if err := os.WriteFile("first.env", []byte(first), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile("second.env", []byte(second), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		mergeOutputFile = ""
+		mergeOnConflict = conflictLastWins
+		mergeNoComments = false
+	}()
+
+	rootCmd.SetArgs([]string{"merge", "-o", "combined.env", "first.env", "second.env"})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("merge returned error: %v", err)
+	}
+
+	content, err := os.ReadFile("combined.env")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "# ConfigMap default/app\nHOST=localhost\n\n# Secret default/app\nPASSWORD=\"s3cr3t #1\"\n\n# EnvFile second.env\nPORT=9090\nDEBUG=true\n"
+	if string(content) != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, string(content))
+	}
+
+	// The most restrictive input mode is kept
+	if info, err := os.Stat("combined.env"); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("expected mode 0600, got %v (%v)", info.Mode().Perm(), err)
+	}
+
+	// first-wins keeps the first value, --no-comments drops the headers
+	rootCmd.SetArgs([]string{"merge", "-o", "combined.env", "--on-conflict", "first-wins", "--no-comments", "first.env", "second.env"})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("merge returned error: %v", err)
+	}
+	content, err = os.ReadFile("combined.env")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = "HOST=localhost\nPORT=8080\nPASSWORD=\"s3cr3t #1\"\nDEBUG=true\n"
+	if string(content) != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, string(content))
+	}
+
+	// error fails on the conflicting key
+	rootCmd.SetArgs([]string{"merge", "-o", "combined.env", "--on-conflict", "error", "first.env", "second.env"})
+	if err := rootCmd.Execute(); err == nil || !strings.Contains(err.Error(), "PORT") {
+		t.Errorf("expected a conflict error naming PORT, got %v", err)
+	}
+}
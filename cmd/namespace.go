@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"enver/sources"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// checkNamespacesFlag makes generate and execute verify that the namespaces of all Kubernetes
+// sources exist before fetching, which needs get access on namespaces
+var checkNamespacesFlag bool
+
+// checkNamespaces returns an error for the first Kubernetes source whose namespace doesn't exist.
+// Optional sources are skipped, their objects may be missing together with the namespace.
+func checkNamespaces(ctx context.Context, configSources []sources.Source, sourceClients []*kubeClientEntry) error {
+	for i, source := range configSources {
+		client := sourceClients[i]
+		if client == nil || !source.NeedsKubernetes() || source.Optional {
+			continue
+		}
+		if err := client.checkNamespace(ctx, source.GetNamespace()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkNamespace returns an error if the namespace doesn't exist. The result is remembered for the
+// lifetime of the client, so every namespace is checked once per run and cluster.
+func (c *kubeClientEntry) checkNamespace(ctx context.Context, namespace string) error {
+	if result, ok := c.namespaces.Load(namespace); ok {
+		err, _ := result.(error)
+		return err
+	}
+
+	var result error
+	_, err := c.Clientset.CoreV1().Namespaces().Get(ctx, namespace, metav1.GetOptions{})
+	switch {
+	case apierrors.IsNotFound(err):
+		result = fmt.Errorf("namespace %q not found%s", namespace, c.namespaceHint(ctx, namespace))
+	case err != nil:
+		// Not remembered, the error may be transient
+		return fmt.Errorf("failed to check namespace %q: %w", namespace, err)
+	}
+	c.namespaces.Store(namespace, result)
+	return result
+}
+
+// namespaceHint suggests the existing namespaces closest to a mistyped one, or lists all of them
+// when none is close. It is empty when the namespaces can't be listed.
+func (c *kubeClientEntry) namespaceHint(ctx context.Context, namespace string) string {
+	list, err := c.Clientset.CoreV1().Namespaces().List(ctx, metav1.ListOptions{})
+	if err != nil || len(list.Items) == 0 {
+		return ""
+	}
+
+	var available, similar []string
+	for _, item := range list.Items {
+		available = append(available, item.Name)
+		if editDistance(namespace, item.Name) <= 2 || strings.Contains(item.Name, namespace) || strings.Contains(namespace, item.Name) {
+			similar = append(similar, fmt.Sprintf("%q", item.Name))
+		}
+	}
+	slices.Sort(available)
+	if len(similar) > 0 {
+		return fmt.Sprintf(" (did you mean %s?)", strings.Join(similar, " or "))
+	}
+	return fmt.Sprintf(" (available namespaces: %s)", strings.Join(available, ", "))
+}
+
+// editDistance returns the Levenshtein distance between a and b
+func editDistance(a, b string) int {
+	previous := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current := make([]int, len(b)+1)
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous = current
+	}
+	return previous[len(b)]
+}
@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"testing"
+
+	"enver/engine"
+	"enver/sources"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestCheckNamespaces(t *testing.T) {
+	clientset := fake.NewClientset(
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "default"}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "production"}},
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "staging"}},
+	)
+	client := &kubeClientEntry{Client: engine.Client{Clientset: clientset}}
+
+	testCases := []struct {
+		namespace string
+		expected  string
+	}{
+		{namespace: "production"},
+		{namespace: "prodcution", expected: `namespace "prodcution" not found (did you mean "production"?)`},
+		{namespace: "billing", expected: `namespace "billing" not found (available namespaces: default, production, staging)`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.namespace, func(t *testing.T) {
+			configSources := []sources.Source{
+				{Type: "Vars", Name: "inline"},
+				{Type: "ConfigMap", Name: "settings", Namespace: tc.namespace},
+				{Type: "Secret", Name: "credentials", Namespace: tc.namespace},
+			}
+			err := checkNamespaces(t.Context(), configSources, []*kubeClientEntry{nil, client, client})
+			if tc.expected == "" {
+				if err != nil {
+					t.Errorf("expected no error, got %v", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tc.expected {
+				t.Errorf("expected error %q, got %v", tc.expected, err)
+			}
+		})
+	}
+
+	// Every namespace is requested once, however many sources use it
+	gets := 0
+	for _, action := range clientset.Actions() {
+		if action.GetVerb() == "get" {
+			gets++
+		}
+	}
+	if gets != len(testCases) {
+		t.Errorf("expected %d namespace gets, got %d", len(testCases), gets)
+	}
+
+	// Optional sources may live in a namespace that doesn't exist yet
+	optional := []sources.Source{{Type: "ConfigMap", Name: "settings", Namespace: "preview-42", Optional: true}}
+	if err := checkNamespaces(t.Context(), optional, []*kubeClientEntry{client}); err != nil {
+		t.Errorf("expected optional sources to be skipped, got %v", err)
+	}
+}
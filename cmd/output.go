@@ -0,0 +1,228 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+
+	"enver/output"
+	"enver/sources"
+)
+
+// stdoutOutput as output directory writes the env file to stdout instead of a file
+const stdoutOutput = "-"
+
+// fileDirectory returns the directory files of transformations are written to. When the env file
+// goes to stdout there is no output directory, so they are written relative to the working directory.
+func fileDirectory(outputDirectory string) string {
+	if outputDirectory == stdoutOutput {
+		return "."
+	}
+	return outputDirectory
+}
+
+// maskedValue replaces masked values in console output
+const maskedValue = "****"
+
+// masker hides the values of Secrets, and of keys matching a pattern, in console output
+type masker struct {
+	keys *regexp.Regexp // nil masks Secrets only
+}
+
+// newMasker returns a masker for the --mask and --mask-pattern flags, or nil when neither is set
+func newMasker(enabled bool, keyPattern string) (*masker, error) {
+	if !enabled && keyPattern == "" {
+		return nil, nil
+	}
+	m := &masker{}
+	if keyPattern != "" {
+		keys, err := regexp.Compile(keyPattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid mask pattern %q: %w", keyPattern, err)
+		}
+		m.keys = keys
+	}
+	return m, nil
+}
+
+// mask returns a copy of the entries with masked values; a nil masker returns the entries as-is
+func (m *masker) mask(envData []sources.EnvEntry) []sources.EnvEntry {
+	if m == nil {
+		return envData
+	}
+	masked := make([]sources.EnvEntry, len(envData))
+	for i, entry := range envData {
+		if entry.IsSecret() || m.keys != nil && m.keys.MatchString(entry.Key) {
+			entry.Value = maskedValue
+		}
+		masked[i] = entry
+	}
+	return masked
+}
+
+// withOutputMode returns a context in which files written while fetching sources get the
+// configured output mode, see sources.WithFileMode
+func withOutputMode(ctx context.Context, mode string) (context.Context, error) {
+	if mode == "" {
+		return ctx, nil
+	}
+	perm, err := output.ParseMode(mode)
+	if err != nil {
+		return ctx, err
+	}
+	return sources.WithFileMode(ctx, perm), nil
+}
+
+// failIfExists makes generate and execute refuse to overwrite existing files, set by --fail-if-exists
+var failIfExists bool
+
+// Strategies for handling the same key being emitted more than once
+const (
+	conflictKeepAll   = "keep-all"   // write every occurrence (the consuming app usually picks the last one)
+	conflictLastWins  = "last-wins"  // keep only the last occurrence
+	conflictFirstWins = "first-wins" // keep only the first occurrence
+	conflictError     = "error"      // fail when a key is emitted more than once
+)
+
+// validateConflictStrategy returns an error if the strategy is not one of the supported values
+func validateConflictStrategy(strategy string) error {
+	switch strategy {
+	case conflictKeepAll, conflictLastWins, conflictFirstWins, conflictError:
+		return nil
+	default:
+		return fmt.Errorf("invalid conflict strategy %q (expected %s, %s, %s or %s)", strategy, conflictKeepAll, conflictLastWins, conflictFirstWins, conflictError)
+	}
+}
+
+// resolveConflicts deduplicates entries by key according to the strategy and returns the remaining
+// entries together with the keys that were emitted more than once, in order of first appearance
+func resolveConflicts(envData []sources.EnvEntry, strategy string) ([]sources.EnvEntry, []string, error) {
+	counts := make(map[string]int)
+	var conflicts []string
+	for _, entry := range envData {
+		counts[entry.Key]++
+		if counts[entry.Key] == 2 {
+			conflicts = append(conflicts, entry.Key)
+		}
+	}
+
+	if len(conflicts) == 0 {
+		return envData, nil, nil
+	}
+
+	switch strategy {
+	case conflictKeepAll:
+		return envData, conflicts, nil
+	case conflictError:
+		return nil, conflicts, fmt.Errorf("conflicting keys across sources: %s", strings.Join(conflicts, ", "))
+	}
+
+	seen := make(map[string]int)
+	var resolved []sources.EnvEntry
+	for _, entry := range envData {
+		seen[entry.Key]++
+		keep := true
+		switch strategy {
+		case conflictFirstWins:
+			keep = seen[entry.Key] == 1
+		case conflictLastWins:
+			keep = seen[entry.Key] == counts[entry.Key]
+		}
+		if keep {
+			resolved = append(resolved, entry)
+		}
+	}
+	return resolved, conflicts, nil
+}
+
+// outputPathData is the data available to output directory, file name and source name templates
+type outputPathData struct {
+	Context     string // selected contexts joined with "-"
+	KubeContext string
+}
+
+// newOutputPathData builds the template data for the given contexts and kube context
+func newOutputPathData(contexts []string, kubeContext string) outputPathData {
+	return outputPathData{
+		Context:     strings.Join(contexts, "-"),
+		KubeContext: kubeContext,
+	}
+}
+
+// renderOutputPath expands a Go template such as "generated/{{ .Context }}" in an output path
+func renderOutputPath(pattern string, data outputPathData) (string, error) {
+	return renderTemplate("output path", pattern, data)
+}
+
+// renderTemplate expands a Go template in a configuration value; what describes the value in errors
+func renderTemplate(what, pattern string, data outputPathData) (string, error) {
+	if !strings.Contains(pattern, "{{") {
+		return pattern, nil
+	}
+
+	tmpl, err := template.New(what).Option("missingkey=error").Parse(pattern)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse %s template %q: %w", what, pattern, err)
+	}
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, data); err != nil {
+		return "", fmt.Errorf("failed to render %s template %q: %w", what, pattern, err)
+	}
+	return sb.String(), nil
+}
+
+// resolveOutputDirectory renders the directory template and, with perContextDir, nests the
+// result under the context name. Without contexts the directory is not nested.
+func resolveOutputDirectory(directory string, data outputPathData, perContextDir bool) (string, error) {
+	resolved, err := renderOutputPath(directory, data)
+	if err != nil {
+		return "", err
+	}
+	if perContextDir && data.Context != "" {
+		resolved = filepath.Join(resolved, data.Context)
+	}
+	return resolved, nil
+}
+
+// sourceOutput holds the entries a single configured source contributed
+type sourceOutput struct {
+	output.SourceEntries
+	SkippedEmpty []string // keys the source skipped because their value was empty
+}
+
+// sourceEntries returns the entries of every source, as written per source by output.Write
+func sourceEntries(outputs []sourceOutput) []output.SourceEntries {
+	entries := make([]output.SourceEntries, len(outputs))
+	for i, o := range outputs {
+		entries[i] = o.SourceEntries
+	}
+	return entries
+}
+
+// describeSkippedEmpty returns the empty values skipped per source, e.g. "ConfigMap default/app: DEBUG, TOKEN",
+// and all their keys
+func describeSkippedEmpty(outputs []sourceOutput) ([]string, []string) {
+	var descriptions, keys []string
+	for _, output := range outputs {
+		if len(output.SkippedEmpty) == 0 {
+			continue
+		}
+		descriptions = append(descriptions, fmt.Sprintf("%s: %s", describeSource(output.Source), strings.Join(output.SkippedEmpty, ", ")))
+		keys = append(keys, output.SkippedEmpty...)
+	}
+	return descriptions, keys
+}
+
+// renderDryRunSummary describes what a run would write: the output file and the number of variables
+// each source contributes. Every line starts with linePrefix.
+func renderDryRunSummary(linePrefix, outputPath string, envData []sources.EnvEntry, outputs []sourceOutput) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%sWould write %d environment variables to %s\n", linePrefix, len(envData), outputPath)
+	for _, output := range outputs {
+		fmt.Fprintf(&sb, "%s  %s: %d\n", linePrefix, describeSource(output.Source), len(output.Entries))
+	}
+	return sb.String()
+}
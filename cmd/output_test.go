@@ -0,0 +1,161 @@
+package cmd
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"enver/sources"
+)
+
+func TestResolveConflicts(t *testing.T) {
+	entries := []sources.EnvEntry{
+		{Key: "HOST", Value: "from-configmap", SourceType: "ConfigMap", Name: "app", Namespace: "default"},
+		{Key: "PORT", Value: "8080", SourceType: "ConfigMap", Name: "app", Namespace: "default"},
+		{Key: "HOST", Value: "from-envfile", SourceType: "EnvFile", Name: "local.env"},
+		{Key: "HOST", Value: "from-vars", SourceType: "Vars", Name: "inline"},
+		{Key: "DEBUG", Value: "true", SourceType: "Vars", Name: "inline"},
+	}
+
+	tests := []struct {
+		strategy string
+		expected []string
+		wantErr  bool
+	}{
+		{conflictKeepAll, []string{"HOST=from-configmap", "PORT=8080", "HOST=from-envfile", "HOST=from-vars", "DEBUG=true"}, false},
+		{conflictLastWins, []string{"PORT=8080", "HOST=from-vars", "DEBUG=true"}, false},
+		{conflictFirstWins, []string{"HOST=from-configmap", "PORT=8080", "DEBUG=true"}, false},
+		{conflictError, nil, true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.strategy, func(t *testing.T) {
+			resolved, conflicts, err := resolveConflicts(entries, tc.strategy)
+			if len(conflicts) != 1 || conflicts[0] != "HOST" {
+				t.Errorf("expected HOST to be reported as conflicting, got %v", conflicts)
+			}
+			if tc.wantErr {
+				if err == nil || !strings.Contains(err.Error(), "HOST") {
+					t.Fatalf("expected an error naming HOST, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("resolveConflicts returned error: %v", err)
+			}
+
+			var actual []string
+			for _, entry := range resolved {
+				actual = append(actual, entry.Key+"="+entry.Value)
+			}
+			if strings.Join(actual, ",") != strings.Join(tc.expected, ",") {
+				t.Errorf("expected %v, got %v", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestResolveConflictsWithoutDuplicates(t *testing.T) {
+	entries := []sources.EnvEntry{
+		{Key: "HOST", Value: "localhost", SourceType: "Vars", Name: "inline"},
+		{Key: "PORT", Value: "8080", SourceType: "Vars", Name: "inline"},
+	}
+
+	resolved, conflicts, err := resolveConflicts(entries, conflictError)
+	if err != nil {
+		t.Fatalf("resolveConflicts returned error: %v", err)
+	}
+	if len(conflicts) != 0 || len(resolved) != len(entries) {
+		t.Errorf("expected entries to be unchanged, got %v (conflicts %v)", resolved, conflicts)
+	}
+}
+
+func TestValidateConflictStrategy(t *testing.T) {
+	for _, strategy := range []string{conflictKeepAll, conflictLastWins, conflictFirstWins, conflictError} {
+		if err := validateConflictStrategy(strategy); err != nil {
+			t.Errorf("expected %s to be valid, got %v", strategy, err)
+		}
+	}
+	if err := validateConflictStrategy("newest"); err == nil {
+		t.Error("expected an error for an unknown strategy")
+	}
+}
+
+func TestExecutionOutputPerContext(t *testing.T) {
+	tests := []struct {
+		name          string
+		directory     string
+		perContextDir bool
+	}{
+		{"template", "generated/{{ .Context }}", false},
+		{"per-context-dir", "generated", true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var paths []string
+			for _, context := range []string{"prod", "staging"} {
+				execution := Execution{
+					Name:     context,
+					Output:   ExecutionOutput{Directory: tc.directory},
+					Contexts: []string{context},
+				}
+				directory, name, err := executionOutput(execution, tc.perContextDir)
+				if err != nil {
+					t.Fatalf("executionOutput returned error: %v", err)
+				}
+				paths = append(paths, filepath.Join(directory, name))
+			}
+
+			expected := []string{filepath.Join("generated", "prod", ".env"), filepath.Join("generated", "staging", ".env")}
+			if strings.Join(paths, ",") != strings.Join(expected, ",") {
+				t.Errorf("expected %v, got %v", expected, paths)
+			}
+		})
+	}
+}
+
+func TestRenderOutputPath(t *testing.T) {
+	data := newOutputPathData([]string{"dev", "eu"}, "kind-kind")
+
+	rendered, err := renderOutputPath("out/{{ .KubeContext }}/{{ .Context }}.env", data)
+	if err != nil {
+		t.Fatalf("renderOutputPath returned error: %v", err)
+	}
+	if rendered != "out/kind-kind/dev-eu.env" {
+		t.Errorf("unexpected rendered path %q", rendered)
+	}
+
+	if _, err := renderOutputPath("out/{{ .Cluster }}", data); err == nil {
+		t.Error("expected an error for an unknown template field")
+	}
+}
+
+func TestMaskerMasksSecrets(t *testing.T) {
+	envData := []sources.EnvEntry{
+		{Key: "PASSWORD", Value: "s3cr3t", SourceType: "Secret", Name: "db"},
+		{Key: "TOKEN", Value: "abc", SourceType: "Deployment", Name: "app (Secret: api)", FromSecret: true},
+		{Key: "HOST", Value: "localhost", SourceType: "ConfigMap", Name: "app"},
+		{Key: "API_KEY", Value: "xyz", SourceType: "Vars", Name: "inline"},
+	}
+
+	m, err := newMasker(true, "_KEY$")
+	if err != nil {
+		t.Fatal(err)
+	}
+	masked := m.mask(envData)
+	expected := []string{maskedValue, maskedValue, "localhost", maskedValue}
+	for i, entry := range masked {
+		if entry.Value != expected[i] {
+			t.Errorf("%s: expected %q, got %q", entry.Key, expected[i], entry.Value)
+		}
+	}
+	if envData[0].Value != "s3cr3t" {
+		t.Error("expected the original entries to be left unchanged")
+	}
+
+	// Without --mask and --mask-pattern nothing is masked
+	if m, err := newMasker(false, ""); err != nil || m != nil || m.mask(envData)[0].Value != "s3cr3t" {
+		t.Errorf("expected no masker, got %v (%v)", m, err)
+	}
+}
@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"enver/output"
+	"enver/transformations"
+
+	"github.com/spf13/cobra"
+)
+
+var printNames []string
+var printAll bool
+var printInputFile string
+var printFormat string
+var printMask bool
+var printMaskPattern string
+
+var printCmd = &cobra.Command{
+	Use:   "print",
+	Short: "Print the environment of predefined executions",
+	Long:  `Reads the .enver.yaml file, collects the environment variables of the selected executions and prints them to stdout in the execution's output format. No file is written and .gitignore is not touched; file transformations only report the path they would write.`,
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := output.ValidateFormat(printFormat); err != nil {
+			return err
+		}
+		consoleMasker, err := newMasker(printMask, printMaskPattern)
+		if err != nil {
+			return err
+		}
+
+		config, err := loadExecuteConfig(printInputFile)
+		if err != nil {
+			return err
+		}
+
+		selectedExecutions, err := selectExecutions(config, printNames, printAll)
+		if err != nil {
+			return err
+		}
+
+		// Nothing is written while fetching, file transformations return the path they would write
+		transformations.SetDryRun(true)
+		defer transformations.SetDryRun(false)
+
+		// Use default loading rules (respects KUBECONFIG env var)
+		clients := newKubeClientCache(kubeconfigLoadingRules())
+
+		// Cancelled on Ctrl+C or when --timeout expires
+		ctx := cmd.Context()
+
+		for _, execution := range selectedExecutions {
+			outputDirectory, outputName, err := executionOutput(execution, false)
+			if err != nil {
+				return fmt.Errorf("%s: %w", execution.Name, err)
+			}
+
+			envData, _, err := collectExecution(ctx, execution, config.Sources, clients, fileDirectory(outputDirectory), false, false)
+			if err != nil {
+				return fmt.Errorf("%s: %w", execution.Name, err)
+			}
+			if err := validateValues(envData, config.Validations); err != nil {
+				return fmt.Errorf("%s: %w", execution.Name, err)
+			}
+
+			format := execution.Output.Format
+			if printFormat != "" {
+				format = printFormat
+			}
+			format = output.ResolveFormat(format, outputName)
+			content, skipped := output.Render(consoleMasker.mask(envData), format, output.RenderOptions{Export: execution.Output.Export})
+			if len(skipped) > 0 {
+				warnf("Warning: [%s] skipped keys that are not valid %s variable names: %s\n", execution.Name, format, strings.Join(skipped, ", "))
+			}
+			fmt.Fprint(cmd.OutOrStdout(), content)
+		}
+		return nil
+	},
+}
+
+func init() {
+	addDeprecatedInputFlag(printCmd, &printInputFile)
+	printCmd.Flags().StringArrayVar(&printNames, "name", []string{}, "execution name to print (can be repeated)")
+	printCmd.RegisterFlagCompletionFunc("name", completeExecutionNames(&printInputFile))
+	printCmd.Flags().BoolVar(&printAll, "all", false, "print all executions")
+	printCmd.Flags().StringVar(&printFormat, "format", "", "output format: env or tfvars (default the execution's format, or detected from its output name's extension)")
+	printCmd.Flags().BoolVar(&printMask, "mask", false, "replace the values of Secrets with ****")
+	printCmd.Flags().StringVar(&printMaskPattern, "mask-pattern", "", "also mask the values of keys matching this regex (implies --mask)")
+	rootCmd.AddCommand(printCmd)
+}
@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"os"
+	"testing"
+)
+
+func TestPrintWritesNoFiles(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	config := `sources:
+  - type: Vars
+    name: inline
+    vars:
+      - name: DB_PASSWORD
+        value: s3cr3t
+      - name: HOST
+        value: localhost
+executions:
+  - name: local
+`
+	if err := os.WriteFile(".enver.yaml", []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { printNames = []string{}; printFormat = ""; printMaskPattern = "" }()
+
+	tests := []struct {
+		args     []string
+		expected string
+	}{
+		{[]string{"print", "--name", "local"}, "# Vars inline\nDB_PASSWORD=s3cr3t\nHOST=localhost\n"},
+		{[]string{"print", "--name", "local", "--mask-pattern", "PASSWORD"}, "# Vars inline\nDB_PASSWORD=****\nHOST=localhost\n"},
+		{[]string{"print", "--name", "local", "--format", "tfvars", "--mask-pattern", ""}, "# Vars inline\nDB_PASSWORD = \"s3cr3t\"\nHOST = \"localhost\"\n"},
+	}
+	for _, tt := range tests {
+		// --name accumulates across runs of the same command
+		printNames = []string{}
+		var err error
+		stdout := captureStdout(t, func() {
+			rootCmd.SetArgs(tt.args)
+			err = rootCmd.Execute()
+		})
+		if err != nil {
+			t.Fatalf("%v returned error: %v", tt.args, err)
+		}
+		if stdout != tt.expected {
+			t.Errorf("%v: expected %q, got %q", tt.args, tt.expected, stdout)
+		}
+	}
+
+	for _, path := range []string{"generated", ".gitignore"} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("expected no %s, got %v", path, err)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"strings"
+
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/manifoldco/promptui"
+)
+
+// fuzzySearchThreshold is the number of options above which selection prompts filter fuzzily, so
+// short lists behave as before
+const fuzzySearchThreshold = 10
+
+// fuzzyMatch returns true if the characters of filter appear in value in order, ignoring case, e.g.
+// "gkeprd" matches "gke-prod-eu"
+func fuzzyMatch(filter, value string) bool {
+	value = strings.ToLower(value)
+	for _, r := range strings.ToLower(filter) {
+		i := strings.IndexRune(value, r)
+		if i < 0 {
+			return false
+		}
+		value = value[i+len(string(r)):]
+	}
+	return true
+}
+
+// multiSelectOptions returns the options of a survey multi-select over the given options: long lists
+// are filtered fuzzily while typing instead of by substring
+func multiSelectOptions(options []string) []survey.AskOpt {
+	if len(options) <= fuzzySearchThreshold {
+		return nil
+	}
+	return []survey.AskOpt{survey.WithFilter(func(filter, value string, _ int) bool {
+		return fuzzyMatch(filter, value)
+	})}
+}
+
+// newSelectPrompt returns a promptui select over the given items. Long lists start in search mode
+// and are filtered fuzzily while typing.
+func newSelectPrompt(label string, items []string) promptui.Select {
+	prompt := promptui.Select{
+		Label: label,
+		Items: items,
+	}
+	if len(items) > fuzzySearchThreshold {
+		prompt.Searcher = func(input string, index int) bool {
+			return fuzzyMatch(input, items[index])
+		}
+		prompt.StartInSearchMode = true
+	}
+	return prompt
+}
@@ -0,0 +1,49 @@
+package cmd
+
+import "testing"
+
+func TestFuzzyMatch(t *testing.T) {
+	testCases := []struct {
+		filter   string
+		value    string
+		expected bool
+	}{
+		{"", "gke-prod-eu", true},
+		{"prod", "gke-prod-eu", true},
+		{"gkeprd", "gke-prod-eu", true},
+		{"GPE", "gke-prod-eu", true},
+		{"eu-prod", "gke-prod-eu", false},
+		{"kind", "gke-prod-eu", false},
+	}
+	for _, tc := range testCases {
+		if got := fuzzyMatch(tc.filter, tc.value); got != tc.expected {
+			t.Errorf("fuzzyMatch(%q, %q): expected %v, got %v", tc.filter, tc.value, tc.expected, got)
+		}
+	}
+}
+
+func TestSelectPromptsSearchLongListsOnly(t *testing.T) {
+	short := []string{"kind-kind", "minikube"}
+	if prompt := newSelectPrompt("Select", short); prompt.Searcher != nil || prompt.StartInSearchMode {
+		t.Error("expected no search for a short list")
+	}
+	if opts := multiSelectOptions(short); len(opts) != 0 {
+		t.Errorf("expected the default filter for a short list, got %d options", len(opts))
+	}
+
+	var long []string
+	for i := 0; i <= fuzzySearchThreshold; i++ {
+		long = append(long, "context-"+string(rune('a'+i)))
+	}
+	long[3] = "gke-prod-eu"
+	prompt := newSelectPrompt("Select", long)
+	if prompt.Searcher == nil || !prompt.StartInSearchMode {
+		t.Fatal("expected search for a long list")
+	}
+	if !prompt.Searcher("gkeprd", 3) || prompt.Searcher("gkeprd", 0) {
+		t.Error("expected the searcher to match fuzzily")
+	}
+	if opts := multiSelectOptions(long); len(opts) != 1 {
+		t.Errorf("expected a fuzzy filter for a long list, got %d options", len(opts))
+	}
+}
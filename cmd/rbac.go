@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"enver/sources"
+
+	"k8s.io/client-go/kubernetes"
+)
+
+// checkSourceAccessPerCluster runs the RBAC preflight for each source against the cluster it is fetched from
+func checkSourceAccessPerCluster(ctx context.Context, configSources []sources.Source, sourceClients []*kubeClientEntry) error {
+	var clientOrder []*kubeClientEntry
+	grouped := make(map[*kubeClientEntry][]sources.Source)
+	for i, source := range configSources {
+		client := sourceClients[i]
+		if client == nil {
+			continue
+		}
+		if _, ok := grouped[client]; !ok {
+			clientOrder = append(clientOrder, client)
+		}
+		grouped[client] = append(grouped[client], source)
+	}
+
+	var errs []error
+	for _, client := range clientOrder {
+		if err := checkSourceAccess(ctx, client.Clientset, grouped[client], sources.CheckAccess); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// checkSourceAccess runs the RBAC preflight for the given sources with review, sources.CheckAccess
+// or sources.CheckWatchAccess, and returns an error listing every denied permission, so all
+// problems are reported before anything is fetched
+func checkSourceAccess(ctx context.Context, clientset kubernetes.Interface, configSources []sources.Source, review func(context.Context, kubernetes.Interface, []sources.Source) ([]sources.AccessDenial, error)) error {
+	denials, err := review(ctx, clientset, configSources)
+	if err != nil {
+		return fmt.Errorf("rbac preflight failed: %w", err)
+	}
+
+	if len(denials) == 0 {
+		return nil
+	}
+
+	var lines []string
+	for _, denial := range denials {
+		line := fmt.Sprintf("%s %s/%s: cannot %s", denial.Source.Type, denial.Source.GetNamespace(), denial.Source.Name, denial.Check)
+		if denial.Reason != "" {
+			line = fmt.Sprintf("%s (%s)", line, denial.Reason)
+		}
+		lines = append(lines, line)
+	}
+
+	return fmt.Errorf("rbac preflight found sources that will fail:\n  %s", strings.Join(lines, "\n  "))
+}
@@ -1,19 +1,165 @@
 package cmd
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+
+	"enver/gitutil"
+	"enver/sources"
 
 	"github.com/spf13/cobra"
 )
 
+// version, commit and date describe the build and are set with -ldflags "-X enver/cmd.version=..."
+var version = "dev"
+var commit = "none"
+var date = "unknown"
+
+// rootConfigFile is the configuration file read by all commands, set with the persistent --config flag
+var rootConfigFile string
+
+// kubeconfigPath overrides the kubeconfig file, which otherwise comes from KUBECONFIG or ~/.kube/config
+var kubeconfigPath string
+
+// namespaceOverride replaces the namespace of all sources, so one configuration can target several namespaces
+var namespaceOverride string
+
+// profileName selects a profile of the configuration file, see applyProfile
+var profileName string
+
+// includeVars and excludeVars filter the variables of all sources from the command line, set by generate and execute
+var includeVars []string
+var excludeVars []string
+
+// inCluster makes all commands use the pod's service account instead of a kubeconfig
+var inCluster bool
+
+// noInput disables all prompts, see nonInteractive
+var noInput bool
+
+// quiet suppresses status messages and the warnings about variables that were skipped because their
+// value is empty, verbose adds a message per fetched source; at most one of them is set
+var quiet bool
+var verbose bool
+
+// gitignoreMode is how files written outside .gitignore are handled: auto, file, dir or skip
+var gitignoreMode string
+
+// gitignoreLocal adds the entries for written files to .git/info/exclude instead of .gitignore
+var gitignoreLocal bool
+
+// clientQPS and clientBurst configure the client-side rate limit of all Kubernetes clients
+var clientQPS float32
+var clientBurst int
+
+// fetchConcurrency limits the number of sources of an execution that are fetched at the same time
+var fetchConcurrency int
+
+// timeout limits how long a command may take, 0 means no limit
+var timeout time.Duration
+
+// cancelTimeout releases the timer of --timeout once the command finished
+var cancelTimeout context.CancelFunc = func() {}
+
+// retries is how often a request to the API server is retried after a transient error
+var retries int
+
+// execConcurrency limits the number of concurrent exec sessions of Container sources
+var execConcurrency int
+
 var rootCmd = &cobra.Command{
 	Use:   "enver",
 	Short: "A tool for managing environment configuration",
 	Long:  `Enver is a CLI tool for reading and managing .enver.yaml configuration files.`,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if timeout > 0 {
+			ctx, cancel := context.WithTimeout(cmd.Context(), timeout)
+			cmd.SetContext(ctx)
+			cancelTimeout = cancel
+		}
+		sources.SetExecConcurrency(execConcurrency)
+		sources.SetRetries(retries)
+		gitutil.SetNonInteractive(nonInteractive())
+		gitutil.SetQuiet(quiet)
+		sources.SetQuiet(quiet)
+		gitutil.SetLocal(gitignoreLocal)
+		return gitutil.SetMode(gitignoreMode)
+	},
+}
+
+// exitCodeError makes the process exit with a specific code instead of 1
+type exitCodeError struct {
+	code int
+}
+
+func (e *exitCodeError) Error() string {
+	return fmt.Sprintf("exit status %d", e.code)
+}
+
+func init() {
+	rootCmd.Version = versionString()
+	rootCmd.SetVersionTemplate("{{ .Version }}\n")
+	rootCmd.PersistentFlags().StringVarP(&rootConfigFile, "config", "f", ".enver.yaml", "configuration file (- reads it from stdin)")
+	rootCmd.PersistentFlags().StringVar(&kubeconfigPath, "kubeconfig", "", "kubeconfig file to use (default $KUBECONFIG or ~/.kube/config)")
+	rootCmd.PersistentFlags().StringVarP(&namespaceOverride, "namespace", "n", "", "namespace for all sources, overriding their namespace in the configuration file")
+	rootCmd.PersistentFlags().StringVar(&profileName, "profile", "", "profile of the configuration file to apply, overriding the namespace, kube-context and output directory")
+	rootCmd.RegisterFlagCompletionFunc("profile", completeProfiles)
+	rootCmd.PersistentFlags().BoolVar(&inCluster, "in-cluster", false, "use the service account of the pod enver runs in instead of a kubeconfig")
+	rootCmd.PersistentFlags().BoolVar(&noInput, "no-input", false, "never prompt: fail if a required selection is not given with flags (also enabled by CI=true)")
+	rootCmd.PersistentFlags().BoolVarP(&quiet, "quiet", "q", false, "only print errors and the output of the command: no status messages and no warnings about variables skipped because their value is empty")
+	rootCmd.PersistentFlags().BoolVar(&verbose, "verbose", false, "also print the number of variables fetched from each source")
+	rootCmd.MarkFlagsMutuallyExclusive("quiet", "verbose")
+	rootCmd.PersistentFlags().StringVar(&gitignoreMode, "gitignore", gitutil.ModeAuto, "how to handle written files that are not in .gitignore: auto (prompt, or add the file without a terminal), file, dir or skip")
+	rootCmd.PersistentFlags().BoolVar(&gitignoreLocal, "gitignore-local", false, "add entries for written files to .git/info/exclude instead of .gitignore, so there is no change to commit")
+	rootCmd.PersistentFlags().Float32Var(&clientQPS, "qps", 20, "maximum queries per second to the Kubernetes API server")
+	rootCmd.PersistentFlags().IntVar(&clientBurst, "burst", 40, "maximum burst of queries to the Kubernetes API server above --qps")
+	rootCmd.PersistentFlags().IntVar(&fetchConcurrency, "fetch-concurrency", 8, "maximum number of sources of an execution fetched at the same time (0 = unlimited)")
+	rootCmd.PersistentFlags().DurationVar(&timeout, "timeout", 0, "abort the command if it takes longer than this, e.g. 30s or 2m (0 = no limit)")
+	rootCmd.PersistentFlags().IntVar(&retries, "retries", 3, "how often a request to the API server is retried after a timeout, 429 or 5xx error, with exponential backoff (0 = no retries)")
+	rootCmd.PersistentFlags().IntVar(&execConcurrency, "exec-concurrency", 0, "maximum number of concurrent exec sessions into containers (0 = unlimited)")
+}
+
+// infof prints a status message to stdout unless --quiet is set
+func infof(format string, args ...any) {
+	if !quiet {
+		fmt.Printf(format, args...)
+	}
+}
+
+// warnf prints a warning to stderr unless --quiet is set
+func warnf(format string, args ...any) {
+	if !quiet {
+		fmt.Fprintf(os.Stderr, format, args...)
+	}
+}
+
+// nonInteractive returns true if prompts are disabled with --no-input or because enver runs in CI
+func nonInteractive() bool {
+	if noInput {
+		return true
+	}
+	ci, _ := strconv.ParseBool(os.Getenv("CI"))
+	return ci
 }
 
 func Execute() {
-	if err := rootCmd.Execute(); err != nil {
+	// Ctrl+C cancels in-flight Kubernetes requests and exec streams
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	err := rootCmd.ExecuteContext(ctx)
+	cancelTimeout()
+	stop()
+
+	if err != nil {
+		var exitErr *exitCodeError
+		if errors.As(err, &exitErr) {
+			os.Exit(exitErr.code)
+		}
 		os.Exit(1)
 	}
 }
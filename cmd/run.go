@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+
+	"github.com/spf13/cobra"
+)
+
+var runNames []string
+var runAll bool
+var runInputFile string
+
+var runCmd = &cobra.Command{
+	Use:   "run -- <command> [args...]",
+	Short: "Run a command with the environment of predefined executions",
+	Long:  `Reads the .enver.yaml file, collects the environment variables of the selected executions in memory and runs the given command with those variables added to its environment. No .env file is written. The command's exit code is forwarded.`,
+	Args:  cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		config, err := loadExecuteConfig(runInputFile)
+		if err != nil {
+			return err
+		}
+
+		selectedExecutions, err := selectExecutions(config, runNames, runAll)
+		if err != nil {
+			return err
+		}
+
+		// Use default loading rules (respects KUBECONFIG env var)
+		clients := newKubeClientCache(kubeconfigLoadingRules())
+
+		// Cancelled on Ctrl+C or when --timeout expires
+		ctx := cmd.Context()
+
+		// Later executions override earlier ones for the same key, as the last occurrence wins
+		env := os.Environ()
+		for _, execution := range selectedExecutions {
+			outputDirectory, _, err := executionOutput(execution, false)
+			if err != nil {
+				return fmt.Errorf("%s: %w", execution.Name, err)
+			}
+
+			envData, _, err := collectExecution(ctx, execution, config.Sources, clients, fileDirectory(outputDirectory), false, false)
+			if err != nil {
+				return fmt.Errorf("%s: %w", execution.Name, err)
+			}
+			if err := validateValues(envData, config.Validations); err != nil {
+				return fmt.Errorf("%s: %w", execution.Name, err)
+			}
+			for _, entry := range envData {
+				env = append(env, entry.Key+"="+entry.Value)
+			}
+		}
+
+		child := exec.Command(args[0], args[1:]...)
+		child.Env = env
+		child.Stdin = os.Stdin
+		child.Stdout = os.Stdout
+		child.Stderr = os.Stderr
+
+		if err := child.Start(); err != nil {
+			return fmt.Errorf("failed to start %s: %w", args[0], err)
+		}
+
+		// Ctrl+C reaches the child directly, as the terminal signals the whole foreground process
+		// group, so it is only caught to keep enver running until the child exits. SIGTERM is sent
+		// to enver alone and forwarded so the child can shut down gracefully.
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+		defer signal.Stop(signals)
+		go func() {
+			for sig := range signals {
+				if sig == syscall.SIGTERM {
+					_ = child.Process.Signal(sig)
+				}
+			}
+		}()
+
+		err = child.Wait()
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			// The child already reported its own failure
+			cmd.SilenceErrors = true
+			cmd.SilenceUsage = true
+			return &exitCodeError{code: childExitCode(exitErr)}
+		}
+		if err != nil {
+			return fmt.Errorf("failed to run %s: %w", args[0], err)
+		}
+		return nil
+	},
+}
+
+// childExitCode returns the exit code of the child, or 128 plus the signal number when a signal
+// ended it, like a shell reports it
+func childExitCode(exitErr *exec.ExitError) int {
+	if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		return 128 + int(status.Signal())
+	}
+	return exitErr.ExitCode()
+}
+
+func init() {
+	addDeprecatedInputFlag(runCmd, &runInputFile)
+	runCmd.Flags().StringArrayVar(&runNames, "name", []string{}, "execution name to collect the environment from (can be repeated)")
+	runCmd.RegisterFlagCompletionFunc("name", completeExecutionNames(&runInputFile))
+	runCmd.Flags().BoolVar(&runAll, "all", false, "collect the environment from all executions")
+	rootCmd.AddCommand(runCmd)
+}
@@ -0,0 +1,32 @@
+package cmd
+
+import (
+	"errors"
+	"os/exec"
+	"testing"
+)
+
+func TestChildExitCode(t *testing.T) {
+	testCases := []struct {
+		name     string
+		script   string
+		expected int
+	}{
+		{name: "exit code", script: "exit 3", expected: 3},
+		{name: "terminated", script: "kill -TERM $$", expected: 143},
+		{name: "interrupted", script: "kill -INT $$", expected: 130},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := exec.Command("sh", "-c", tc.script).Run()
+			var exitErr *exec.ExitError
+			if !errors.As(err, &exitErr) {
+				t.Fatalf("expected an exit error, got %v", err)
+			}
+			if code := childExitCode(exitErr); code != tc.expected {
+				t.Errorf("expected exit code %d, got %d", tc.expected, code)
+			}
+		})
+	}
+}
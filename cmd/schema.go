@@ -0,0 +1,168 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Inspect the configuration file format",
+}
+
+var configSchemaCmd = &cobra.Command{
+	Use:   "schema",
+	Short: "Print the JSON Schema of the configuration file",
+	Long:  `Prints a JSON Schema for .enver.yaml, generated from the configuration structs so it always matches the fields this version of enver reads. Point your editor at it for autocompletion and to catch typos.`,
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		content, err := json.MarshalIndent(configSchema(), "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to render schema: %w", err)
+		}
+		fmt.Fprintln(cmd.OutOrStdout(), string(content))
+		return nil
+	},
+}
+
+// configSchema returns the JSON Schema of the configuration file
+func configSchema() map[string]any {
+	schema := typeSchema(reflect.TypeOf(ExecuteConfig{}))
+	schema["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	schema["title"] = "enver configuration"
+	return schema
+}
+
+// typeSchema returns the schema of a configuration type. Struct fields are named after their yaml
+// tag and unknown fields are not allowed.
+func typeSchema(t reflect.Type) map[string]any {
+	switch t.Kind() {
+	case reflect.Pointer:
+		return typeSchema(t.Elem())
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": typeSchema(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": typeSchema(t.Elem())}
+	case reflect.Struct:
+		properties := make(map[string]any)
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			name, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+			if !field.IsExported() || name == "-" {
+				continue
+			}
+			if name == "" {
+				name = strings.ToLower(field.Name)
+			}
+			properties[name] = typeSchema(field.Type)
+		}
+		return map[string]any{"type": "object", "properties": properties, "additionalProperties": false}
+	default:
+		return map[string]any{}
+	}
+}
+
+// checkConfigSchema checks the configuration file, without its includes, against the schema and
+// returns the unknown fields and values of the wrong type
+func checkConfigSchema(configFile string) ([]string, error) {
+	content, err := readConfigFile(configFile)
+	if err != nil {
+		return nil, err
+	}
+	var value any
+	if err := unmarshalConfig(content, &value); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", configFile, err)
+	}
+	return validateSchema(configSchema(), value, ""), nil
+}
+
+// validateSchema returns the problems of a parsed YAML value against a schema from typeSchema. An
+// empty value matches every type, and scalars match strings as YAML reads them as strings too.
+func validateSchema(schema map[string]any, value any, path string) []string {
+	if value == nil {
+		return nil
+	}
+	label := path
+	if label == "" {
+		label = "configuration"
+	}
+
+	switch schema["type"] {
+	case "object":
+		object, ok := value.(map[string]any)
+		if !ok {
+			return []string{fmt.Sprintf("%s: expected an object", label)}
+		}
+		var problems []string
+		properties, _ := schema["properties"].(map[string]any)
+		for _, key := range slices.Sorted(maps.Keys(object)) {
+			fieldPath := joinSchemaPath(path, key)
+			if fieldSchema, ok := properties[key].(map[string]any); ok {
+				problems = append(problems, validateSchema(fieldSchema, object[key], fieldPath)...)
+				continue
+			}
+			switch additional := schema["additionalProperties"].(type) {
+			case map[string]any:
+				problems = append(problems, validateSchema(additional, object[key], fieldPath)...)
+			case bool:
+				if !additional {
+					problems = append(problems, fmt.Sprintf("%s: unknown field", fieldPath))
+				}
+			}
+		}
+		return problems
+	case "array":
+		items, ok := value.([]any)
+		if !ok {
+			return []string{fmt.Sprintf("%s: expected a list", label)}
+		}
+		var problems []string
+		itemSchema, _ := schema["items"].(map[string]any)
+		for i, item := range items {
+			problems = append(problems, validateSchema(itemSchema, item, fmt.Sprintf("%s[%d]", path, i))...)
+		}
+		return problems
+	case "string":
+		switch value.(type) {
+		case map[string]any, []any:
+			return []string{fmt.Sprintf("%s: expected a string", label)}
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return []string{fmt.Sprintf("%s: expected true or false", label)}
+		}
+	case "integer":
+		if _, ok := value.(int); !ok {
+			return []string{fmt.Sprintf("%s: expected an integer", label)}
+		}
+	}
+	return nil
+}
+
+// joinSchemaPath returns the path of a field, e.g. sources[0].name
+func joinSchemaPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func init() {
+	configCmd.AddCommand(configSchemaCmd)
+	rootCmd.AddCommand(configCmd)
+}
@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestConfigSchemaAcceptsKnownGoodConfigs(t *testing.T) {
+	for _, configFile := range []string{"../.enver.yaml", "../tests/e2e/testdata/.enver.yaml"} {
+		problems, err := checkConfigSchema(configFile)
+		if err != nil {
+			t.Fatalf("checkConfigSchema(%s) returned error: %v", configFile, err)
+		}
+		if len(problems) > 0 {
+			t.Errorf("expected %s to match the schema, got:\n  %s", configFile, strings.Join(problems, "\n  "))
+		}
+	}
+}
+
+func TestConfigSchemaRejectsKnownBadConfig(t *testing.T) {
+	configFile := filepath.Join(t.TempDir(), ".enver.yaml")
+	content := `sources:
+  - type: ConfigMap
+    name: app-config
+    namspace: default
+    optional: "yes"
+  - type: Vars
+    vars: HOST
+executions:
+  - name: local
+    output:
+      directory: [generated]
+`
+	if err := os.WriteFile(configFile, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	problems, err := checkConfigSchema(configFile)
+	if err != nil {
+		t.Fatalf("checkConfigSchema returned error: %v", err)
+	}
+	// Fields are reported in alphabetical order
+	expected := []string{
+		"executions[0].output.directory: expected a string",
+		"sources[0].namspace: unknown field",
+		"sources[0].optional: expected true or false",
+		"sources[1].vars: expected a list",
+	}
+	if strings.Join(problems, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("expected problems:\n  %s\ngot:\n  %s", strings.Join(expected, "\n  "), strings.Join(problems, "\n  "))
+	}
+}
@@ -0,0 +1,103 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+
+	"enver/sources"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+var configShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Print the effective configuration",
+	Long:  `Prints the configuration as the other commands use it: with its includes merged, environment variables expanded, the --profile applied and --namespace set on all sources. Empty fields are left out. Templated source names and output paths are printed as written, they are expanded per execution.`,
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		config, err := readConfig(configFilePath(""))
+		if err != nil {
+			return err
+		}
+
+		content, err := renderEffectiveConfig(config)
+		if err != nil {
+			return err
+		}
+		fmt.Fprint(cmd.OutOrStdout(), content)
+		return nil
+	},
+}
+
+// renderEffectiveConfig renders the configuration as YAML after the command line overrides that
+// apply to all commands. The includes and top-level transformations are left out as they are
+// already merged into the sources.
+func renderEffectiveConfig(config *ExecuteConfig) (string, error) {
+	effective := *config
+	effective.Includes = nil
+	effective.Transformations = nil
+	if namespaceOverride != "" {
+		effective.Sources = make([]sources.Source, len(config.Sources))
+		for i, source := range config.Sources {
+			source.Namespace = namespaceOverride
+			effective.Sources[i] = source
+		}
+	}
+
+	var node yaml.Node
+	if err := node.Encode(&effective); err != nil {
+		return "", fmt.Errorf("failed to render configuration: %w", err)
+	}
+	pruneEmpty(&node)
+
+	var content bytes.Buffer
+	encoder := yaml.NewEncoder(&content)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&node); err != nil {
+		return "", fmt.Errorf("failed to render configuration: %w", err)
+	}
+	return content.String(), nil
+}
+
+// pruneEmpty removes the fields with an empty value, such as "", false, null or an empty list, from
+// the mappings in the node, so only the configured fields remain
+func pruneEmpty(node *yaml.Node) {
+	for _, child := range node.Content {
+		pruneEmpty(child)
+	}
+	if node.Kind != yaml.MappingNode {
+		return
+	}
+	var content []*yaml.Node
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if !isEmptyNode(node.Content[i+1]) {
+			content = append(content, node.Content[i], node.Content[i+1])
+		}
+	}
+	node.Content = content
+}
+
+// isEmptyNode returns true for the zero value of a field
+func isEmptyNode(node *yaml.Node) bool {
+	switch node.Kind {
+	case yaml.MappingNode, yaml.SequenceNode:
+		return len(node.Content) == 0
+	case yaml.ScalarNode:
+		switch node.Tag {
+		case "!!null":
+			return true
+		case "!!bool":
+			return node.Value == "false"
+		case "!!int":
+			return node.Value == "0"
+		case "!!str":
+			return node.Value == ""
+		}
+	}
+	return false
+}
+
+func init() {
+	configCmd.AddCommand(configShowCmd)
+}
@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestConfigShowPrintsResolvedConfig(t *testing.T) {
+	t.Chdir(t.TempDir())
+	t.Setenv("APP_HOST", "app.example.com")
+
+	files := map[string]string{
+		".enver.yaml": `includes:
+  - base.yaml
+sources:
+  - type: Vars
+    name: inline
+    vars:
+      - name: HOST
+        value: ${APP_HOST}
+executions:
+  - name: local
+    output:
+      directory: generated
+profiles:
+  staging:
+    kube-context: staging-cluster
+    directory: out/staging
+`,
+		"base.yaml": `contexts:
+  - dev
+sources:
+  - type: ConfigMap
+    name: app-config
+    namespace: default
+`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(name, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	defer func() { profileName = ""; namespaceOverride = "" }()
+
+	var out bytes.Buffer
+	rootCmd.SetOut(&out)
+	defer rootCmd.SetOut(nil)
+	rootCmd.SetArgs([]string{"config", "show", "--profile", "staging", "--namespace", "apps"})
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("config show returned error: %v", err)
+	}
+
+	expected := `contexts:
+  - dev
+sources:
+  - name: inline
+    namespace: apps
+    type: Vars
+    vars:
+      - name: HOST
+        value: app.example.com
+  - name: app-config
+    namespace: apps
+    type: ConfigMap
+executions:
+  - name: local
+    output:
+      directory: out/staging
+    kube-context: staging-cluster
+profiles:
+  staging:
+    kube-context: staging-cluster
+    directory: out/staging
+`
+	if out.String() != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, out.String())
+	}
+	if _, err := os.Stat(filepath.Join("out", "staging")); !os.IsNotExist(err) {
+		t.Errorf("expected nothing to be written, got %v", err)
+	}
+}
@@ -0,0 +1,215 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"enver/engine"
+	"enver/output"
+	"enver/sources"
+	"enver/transformations"
+
+	"github.com/spf13/cobra"
+)
+
+var validateInputFile string
+var validateSchemaFlag bool
+
+var validateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Validate the .enver.yaml file",
+	Long:  `Parses the .enver.yaml file and checks all sources and executions for problems that would otherwise only fail at runtime, such as unknown source or transformation types and executions using Kubernetes sources without a kube-context. No cluster is contacted. All problems are reported at once.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		configFile := configFilePath(validateInputFile)
+		config, err := readConfig(configFile)
+		if err != nil {
+			return err
+		}
+
+		problems := validateConfig(config)
+		if validateSchemaFlag {
+			schemaProblems, err := checkConfigSchema(configFile)
+			if err != nil {
+				return err
+			}
+			problems = append(schemaProblems, problems...)
+		}
+		if len(problems) > 0 {
+			cmd.SilenceUsage = true
+			return fmt.Errorf("%s has %d problem(s):\n  %s", configFile, len(problems), strings.Join(problems, "\n  "))
+		}
+
+		infof("%s is valid (%d sources, %d executions)\n", configFile, len(config.Sources), len(config.Executions))
+		return nil
+	},
+}
+
+// validateConfig checks the configuration without contacting a cluster and returns all problems found
+func validateConfig(config *ExecuteConfig) []string {
+	var problems []string
+
+	if len(config.Sources) == 0 {
+		problems = append(problems, "no sources defined")
+	}
+
+	// The fetchers map is the single list of supported source types
+	fetchers := engine.Fetchers(nil)
+
+	for i, source := range config.Sources {
+		label := fmt.Sprintf("source %d (%s)", i+1, describeSource(source))
+		for _, problem := range validateSource(source, fetchers) {
+			problems = append(problems, fmt.Sprintf("%s: %s", label, problem))
+		}
+	}
+
+	for i, execution := range config.Executions {
+		label := fmt.Sprintf("execution %d (%s)", i+1, execution.Name)
+		if execution.Name == "" {
+			problems = append(problems, fmt.Sprintf("%s: name is required", label))
+		}
+
+		if execution.KubeContext == "" && !inCluster {
+			for _, source := range config.Sources {
+				if source.ShouldInclude(execution.Contexts) && source.NeedsKubernetes() && !source.UsesOwnCluster() {
+					problems = append(problems, fmt.Sprintf("%s: uses Kubernetes source %s but no kube-context is specified", label, describeSource(source)))
+					break
+				}
+			}
+		}
+
+		if _, _, err := executionOutput(execution, false); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", label, err))
+		}
+
+		if err := output.ValidateFormat(execution.Output.Format); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", label, err))
+		}
+
+		if err := checkOutputOptions(execution); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", label, err))
+		}
+
+		if execution.Output.Mode != "" {
+			if _, err := output.ParseMode(execution.Output.Mode); err != nil {
+				problems = append(problems, fmt.Sprintf("%s: %v", label, err))
+			}
+		}
+	}
+
+	if err := checkDuplicates(config.Executions, config.Sources); err != nil {
+		problems = append(problems, err.Error())
+	}
+
+	if err := checkDependencies(config.Executions); err != nil {
+		problems = append(problems, err.Error())
+	}
+
+	if err := checkValidationRules(config.Validations); err != nil {
+		problems = append(problems, err.Error())
+	}
+
+	return problems
+}
+
+// validateSource checks a single source's type, required fields and transformations
+func validateSource(source sources.Source, fetchers map[string]sources.Fetcher) []string {
+	var problems []string
+
+	switch {
+	case source.Type == "":
+		problems = append(problems, "type is required")
+	case fetchers[source.Type] == nil:
+		problems = append(problems, fmt.Sprintf("unknown source type %q", source.Type))
+	}
+
+	switch source.Type {
+	case "EnvFile":
+		if source.Path == "" {
+			problems = append(problems, "path is required")
+		}
+	case "Vars":
+		if len(source.Vars) == 0 {
+			problems = append(problems, "vars is required")
+		}
+	case "Container":
+		if source.Name == "" {
+			problems = append(problems, "name is required")
+		}
+		if _, ok := workloadKinds[source.Kind]; !ok {
+			problems = append(problems, fmt.Sprintf("kind must be one of Pod, Deployment, StatefulSet or DaemonSet, got %q", source.Kind))
+		}
+		if source.Pod != "" && source.Kind != "StatefulSet" {
+			problems = append(problems, "pod is only supported for kind StatefulSet")
+		}
+		switch source.Method {
+		case "", sources.ContainerMethodExec:
+		case sources.ContainerMethodStatic:
+			if len(source.Files) > 0 {
+				problems = append(problems, "files cannot be extracted with method static")
+			}
+		default:
+			problems = append(problems, fmt.Sprintf("method must be exec or static, got %q", source.Method))
+		}
+	case "Metadata":
+		if source.Name == "" {
+			problems = append(problems, "name is required")
+		}
+		if _, ok := sources.MetadataKinds[source.Kind]; !ok {
+			problems = append(problems, fmt.Sprintf("kind must be one of Pod, Deployment, StatefulSet, DaemonSet, ConfigMap or Secret, got %q", source.Kind))
+		}
+		switch source.Metadata {
+		case "", sources.MetadataAnnotations, sources.MetadataLabels, sources.MetadataAll:
+		default:
+			problems = append(problems, fmt.Sprintf("metadata must be annotations, labels or all, got %q", source.Metadata))
+		}
+	default:
+		if source.NeedsKubernetes() && source.Name == "" {
+			problems = append(problems, "name is required")
+		}
+	}
+
+	switch source.MergeContainers {
+	case "", sources.ContainerMergeKeepAll, sources.ContainerMergeLastWins, sources.ContainerMergePrefix:
+	default:
+		problems = append(problems, fmt.Sprintf("mergeContainers must be keep-all, last-wins or prefix, got %q", source.MergeContainers))
+	}
+
+	if err := sources.ValidateVariableMode(source.Variables.Mode); err != nil {
+		problems = append(problems, err.Error())
+	}
+
+	if _, err := renderTemplate("source name", source.Name, outputPathData{}); err != nil {
+		problems = append(problems, err.Error())
+	}
+
+	for i, cfg := range source.Transformations {
+		if cfg.Target != "" && cfg.Target != "key" && cfg.Target != "value" {
+			problems = append(problems, fmt.Sprintf("transformation %d: target must be key or value, got %q", i+1, cfg.Target))
+		}
+
+		err := transformations.Validate(transformations.Config{
+			Type:   cfg.Type,
+			Target: cfg.Target,
+			Value:  cfg.Value,
+		})
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("transformation %d: %v", i+1, err))
+		}
+	}
+
+	return problems
+}
+
+// workloadKinds are the kinds supported by the Container source
+var workloadKinds = map[string]struct{}{
+	"Pod":         {},
+	"Deployment":  {},
+	"StatefulSet": {},
+	"DaemonSet":   {},
+}
+
+func init() {
+	addDeprecatedInputFlag(validateCmd, &validateInputFile)
+	validateCmd.Flags().BoolVar(&validateSchemaFlag, "schema", false, "also check the configuration file against the schema of enver config schema, reporting unknown fields and values of the wrong type")
+	rootCmd.AddCommand(validateCmd)
+}
@@ -0,0 +1,103 @@
+package cmd
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"enver/sources"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestValidateConfigReportsAllProblems(t *testing.T) {
+	content := `sources:
+  - type: ConfigMap
+    name: app-config
+    transformations:
+      - type: upper_case
+      - type: shell_quote
+        target: key
+  - type: Secrets
+    name: app-secret
+  - type: EnvFile
+  - type: Vars
+    name: inline
+    vars:
+      - name: HOST
+        value: localhost
+    transformations:
+      - type: file
+        output: host.txt
+  - type: Container
+    kind: Pod
+    name: app-pod
+    method: snapshot
+executions:
+  - name: dev
+    contexts:
+      - dev
+  - name: local
+  - name: dev
+    kube-context: kind-kind
+`
+	var config ExecuteConfig
+	if err := yaml.Unmarshal([]byte(content), &config); err != nil {
+		t.Fatalf("failed to parse config: %v", err)
+	}
+
+	problems := validateConfig(&config)
+
+	expected := []string{
+		`source 1 (ConfigMap default/app-config): transformation 1: unknown transformation type: upper_case`,
+		`source 1 (ConfigMap default/app-config): transformation 2: shell_quote transformation can only be applied to values`,
+		`source 2 (Secrets app-secret): unknown source type "Secrets"`,
+		`source 3 (EnvFile): path is required`,
+		`source 5 (Container default/app-pod): method must be exec or static, got "snapshot"`,
+		`execution 1 (dev): uses Kubernetes source ConfigMap default/app-config but no kube-context is specified`,
+		`execution 2 (local): uses Kubernetes source ConfigMap default/app-config but no kube-context is specified`,
+		`duplicate execution names: dev`,
+	}
+	if strings.Join(problems, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("expected problems:\n%s\ngot:\n%s", strings.Join(expected, "\n"), strings.Join(problems, "\n"))
+	}
+}
+
+func TestValidateConfigAcceptsE2EConfig(t *testing.T) {
+	content, err := os.ReadFile("../tests/e2e/testdata/.enver.yaml")
+	if err != nil {
+		t.Fatalf("failed to read e2e config: %v", err)
+	}
+
+	var config ExecuteConfig
+	if err := yaml.Unmarshal(content, &config); err != nil {
+		t.Fatalf("failed to parse e2e config: %v", err)
+	}
+
+	if problems := validateConfig(&config); len(problems) > 0 {
+		t.Errorf("expected the e2e config to be valid, got:\n%s", strings.Join(problems, "\n"))
+	}
+}
+
+func TestValidateConfigInClusterDoesNotRequireKubeContext(t *testing.T) {
+	config := ExecuteConfig{
+		Sources:    []sources.Source{{Type: "ConfigMap", Name: "app-config"}},
+		Executions: []Execution{{Name: "init"}},
+	}
+
+	if problems := validateConfig(&config); len(problems) != 1 {
+		t.Fatalf("expected the missing kube-context to be reported, got %v", problems)
+	}
+
+	inCluster = true
+	defer func() { inCluster = false }()
+	if problems := validateConfig(&config); len(problems) > 0 {
+		t.Errorf("expected no problems with --in-cluster, got %v", problems)
+	}
+
+	// Outside a pod the in-cluster config cannot be loaded
+	t.Setenv("KUBERNETES_SERVICE_HOST", "")
+	if _, err := loadRESTConfig(nil, ""); err == nil || !strings.Contains(err.Error(), "failed to load in-cluster config") {
+		t.Errorf("expected an in-cluster config error, got %v", err)
+	}
+}
@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"enver/output"
+	"enver/sources"
+)
+
+// ValueValidation is a rule a resolved value must satisfy
+type ValueValidation struct {
+	Regex string `yaml:"regex"` // the value must match this regular expression
+}
+
+// checkValidationRules returns an error listing the rules whose regex does not compile
+func checkValidationRules(validations map[string]ValueValidation) error {
+	var problems []string
+	for _, key := range sortedValidationKeys(validations) {
+		if _, err := regexp.Compile(validations[key].Regex); err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", key, err))
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid validations:\n  %s", strings.Join(problems, "\n  "))
+	}
+	return nil
+}
+
+// validateValues checks the resolved entries against the validation rules of their key and returns
+// an error naming every key and pattern that didn't match. Keys without a rule are not checked.
+func validateValues(envData []sources.EnvEntry, validations map[string]ValueValidation) error {
+	if len(validations) == 0 {
+		return nil
+	}
+
+	var problems []string
+	for _, entry := range envData {
+		validation, ok := validations[entry.Key]
+		if !ok {
+			continue
+		}
+		re, err := regexp.Compile(validation.Regex)
+		if err != nil {
+			return fmt.Errorf("invalid validation for %s: %w", entry.Key, err)
+		}
+		if !re.MatchString(entry.Value) {
+			problems = append(problems, fmt.Sprintf("%s (%s) does not match %s", entry.Key, output.EntrySource(entry), validation.Regex))
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("values failed validation:\n    %s", strings.Join(problems, "\n    "))
+	}
+	return nil
+}
+
+// sortedValidationKeys returns the keys of the validation rules in a stable order
+func sortedValidationKeys(validations map[string]ValueValidation) []string {
+	keys := make([]string, 0, len(validations))
+	for key := range validations {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
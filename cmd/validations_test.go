@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExecuteFailsOnValueValidation(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	config := `sources:
+  - type: Vars
+    name: inline
+    vars:
+      - name: DATABASE_URL
+        value: mysql://localhost/app
+      - name: LOG_LEVEL
+        value: debug
+executions:
+  - name: local
+validations:
+  DATABASE_URL:
+    regex: ^postgres://
+  LOG_LEVEL:
+    regex: ^(debug|info)$
+`
+	if err := os.WriteFile(".enver.yaml", []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { executeAll = false }()
+
+	rootCmd.SetArgs([]string{"execute", "--all"})
+	err := rootCmd.Execute()
+	if err == nil {
+		t.Fatal("expected the execution to fail validation")
+	}
+	if !strings.Contains(err.Error(), "DATABASE_URL (Vars inline) does not match ^postgres://") {
+		t.Errorf("expected the error to name the key and pattern, got %v", err)
+	}
+	if strings.Contains(err.Error(), "LOG_LEVEL") {
+		t.Errorf("expected LOG_LEVEL to pass validation, got %v", err)
+	}
+
+	// Nothing is written when a value fails validation
+	if _, err := os.Stat(filepath.Join("generated", ".env")); !os.IsNotExist(err) {
+		t.Errorf("expected no output file, got %v", err)
+	}
+}
+
+func TestCheckValidationRules(t *testing.T) {
+	if err := checkValidationRules(map[string]ValueValidation{"PORT": {Regex: `^\d+$`}}); err != nil {
+		t.Errorf("expected a valid rule, got %v", err)
+	}
+
+	err := checkValidationRules(map[string]ValueValidation{"PORT": {Regex: "("}})
+	if err == nil || !strings.Contains(err.Error(), "PORT") {
+		t.Errorf("expected an error naming PORT, got %v", err)
+	}
+}
@@ -0,0 +1,26 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+var versionCmd = &cobra.Command{
+	Use:   "version",
+	Short: "Print the version of enver",
+	Long:  `Prints the version, commit and build date of enver. Include it when reporting an issue.`,
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		fmt.Fprintln(cmd.OutOrStdout(), versionString())
+	},
+}
+
+// versionString describes the build, e.g. "enver 1.2.0 (commit 3f2c1ab, built 2024-05-01T10:00:00Z)"
+func versionString() string {
+	return fmt.Sprintf("enver %s (commit %s, built %s)", version, commit, date)
+}
+
+func init() {
+	rootCmd.AddCommand(versionCmd)
+}
@@ -0,0 +1,248 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	"enver/engine"
+	"enver/sources"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/tools/cache"
+)
+
+// watchDebounce is how long to wait after a change before regenerating, so a burst of updates
+// results in a single run
+const watchDebounce = time.Second
+
+// watchTarget is a ConfigMap or Secret an execution reads
+type watchTarget struct {
+	kind      string // ConfigMap or Secret
+	namespace string
+	name      string
+	client    *engine.Client
+}
+
+// executionWatchTargets returns the ConfigMaps and Secrets the sources of the execution read: those
+// of ConfigMap and Secret sources and those workload sources reference, see sources.References.
+// Only these objects are watched, so no list or watch access is needed beyond them.
+func executionWatchTargets(ctx context.Context, execution Execution, configSources []sources.Source, clients *kubeClientCache) ([]watchTarget, error) {
+	executionSources, client, err := resolveExecutionSources(execution, configSources, clients)
+	if err != nil {
+		return nil, err
+	}
+	executionSources, sourceClients, err := engine.Select(executionSources, sourceSelection(execution.Contexts, client, clients, "", false))
+	if err != nil {
+		return nil, err
+	}
+
+	var targets []watchTarget
+	for i, source := range executionSources {
+		if sourceClients[i] == nil {
+			continue
+		}
+		references, err := sources.References(ctx, sourceClients[i].Clientset, source)
+		if err != nil {
+			return nil, fmt.Errorf("source %s: %w", describeSource(source), err)
+		}
+		for _, reference := range references {
+			target := watchTarget{
+				kind:      reference.Kind,
+				namespace: source.GetNamespace(),
+				name:      reference.Name,
+				client:    sourceClients[i],
+			}
+			if !slices.Contains(targets, target) {
+				targets = append(targets, target)
+			}
+		}
+	}
+	return targets, nil
+}
+
+// executionWatch is a watched execution with the channel its informers signal changes on
+type executionWatch struct {
+	execution Execution
+	changes   chan struct{}
+}
+
+// startWatches starts the informers of the executions and waits until they listed their objects.
+// They are started before the first run, so a change made while it runs is not missed but
+// regenerates the execution once watchExecutions runs.
+func startWatches(ctx context.Context, executions []Execution, config *ExecuteConfig) ([]executionWatch, error) {
+	watchClients := newKubeClientCache(kubeconfigLoadingRules())
+
+	var watches []executionWatch
+	var synced []cache.InformerSynced
+	for _, execution := range executions {
+		targets, err := executionWatchTargets(ctx, execution, config.Sources, watchClients)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", execution.Name, err)
+		}
+		if executeRBACCheck {
+			if err := checkWatchAccess(ctx, targets); err != nil {
+				return nil, fmt.Errorf("%s: %w", execution.Name, err)
+			}
+		}
+		if len(targets) == 0 {
+			executeLog.info("watch_skipped", fmt.Sprintf("  [%s] No ConfigMaps or Secrets to watch", execution.Name), "execution", execution.Name)
+			continue
+		}
+
+		changes := make(chan struct{}, 1)
+		for _, target := range targets {
+			synced = append(synced, startWatch(ctx, target, changes).HasSynced)
+		}
+		watches = append(watches, executionWatch{execution: execution, changes: changes})
+	}
+
+	if !cache.WaitForCacheSync(ctx.Done(), synced...) {
+		return nil, fmt.Errorf("failed to start watching: %w", context.Cause(ctx))
+	}
+	return watches, nil
+}
+
+// checkWatchAccess runs the RBAC preflight for watching the targets, against the cluster of each
+func checkWatchAccess(ctx context.Context, targets []watchTarget) error {
+	var clientOrder []*engine.Client
+	grouped := make(map[*engine.Client][]sources.Source)
+	for _, target := range targets {
+		if _, ok := grouped[target.client]; !ok {
+			clientOrder = append(clientOrder, target.client)
+		}
+		grouped[target.client] = append(grouped[target.client], sources.Source{Type: target.kind, Namespace: target.namespace, Name: target.name})
+	}
+
+	var errs []error
+	for _, client := range clientOrder {
+		if err := checkSourceAccess(ctx, client.Clientset, grouped[client], sources.CheckWatchAccess); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// watchExecutions regenerates each execution when one of the objects it reads changes, until ctx is done
+func watchExecutions(ctx context.Context, watches []executionWatch, config *ExecuteConfig, outputMu *sync.Mutex) error {
+	var wg sync.WaitGroup
+	for _, watched := range watches {
+		wg.Add(1)
+		go func(watched executionWatch) {
+			defer wg.Done()
+			regenerateOnChange(ctx, watched.execution, config, watched.changes, outputMu)
+		}(watched)
+	}
+
+	executeLog.info("watch_started", "Watching for changes, press Ctrl+C to stop")
+	wg.Wait()
+	return nil
+}
+
+// regenerateOnChange reruns the execution once no change arrived for watchDebounce
+func regenerateOnChange(ctx context.Context, execution Execution, config *ExecuteConfig, changes <-chan struct{}, outputMu *sync.Mutex) {
+	var debounce <-chan time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-changes:
+			debounce = time.After(watchDebounce)
+		case <-debounce:
+			debounce = nil
+
+			outputMu.Lock()
+			executeLog.info("change_detected", fmt.Sprintf("  [%s] Change detected at %s, regenerating", execution.Name, time.Now().Format(time.TimeOnly)), "execution", execution.Name)
+			outputMu.Unlock()
+
+			// New clients so the ConfigMap and Secret cache doesn't return the previous objects
+			clients := newKubeClientCache(kubeconfigLoadingRules())
+			if _, err := runExecution(ctx, execution, config, clients, newLockRecorder(nil), outputMu); err != nil {
+				outputMu.Lock()
+				executeLog.log(slog.LevelError, "regeneration_failed", fmt.Sprintf("  [%s] Regeneration failed: %v", execution.Name, err), "execution", execution.Name, "error", err.Error())
+				outputMu.Unlock()
+			}
+		}
+	}
+}
+
+// startWatch runs an informer limited to the target object and signals changes on the channel.
+// The informer re-lists and re-watches after a dropped connection; objects that didn't change
+// meanwhile are not reported.
+func startWatch(ctx context.Context, target watchTarget, changes chan<- struct{}) cache.Controller {
+	selector := fields.OneTermEqualSelector("metadata.name", target.name).String()
+	coreV1 := target.client.Clientset.CoreV1()
+
+	var objectType runtime.Object
+	listWatch := &cache.ListWatch{}
+	switch target.kind {
+	case "ConfigMap":
+		objectType = &corev1.ConfigMap{}
+		listWatch.ListWithContextFunc = func(ctx context.Context, options metav1.ListOptions) (runtime.Object, error) {
+			options.FieldSelector = selector
+			return coreV1.ConfigMaps(target.namespace).List(ctx, options)
+		}
+		listWatch.WatchFuncWithContext = func(ctx context.Context, options metav1.ListOptions) (watch.Interface, error) {
+			options.FieldSelector = selector
+			return coreV1.ConfigMaps(target.namespace).Watch(ctx, options)
+		}
+	default:
+		objectType = &corev1.Secret{}
+		listWatch.ListWithContextFunc = func(ctx context.Context, options metav1.ListOptions) (runtime.Object, error) {
+			options.FieldSelector = selector
+			return coreV1.Secrets(target.namespace).List(ctx, options)
+		}
+		listWatch.WatchFuncWithContext = func(ctx context.Context, options metav1.ListOptions) (watch.Interface, error) {
+			options.FieldSelector = selector
+			return coreV1.Secrets(target.namespace).Watch(ctx, options)
+		}
+	}
+
+	notify := func() {
+		select {
+		case changes <- struct{}{}:
+		default:
+		}
+	}
+
+	_, controller := cache.NewInformerWithOptions(cache.InformerOptions{
+		ListerWatcher: cache.ToListWatcherWithWatchListSemantics(listWatch, target.client.Clientset),
+		ObjectType:    objectType,
+		Handler: cache.ResourceEventHandlerDetailedFuncs{
+			AddFunc: func(obj interface{}, isInInitialList bool) {
+				if !isInInitialList {
+					notify()
+				}
+			},
+			UpdateFunc: func(oldObj, newObj interface{}) {
+				// A re-list after a reconnect reports every object as updated
+				if resourceVersion(oldObj) != resourceVersion(newObj) {
+					notify()
+				}
+			},
+			DeleteFunc: func(obj interface{}) {
+				notify()
+			},
+		},
+	})
+	go controller.RunWithContext(ctx)
+	return controller
+}
+
+// resourceVersion returns the resourceVersion of a watched object
+func resourceVersion(obj interface{}) string {
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return ""
+	}
+	return accessor.GetResourceVersion()
+}
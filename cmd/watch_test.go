@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"enver/engine"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestStartWatchReportsChangesOfTheTarget(t *testing.T) {
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "settings", Namespace: "default", ResourceVersion: "1"},
+		Data:       map[string]string{"REGION": "eu"},
+	}
+	clientset := fake.NewClientset(configMap)
+	target := watchTarget{kind: "ConfigMap", namespace: "default", name: "settings", client: &engine.Client{Clientset: clientset}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	changes := make(chan struct{}, 1)
+	controller := startWatch(ctx, target, changes)
+	if !cache.WaitForCacheSync(ctx.Done(), controller.HasSynced) {
+		t.Fatal("informer did not sync")
+	}
+
+	// The initial list is not a change
+	select {
+	case <-changes:
+		t.Fatal("expected no change for the initial list")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	updated := configMap.DeepCopy()
+	updated.ResourceVersion = "2"
+	updated.Data["REGION"] = "us"
+	if _, err := clientset.CoreV1().ConfigMaps("default").Update(ctx, updated, metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-changes:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the update to be reported")
+	}
+}
@@ -0,0 +1,66 @@
+package cryptutil
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestEncryptDecryptRoundTrip(t *testing.T) {
+	plaintext := []byte("# Secret default/db\nDB_PASSWORD=s3cr3t\n")
+
+	encrypted, err := Encrypt(plaintext, "correct horse")
+	if err != nil {
+		t.Fatalf("Encrypt returned error: %v", err)
+	}
+	if !IsEncrypted(encrypted) || bytes.Contains(encrypted, []byte("s3cr3t")) {
+		t.Fatalf("expected encrypted output, got %q", encrypted)
+	}
+
+	decrypted, err := Decrypt(encrypted, "correct horse")
+	if err != nil {
+		t.Fatalf("Decrypt returned error: %v", err)
+	}
+	if !bytes.Equal(decrypted, plaintext) {
+		t.Errorf("expected %q, got %q", plaintext, decrypted)
+	}
+
+	// A new salt and nonce for every encryption
+	again, err := Encrypt(plaintext, "correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(again, encrypted) {
+		t.Error("expected different output for every encryption")
+	}
+}
+
+func TestDecryptFailsWithWrongPassphrase(t *testing.T) {
+	encrypted, err := Encrypt([]byte("HOST=localhost\n"), "correct horse")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Decrypt(encrypted, "battery staple"); !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("expected ErrWrongPassphrase for a wrong passphrase, got %v", err)
+	}
+
+	// Flip a character of the ciphertext
+	tampered := bytes.Clone(encrypted)
+	i := len(tampered) - 5
+	if tampered[i] == 'A' {
+		tampered[i] = 'B'
+	} else {
+		tampered[i] = 'A'
+	}
+	if _, err := Decrypt(tampered, "correct horse"); !errors.Is(err, ErrWrongPassphrase) {
+		t.Errorf("expected ErrWrongPassphrase for a modified file, got %v", err)
+	}
+
+	if _, err := Decrypt([]byte("HOST=localhost\n"), "correct horse"); err == nil {
+		t.Error("expected an error for a file that is not encrypted")
+	}
+	if _, err := Encrypt([]byte("HOST=localhost\n"), ""); err == nil {
+		t.Error("expected an error for an empty passphrase")
+	}
+}
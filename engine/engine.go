@@ -0,0 +1,195 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"enver/sources"
+
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+// Client holds the Kubernetes clients sources are fetched with
+type Client struct {
+	Clientset  kubernetes.Interface
+	Dynamic    dynamic.Interface // only needed for KnativeService sources
+	RESTConfig *rest.Config      // only needed for Container sources
+}
+
+// Selection selects the sources to resolve and how they are fetched
+type Selection struct {
+	Contexts []string // only sources included in these contexts, see sources.Source.ShouldInclude
+	Client   *Client  // client of the sources that need Kubernetes, may be nil when none do
+
+	// SourceClient returns the client of a source with its own kubeconfig or kube context, see
+	// sources.Source.UsesOwnCluster. When nil those sources are fetched with Client too.
+	SourceClient func(source sources.Source) (*Client, error)
+
+	// Variables is a filter the variables of every source must pass in addition to the source's
+	// own, such as --include-var and --exclude-var. May be nil.
+	Variables *sources.SourceVariables
+
+	// Preflight runs after the clients are resolved and before anything is fetched, for checks
+	// such as RBAC access. May be nil.
+	Preflight func(ctx context.Context, configSources []sources.Source, clients []*Client) error
+
+	OutputDirectory string // directory of files written by transformations
+	Concurrency     int    // maximum number of sources fetched at the same time (0 = unlimited)
+}
+
+// Result holds the entries fetched from a source, or the error it failed with
+type Result struct {
+	Source       sources.Source
+	Entries      []sources.EnvEntry
+	SkippedEmpty []string // keys skipped because their value was empty, see sources.SkippedEmpty
+	Err          error
+}
+
+// Resolve fetches the sources included in the selection's contexts and returns their entries in
+// the declaration order of the sources. The error of the first failing source is returned.
+func Resolve(ctx context.Context, configSources []sources.Source, selection Selection) ([]sources.EnvEntry, error) {
+	results, err := ResolveSources(ctx, configSources, selection)
+	if err != nil {
+		return nil, err
+	}
+	var envData []sources.EnvEntry
+	for _, result := range results {
+		if result.Err != nil {
+			return nil, result.Err
+		}
+		envData = append(envData, result.Entries...)
+	}
+	return envData, nil
+}
+
+// ResolveSources fetches the sources included in the selection's contexts and returns a result per
+// source, in the declaration order of the sources. Errors of single sources are set on their
+// result, so callers can keep the entries of the others.
+func ResolveSources(ctx context.Context, configSources []sources.Source, selection Selection) ([]Result, error) {
+	included, clients, err := Select(configSources, selection)
+	if err != nil {
+		return nil, err
+	}
+
+	if selection.Preflight != nil {
+		if err := selection.Preflight(ctx, included, clients); err != nil {
+			return nil, err
+		}
+	}
+
+	return Fetch(ctx, included, clients, selection.OutputDirectory, selection.Concurrency)
+}
+
+// Select returns the sources included in the selection's contexts, filtered by the selection's
+// variables, and the client to fetch each of them with (nil for sources that don't need Kubernetes)
+func Select(configSources []sources.Source, selection Selection) ([]sources.Source, []*Client, error) {
+	var included []sources.Source
+	var clients []*Client
+	for _, source := range configSources {
+		if !source.ShouldInclude(selection.Contexts) {
+			continue
+		}
+		if selection.Variables != nil {
+			source.Variables.CommandLine = selection.Variables
+		}
+
+		var client *Client
+		switch {
+		case !source.NeedsKubernetes():
+		case source.UsesOwnCluster() && selection.SourceClient != nil:
+			var err error
+			client, err = selection.SourceClient(source)
+			if err != nil {
+				return nil, nil, fmt.Errorf("source %s %s/%s: %w", source.Type, source.GetNamespace(), source.Name, err)
+			}
+		case selection.Client == nil:
+			return nil, nil, fmt.Errorf("%s %s/%s requires Kubernetes but no client is set", source.Type, source.GetNamespace(), source.Name)
+		default:
+			client = selection.Client
+		}
+
+		included = append(included, source)
+		clients = append(clients, client)
+	}
+	return included, clients, nil
+}
+
+// Fetch fetches every source with the client at the same index and returns a result per source, in
+// the order of the sources. Sources are fetched concurrently, at most concurrency at a time
+// (0 = unlimited). The error is only set for sources without a type or with an unknown type, which
+// are reported before anything is fetched.
+func Fetch(ctx context.Context, configSources []sources.Source, clients []*Client, outputDirectory string, concurrency int) ([]Result, error) {
+	// The fetchers of a client are shared by all its sources
+	clientFetchers := make(map[*Client]map[string]sources.Fetcher)
+	fetchers := make([]sources.Fetcher, len(configSources))
+	for i, source := range configSources {
+		if source.Type == "" {
+			return nil, fmt.Errorf("type is required for source %q in namespace %q", source.Name, source.GetNamespace())
+		}
+
+		if _, ok := clientFetchers[clients[i]]; !ok {
+			clientFetchers[clients[i]] = Fetchers(clients[i])
+		}
+		fetcher, ok := clientFetchers[clients[i]][source.Type]
+		if !ok {
+			return nil, fmt.Errorf("unknown source type %q for %s/%s", source.Type, source.GetNamespace(), source.Name)
+		}
+		fetchers[i] = fetcher
+	}
+
+	limit := concurrency
+	if limit <= 0 || limit > len(configSources) {
+		limit = len(configSources)
+	}
+	semaphore := make(chan struct{}, limit)
+
+	results := make([]Result, len(configSources))
+	var wg sync.WaitGroup
+	for i, source := range configSources {
+		wg.Add(1)
+		go func(i int, source sources.Source) {
+			defer wg.Done()
+			semaphore <- struct{}{}
+			defer func() { <-semaphore }()
+
+			var clientset kubernetes.Interface
+			if clients[i] != nil {
+				clientset = clients[i].Clientset
+			}
+			var skipped sources.SkippedEmpty
+			entries, err := fetchers[i].Fetch(sources.WithSkippedEmpty(ctx, &skipped), clientset, source, outputDirectory)
+			results[i] = Result{Source: source, Entries: entries, SkippedEmpty: skipped.Keys(), Err: err}
+		}(i, source)
+	}
+	wg.Wait()
+	return results, nil
+}
+
+// Fetchers returns the map of source types to their fetchers
+// client may be nil when none of the sources need Kubernetes
+func Fetchers(client *Client) map[string]sources.Fetcher {
+	var restConfig *rest.Config
+	var dynamicClient dynamic.Interface
+	if client != nil {
+		restConfig = client.RESTConfig
+		dynamicClient = client.Dynamic
+	}
+
+	fetchers := map[string]sources.Fetcher{
+		"ConfigMap":      &sources.ConfigMapFetcher{},
+		"Secret":         &sources.SecretFetcher{},
+		"EnvFile":        &sources.EnvFileFetcher{},
+		"Vars":           &sources.VarsFetcher{},
+		"Deployment":     &sources.DeploymentFetcher{},
+		"StatefulSet":    &sources.StatefulSetFetcher{},
+		"DaemonSet":      &sources.DaemonSetFetcher{},
+		"Container":      sources.NewContainerFetcher(restConfig),
+		"KnativeService": sources.NewKnativeServiceFetcher(dynamicClient),
+		"Metadata":       &sources.MetadataFetcher{},
+	}
+	fetchers[sources.AutoType] = sources.NewAutoFetcher(fetchers)
+	return fetchers
+}
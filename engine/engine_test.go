@@ -0,0 +1,178 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"enver/sources"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestResolveFetchesSelectedSources(t *testing.T) {
+	clientset := fake.NewClientset(
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "default"},
+			Data:       map[string]string{"PORT": "8080"},
+		},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "default"},
+			Data:       map[string][]byte{"DB_PASSWORD": []byte("s3cr3t")},
+		},
+	)
+
+	configSources := []sources.Source{
+		{Type: "Vars", Name: "defaults", Vars: []sources.VarEntry{{Name: "HOST", Value: "localhost"}}},
+		{Type: "ConfigMap", Name: "app", Namespace: "default"},
+		{Type: "Secret", Name: "db", Namespace: "default", Contexts: sources.SourceContexts{Include: []string{"prod"}}},
+	}
+
+	tests := []struct {
+		contexts []string
+		expected string
+	}{
+		{contexts: []string{"dev"}, expected: "HOST=localhost,PORT=8080"},
+		{contexts: []string{"prod"}, expected: "HOST=localhost,PORT=8080,DB_PASSWORD=s3cr3t"},
+	}
+	for _, tt := range tests {
+		envData, err := Resolve(t.Context(), configSources, Selection{
+			Contexts:        tt.contexts,
+			Client:          &Client{Clientset: clientset},
+			OutputDirectory: t.TempDir(),
+		})
+		if err != nil {
+			t.Fatalf("%v: Resolve returned error: %v", tt.contexts, err)
+		}
+
+		var entries []string
+		for _, entry := range envData {
+			entries = append(entries, entry.Key+"="+entry.Value)
+		}
+		if got := strings.Join(entries, ","); got != tt.expected {
+			t.Errorf("%v: expected %s, got %s", tt.contexts, tt.expected, got)
+		}
+	}
+}
+
+func TestResolveErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		source   sources.Source
+		client   *Client
+		expected string
+	}{
+		{
+			name:     "no client",
+			source:   sources.Source{Type: "ConfigMap", Name: "app", Namespace: "default"},
+			expected: "requires Kubernetes but no client is set",
+		},
+		{
+			name:     "unknown type",
+			source:   sources.Source{Type: "Unknown", Name: "app", Namespace: "default"},
+			client:   &Client{Clientset: fake.NewClientset()},
+			expected: `unknown source type "Unknown"`,
+		},
+		{
+			name:     "missing object",
+			source:   sources.Source{Type: "ConfigMap", Name: "missing", Namespace: "default"},
+			client:   &Client{Clientset: fake.NewClientset()},
+			expected: "missing",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Resolve(t.Context(), []sources.Source{tt.source}, Selection{Client: tt.client, OutputDirectory: t.TempDir()})
+			if err == nil || !strings.Contains(err.Error(), tt.expected) {
+				t.Errorf("expected an error containing %q, got %v", tt.expected, err)
+			}
+		})
+	}
+}
+
+func TestFetchReportsErrorsPerSource(t *testing.T) {
+	clientset := fake.NewClientset(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "default"},
+		Data:       map[string]string{"PORT": "8080"},
+	})
+	client := &Client{Clientset: clientset}
+
+	configSources := []sources.Source{
+		{Type: "ConfigMap", Name: "missing", Namespace: "default"},
+		{Type: "ConfigMap", Name: "app", Namespace: "default"},
+	}
+	results, err := Fetch(t.Context(), configSources, []*Client{client, client}, t.TempDir(), 1)
+	if err != nil {
+		t.Fatalf("Fetch returned error: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("expected 2 results, got %d", len(results))
+	}
+	if results[0].Err == nil {
+		t.Error("expected an error for the missing ConfigMap")
+	}
+	if results[1].Err != nil || len(results[1].Entries) != 1 || results[1].Entries[0].Key != "PORT" {
+		t.Errorf("expected PORT from app, got %+v", results[1])
+	}
+}
+
+func TestResolveSelectsClientsFiltersAndRunsPreflight(t *testing.T) {
+	defaultClient := &Client{Clientset: fake.NewClientset(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "default"},
+		Data:       map[string]string{"PORT": "8080", "DEBUG": "true"},
+	})}
+	otherClient := &Client{Clientset: fake.NewClientset(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "shared", Namespace: "default"},
+		Data:       map[string]string{"REGION": "eu"},
+	})}
+
+	configSources := []sources.Source{
+		{Type: "Vars", Name: "defaults", Vars: []sources.VarEntry{{Name: "HOST", Value: "localhost"}}},
+		{Type: "ConfigMap", Name: "app", Namespace: "default"},
+		{Type: "ConfigMap", Name: "shared", Namespace: "default", KubeContext: "other"},
+	}
+
+	var checked []*Client
+	envData, err := Resolve(t.Context(), configSources, Selection{
+		Client: defaultClient,
+		SourceClient: func(source sources.Source) (*Client, error) {
+			if source.KubeContext != "other" {
+				t.Errorf("unexpected source client request for %s", source.Name)
+			}
+			return otherClient, nil
+		},
+		Variables: &sources.SourceVariables{Exclude: []string{"DEBUG"}},
+		Preflight: func(ctx context.Context, configSources []sources.Source, clients []*Client) error {
+			checked = clients
+			return nil
+		},
+		OutputDirectory: t.TempDir(),
+	})
+	if err != nil {
+		t.Fatalf("Resolve returned error: %v", err)
+	}
+
+	var entries []string
+	for _, entry := range envData {
+		entries = append(entries, entry.Key+"="+entry.Value)
+	}
+	if got := strings.Join(entries, ","); got != "HOST=localhost,PORT=8080,REGION=eu" {
+		t.Errorf("expected the filtered entries of all sources, got %s", got)
+	}
+	if len(checked) != 3 || checked[0] != nil || checked[1] != defaultClient || checked[2] != otherClient {
+		t.Errorf("expected the preflight to get the client of every source, got %v", checked)
+	}
+
+	// A failing preflight stops before anything is fetched
+	_, err = Resolve(t.Context(), configSources[:1], Selection{
+		Preflight: func(ctx context.Context, configSources []sources.Source, clients []*Client) error {
+			return errors.New("access denied")
+		},
+	})
+	if err == nil || err.Error() != "access denied" {
+		t.Errorf("expected the preflight error, got %v", err)
+	}
+}
@@ -1,70 +1,296 @@
 package gitutil
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
 
 	"github.com/AlecAivazis/survey/v2"
+	"golang.org/x/term"
 )
 
+// askOne and isTerminal show the gitignore prompt and tell whether it can be answered, replaced in tests
+var askOne = survey.AskOne
+var isTerminal = func() bool {
+	return term.IsTerminal(int(os.Stdin.Fd()))
+}
+
+// gitCommand returns the git command to run with the arguments, replaced in tests to count them
+var gitCommand = func(args ...string) *exec.Cmd {
+	return exec.Command("git", args...)
+}
+
 // IsIgnored checks if a file path is covered by .gitignore
 func IsIgnored(path string) bool {
-	cmd := exec.Command("git", "check-ignore", "-q", path)
+	cmd := gitCommand("check-ignore", "-q", path)
 	err := cmd.Run()
 	return err == nil
 }
 
+// IgnoredPaths returns which of the paths are covered by .gitignore, checked with a single git
+// invocation
+func IgnoredPaths(paths []string) (map[string]bool, error) {
+	ignored := make(map[string]bool)
+	if len(paths) == 0 {
+		return ignored, nil
+	}
+
+	// NUL-separated so any path survives the round trip
+	cmd := gitCommand("check-ignore", "-z", "--stdin")
+	cmd.Stdin = strings.NewReader(strings.Join(paths, "\x00") + "\x00")
+	output, err := cmd.Output()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+		// None of the paths are ignored
+		return ignored, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to check .gitignore: %w", err)
+	}
+	for _, path := range bytes.Split(output, []byte{0}) {
+		if len(path) > 0 {
+			ignored[string(path)] = true
+		}
+	}
+	return ignored, nil
+}
+
 // IsGitRepo checks if the current directory is inside a git repository
 func IsGitRepo() bool {
-	cmd := exec.Command("git", "rev-parse", "--git-dir")
+	cmd := gitCommand("rev-parse", "--git-dir")
 	err := cmd.Run()
 	return err == nil
 }
 
-// EnsureGitignored checks if a file is gitignored, and if not, prompts the user
-// to add it to .gitignore. Returns an error if something goes wrong.
+// Modes of EnsureGitignored for files that are not ignored yet
+const (
+	ModeAuto = "auto" // prompt on a terminal, otherwise add the file
+	ModeFile = "file" // add the file without prompting
+	ModeDir  = "dir"  // add the file's directory without prompting
+	ModeSkip = "skip" // leave .gitignore untouched
+)
+
+// mode is the behavior of EnsureGitignored, see SetMode
+var mode = ModeAuto
+
+// gitignoreMu serializes checks, prompts and writes of .gitignore between concurrently fetched sources
+var gitignoreMu sync.Mutex
+
+// nonInteractive disables the prompt of EnsureGitignored
+var nonInteractive bool
+
+// quiet stops EnsureGitignored from reporting the entries it adds
+var quiet bool
+
+// status receives the entries EnsureGitignored adds. It is stderr, because stdout may carry an env
+// file or export script.
+var status io.Writer = os.Stderr
+
+// local makes EnsureGitignored add entries to .git/info/exclude instead of .gitignore
+var local bool
+
+// deferred collects the files passed to EnsureGitignored while Defer is in effect, nil otherwise
+var deferred *[]string
+
+// SetMode sets how EnsureGitignored handles files that are not ignored: auto, file, dir or skip
+func SetMode(m string) error {
+	switch m {
+	case ModeAuto, ModeFile, ModeDir, ModeSkip:
+		mode = m
+		return nil
+	default:
+		return fmt.Errorf("invalid gitignore mode %q: must be auto, file, dir or skip", m)
+	}
+}
+
+// SetNonInteractive makes EnsureGitignored add files without prompting in auto mode
+func SetNonInteractive(enabled bool) {
+	nonInteractive = enabled
+}
+
+// SetLocal makes EnsureGitignored add entries to .git/info/exclude, which isn't committed, instead of
+// .gitignore
+func SetLocal(enabled bool) {
+	local = enabled
+}
+
+// SetQuiet stops EnsureGitignored from printing the entries it adds to .gitignore
+func SetQuiet(enabled bool) {
+	quiet = enabled
+}
+
+// Defer makes EnsureGitignored collect the files it is given instead of checking them one git
+// invocation at a time. The returned function checks all collected files with EnsureGitignoredAll
+// and ends the deferral. Deferring while already deferred leaves the files to the outer call.
+func Defer() func() error {
+	gitignoreMu.Lock()
+	defer gitignoreMu.Unlock()
+	if deferred != nil {
+		return func() error { return nil }
+	}
+
+	collected := []string{}
+	deferred = &collected
+	return func() error {
+		gitignoreMu.Lock()
+		deferred = nil
+		gitignoreMu.Unlock()
+		return EnsureGitignoredAll(collected)
+	}
+}
+
+// EnsureGitignored checks if a file is gitignored, and if not, adds it to .gitignore according to the
+// mode set with SetMode. In auto mode the user is prompted when a terminal is available.
+// Returns an error if something goes wrong.
 func EnsureGitignored(filePath string) error {
+	if mode == ModeSkip {
+		return nil
+	}
+
+	gitignoreMu.Lock()
+	if deferred != nil {
+		*deferred = append(*deferred, filePath)
+		gitignoreMu.Unlock()
+		return nil
+	}
+	gitignoreMu.Unlock()
+
+	return EnsureGitignoredAll([]string{filePath})
+}
+
+// EnsureGitignoredAll is EnsureGitignored for many files. The files are checked with a single git
+// invocation, which is repeated only for the remaining files after an entry was added. In auto mode
+// one prompt covers all files that are not ignored.
+func EnsureGitignoredAll(filePaths []string) error {
+	if mode == ModeSkip || len(filePaths) == 0 {
+		return nil
+	}
+
+	gitignoreMu.Lock()
+	defer gitignoreMu.Unlock()
+
 	// Skip if not in a git repo
 	if !IsGitRepo() {
 		return nil
 	}
 
-	// Skip if already ignored
-	if IsIgnored(filePath) {
-		return nil
+	if mode == ModeAuto && !nonInteractive && isTerminal() {
+		ignored, err := IgnoredPaths(filePaths)
+		if err != nil {
+			return err
+		}
+		var unignored []string
+		for _, filePath := range filePaths {
+			if !ignored[filePath] && !slices.Contains(unignored, filePath) {
+				unignored = append(unignored, filePath)
+			}
+		}
+		if len(unignored) == 0 {
+			return nil
+		}
+		return promptGitignore(unignored)
 	}
 
-	// Prompt user
-	dir := filepath.Dir(filePath)
-	fileName := filepath.Base(filePath)
+	pending := filePaths
+	for len(pending) > 0 {
+		ignored, err := IgnoredPaths(pending)
+		if err != nil {
+			return err
+		}
 
-	var choice string
-	prompt := &survey.Select{
-		Message: fmt.Sprintf("File %q is not in .gitignore. Add to .gitignore?", filePath),
-		Options: []string{
-			fmt.Sprintf("Add file (%s)", filePath),
-			fmt.Sprintf("Add directory (%s/)", dir),
-			"Skip",
-		},
+		// An added directory may cover the files after it, so they are checked again
+		i := slices.IndexFunc(pending, func(filePath string) bool { return !ignored[filePath] })
+		if i < 0 {
+			return nil
+		}
+		if err := ensureGitignored(pending[i]); err != nil {
+			return err
+		}
+		pending = pending[i+1:]
 	}
+	return nil
+}
 
-	err := survey.AskOne(prompt, &choice)
-	if err != nil {
+// ensureGitignored adds a file that is not ignored to .gitignore according to the mode, without
+// prompting
+func ensureGitignored(filePath string) error {
+	if mode == ModeDir {
+		return addToGitignore(filepath.Dir(filePath) + "/")
+	}
+	// ModeFile, or auto mode when nobody can answer a prompt
+	return addToGitignore(filePath)
+}
+
+// promptGitignore asks once whether to add the files that are not ignored, their deepest common
+// directory or nothing to .gitignore
+func promptGitignore(filePaths []string) error {
+	message := fmt.Sprintf("File %q is not in .gitignore. Add to .gitignore?", filePaths[0])
+	addFiles := fmt.Sprintf("Add file (%s)", filePaths[0])
+	if len(filePaths) > 1 {
+		message = fmt.Sprintf("%d files are not in .gitignore (%s). Add to .gitignore?", len(filePaths), strings.Join(filePaths, ", "))
+		addFiles = fmt.Sprintf("Add %d files", len(filePaths))
+	}
+	options := []string{addFiles}
+
+	// The working directory would ignore the whole tree
+	dir := commonDir(filePaths)
+	addDir := fmt.Sprintf("Add directory (%s/)", dir)
+	if dir != "." {
+		options = append(options, addDir)
+	}
+	options = append(options, "Skip")
+
+	var choice string
+	prompt := &survey.Select{Message: message, Options: options}
+	// Like the status lines, the prompt stays off stdout, which may carry an env file or script
+	if err := askOne(prompt, &choice, survey.WithStdio(os.Stdin, os.Stderr, os.Stderr)); err != nil {
 		return fmt.Errorf("gitignore prompt failed: %w", err)
 	}
 
-	var entryToAdd string
 	switch choice {
-	case fmt.Sprintf("Add file (%s)", filePath):
-		entryToAdd = filePath
-	case fmt.Sprintf("Add directory (%s/)", dir):
-		entryToAdd = dir + "/"
+	case addFiles:
+		for _, filePath := range filePaths {
+			if err := addToGitignore(filePath); err != nil {
+				return err
+			}
+		}
+		return nil
+	case addDir:
+		return addToGitignore(dir + "/")
 	default:
 		// User chose to skip
 		return nil
 	}
+}
+
+// commonDir returns the deepest directory that contains all paths, "." when there is none
+func commonDir(paths []string) string {
+	dir := filepath.Dir(paths[0])
+	for _, path := range paths[1:] {
+		for dir != "." && !strings.HasPrefix(filepath.Dir(path)+string(filepath.Separator), dir+string(filepath.Separator)) {
+			parent := filepath.Dir(dir)
+			if parent == dir {
+				return "."
+			}
+			dir = parent
+		}
+	}
+	return dir
+}
+
+// addToGitignore appends an entry to the .gitignore in the repository root.
+// With SetLocal the entry goes to .git/info/exclude instead
+func addToGitignore(entryToAdd string) error {
+	if local {
+		return addToExcludeFile(entryToAdd)
+	}
 
 	// Find .gitignore location (in repo root)
 	gitRoot, err := getGitRoot()
@@ -72,27 +298,44 @@ func EnsureGitignored(filePath string) error {
 		return fmt.Errorf("failed to find git root: %w", err)
 	}
 
-	gitignorePath := filepath.Join(gitRoot, ".gitignore")
+	return appendEntry(filepath.Join(gitRoot, ".gitignore"), ".gitignore", entryToAdd)
+}
 
-	// Append to .gitignore
-	f, err := os.OpenFile(gitignorePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+// addToExcludeFile appends an entry to .git/info/exclude, which ignores files without a change to commit
+func addToExcludeFile(entryToAdd string) error {
+	// Also right in worktrees, where .git is a file
+	output, err := gitCommand("rev-parse", "--git-path", "info/exclude").Output()
 	if err != nil {
-		return fmt.Errorf("failed to open .gitignore: %w", err)
+		return fmt.Errorf("failed to find .git/info/exclude: %w", err)
+	}
+	excludePath := strings.TrimSpace(string(output))
+	if err := os.MkdirAll(filepath.Dir(excludePath), 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Dir(excludePath), err)
+	}
+
+	return appendEntry(excludePath, ".git/info/exclude", entryToAdd)
+}
+
+// appendEntry appends an entry on its own line to the ignore file at path, name is how it is reported
+func appendEntry(path, name, entryToAdd string) error {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", name, err)
 	}
 	defer f.Close()
 
 	// Make sure we start on a new line
 	stat, err := f.Stat()
 	if err != nil {
-		return fmt.Errorf("failed to stat .gitignore: %w", err)
+		return fmt.Errorf("failed to stat %s: %w", name, err)
 	}
 
 	prefix := ""
 	if stat.Size() > 0 {
 		// Read last byte to check if file ends with newline
-		content, err := os.ReadFile(gitignorePath)
+		content, err := os.ReadFile(path)
 		if err != nil {
-			return fmt.Errorf("failed to read .gitignore: %w", err)
+			return fmt.Errorf("failed to read %s: %w", name, err)
 		}
 		if len(content) > 0 && content[len(content)-1] != '\n' {
 			prefix = "\n"
@@ -100,21 +343,18 @@ func EnsureGitignored(filePath string) error {
 	}
 
 	if _, err := f.WriteString(prefix + entryToAdd + "\n"); err != nil {
-		return fmt.Errorf("failed to write to .gitignore: %w", err)
+		return fmt.Errorf("failed to write to %s: %w", name, err)
 	}
 
-	fmt.Printf("Added %q to .gitignore\n", entryToAdd)
-
-	// Also add the filename pattern (without path) for better coverage
-	if entryToAdd == filePath && fileName != entryToAdd {
-		// The file was added with full path, no need to add pattern
+	if !quiet {
+		fmt.Fprintf(status, "Added %q to %s\n", entryToAdd, name)
 	}
 
 	return nil
 }
 
 func getGitRoot() (string, error) {
-	cmd := exec.Command("git", "rev-parse", "--show-toplevel")
+	cmd := gitCommand("rev-parse", "--show-toplevel")
 	output, err := cmd.Output()
 	if err != nil {
 		return "", err
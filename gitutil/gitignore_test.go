@@ -0,0 +1,235 @@
+package gitutil
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/AlecAivazis/survey/v2"
+)
+
+func TestEnsureGitignoredModes(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	defer SetMode(ModeAuto)
+
+	testCases := []struct {
+		mode     string
+		expected string
+	}{
+		{mode: ModeFile, expected: "generated/.env\n"},
+		{mode: ModeDir, expected: "generated/\n"},
+		{mode: ModeSkip, expected: ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.mode, func(t *testing.T) {
+			t.Chdir(t.TempDir())
+			if output, err := exec.Command("git", "init", "-q").CombinedOutput(); err != nil {
+				t.Fatalf("git init failed: %v\n%s", err, output)
+			}
+
+			if err := SetMode(tc.mode); err != nil {
+				t.Fatal(err)
+			}
+			if err := EnsureGitignored(filepath.Join("generated", ".env")); err != nil {
+				t.Fatalf("EnsureGitignored returned error: %v", err)
+			}
+
+			content, err := os.ReadFile(".gitignore")
+			if err != nil && !os.IsNotExist(err) {
+				t.Fatal(err)
+			}
+			if string(content) != tc.expected {
+				t.Errorf("expected .gitignore %q, got %q", tc.expected, string(content))
+			}
+		})
+	}
+}
+
+func TestSetModeRejectsUnknownModes(t *testing.T) {
+	if err := SetMode("always"); err == nil {
+		t.Error("expected an error for an unknown mode")
+	}
+	if mode != ModeAuto {
+		t.Errorf("expected the mode to stay %q, got %q", ModeAuto, mode)
+	}
+}
+
+func TestDeferChecksAllFilesWithOneGitInvocation(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	defer SetMode(ModeAuto)
+	if err := SetMode(ModeFile); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Chdir(t.TempDir())
+	if output, err := exec.Command("git", "init", "-q").CombinedOutput(); err != nil {
+		t.Fatalf("git init failed: %v\n%s", err, output)
+	}
+	if err := os.WriteFile(".gitignore", []byte("files/\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var invocations []string
+	defer func(command func(...string) *exec.Cmd) { gitCommand = command }(gitCommand)
+	gitCommand = func(args ...string) *exec.Cmd {
+		invocations = append(invocations, strings.Join(args, " "))
+		return exec.Command("git", args...)
+	}
+
+	flush := Defer()
+	for i := range 50 {
+		if err := EnsureGitignored(filepath.Join("files", fmt.Sprintf("file-%d", i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := EnsureGitignored(".env"); err != nil {
+		t.Fatal(err)
+	}
+	if len(invocations) != 0 {
+		t.Fatalf("expected no git invocations before the flush, got %v", invocations)
+	}
+	if err := flush(); err != nil {
+		t.Fatalf("flush returned error: %v", err)
+	}
+
+	// Only .env is added, which needs the repository root
+	expected := []string{"rev-parse --git-dir", "check-ignore -z --stdin", "rev-parse --show-toplevel"}
+	if !slices.Equal(invocations, expected) {
+		t.Errorf("expected git invocations %v, got %v", expected, invocations)
+	}
+	content, err := os.ReadFile(".gitignore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "files/\n.env\n"; string(content) != expected {
+		t.Errorf("expected .gitignore %q, got %q", expected, string(content))
+	}
+}
+
+func BenchmarkEnsureGitignoredAll(b *testing.B) {
+	if _, err := exec.LookPath("git"); err != nil {
+		b.Skip("git is not installed")
+	}
+	b.Chdir(b.TempDir())
+	if output, err := exec.Command("git", "init", "-q").CombinedOutput(); err != nil {
+		b.Fatalf("git init failed: %v\n%s", err, output)
+	}
+	if err := os.WriteFile(".gitignore", []byte("files/\n"), 0644); err != nil {
+		b.Fatal(err)
+	}
+	var paths []string
+	for i := range 50 {
+		paths = append(paths, filepath.Join("files", fmt.Sprintf("file-%d", i)))
+	}
+
+	for b.Loop() {
+		if err := EnsureGitignoredAll(paths); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestEnsureGitignoredLocal(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	defer SetMode(ModeAuto)
+	defer SetLocal(false)
+	if err := SetMode(ModeFile); err != nil {
+		t.Fatal(err)
+	}
+	SetLocal(true)
+
+	t.Chdir(t.TempDir())
+	if output, err := exec.Command("git", "init", "-q").CombinedOutput(); err != nil {
+		t.Fatalf("git init failed: %v\n%s", err, output)
+	}
+	// Without a trailing newline the entry must start on its own line
+	excludePath := filepath.Join(".git", "info", "exclude")
+	if err := os.MkdirAll(filepath.Dir(excludePath), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(excludePath, []byte("*.log"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	envPath := filepath.Join("generated", ".env")
+	if err := EnsureGitignored(envPath); err != nil {
+		t.Fatalf("EnsureGitignored returned error: %v", err)
+	}
+
+	content, err := os.ReadFile(excludePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "*.log\ngenerated/.env\n"; string(content) != expected {
+		t.Errorf("expected .git/info/exclude %q, got %q", expected, string(content))
+	}
+	if _, err := os.Stat(".gitignore"); !os.IsNotExist(err) {
+		t.Errorf("expected no .gitignore, got %v", err)
+	}
+	if !IsIgnored(envPath) {
+		t.Errorf("expected %s to be ignored", envPath)
+	}
+}
+
+func TestDeferPromptsOnceForAllFiles(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	t.Chdir(t.TempDir())
+	if output, err := exec.Command("git", "init", "-q").CombinedOutput(); err != nil {
+		t.Fatalf("git init failed: %v\n%s", err, output)
+	}
+
+	var prompts []*survey.Select
+	defer func(ask func(survey.Prompt, any, ...survey.AskOpt) error, terminal func() bool) {
+		askOne, isTerminal = ask, terminal
+	}(askOne, isTerminal)
+	isTerminal = func() bool { return true }
+	askOne = func(prompt survey.Prompt, response any, _ ...survey.AskOpt) error {
+		selection := prompt.(*survey.Select)
+		prompts = append(prompts, selection)
+		*response.(*string) = selection.Options[1]
+		return nil
+	}
+
+	// What an execution with file transformations writes
+	flush := Defer()
+	for _, path := range []string{"generated/.env", "generated/certs/tls.crt", "generated/certs/tls.key", "generated/.env"} {
+		if err := EnsureGitignored(path); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := flush(); err != nil {
+		t.Fatalf("flush returned error: %v", err)
+	}
+
+	if len(prompts) != 1 {
+		t.Fatalf("expected a single prompt, got %d", len(prompts))
+	}
+	expectedMessage := "3 files are not in .gitignore (generated/.env, generated/certs/tls.crt, generated/certs/tls.key). Add to .gitignore?"
+	if prompts[0].Message != expectedMessage {
+		t.Errorf("expected message %q, got %q", expectedMessage, prompts[0].Message)
+	}
+	expectedOptions := []string{"Add 3 files", "Add directory (generated/)", "Skip"}
+	if !slices.Equal(prompts[0].Options, expectedOptions) {
+		t.Errorf("expected options %v, got %v", expectedOptions, prompts[0].Options)
+	}
+	content, err := os.ReadFile(".gitignore")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "generated/\n"; string(content) != expected {
+		t.Errorf("expected .gitignore %q, got %q", expected, string(content))
+	}
+}
@@ -0,0 +1,213 @@
+package output
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"enver/sources"
+)
+
+// RenderOptions controls how environment entries are rendered to a .env file
+type RenderOptions struct {
+	Export bool // prefix each variable line with "export "
+}
+
+// Output file formats
+const (
+	FormatEnv    = "env"    // KEY=value lines (default)
+	FormatTfvars = "tfvars" // Terraform variable definitions: key = "value"
+)
+
+// ValidateFormat returns an error if the format is not one of the supported output formats
+func ValidateFormat(format string) error {
+	switch format {
+	case "", FormatEnv, FormatTfvars:
+		return nil
+	default:
+		return fmt.Errorf("invalid format %q (expected %s or %s)", format, FormatEnv, FormatTfvars)
+	}
+}
+
+// formatExtensions maps output file extensions to the format written for them
+var formatExtensions = map[string]string{
+	".env":    FormatEnv,
+	".tfvars": FormatTfvars,
+}
+
+// ResolveFormat returns format when set, so --format and output.format override the file name,
+// otherwise the format detected from the extension of fileName. Unknown extensions, such as
+// .envrc or .txt, and names like .env.local are written as env.
+func ResolveFormat(format, fileName string) string {
+	if format != "" {
+		return format
+	}
+	if detected, ok := formatExtensions[strings.ToLower(filepath.Ext(fileName))]; ok {
+		return detected
+	}
+	return FormatEnv
+}
+
+// Render renders entries in the format of the output file. Keys the format can't represent are
+// skipped and returned.
+func Render(envData []sources.EnvEntry, format string, opts RenderOptions) (string, []string) {
+	if format == FormatTfvars {
+		return renderTfvars(envData)
+	}
+	return renderEnv(envData, opts), nil
+}
+
+// EntrySource returns the comment header identifying the source of an entry
+func EntrySource(entry sources.EnvEntry) string {
+	var header string
+	if entry.Namespace != "" {
+		header = fmt.Sprintf("%s %s/%s", entry.SourceType, entry.Namespace, entry.Name)
+	} else {
+		header = fmt.Sprintf("%s %s", entry.SourceType, entry.Name)
+	}
+	if entry.Owner != "" {
+		header = fmt.Sprintf("%s (%s)", header, entry.Owner)
+	}
+	return header
+}
+
+// SortWithinSources returns a copy of the entries with keys sorted within each group of consecutive
+// entries from the same source. The order of the groups themselves is preserved, so sources still
+// appear in declaration order and a later duplicate of a key within a group still wins.
+func SortWithinSources(envData []sources.EnvEntry) []sources.EnvEntry {
+	sorted := make([]sources.EnvEntry, len(envData))
+	copy(sorted, envData)
+
+	start := 0
+	for i := 1; i <= len(sorted); i++ {
+		if i < len(sorted) && EntrySource(sorted[i]) == EntrySource(sorted[start]) {
+			continue
+		}
+		group := sorted[start:i]
+		sort.SliceStable(group, func(a, b int) bool {
+			return group[a].Key < group[b].Key
+		})
+		start = i
+	}
+	return sorted
+}
+
+// renderEnv renders entries as .env content with one comment per source
+// Keys are sorted within each source so the output is stable across runs
+func renderEnv(envData []sources.EnvEntry, opts RenderOptions) string {
+	linePrefix := ""
+	if opts.Export {
+		linePrefix = "export "
+	}
+
+	var sb strings.Builder
+	var lastSource string
+	for _, entry := range SortWithinSources(envData) {
+		currentSource := EntrySource(entry)
+		if currentSource != lastSource {
+			if lastSource != "" {
+				sb.WriteString("\n")
+			}
+			fmt.Fprintf(&sb, "# %s\n", currentSource)
+			lastSource = currentSource
+		}
+		fmt.Fprintf(&sb, "%s%s=%s\n", linePrefix, entry.Key, QuoteEnvValue(entry.Value))
+	}
+	return sb.String()
+}
+
+// envValueEscaper escapes the characters that have a special meaning inside double quotes
+var envValueEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	`"`, `\"`,
+	"$", `\$`,
+	"`", "\\`",
+	"\n", `\n`,
+	"\r", `\r`,
+)
+
+// QuoteEnvValue wraps values containing whitespace, quotes or other special characters in
+// double quotes, escaping embedded quotes, backslashes, newlines, $ and backticks so sourcing the
+// file doesn't expand variables or run command substitutions. Plain values are kept as-is.
+func QuoteEnvValue(value string) string {
+	if !strings.ContainsAny(value, " \t\n\r\"'\\#$`") {
+		return value
+	}
+	return `"` + envValueEscaper.Replace(value) + `"`
+}
+
+// tfvarsNamePattern matches names that can be used as Terraform variable names
+var tfvarsNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_-]*$`)
+
+// tfvarsValueEscaper escapes a value for an HCL quoted string. ${ and %{ would start a template
+// sequence, so they are escaped too.
+var tfvarsValueEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	`"`, `\"`,
+	"\n", `\n`,
+	"\r", `\r`,
+	"\t", `\t`,
+	"${", "$${",
+	"%{", "%%{",
+)
+
+// renderTfvars renders entries as a Terraform .tfvars file with one comment per source. Keys that
+// are not valid Terraform variable names are skipped and returned.
+func renderTfvars(envData []sources.EnvEntry) (string, []string) {
+	var sb strings.Builder
+	var skipped []string
+	var lastSource string
+	for _, entry := range SortWithinSources(envData) {
+		if !tfvarsNamePattern.MatchString(entry.Key) {
+			skipped = append(skipped, entry.Key)
+			continue
+		}
+		currentSource := EntrySource(entry)
+		if currentSource != lastSource {
+			if lastSource != "" {
+				sb.WriteString("\n")
+			}
+			fmt.Fprintf(&sb, "# %s\n", currentSource)
+			lastSource = currentSource
+		}
+		fmt.Fprintf(&sb, "%s = \"%s\"\n", entry.Key, tfvarsValueEscaper.Replace(entry.Value))
+	}
+	return sb.String(), skipped
+}
+
+// shellNamePattern matches names that can be used as shell variables
+var shellNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// shellValueEscaper escapes the characters that keep their special meaning inside double quotes in a POSIX shell
+var shellValueEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	`"`, `\"`,
+	`$`, `\$`,
+	"`", "\\`",
+)
+
+// RenderExportScript renders entries as a POSIX shell script of export statements, with one
+// comment per source. Keys that are not valid shell variable names are skipped and returned.
+func RenderExportScript(envData []sources.EnvEntry) (string, []string) {
+	var sb strings.Builder
+	var skipped []string
+	var lastSource string
+	for _, entry := range SortWithinSources(envData) {
+		if !shellNamePattern.MatchString(entry.Key) {
+			skipped = append(skipped, entry.Key)
+			continue
+		}
+		currentSource := EntrySource(entry)
+		if currentSource != lastSource {
+			if lastSource != "" {
+				sb.WriteString("\n")
+			}
+			fmt.Fprintf(&sb, "# %s\n", currentSource)
+			lastSource = currentSource
+		}
+		fmt.Fprintf(&sb, "export %s=\"%s\"\n", entry.Key, shellValueEscaper.Replace(entry.Value))
+	}
+	return sb.String(), skipped
+}
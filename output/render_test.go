@@ -0,0 +1,205 @@
+package output
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"enver/sources"
+)
+
+func TestRenderEnvQuotesSpecialValues(t *testing.T) {
+	entries := []sources.EnvEntry{
+		{Key: "PLAIN", Value: "plain-value", SourceType: "Secret", Name: "db", Namespace: "default"},
+		{Key: "EMPTY", Value: "", SourceType: "Secret", Name: "db", Namespace: "default"},
+	}
+	content := renderEnv(entries, RenderOptions{})
+	// Keys are sorted: EMPTY, PLAIN
+	if !strings.Contains(content, "\nEMPTY=\nPLAIN=plain-value\n") {
+		t.Errorf("expected empty and plain values to stay unquoted, got:\n%s", content)
+	}
+}
+
+func TestRenderEnvRoundTripsSpecialValues(t *testing.T) {
+	values := map[string]string{
+		"PASSWORD":  "pa\"ss word with \\ backslash",
+		"QUOTES":    `it's "quoted"`,
+		"COMMENT":   "value # not a comment",
+		"DOLLAR":    "$HOME and ${PATH}",
+		"BACKTICK":  "`echo PWNED` and $(echo PWNED)",
+		"BACKSLASH": "C:\\temp\\new \\\" \\\\ \\$ \\`",
+	}
+	var entries []sources.EnvEntry
+	for key, value := range values {
+		entries = append(entries, sources.EnvEntry{Key: key, Value: value, SourceType: "Vars", Name: "inline"})
+	}
+	multiline := "-----BEGIN-----\nline\r\n-----END-----\n"
+	entries = append(entries, sources.EnvEntry{Key: "MULTILINE", Value: multiline, SourceType: "Vars", Name: "inline"})
+
+	dir := t.TempDir()
+	envPath := filepath.Join(dir, "out.env")
+	if err := os.WriteFile(envPath, []byte(renderEnv(entries, RenderOptions{})), 0644); err != nil {
+		t.Fatalf("failed to write env file: %v", err)
+	}
+	parsed, err := (&sources.EnvFileFetcher{}).Fetch(t.Context(), nil, sources.Source{Type: "EnvFile", Path: envPath}, dir)
+	if err != nil {
+		t.Fatalf("failed to parse rendered env file: %v", err)
+	}
+	want := map[string]string{"MULTILINE": multiline}
+	for key, value := range values {
+		want[key] = value
+	}
+	if len(parsed) != len(want) {
+		t.Fatalf("expected %d variables after parsing, got %v", len(want), parsed)
+	}
+	for _, entry := range parsed {
+		if entry.Value != want[entry.Key] {
+			t.Errorf("%s: expected %q after parsing, got %q", entry.Key, want[entry.Key], entry.Value)
+		}
+	}
+
+	// Sourcing an exported file must neither expand variables nor run command substitutions.
+	// Newlines are left out: \n is only an escape for env file parsers, not for the shell.
+	exportPath := filepath.Join(dir, "export.env")
+	if err := os.WriteFile(exportPath, []byte(renderEnv(entries[:len(values)], RenderOptions{Export: true})), 0644); err != nil {
+		t.Fatalf("failed to write export file: %v", err)
+	}
+	for key, value := range values {
+		cmd := exec.Command("sh", "-c", fmt.Sprintf(`. "$1"; printf %%s "$%s"`, key), "sh", exportPath)
+		output, err := cmd.Output()
+		if err != nil {
+			t.Fatalf("failed to source export file and read %s: %v", key, err)
+		}
+		if string(output) != value {
+			t.Errorf("%s: expected %q after sourcing, got %q", key, value, output)
+		}
+	}
+}
+
+func TestRenderEnvSortsKeysWithinSources(t *testing.T) {
+	entries := []sources.EnvEntry{
+		{Key: "ZONE", Value: "eu", SourceType: "ConfigMap", Name: "app", Namespace: "default"},
+		{Key: "HOST", Value: "localhost", SourceType: "ConfigMap", Name: "app", Namespace: "default"},
+		{Key: "DEBUG", Value: "true", SourceType: "EnvFile", Name: "local.env"},
+		{Key: "API_KEY", Value: "first", SourceType: "Vars", Name: "inline"},
+		{Key: "API_KEY", Value: "second", SourceType: "Vars", Name: "inline"},
+		{Key: "ANOTHER", Value: "x", SourceType: "Vars", Name: "inline"},
+	}
+
+	expected := "# ConfigMap default/app\nHOST=localhost\nZONE=eu\n" +
+		"\n# EnvFile local.env\nDEBUG=true\n" +
+		"\n# Vars inline\nANOTHER=x\nAPI_KEY=first\nAPI_KEY=second\n"
+	if content := renderEnv(entries, RenderOptions{}); content != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, content)
+	}
+
+	// The input slice is left untouched
+	if entries[0].Key != "ZONE" {
+		t.Errorf("expected renderEnv not to reorder its input, got %s first", entries[0].Key)
+	}
+}
+
+func TestRenderExportScriptIsSourceable(t *testing.T) {
+	values := map[string]string{
+		"PLAIN":     "plain-value",
+		"SPACES":    "value with spaces",
+		"QUOTES":    `it's "quoted"`,
+		"DOLLAR":    "$HOME and ${PATH}",
+		"BACKTICK":  "`id` and $(id)",
+		"BACKSLASH": `C:\temp\new \" \\`,
+		"MULTILINE": "line1\nline2\n",
+		"EMPTY":     "",
+	}
+	var entries []sources.EnvEntry
+	for key, value := range values {
+		entries = append(entries, sources.EnvEntry{Key: key, Value: value, SourceType: "Vars", Name: "inline"})
+	}
+	entries = append(entries, sources.EnvEntry{Key: "not-a-shell-name", Value: "x", SourceType: "Vars", Name: "inline"})
+
+	script, skipped := RenderExportScript(entries)
+	if len(skipped) != 1 || skipped[0] != "not-a-shell-name" {
+		t.Errorf("expected the invalid key to be skipped, got %v", skipped)
+	}
+
+	scriptPath := filepath.Join(t.TempDir(), "env.sh")
+	if err := os.WriteFile(scriptPath, []byte(script), 0644); err != nil {
+		t.Fatalf("failed to write script: %v", err)
+	}
+
+	for key, want := range values {
+		// printenv runs as a child process, so this also checks the variable was exported
+		cmd := exec.Command("sh", "-c", `. "$1" && printenv "$2"`, "sh", scriptPath, key)
+		cmd.Env = []string{}
+		output, err := cmd.Output()
+		if err != nil {
+			t.Fatalf("failed to source script and read %s: %v\n%s", key, err, script)
+		}
+		if got := strings.TrimSuffix(string(output), "\n"); got != want {
+			t.Errorf("%s: expected %q after sourcing, got %q", key, want, got)
+		}
+	}
+}
+
+func TestRenderEnvIncludesOwnerInHeader(t *testing.T) {
+	entries := []sources.EnvEntry{
+		{Key: "PASSWORD", Value: "secret", SourceType: "Secret", Name: "db", Namespace: "apps", Owner: "owned by SealedSecret/db"},
+	}
+
+	expected := "# Secret apps/db (owned by SealedSecret/db)\nPASSWORD=secret\n"
+	if content := renderEnv(entries, RenderOptions{}); content != expected {
+		t.Errorf("expected %q, got %q", expected, content)
+	}
+}
+
+func TestRenderTfvarsEscapesValues(t *testing.T) {
+	entries := []sources.EnvEntry{
+		{Key: "db_password", Value: "pa\"ss\nword \\ ${var.x} %{if}", SourceType: "Secret", Name: "db", Namespace: "default"},
+		{Key: "region", Value: "eu-west-1", SourceType: "Secret", Name: "db", Namespace: "default"},
+		{Key: "1INVALID", Value: "x", SourceType: "Secret", Name: "db", Namespace: "default"},
+	}
+
+	content, skipped := Render(entries, FormatTfvars, RenderOptions{})
+	expected := "# Secret default/db\n" +
+		`db_password = "pa\"ss\nword \\ $${var.x} %%{if}"` + "\n" +
+		`region = "eu-west-1"` + "\n"
+	if content != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, content)
+	}
+	if len(skipped) != 1 || skipped[0] != "1INVALID" {
+		t.Errorf("expected 1INVALID to be skipped, got %v", skipped)
+	}
+
+	if err := ValidateFormat("json"); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+}
+
+func TestResolveFormatFromExtension(t *testing.T) {
+	testCases := []struct {
+		format   string
+		fileName string
+		expected string
+	}{
+		{fileName: ".env", expected: FormatEnv},
+		{fileName: "app.env", expected: FormatEnv},
+		{fileName: ".env.local", expected: FormatEnv},
+		{fileName: "app.auto.tfvars", expected: FormatTfvars},
+		{fileName: "APP.TFVARS", expected: FormatTfvars},
+		{fileName: "app.json", expected: FormatEnv},
+		{fileName: "app.properties", expected: FormatEnv},
+		{fileName: ".envrc", expected: FormatEnv},
+		{fileName: "foo.txt", expected: FormatEnv},
+		{format: FormatTfvars, fileName: "foo.txt", expected: FormatTfvars},
+		{format: FormatEnv, fileName: "app.tfvars", expected: FormatEnv},
+		{format: FormatTfvars, fileName: ".env", expected: FormatTfvars},
+	}
+
+	for _, tc := range testCases {
+		if got := ResolveFormat(tc.format, tc.fileName); got != tc.expected {
+			t.Errorf("ResolveFormat(%q, %q) = %q, expected %q", tc.format, tc.fileName, got, tc.expected)
+		}
+	}
+}
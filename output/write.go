@@ -0,0 +1,253 @@
+package output
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"enver/cryptutil"
+	"enver/gitutil"
+	"enver/sources"
+)
+
+// Options controls where and how Write writes entries
+type Options struct {
+	Directory     string        // directory of the files, created if it doesn't exist
+	Name          string        // name of the combined file in Directory
+	Format        string        // format of the combined file, detected from Name when empty, see ResolveFormat
+	RenderOptions RenderOptions // how env files are rendered
+	Mode          string        // octal permissions of the files, see FileMode
+	PerSource     bool          // write one file per source instead of the combined file
+	Explode       bool          // also write one file per source next to the combined file
+	Encrypt       bool          // encrypt the written files with Passphrase, they aren't added to .gitignore
+	Passphrase    string
+	OnlyDiffWrite bool // leave files untouched when their content is unchanged
+	FailIfExists  bool // fail instead of overwriting files that already exist
+}
+
+// SourceEntries holds the entries a single configured source contributed
+type SourceEntries struct {
+	Source  sources.Source
+	Entries []sources.EnvEntry
+}
+
+// Result describes what Write wrote
+type Result struct {
+	Path        string   // the combined file, empty with PerSource
+	Unchanged   bool     // the combined file was up to date and not written, see OnlyDiffWrite
+	SourcePaths []string // the files written per source, without the ones left untouched by OnlyDiffWrite
+	Skipped     []string // keys the format can't represent
+}
+
+// Write renders the entries and writes them to the combined file and/or one file per source, see
+// Options. Written files that are not encrypted are added to .gitignore.
+func Write(envData []sources.EnvEntry, perSource []SourceEntries, opts Options) (Result, error) {
+	var result Result
+	opts.Format = ResolveFormat(opts.Format, opts.Name)
+
+	// Write one file per source instead of the combined file
+	if opts.PerSource {
+		return writeSourceFiles(perSource, opts)
+	}
+
+	content, skipped := Render(envData, opts.Format, opts.RenderOptions)
+	result.Skipped = skipped
+
+	// Create output directory if it doesn't exist
+	if err := os.MkdirAll(opts.Directory, 0755); err != nil {
+		return result, fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	path := filepath.Join(opts.Directory, opts.Name)
+	unchanged, err := writeOutput(path, content, envData, opts)
+	if err != nil {
+		return result, fmt.Errorf("failed to write output file: %w", err)
+	}
+	result.Path = path
+	result.Unchanged = unchanged
+
+	// Write one additional file per source for debugging, these are never encrypted
+	if opts.Explode {
+		sourceOpts := opts
+		sourceOpts.Encrypt = false
+		sourceResult, err := writeSourceFiles(perSource, sourceOpts)
+		result.SourcePaths = sourceResult.SourcePaths
+		if err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+// writeOutput writes rendered content to path with the permissions, encryption and only-diff-write
+// behaviour of the options, and adds the file to .gitignore unless it is encrypted. It returns true
+// when the file already had the content and was left untouched.
+func writeOutput(path, content string, envData []sources.EnvEntry, opts Options) (bool, error) {
+	if opts.OnlyDiffWrite {
+		unchanged, err := isUnchanged(path, content, opts)
+		if err != nil || unchanged {
+			return unchanged, err
+		}
+	}
+
+	// Files containing secrets are only readable by the owner unless a mode is configured
+	perm, err := FileMode(opts.Mode, envData)
+	if err != nil {
+		return false, err
+	}
+	data := []byte(content)
+	if opts.Encrypt {
+		if data, err = cryptutil.Encrypt(data, opts.Passphrase); err != nil {
+			return false, fmt.Errorf("failed to encrypt: %w", err)
+		}
+	}
+	if err := WriteFile(path, data, perm, opts.FailIfExists); err != nil {
+		return false, err
+	}
+
+	// Encrypted files are meant to be committed
+	if !opts.Encrypt {
+		if err := gitutil.EnsureGitignored(path); err != nil {
+			return false, err
+		}
+	}
+	return false, nil
+}
+
+// isUnchanged returns true if the file at path already has the content
+func isUnchanged(path, content string, opts Options) (bool, error) {
+	existing, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to read existing file: %w", err)
+	}
+	// Encryption is randomized, so the decrypted content is compared
+	if opts.Encrypt && cryptutil.IsEncrypted(existing) {
+		if existing, err = cryptutil.Decrypt(existing, opts.Passphrase); err != nil {
+			return false, fmt.Errorf("failed to decrypt existing file: %w", err)
+		}
+	}
+	return string(existing) == content, nil
+}
+
+// unsafeFileNameChars matches characters that should not end up in generated file names
+var unsafeFileNameChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// formatFileExtension returns the extension of the files written in a format
+func formatFileExtension(format string) string {
+	if format == FormatTfvars {
+		return ".tfvars"
+	}
+	return ".env"
+}
+
+// sourceFileName returns the file name for a source's own output file: <sourceType>-<name><ext>
+func sourceFileName(source sources.Source, ext string) string {
+	name := source.Name
+	if name == "" {
+		// EnvFile sources are identified by their path
+		name = source.Path
+	}
+	name = strings.Trim(unsafeFileNameChars.ReplaceAllString(name, "-"), "-.")
+	if name == "" {
+		return source.Type + ext
+	}
+	return fmt.Sprintf("%s-%s%s", source.Type, name, ext)
+}
+
+// writeSourceFiles writes the entries of each source to its own file in the output directory, in
+// the format of the options. Sources that resolve to the same file name get a numeric suffix.
+// Files left untouched by OnlyDiffWrite are not part of the returned SourcePaths.
+func writeSourceFiles(perSource []SourceEntries, opts Options) (Result, error) {
+	var result Result
+	if err := os.MkdirAll(opts.Directory, 0755); err != nil {
+		return result, fmt.Errorf("failed to create output directory: %w", err)
+	}
+
+	ext := formatFileExtension(opts.Format)
+	used := make(map[string]int)
+	for _, source := range perSource {
+		fileName := sourceFileName(source.Source, ext)
+		used[fileName]++
+		if count := used[fileName]; count > 1 {
+			fileName = fmt.Sprintf("%s-%d%s", strings.TrimSuffix(fileName, ext), count, ext)
+		}
+
+		content, skipped := Render(source.Entries, opts.Format, opts.RenderOptions)
+		result.Skipped = append(result.Skipped, skipped...)
+
+		path := filepath.Join(opts.Directory, fileName)
+		unchanged, err := writeOutput(path, content, source.Entries, opts)
+		if err != nil {
+			return result, fmt.Errorf("failed to write source file: %w", err)
+		}
+		if !unchanged {
+			result.SourcePaths = append(result.SourcePaths, path)
+		}
+	}
+	return result, nil
+}
+
+// ParseMode parses an octal file mode such as "0600"
+func ParseMode(mode string) (os.FileMode, error) {
+	perm, err := strconv.ParseUint(mode, 8, 32)
+	if err != nil || perm > 0777 {
+		return 0, fmt.Errorf("invalid output mode %q: must be octal permissions such as 0600", mode)
+	}
+	return os.FileMode(perm), nil
+}
+
+// FileMode returns the permissions of an output file: the configured mode if set, otherwise
+// 0600 when any entry comes from a Secret and 0644 when none does
+func FileMode(mode string, envData []sources.EnvEntry) (os.FileMode, error) {
+	if mode != "" {
+		return ParseMode(mode)
+	}
+	for _, entry := range envData {
+		if entry.IsSecret() {
+			return 0600, nil
+		}
+	}
+	return 0644, nil
+}
+
+// WriteFile writes an output file with the given permissions. os.WriteFile keeps the permissions
+// of an existing file, so they are set explicitly. With failIfExists an existing file is not
+// overwritten.
+func WriteFile(path string, content []byte, perm os.FileMode, failIfExists bool) error {
+	if failIfExists {
+		return writeNewFile(path, content, perm)
+	}
+	if err := os.WriteFile(path, content, perm); err != nil {
+		return err
+	}
+	return os.Chmod(path, perm)
+}
+
+// writeNewFile writes a file that must not exist yet. The file is created exclusively, so a file
+// created between a check and the write is not overwritten either.
+func writeNewFile(path string, content []byte, perm os.FileMode) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if errors.Is(err, fs.ErrExist) {
+		return fmt.Errorf("%s already exists, remove it or leave out --fail-if-exists", path)
+	}
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(content); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	// The umask may have removed permissions
+	return os.Chmod(path, perm)
+}
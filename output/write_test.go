@@ -0,0 +1,169 @@
+package output
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"enver/cryptutil"
+	"enver/sources"
+)
+
+func TestWriteSourceFiles(t *testing.T) {
+	t.Chdir(t.TempDir())
+	dir := "generated"
+
+	outputs := []SourceEntries{
+		{
+			Source: sources.Source{Type: "ConfigMap", Name: "app-config", Namespace: "default"},
+			Entries: []sources.EnvEntry{
+				{Key: "HOST", Value: "localhost", SourceType: "ConfigMap", Name: "app-config", Namespace: "default"},
+			},
+		},
+		{
+			Source: sources.Source{Type: "EnvFile", Path: "config/local.env"},
+			Entries: []sources.EnvEntry{
+				{Key: "DEBUG", Value: "true", SourceType: "EnvFile", Name: "config/local.env"},
+			},
+		},
+		{
+			Source: sources.Source{Type: "ConfigMap", Name: "app-config", Namespace: "other"},
+			Entries: []sources.EnvEntry{
+				{Key: "HOST", Value: "remote", SourceType: "ConfigMap", Name: "app-config", Namespace: "other"},
+			},
+		},
+	}
+
+	result, err := writeSourceFiles(outputs, Options{Directory: dir, Format: FormatEnv})
+	if err != nil {
+		t.Fatalf("writeSourceFiles returned error: %v", err)
+	}
+	paths := result.SourcePaths
+
+	expected := map[string]string{
+		"ConfigMap-app-config.env":     "# ConfigMap default/app-config\nHOST=localhost\n",
+		"EnvFile-config-local.env.env": "# EnvFile config/local.env\nDEBUG=true\n",
+		"ConfigMap-app-config-2.env":   "# ConfigMap other/app-config\nHOST=remote\n",
+	}
+	if len(paths) != len(expected) {
+		t.Fatalf("expected %d files, got %d: %v", len(expected), len(paths), paths)
+	}
+
+	for name, want := range expected {
+		content, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Errorf("expected file %s: %v", name, err)
+			continue
+		}
+		if string(content) != want {
+			t.Errorf("%s: expected %q, got %q", name, want, string(content))
+		}
+	}
+}
+
+func TestFileModeRestrictsSecrets(t *testing.T) {
+	secretEntries := []sources.EnvEntry{
+		{Key: "HOST", Value: "localhost", SourceType: "Vars", Name: "inline"},
+		{Key: "PASSWORD", Value: "secret", SourceType: "Secret", Name: "db", Namespace: "default"},
+	}
+	plainEntries := secretEntries[:1]
+
+	path := filepath.Join(t.TempDir(), ".env")
+	// An existing world-readable file is restricted as well
+	if err := os.WriteFile(path, []byte("OLD=value\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	perm, err := FileMode("", secretEntries)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteFile(path, []byte(renderEnv(secretEntries, RenderOptions{})), perm, false); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("expected a secret-containing output to be 0600, got %o", info.Mode().Perm())
+	}
+
+	if perm, err := FileMode("", plainEntries); err != nil || perm != 0644 {
+		t.Errorf("expected 0644 without secrets, got %o (%v)", perm, err)
+	}
+	if perm, err := FileMode("0640", secretEntries); err != nil || perm != 0640 {
+		t.Errorf("expected the configured mode 0640, got %o (%v)", perm, err)
+	}
+	if _, err := FileMode("rw-r--r--", plainEntries); err == nil {
+		t.Error("expected an error for a mode that is not octal")
+	}
+}
+
+func TestWriteOnlyDiffWriteAndExplode(t *testing.T) {
+	t.Chdir(t.TempDir())
+	entries := []sources.EnvEntry{
+		{Key: "HOST", Value: "localhost", SourceType: "ConfigMap", Name: "app", Namespace: "default"},
+	}
+	perSource := []SourceEntries{{Source: sources.Source{Type: "ConfigMap", Name: "app"}, Entries: entries}}
+	opts := Options{Directory: "generated", Name: ".env", Explode: true, OnlyDiffWrite: true}
+
+	result, err := Write(entries, perSource, opts)
+	if err != nil {
+		t.Fatalf("Write returned error: %v", err)
+	}
+	if result.Unchanged || result.Path != filepath.Join("generated", ".env") {
+		t.Fatalf("expected the combined file to be written, got %+v", result)
+	}
+	if len(result.SourcePaths) != 1 || result.SourcePaths[0] != filepath.Join("generated", "ConfigMap-app.env") {
+		t.Fatalf("expected one exploded source file, got %v", result.SourcePaths)
+	}
+
+	result, err = Write(entries, perSource, opts)
+	if err != nil {
+		t.Fatalf("second Write returned error: %v", err)
+	}
+	if !result.Unchanged {
+		t.Errorf("expected unchanged content not to be written again, got %+v", result)
+	}
+}
+
+func TestWritePerSourceHonoursFormatEncryptAndOnlyDiffWrite(t *testing.T) {
+	t.Chdir(t.TempDir())
+	perSource := []SourceEntries{
+		{
+			Source:  sources.Source{Type: "Vars", Name: "app"},
+			Entries: []sources.EnvEntry{{Key: "HOST", Value: "localhost", SourceType: "Vars", Name: "app"}},
+		},
+	}
+	opts := Options{Directory: "generated", Name: "app.tfvars", PerSource: true, Encrypt: true, Passphrase: "secret", OnlyDiffWrite: true}
+
+	result, err := Write(nil, perSource, opts)
+	if err != nil {
+		t.Fatalf("Write returned error: %v", err)
+	}
+	path := filepath.Join("generated", "Vars-app.tfvars")
+	if len(result.SourcePaths) != 1 || result.SourcePaths[0] != path {
+		t.Fatalf("expected %s to be written, got %v", path, result.SourcePaths)
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read %s: %v", path, err)
+	}
+	decrypted, err := cryptutil.Decrypt(content, "secret")
+	if err != nil {
+		t.Fatalf("expected an encrypted file: %v", err)
+	}
+	if want := "# Vars app\nHOST = \"localhost\"\n"; string(decrypted) != want {
+		t.Errorf("expected %q, got %q", want, string(decrypted))
+	}
+
+	result, err = Write(nil, perSource, opts)
+	if err != nil {
+		t.Fatalf("second Write returned error: %v", err)
+	}
+	if len(result.SourcePaths) != 0 {
+		t.Errorf("expected unchanged source files not to be written again, got %v", result.SourcePaths)
+	}
+}
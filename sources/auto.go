@@ -0,0 +1,99 @@
+package sources
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// AutoType is the source type that detects the kind of the named object
+const AutoType = "auto"
+
+// autoKinds are the kinds a source of type auto can resolve to, in the order they are looked up
+var autoKinds = []string{"ConfigMap", "Secret", "Deployment", "StatefulSet", "DaemonSet"}
+
+// AutoFetcher fetches a source of type auto with the fetcher of the only kind of object that has
+// the source's name in its namespace
+type AutoFetcher struct {
+	fetchers map[string]Fetcher
+}
+
+// NewAutoFetcher returns an AutoFetcher that dispatches to the given fetchers by type
+func NewAutoFetcher(fetchers map[string]Fetcher) *AutoFetcher {
+	return &AutoFetcher{fetchers: fetchers}
+}
+
+func (f *AutoFetcher) Fetch(ctx context.Context, clientset kubernetes.Interface, source Source, outputDirectory string) ([]EnvEntry, error) {
+	namespace := source.GetNamespace()
+	kinds, err := DetectKinds(ctx, clientset, namespace, source.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	switch len(kinds) {
+	case 0:
+		if source.Optional {
+			if !quiet {
+				fmt.Fprintf(warnings, "Warning: optional object %s/%s not found, skipping\n", namespace, source.Name)
+			}
+			return nil, nil
+		}
+		return nil, fmt.Errorf("no ConfigMap, Secret, Deployment, StatefulSet or DaemonSet named %s/%s", namespace, source.Name)
+	case 1:
+	default:
+		return nil, fmt.Errorf("%s/%s is ambiguous, it is a %s: set type to one of them", namespace, source.Name, strings.Join(kinds, " and a "))
+	}
+
+	fetcher, ok := f.fetchers[kinds[0]]
+	if !ok {
+		return nil, fmt.Errorf("no fetcher for %s", kinds[0])
+	}
+	source.Type = kinds[0]
+	return fetcher.Fetch(ctx, clientset, source, outputDirectory)
+}
+
+// DetectKinds returns the kinds of the objects named name in the namespace, out of ConfigMap,
+// Secret, Deployment, StatefulSet and DaemonSet
+func DetectKinds(ctx context.Context, clientset kubernetes.Interface, namespace, name string) ([]string, error) {
+	lookups := map[string]func() error{
+		"ConfigMap": func() error {
+			_, err := clientset.CoreV1().ConfigMaps(namespace).Get(ctx, name, metav1.GetOptions{})
+			return err
+		},
+		"Secret": func() error {
+			_, err := clientset.CoreV1().Secrets(namespace).Get(ctx, name, metav1.GetOptions{})
+			return err
+		},
+		"Deployment": func() error {
+			_, err := clientset.AppsV1().Deployments(namespace).Get(ctx, name, metav1.GetOptions{})
+			return err
+		},
+		"StatefulSet": func() error {
+			_, err := clientset.AppsV1().StatefulSets(namespace).Get(ctx, name, metav1.GetOptions{})
+			return err
+		},
+		"DaemonSet": func() error {
+			_, err := clientset.AppsV1().DaemonSets(namespace).Get(ctx, name, metav1.GetOptions{})
+			return err
+		},
+	}
+
+	var kinds []string
+	for _, kind := range autoKinds {
+		_, err := withRetry(ctx, func() (struct{}, error) {
+			return struct{}{}, lookups[kind]()
+		})
+		if apierrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to look up %s %s/%s: %w", strings.ToLower(kind), namespace, name, err)
+		}
+		kinds = append(kinds, kind)
+	}
+	return kinds, nil
+}
@@ -0,0 +1,84 @@
+package sources
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestAutoFetcherDetectsKind(t *testing.T) {
+	clientset := fake.NewClientset(
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "settings", Namespace: "default"},
+			Data:       map[string]string{"PORT": "8080"},
+		},
+		&appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "worker", Namespace: "default"},
+			Spec: appsv1.DeploymentSpec{Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{
+				Containers: []corev1.Container{{Name: "worker", Env: []corev1.EnvVar{{Name: "QUEUE", Value: "jobs"}}}},
+			}}},
+		},
+	)
+	fetcher := NewAutoFetcher(map[string]Fetcher{
+		"ConfigMap":  &ConfigMapFetcher{},
+		"Deployment": &DeploymentFetcher{},
+	})
+
+	tests := []struct {
+		name     string
+		expected EnvEntry
+	}{
+		{name: "settings", expected: EnvEntry{Key: "PORT", Value: "8080", SourceType: "ConfigMap", Name: "settings"}},
+		{name: "worker", expected: EnvEntry{Key: "QUEUE", Value: "jobs", SourceType: "Deployment", Name: "worker/worker"}},
+	}
+	for _, tt := range tests {
+		entries, err := fetcher.Fetch(context.Background(), clientset, Source{Type: AutoType, Name: tt.name, Namespace: "default"}, t.TempDir())
+		if err != nil {
+			t.Fatalf("%s: Fetch returned error: %v", tt.name, err)
+		}
+		if len(entries) != 1 {
+			t.Fatalf("%s: expected 1 entry, got %+v", tt.name, entries)
+		}
+		got := entries[0]
+		if got.Key != tt.expected.Key || got.Value != tt.expected.Value || got.SourceType != tt.expected.SourceType || got.Name != tt.expected.Name {
+			t.Errorf("%s: expected %+v, got %+v", tt.name, tt.expected, got)
+		}
+	}
+}
+
+func TestAutoFetcherRejectsAmbiguousAndMissingNames(t *testing.T) {
+	clientset := fake.NewClientset(
+		&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "default"}},
+		&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "default"}},
+	)
+	fetcher := NewAutoFetcher(map[string]Fetcher{"ConfigMap": &ConfigMapFetcher{}, "Secret": &SecretFetcher{}})
+
+	_, err := fetcher.Fetch(context.Background(), clientset, Source{Type: AutoType, Name: "app", Namespace: "default"}, t.TempDir())
+	if err == nil || !strings.Contains(err.Error(), "default/app is ambiguous, it is a ConfigMap and a Secret") {
+		t.Errorf("expected an ambiguity error, got %v", err)
+	}
+
+	_, err = fetcher.Fetch(context.Background(), clientset, Source{Type: AutoType, Name: "missing", Namespace: "default"}, t.TempDir())
+	if err == nil || !strings.Contains(err.Error(), "no ConfigMap, Secret, Deployment, StatefulSet or DaemonSet named default/missing") {
+		t.Errorf("expected a not found error, got %v", err)
+	}
+
+	// An optional source contributes nothing
+	var warned bytes.Buffer
+	defer func(w io.Writer) { warnings = w }(warnings)
+	warnings = &warned
+	entries, err := fetcher.Fetch(context.Background(), clientset, Source{Type: AutoType, Name: "missing", Namespace: "default", Optional: true}, t.TempDir())
+	if err != nil || len(entries) != 0 {
+		t.Errorf("expected no entries and no error for an optional source, got %+v and %v", entries, err)
+	}
+	if !strings.Contains(warned.String(), "optional object default/missing not found") {
+		t.Errorf("expected a warning, got %q", warned.String())
+	}
+}
@@ -0,0 +1,116 @@
+package sources
+
+import (
+	"context"
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+)
+
+// objectCache remembers the result of every ConfigMap and Secret GET, keyed by namespace/kind/name.
+// Failed GETs are remembered too, unless the error is transient.
+type objectCache struct {
+	mu      sync.Mutex
+	entries map[string]*cachedObject
+}
+
+// cachedObject is the result of a single GET, loaded once even when requested concurrently
+type cachedObject struct {
+	once   sync.Once
+	object any
+	err    error
+}
+
+// load returns the cached result for key, calling get on the first request
+func (c *objectCache) load(key string, get func() (any, error)) (any, error) {
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	if !ok {
+		entry = &cachedObject{}
+		c.entries[key] = entry
+	}
+	c.mu.Unlock()
+
+	entry.once.Do(func() {
+		entry.object, entry.err = get()
+	})
+
+	// Transient errors are forgotten so a retry requests the object again
+	if entry.err != nil && isRetryable(entry.err) {
+		c.mu.Lock()
+		if c.entries[key] == entry {
+			delete(c.entries, key)
+		}
+		c.mu.Unlock()
+	}
+	return entry.object, entry.err
+}
+
+// NewCachingClientset wraps a clientset so that ConfigMaps and Secrets are only requested once.
+// Workloads often reference the same object from several containers, envFrom entries and volumes.
+// The cache lives as long as the returned clientset, so create one per run and kube context.
+func NewCachingClientset(clientset kubernetes.Interface) kubernetes.Interface {
+	return &cachingClientset{
+		Interface: clientset,
+		cache:     &objectCache{entries: make(map[string]*cachedObject)},
+	}
+}
+
+type cachingClientset struct {
+	kubernetes.Interface
+	cache *objectCache
+}
+
+func (c *cachingClientset) CoreV1() typedcorev1.CoreV1Interface {
+	return &cachingCoreV1{CoreV1Interface: c.Interface.CoreV1(), cache: c.cache}
+}
+
+type cachingCoreV1 struct {
+	typedcorev1.CoreV1Interface
+	cache *objectCache
+}
+
+func (c *cachingCoreV1) ConfigMaps(namespace string) typedcorev1.ConfigMapInterface {
+	return &cachingConfigMaps{ConfigMapInterface: c.CoreV1Interface.ConfigMaps(namespace), namespace: namespace, cache: c.cache}
+}
+
+func (c *cachingCoreV1) Secrets(namespace string) typedcorev1.SecretInterface {
+	return &cachingSecrets{SecretInterface: c.CoreV1Interface.Secrets(namespace), namespace: namespace, cache: c.cache}
+}
+
+type cachingConfigMaps struct {
+	typedcorev1.ConfigMapInterface
+	namespace string
+	cache     *objectCache
+}
+
+// Get returns a copy of the cached ConfigMap so callers can't modify each other's results
+func (c *cachingConfigMaps) Get(ctx context.Context, name string, opts metav1.GetOptions) (*corev1.ConfigMap, error) {
+	object, err := c.cache.load(c.namespace+"/ConfigMap/"+name, func() (any, error) {
+		return c.ConfigMapInterface.Get(ctx, name, opts)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return object.(*corev1.ConfigMap).DeepCopy(), nil
+}
+
+type cachingSecrets struct {
+	typedcorev1.SecretInterface
+	namespace string
+	cache     *objectCache
+}
+
+// Get returns a copy of the cached Secret so callers can't modify each other's results
+func (c *cachingSecrets) Get(ctx context.Context, name string, opts metav1.GetOptions) (*corev1.Secret, error) {
+	object, err := c.cache.load(c.namespace+"/Secret/"+name, func() (any, error) {
+		return c.SecretInterface.Get(ctx, name, opts)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return object.(*corev1.Secret).DeepCopy(), nil
+}
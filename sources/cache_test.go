@@ -0,0 +1,69 @@
+package sources
+
+import (
+	"context"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestCachingClientsetGetsEachObjectOnce(t *testing.T) {
+	envFrom := []corev1.EnvFromSource{
+		{ConfigMapRef: &corev1.ConfigMapEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: "shared"}}},
+	}
+	env := []corev1.EnvVar{{
+		Name: "PASSWORD",
+		ValueFrom: &corev1.EnvVarSource{SecretKeyRef: &corev1.SecretKeySelector{
+			LocalObjectReference: corev1.LocalObjectReference{Name: "credentials"},
+			Key:                  "password",
+		}},
+	}}
+
+	fakeClientset := fake.NewClientset(
+		&appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "default"},
+			Spec: appsv1.DeploymentSpec{Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{
+				Containers: []corev1.Container{
+					{Name: "app", EnvFrom: envFrom, Env: env},
+					{Name: "sidecar", EnvFrom: envFrom, Env: env},
+				},
+			}}},
+		},
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "shared", Namespace: "default"},
+			Data:       map[string]string{"REGION": "eu"},
+		},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "credentials", Namespace: "default"},
+			Data:       map[string][]byte{"password": []byte("secret")},
+		},
+	)
+	clientset := NewCachingClientset(fakeClientset)
+
+	source := Source{Type: "Deployment", Name: "app"}
+	entries, err := (&DeploymentFetcher{}).Fetch(context.Background(), clientset, source, t.TempDir())
+	if err != nil {
+		t.Fatalf("Fetch returned error: %v", err)
+	}
+	if len(entries) != 4 {
+		t.Fatalf("expected 4 entries, got %d: %v", len(entries), entries)
+	}
+
+	// A second source reading the same ConfigMap is served from the cache too
+	if _, err := (&ConfigMapFetcher{}).Fetch(context.Background(), clientset, Source{Type: "ConfigMap", Name: "shared"}, t.TempDir()); err != nil {
+		t.Fatalf("Fetch returned error: %v", err)
+	}
+
+	gets := make(map[string]int)
+	for _, action := range fakeClientset.Actions() {
+		if action.GetVerb() == "get" {
+			gets[action.GetResource().Resource]++
+		}
+	}
+	if gets["configmaps"] != 1 || gets["secrets"] != 1 {
+		t.Errorf("expected one get per configmap and secret, got %v", gets)
+	}
+}
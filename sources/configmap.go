@@ -6,16 +6,22 @@ import (
 
 	"enver/transformations"
 
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 )
 
 type ConfigMapFetcher struct{}
 
-func (f *ConfigMapFetcher) Fetch(clientset *kubernetes.Clientset, source Source, outputDirectory string) ([]EnvEntry, error) {
+func (f *ConfigMapFetcher) Fetch(ctx context.Context, clientset kubernetes.Interface, source Source, outputDirectory string) ([]EnvEntry, error) {
 	namespace := source.GetNamespace()
-	cm, err := clientset.CoreV1().ConfigMaps(namespace).Get(context.Background(), source.Name, metav1.GetOptions{})
+	cm, err := withRetry(ctx, func() (*corev1.ConfigMap, error) {
+		return clientset.CoreV1().ConfigMaps(namespace).Get(ctx, source.Name, metav1.GetOptions{})
+	})
 	if err != nil {
+		if source.skipMissing("configmap", namespace, err) {
+			return nil, nil
+		}
 		return nil, fmt.Errorf("failed to get configmap %s/%s: %w", namespace, source.Name, err)
 	}
 
@@ -30,24 +36,33 @@ func (f *ConfigMapFetcher) Fetch(clientset *kubernetes.Clientset, source Source,
 			Output:        tc.Output,
 			Key:           tc.Key,
 			BaseDirectory: outputDirectory,
+			Mode:          fileMode(ctx, false),
 		})
 	}
 
+	// Report what manages the configmap if requested
+	var owner string
+	if source.IncludeOwner {
+		owner = describeOwner(cm.ObjectMeta)
+	}
+
 	var entries []EnvEntry
 	for key, value := range cm.Data {
-		if value != "" && !source.ShouldExcludeVariable(key) {
-			// Apply transformations
-			transformedKey, transformedValue, err := transformations.ApplyTransformations(key, value, transformConfigs)
+		if source.keepValue(ctx, key, value) {
+			// Apply transformations to the mapped key
+			transformedKey, transformedValue, err := transformations.ApplyTransformations(source.GetKeyMapping(key), value, transformConfigs)
 			if err != nil {
 				return nil, fmt.Errorf("failed to apply transformation: %w", err)
 			}
 
 			entries = append(entries, EnvEntry{
-				Key:        transformedKey,
-				Value:      transformedValue,
-				SourceType: "ConfigMap",
-				Name:       source.Name,
-				Namespace:  namespace,
+				Key:             transformedKey,
+				Value:           transformedValue,
+				SourceType:      "ConfigMap",
+				Name:            source.Name,
+				Namespace:       namespace,
+				Owner:           owner,
+				ResourceVersion: cm.ResourceVersion,
 			})
 		}
 	}
@@ -4,13 +4,16 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 
 	"enver/gitutil"
 	"enver/transformations"
 
+	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
@@ -19,15 +22,42 @@ import (
 	"k8s.io/client-go/tools/remotecommand"
 )
 
+// Methods for reading a container's environment
+const (
+	ContainerMethodExec   = "exec"   // run env in the container (default)
+	ContainerMethodStatic = "static" // compute the environment from the pod spec and status without exec
+)
+
+// podExecFunc runs a command in a container and returns its stdout and stderr separately
+type podExecFunc func(ctx context.Context, clientset kubernetes.Interface, namespace, podName, containerName string, command []string) (string, string, error)
+
+// execSemaphore bounds the number of concurrent exec sessions across all Container sources, nil means unlimited
+var execSemaphore chan struct{}
+
+// SetExecConcurrency limits how many exec sessions run at the same time; 0 or less means unlimited.
+// It must be called before any source is fetched.
+func SetExecConcurrency(limit int) {
+	if limit <= 0 {
+		execSemaphore = nil
+		return
+	}
+	execSemaphore = make(chan struct{}, limit)
+}
+
 type ContainerFetcher struct {
+	processor  WorkloadProcessor
 	restConfig *rest.Config
+	exec       podExecFunc
+	stderr     io.Writer // where stderr captured with captureStderr is logged
 }
 
 func NewContainerFetcher(restConfig *rest.Config) *ContainerFetcher {
-	return &ContainerFetcher{restConfig: restConfig}
+	f := &ContainerFetcher{restConfig: restConfig, stderr: os.Stderr}
+	f.exec = f.spdyExec
+	return f
 }
 
-func (f *ContainerFetcher) Fetch(clientset *kubernetes.Clientset, source Source, outputDirectory string) ([]EnvEntry, error) {
+func (f *ContainerFetcher) Fetch(ctx context.Context, clientset kubernetes.Interface, source Source, outputDirectory string) ([]EnvEntry, error) {
 	namespace := source.GetNamespace()
 
 	// Validate kind
@@ -43,6 +73,18 @@ func (f *ContainerFetcher) Fetch(clientset *kubernetes.Clientset, source Source,
 	if !validKinds[source.Kind] {
 		return nil, fmt.Errorf("invalid kind %q for Container source %q (must be Pod, Deployment, StatefulSet, or DaemonSet)", source.Kind, source.Name)
 	}
+	switch source.Method {
+	case "", ContainerMethodExec:
+	case ContainerMethodStatic:
+		if len(source.Files) > 0 {
+			return nil, fmt.Errorf("files of Container source %q cannot be extracted with method %s", source.Name, ContainerMethodStatic)
+		}
+	default:
+		return nil, fmt.Errorf("invalid method %q for Container source %q (must be exec or static)", source.Method, source.Name)
+	}
+	if source.Pod != "" && source.Kind != "StatefulSet" {
+		return nil, fmt.Errorf("pod of Container source %q is only supported for kind StatefulSet", source.Name)
+	}
 
 	// Find the target pod
 	var podName string
@@ -52,24 +94,26 @@ func (f *ContainerFetcher) Fetch(clientset *kubernetes.Clientset, source Source,
 	switch source.Kind {
 	case "Pod":
 		podName = source.Name
-		pod, err = clientset.CoreV1().Pods(namespace).Get(context.Background(), podName, metav1.GetOptions{})
+		pod, err = withRetry(ctx, func() (*corev1.Pod, error) {
+			return clientset.CoreV1().Pods(namespace).Get(ctx, podName, metav1.GetOptions{})
+		})
 		if err != nil {
 			return nil, fmt.Errorf("failed to get pod %s/%s: %w", namespace, podName, err)
 		}
 	case "Deployment":
-		pod, err = f.findPodForDeployment(clientset, namespace, source.Name)
+		pod, err = f.findPodForDeployment(ctx, clientset, namespace, source.Name)
 		if err != nil {
 			return nil, err
 		}
 		podName = pod.Name
 	case "StatefulSet":
-		pod, err = f.findPodForStatefulSet(clientset, namespace, source.Name)
+		pod, err = f.findPodForStatefulSet(ctx, clientset, namespace, source.Name, source.Pod)
 		if err != nil {
 			return nil, err
 		}
 		podName = pod.Name
 	case "DaemonSet":
-		pod, err = f.findPodForDaemonSet(clientset, namespace, source.Name)
+		pod, err = f.findPodForDaemonSet(ctx, clientset, namespace, source.Name)
 		if err != nil {
 			return nil, err
 		}
@@ -81,6 +125,11 @@ func (f *ContainerFetcher) Fetch(clientset *kubernetes.Clientset, source Source,
 		return nil, fmt.Errorf("pod %s/%s is not running (phase: %s)", namespace, podName, pod.Status.Phase)
 	}
 
+	// Compute the environment from the API instead of exec'ing into the pod
+	if source.Method == ContainerMethodStatic {
+		return f.processor.ProcessPod(ctx, clientset, pod, source, "Container", outputDirectory)
+	}
+
 	// Convert transformation configs
 	var transformConfigs []transformations.Config
 	for _, tc := range source.Transformations {
@@ -92,33 +141,22 @@ func (f *ContainerFetcher) Fetch(clientset *kubernetes.Clientset, source Source,
 			Output:        tc.Output,
 			Key:           tc.Key,
 			BaseDirectory: outputDirectory,
+			Mode:          fileMode(ctx, false),
 		})
 	}
 
-	// Build set of container names to include
-	containerFilter := make(map[string]bool)
-	for _, name := range source.Containers {
-		containerFilter[name] = true
-	}
-	filterContainers := len(containerFilter) > 0
-
 	var entries []EnvEntry
 
 	// Process each container
-	for _, container := range pod.Spec.Containers {
-		// Skip if container is not in the filter list
-		if filterContainers && !containerFilter[container.Name] {
-			continue
-		}
-
+	for _, container := range podContainers(pod.Spec, pod, source) {
 		// Exec into container and run env command
-		envOutput, err := f.execEnvCommand(clientset, namespace, podName, container.Name)
+		envOutput, err := f.execEnvCommand(ctx, clientset, source, namespace, podName, container.Name)
 		if err != nil {
 			return nil, fmt.Errorf("failed to exec into container %s in pod %s/%s: %w", container.Name, namespace, podName, err)
 		}
 
 		// Parse env output
-		containerEntries, err := f.parseEnvOutput(envOutput, source, container.Name, podName, namespace, transformConfigs)
+		containerEntries, err := f.parseEnvOutput(envOutput, source, container.label, podName, namespace, transformConfigs)
 		if err != nil {
 			return nil, err
 		}
@@ -128,7 +166,11 @@ func (f *ContainerFetcher) Fetch(clientset *kubernetes.Clientset, source Source,
 
 	// Process file extractions
 	for _, fileExtract := range source.Files {
-		fileEntry, err := f.extractFile(clientset, namespace, podName, pod, fileExtract, outputDirectory)
+		// The source's own patterns don't apply to files, but the command line filters do
+		if source.Variables.CommandLine.excludesName(fileExtract.Key) {
+			continue
+		}
+		fileEntry, err := f.extractFile(ctx, clientset, namespace, podName, pod, fileExtract, outputDirectory)
 		if err != nil {
 			return nil, err
 		}
@@ -138,42 +180,79 @@ func (f *ContainerFetcher) Fetch(clientset *kubernetes.Clientset, source Source,
 	return entries, nil
 }
 
-func (f *ContainerFetcher) findPodForDeployment(clientset *kubernetes.Clientset, namespace, deploymentName string) (*corev1.Pod, error) {
-	deployment, err := clientset.AppsV1().Deployments(namespace).Get(context.Background(), deploymentName, metav1.GetOptions{})
+func (f *ContainerFetcher) findPodForDeployment(ctx context.Context, clientset kubernetes.Interface, namespace, deploymentName string) (*corev1.Pod, error) {
+	deployment, err := withRetry(ctx, func() (*appsv1.Deployment, error) {
+		return clientset.AppsV1().Deployments(namespace).Get(ctx, deploymentName, metav1.GetOptions{})
+	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to get deployment %s/%s: %w", namespace, deploymentName, err)
 	}
 
 	// Get pods matching the deployment's selector
 	labelSelector := metav1.FormatLabelSelector(deployment.Spec.Selector)
-	return f.findRunningPod(clientset, namespace, labelSelector, "Deployment", deploymentName)
+	return f.findRunningPod(ctx, clientset, namespace, labelSelector, "Deployment", deploymentName)
 }
 
-func (f *ContainerFetcher) findPodForStatefulSet(clientset *kubernetes.Clientset, namespace, statefulSetName string) (*corev1.Pod, error) {
-	statefulSet, err := clientset.AppsV1().StatefulSets(namespace).Get(context.Background(), statefulSetName, metav1.GetOptions{})
+// findPodForStatefulSet returns the replica selected by replica, an ordinal or pod name, or the first
+// running pod when replica is empty
+func (f *ContainerFetcher) findPodForStatefulSet(ctx context.Context, clientset kubernetes.Interface, namespace, statefulSetName, replica string) (*corev1.Pod, error) {
+	statefulSet, err := withRetry(ctx, func() (*appsv1.StatefulSet, error) {
+		return clientset.AppsV1().StatefulSets(namespace).Get(ctx, statefulSetName, metav1.GetOptions{})
+	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to get statefulset %s/%s: %w", namespace, statefulSetName, err)
 	}
 
-	// Get pods matching the statefulset's selector
-	labelSelector := metav1.FormatLabelSelector(statefulSet.Spec.Selector)
-	return f.findRunningPod(clientset, namespace, labelSelector, "StatefulSet", statefulSetName)
+	if replica == "" {
+		// Get pods matching the statefulset's selector
+		labelSelector := metav1.FormatLabelSelector(statefulSet.Spec.Selector)
+		return f.findRunningPod(ctx, clientset, namespace, labelSelector, "StatefulSet", statefulSetName)
+	}
+
+	// Replicas are named <statefulset>-<ordinal>, with ordinals from spec.ordinals.start
+	ordinal, err := strconv.Atoi(strings.TrimPrefix(replica, statefulSetName+"-"))
+	if err != nil || ordinal < 0 {
+		return nil, fmt.Errorf("pod %q is not an ordinal or a pod name of StatefulSet %s/%s", replica, namespace, statefulSetName)
+	}
+	start, replicas := 0, 1
+	if statefulSet.Spec.Ordinals != nil {
+		start = int(statefulSet.Spec.Ordinals.Start)
+	}
+	if statefulSet.Spec.Replicas != nil {
+		replicas = int(*statefulSet.Spec.Replicas)
+	}
+	if ordinal < start || ordinal >= start+replicas {
+		return nil, fmt.Errorf("StatefulSet %s/%s has no ordinal %d (it has %d replicas starting at %d)", namespace, statefulSetName, ordinal, replicas, start)
+	}
+
+	podName := fmt.Sprintf("%s-%d", statefulSetName, ordinal)
+	pod, err := withRetry(ctx, func() (*corev1.Pod, error) {
+		return clientset.CoreV1().Pods(namespace).Get(ctx, podName, metav1.GetOptions{})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pod %s/%s of StatefulSet %s: %w", namespace, podName, statefulSetName, err)
+	}
+	return pod, nil
 }
 
-func (f *ContainerFetcher) findPodForDaemonSet(clientset *kubernetes.Clientset, namespace, daemonSetName string) (*corev1.Pod, error) {
-	daemonSet, err := clientset.AppsV1().DaemonSets(namespace).Get(context.Background(), daemonSetName, metav1.GetOptions{})
+func (f *ContainerFetcher) findPodForDaemonSet(ctx context.Context, clientset kubernetes.Interface, namespace, daemonSetName string) (*corev1.Pod, error) {
+	daemonSet, err := withRetry(ctx, func() (*appsv1.DaemonSet, error) {
+		return clientset.AppsV1().DaemonSets(namespace).Get(ctx, daemonSetName, metav1.GetOptions{})
+	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to get daemonset %s/%s: %w", namespace, daemonSetName, err)
 	}
 
 	// Get pods matching the daemonset's selector
 	labelSelector := metav1.FormatLabelSelector(daemonSet.Spec.Selector)
-	return f.findRunningPod(clientset, namespace, labelSelector, "DaemonSet", daemonSetName)
+	return f.findRunningPod(ctx, clientset, namespace, labelSelector, "DaemonSet", daemonSetName)
 }
 
-func (f *ContainerFetcher) findRunningPod(clientset *kubernetes.Clientset, namespace, labelSelector, workloadType, workloadName string) (*corev1.Pod, error) {
-	pods, err := clientset.CoreV1().Pods(namespace).List(context.Background(), metav1.ListOptions{
-		LabelSelector: labelSelector,
+func (f *ContainerFetcher) findRunningPod(ctx context.Context, clientset kubernetes.Interface, namespace, labelSelector, workloadType, workloadName string) (*corev1.Pod, error) {
+	pods, err := withRetry(ctx, func() (*corev1.PodList, error) {
+		return clientset.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{
+			LabelSelector: labelSelector,
+		})
 	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to list pods for %s %s/%s: %w", workloadType, namespace, workloadName, err)
@@ -194,7 +273,39 @@ func (f *ContainerFetcher) findRunningPod(clientset *kubernetes.Clientset, names
 	return nil, fmt.Errorf("no running pods found for %s %s/%s (found %d pods, none running)", workloadType, namespace, workloadName, len(pods.Items))
 }
 
-func (f *ContainerFetcher) execEnvCommand(clientset *kubernetes.Clientset, namespace, podName, containerName string) (string, error) {
+// execEnvCommand runs env in the container and returns its stdout. Stderr is never parsed: it is
+// added to the error when the command fails and logged when the source has captureStderr.
+func (f *ContainerFetcher) execEnvCommand(ctx context.Context, clientset kubernetes.Interface, source Source, namespace, podName, containerName string) (string, error) {
+	stdout, stderr, err := f.limitedExec(ctx, clientset, namespace, podName, containerName, []string{"env"})
+	if err != nil {
+		return "", fmt.Errorf("exec failed: %w (stderr: %s)", err, stderr)
+	}
+
+	if source.CaptureStderr && !quiet && strings.TrimSpace(stderr) != "" {
+		fmt.Fprintf(f.stderr, "Container %s/%s (%s) stderr:\n", namespace, podName, containerName)
+		for _, line := range strings.Split(strings.TrimRight(stderr, "\n"), "\n") {
+			fmt.Fprintf(f.stderr, "  %s\n", line)
+		}
+	}
+
+	return stdout, nil
+}
+
+// limitedExec runs a command in a container once an exec slot is available
+func (f *ContainerFetcher) limitedExec(ctx context.Context, clientset kubernetes.Interface, namespace, podName, containerName string, command []string) (string, string, error) {
+	if semaphore := execSemaphore; semaphore != nil {
+		select {
+		case semaphore <- struct{}{}:
+		case <-ctx.Done():
+			return "", "", ctx.Err()
+		}
+		defer func() { <-semaphore }()
+	}
+	return f.exec(ctx, clientset, namespace, podName, containerName, command)
+}
+
+// spdyExec runs a command in a container through the pods/exec subresource
+func (f *ContainerFetcher) spdyExec(ctx context.Context, clientset kubernetes.Interface, namespace, podName, containerName string, command []string) (string, string, error) {
 	req := clientset.CoreV1().RESTClient().Post().
 		Resource("pods").
 		Name(podName).
@@ -202,29 +313,25 @@ func (f *ContainerFetcher) execEnvCommand(clientset *kubernetes.Clientset, names
 		SubResource("exec").
 		VersionedParams(&corev1.PodExecOptions{
 			Container: containerName,
-			Command:   []string{"env"},
+			Command:   command,
 			Stdout:    true,
 			Stderr:    true,
 		}, scheme.ParameterCodec)
 
 	exec, err := remotecommand.NewSPDYExecutor(f.restConfig, "POST", req.URL())
 	if err != nil {
-		return "", fmt.Errorf("failed to create executor: %w", err)
+		return "", "", fmt.Errorf("failed to create executor: %w", err)
 	}
 
 	var stdout, stderr bytes.Buffer
-	err = exec.StreamWithContext(context.Background(), remotecommand.StreamOptions{
+	err = exec.StreamWithContext(ctx, remotecommand.StreamOptions{
 		Stdout: &stdout,
 		Stderr: &stderr,
 	})
-	if err != nil {
-		return "", fmt.Errorf("exec failed: %w (stderr: %s)", err, stderr.String())
-	}
-
-	return stdout.String(), nil
+	return stdout.String(), stderr.String(), err
 }
 
-func (f *ContainerFetcher) parseEnvOutput(output string, source Source, containerName, podName, namespace string, transformConfigs []transformations.Config) ([]EnvEntry, error) {
+func (f *ContainerFetcher) parseEnvOutput(output string, source Source, containerLabel, podName, namespace string, transformConfigs []transformations.Config) ([]EnvEntry, error) {
 	var entries []EnvEntry
 
 	lines := strings.Split(output, "\n")
@@ -243,7 +350,7 @@ func (f *ContainerFetcher) parseEnvOutput(output string, source Source, containe
 		key := line[:idx]
 		value := line[idx+1:]
 
-		if source.ShouldExcludeVariable(key) {
+		if source.ShouldExcludeEntry(key, value) {
 			continue
 		}
 
@@ -256,7 +363,7 @@ func (f *ContainerFetcher) parseEnvOutput(output string, source Source, containe
 			Key:        transformedKey,
 			Value:      transformedValue,
 			SourceType: "Container",
-			Name:       fmt.Sprintf("%s/%s", podName, containerName),
+			Name:       fmt.Sprintf("%s/%s", podName, containerLabel),
 			Namespace:  namespace,
 		})
 	}
@@ -264,7 +371,7 @@ func (f *ContainerFetcher) parseEnvOutput(output string, source Source, containe
 	return entries, nil
 }
 
-func (f *ContainerFetcher) extractFile(clientset *kubernetes.Clientset, namespace, podName string, pod *corev1.Pod, fileExtract ContainerFileExtract, outputDirectory string) (EnvEntry, error) {
+func (f *ContainerFetcher) extractFile(ctx context.Context, clientset kubernetes.Interface, namespace, podName string, pod *corev1.Pod, fileExtract ContainerFileExtract, outputDirectory string) (EnvEntry, error) {
 	// Validate that container exists in the pod
 	containerName := fileExtract.Container
 	containerFound := false
@@ -279,7 +386,7 @@ func (f *ContainerFetcher) extractFile(clientset *kubernetes.Clientset, namespac
 	}
 
 	// Exec cat to read the file content
-	fileContent, err := f.execCatCommand(clientset, namespace, podName, containerName, fileExtract.Path)
+	fileContent, err := f.execCatCommand(ctx, clientset, namespace, podName, containerName, fileExtract.Path)
 	if err != nil {
 		return EnvEntry{}, fmt.Errorf("failed to read file %q from container %s in pod %s/%s: %w", fileExtract.Path, containerName, namespace, podName, err)
 	}
@@ -287,57 +394,47 @@ func (f *ContainerFetcher) extractFile(clientset *kubernetes.Clientset, namespac
 	// Build output path relative to output directory
 	outputPath := filepath.Join(outputDirectory, fileExtract.Output)
 
+	entry := EnvEntry{
+		Key:        fileExtract.Key,
+		Value:      outputPath,
+		SourceType: "Container",
+		Name:       fmt.Sprintf("%s/%s (file: %s)", podName, containerName, fileExtract.Path),
+		Namespace:  namespace,
+	}
+
+	// Report the intended path without touching the filesystem or .gitignore
+	if transformations.IsDryRun() {
+		return entry, nil
+	}
+
 	// Create output directory if it doesn't exist
 	outputDir := filepath.Dir(outputPath)
 	if err := os.MkdirAll(outputDir, 0755); err != nil {
 		return EnvEntry{}, fmt.Errorf("failed to create output directory: %w", err)
 	}
 
-	// Write file content
-	if err := os.WriteFile(outputPath, []byte(fileContent), 0644); err != nil {
+	// Write file content, os.WriteFile keeps the permissions of an existing file
+	perm := fileMode(ctx, false)
+	if err := os.WriteFile(outputPath, []byte(fileContent), perm); err != nil {
 		return EnvEntry{}, fmt.Errorf("failed to write file %s: %w", outputPath, err)
 	}
+	if err := os.Chmod(outputPath, perm); err != nil {
+		return EnvEntry{}, fmt.Errorf("failed to set permissions of %s: %w", outputPath, err)
+	}
 
 	// Check if output file should be added to .gitignore
 	if err := gitutil.EnsureGitignored(outputPath); err != nil {
 		return EnvEntry{}, err
 	}
 
-	return EnvEntry{
-		Key:        fileExtract.Key,
-		Value:      outputPath,
-		SourceType: "Container",
-		Name:       fmt.Sprintf("%s/%s (file: %s)", podName, containerName, fileExtract.Path),
-		Namespace:  namespace,
-	}, nil
+	return entry, nil
 }
 
-func (f *ContainerFetcher) execCatCommand(clientset *kubernetes.Clientset, namespace, podName, containerName, filePath string) (string, error) {
-	req := clientset.CoreV1().RESTClient().Post().
-		Resource("pods").
-		Name(podName).
-		Namespace(namespace).
-		SubResource("exec").
-		VersionedParams(&corev1.PodExecOptions{
-			Container: containerName,
-			Command:   []string{"cat", filePath},
-			Stdout:    true,
-			Stderr:    true,
-		}, scheme.ParameterCodec)
-
-	exec, err := remotecommand.NewSPDYExecutor(f.restConfig, "POST", req.URL())
-	if err != nil {
-		return "", fmt.Errorf("failed to create executor: %w", err)
-	}
-
-	var stdout, stderr bytes.Buffer
-	err = exec.StreamWithContext(context.Background(), remotecommand.StreamOptions{
-		Stdout: &stdout,
-		Stderr: &stderr,
-	})
+func (f *ContainerFetcher) execCatCommand(ctx context.Context, clientset kubernetes.Interface, namespace, podName, containerName, filePath string) (string, error) {
+	stdout, stderr, err := f.limitedExec(ctx, clientset, namespace, podName, containerName, []string{"cat", filePath})
 	if err != nil {
-		return "", fmt.Errorf("cat failed: %w (stderr: %s)", err, stderr.String())
+		return "", fmt.Errorf("cat failed: %w (stderr: %s)", err, stderr)
 	}
 
-	return stdout.String(), nil
+	return stdout, nil
 }
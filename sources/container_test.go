@@ -0,0 +1,347 @@
+package sources
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestContainerFetcherCapturesStderr(t *testing.T) {
+	clientset := fake.NewClientset(&corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "app-0", Namespace: "apps"},
+		Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "app"}}},
+		Status:     corev1.PodStatus{Phase: corev1.PodRunning},
+	})
+
+	var logged bytes.Buffer
+	fetcher := &ContainerFetcher{
+		exec: func(_ context.Context, clientset kubernetes.Interface, namespace, podName, containerName string, command []string) (string, string, error) {
+			return "HOST=localhost\nPORT=8080\n", "warning: profile not found\nHOST=from-stderr\n", nil
+		},
+		stderr: &logged,
+	}
+
+	source := Source{Type: "Container", Kind: "Pod", Name: "app-0", Namespace: "apps", CaptureStderr: true}
+	entries, err := fetcher.Fetch(context.Background(), clientset, source, t.TempDir())
+	if err != nil {
+		t.Fatalf("Fetch returned error: %v", err)
+	}
+
+	// Only stdout is parsed
+	if len(entries) != 2 || entries[0].Key != "HOST" || entries[0].Value != "localhost" || entries[1].Key != "PORT" {
+		t.Errorf("expected HOST and PORT from stdout only, got %+v", entries)
+	}
+
+	expected := "Container apps/app-0 (app) stderr:\n  warning: profile not found\n  HOST=from-stderr\n"
+	if logged.String() != expected {
+		t.Errorf("expected stderr to be logged as %q, got %q", expected, logged.String())
+	}
+
+	// Without captureStderr nothing is logged
+	logged.Reset()
+	source.CaptureStderr = false
+	if _, err := fetcher.Fetch(context.Background(), clientset, source, t.TempDir()); err != nil {
+		t.Fatalf("Fetch returned error: %v", err)
+	}
+	if logged.Len() != 0 {
+		t.Errorf("expected nothing to be logged without captureStderr, got %q", logged.String())
+	}
+}
+
+func TestContainerFetcherIncludesStderrInError(t *testing.T) {
+	clientset := fake.NewClientset(&corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "app-0", Namespace: "apps"},
+		Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "app"}}},
+		Status:     corev1.PodStatus{Phase: corev1.PodRunning},
+	})
+
+	fetcher := &ContainerFetcher{
+		exec: func(_ context.Context, clientset kubernetes.Interface, namespace, podName, containerName string, command []string) (string, string, error) {
+			return "", "env: not found\n", errors.New("command terminated with exit code 127")
+		},
+		stderr: &bytes.Buffer{},
+	}
+
+	_, err := fetcher.Fetch(context.Background(), clientset, Source{Type: "Container", Kind: "Pod", Name: "app-0", Namespace: "apps"}, t.TempDir())
+	if err == nil || !strings.Contains(err.Error(), "env: not found") {
+		t.Errorf("expected the error to include stderr, got %v", err)
+	}
+}
+
+func TestContainerFetcherStaticMethodResolvesFieldRefs(t *testing.T) {
+	clientset := fake.NewClientset(
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "app-0", Namespace: "apps", Labels: map[string]string{"app": "web"}},
+			Spec: corev1.PodSpec{
+				NodeName: "node-1",
+				Containers: []corev1.Container{{
+					Name: "app",
+					Env: []corev1.EnvVar{
+						{Name: "LOG_LEVEL", Value: "debug"},
+						{Name: "POD_IP", ValueFrom: &corev1.EnvVarSource{FieldRef: &corev1.ObjectFieldSelector{FieldPath: "status.podIP"}}},
+						{Name: "POD_NAME", ValueFrom: &corev1.EnvVarSource{FieldRef: &corev1.ObjectFieldSelector{FieldPath: "metadata.name"}}},
+						{Name: "APP", ValueFrom: &corev1.EnvVarSource{FieldRef: &corev1.ObjectFieldSelector{FieldPath: "metadata.labels['app']"}}},
+						{Name: "NODE", ValueFrom: &corev1.EnvVarSource{FieldRef: &corev1.ObjectFieldSelector{FieldPath: "spec.nodeName"}}},
+						{Name: "DB_HOST", ValueFrom: &corev1.EnvVarSource{ConfigMapKeyRef: &corev1.ConfigMapKeySelector{
+							LocalObjectReference: corev1.LocalObjectReference{Name: "app-config"},
+							Key:                  "db-host",
+						}}},
+					},
+				}},
+			},
+			Status: corev1.PodStatus{Phase: corev1.PodRunning, PodIP: "10.0.0.12"},
+		},
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "app-config", Namespace: "apps"},
+			Data:       map[string]string{"db-host": "db.apps"},
+		},
+	)
+
+	fetcher := &ContainerFetcher{
+		exec: func(_ context.Context, clientset kubernetes.Interface, namespace, podName, containerName string, command []string) (string, string, error) {
+			t.Errorf("expected no exec with method static, got %v", command)
+			return "", "", nil
+		},
+	}
+
+	source := Source{Type: "Container", Kind: "Pod", Name: "app-0", Namespace: "apps", Method: ContainerMethodStatic}
+	entries, err := fetcher.Fetch(context.Background(), clientset, source, t.TempDir())
+	if err != nil {
+		t.Fatalf("Fetch returned error: %v", err)
+	}
+
+	expected := map[string]string{
+		"LOG_LEVEL": "debug",
+		"POD_IP":    "10.0.0.12",
+		"POD_NAME":  "app-0",
+		"APP":       "web",
+		"NODE":      "node-1",
+		"DB_HOST":   "db.apps",
+	}
+	if len(entries) != len(expected) {
+		t.Fatalf("expected %d entries, got %+v", len(expected), entries)
+	}
+	for _, entry := range entries {
+		if expected[entry.Key] != entry.Value {
+			t.Errorf("%s: expected %q, got %q", entry.Key, expected[entry.Key], entry.Value)
+		}
+		if entry.SourceType != "Container" || entry.Name != "app-0/app" {
+			t.Errorf("%s: expected source Container app-0/app, got %s %s", entry.Key, entry.SourceType, entry.Name)
+		}
+	}
+
+	// File extraction needs exec and is rejected
+	source.Files = []ContainerFileExtract{{Container: "app", Path: "/etc/app.yaml", Output: "app.yaml", Key: "APP_CONFIG"}}
+	if _, err := fetcher.Fetch(context.Background(), clientset, source, t.TempDir()); err == nil {
+		t.Error("expected an error for files with method static")
+	}
+}
+
+func TestContainerFetcherIncludesEphemeralContainers(t *testing.T) {
+	clientset := fake.NewClientset(&corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "app-0", Namespace: "apps"},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{Name: "app", Env: []corev1.EnvVar{{Name: "HOST", Value: "localhost"}}}},
+			EphemeralContainers: []corev1.EphemeralContainer{
+				{EphemeralContainerCommon: corev1.EphemeralContainerCommon{Name: "debugger", Env: []corev1.EnvVar{{Name: "DEBUG_TOKEN", Value: "abc123"}}}},
+				{EphemeralContainerCommon: corev1.EphemeralContainerCommon{Name: "profiler", Env: []corev1.EnvVar{{Name: "PROFILE", Value: "cpu"}}}},
+			},
+		},
+		Status: corev1.PodStatus{Phase: corev1.PodRunning},
+	})
+
+	fetcher := &ContainerFetcher{
+		exec: func(_ context.Context, clientset kubernetes.Interface, namespace, podName, containerName string, command []string) (string, string, error) {
+			return "FROM_EXEC=" + containerName + "\n", "", nil
+		},
+	}
+
+	describe := func(entries []EnvEntry) string {
+		var described []string
+		for _, entry := range entries {
+			described = append(described, entry.Name+":"+entry.Key+"="+entry.Value)
+		}
+		return strings.Join(described, ",")
+	}
+
+	tests := []struct {
+		name     string
+		source   Source
+		expected string
+	}{
+		{
+			name:     "not included by default",
+			source:   Source{Method: ContainerMethodStatic},
+			expected: "app-0/app:HOST=localhost",
+		},
+		{
+			name:     "static",
+			source:   Source{Method: ContainerMethodStatic, IncludeEphemeralContainers: true},
+			expected: "app-0/app:HOST=localhost,app-0/debugger (ephemeral):DEBUG_TOKEN=abc123,app-0/profiler (ephemeral):PROFILE=cpu",
+		},
+		{
+			name:     "container filter",
+			source:   Source{Method: ContainerMethodStatic, IncludeEphemeralContainers: true, Containers: []string{"debugger"}},
+			expected: "app-0/debugger (ephemeral):DEBUG_TOKEN=abc123",
+		},
+		{
+			name:     "exec",
+			source:   Source{IncludeEphemeralContainers: true, Containers: []string{"app", "debugger"}},
+			expected: "app-0/app:FROM_EXEC=app,app-0/debugger (ephemeral):FROM_EXEC=debugger",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source := tt.source
+			source.Type, source.Kind, source.Name, source.Namespace = "Container", "Pod", "app-0", "apps"
+
+			entries, err := fetcher.Fetch(context.Background(), clientset, source, t.TempDir())
+			if err != nil {
+				t.Fatalf("Fetch returned error: %v", err)
+			}
+			if got := describe(entries); got != tt.expected {
+				t.Errorf("expected %s, got %s", tt.expected, got)
+			}
+		})
+	}
+
+	// Workload templates have no ephemeral containers, only live pods do
+	processor := &WorkloadProcessor{}
+	pod, err := clientset.CoreV1().Pods("apps").Get(context.Background(), "app-0", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries, err := processor.ProcessPodSpec(context.Background(), clientset, pod.Spec, Source{IncludeEphemeralContainers: true}, "app", "Deployment", "apps", t.TempDir())
+	if err != nil {
+		t.Fatalf("ProcessPodSpec returned error: %v", err)
+	}
+	if got := describe(entries); got != "app/app:HOST=localhost" {
+		t.Errorf("expected only the regular container from a pod template, got %s", got)
+	}
+}
+
+func TestContainerFetcherSelectsStatefulSetReplica(t *testing.T) {
+	replicas := int32(2)
+	labels := map[string]string{"app": "web"}
+	replica := func(name string, phase corev1.PodPhase) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "apps", Labels: labels},
+			Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "web"}}},
+			Status:     corev1.PodStatus{Phase: phase},
+		}
+	}
+	clientset := fake.NewClientset(
+		&appsv1.StatefulSet{
+			ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "apps"},
+			Spec:       appsv1.StatefulSetSpec{Replicas: &replicas, Selector: &metav1.LabelSelector{MatchLabels: labels}},
+		},
+		replica("web-0", corev1.PodRunning),
+		replica("web-1", corev1.PodRunning),
+	)
+
+	fetcher := &ContainerFetcher{
+		exec: func(_ context.Context, clientset kubernetes.Interface, namespace, podName, containerName string, command []string) (string, string, error) {
+			return "POD_NAME=" + podName + "\n", "", nil
+		},
+	}
+	fetch := func(pod string) ([]EnvEntry, error) {
+		source := Source{Type: "Container", Kind: "StatefulSet", Name: "web", Namespace: "apps", Pod: pod}
+		return fetcher.Fetch(context.Background(), clientset, source, t.TempDir())
+	}
+
+	for pod, expected := range map[string]string{"1": "web-1", "web-1": "web-1", "0": "web-0", "web-0": "web-0"} {
+		entries, err := fetch(pod)
+		if err != nil {
+			t.Fatalf("%s: Fetch returned error: %v", pod, err)
+		}
+		if len(entries) != 1 || entries[0].Value != expected {
+			t.Errorf("%s: expected POD_NAME=%s, got %+v", pod, expected, entries)
+		}
+	}
+
+	// A replica that is not running is not replaced by another one
+	if _, err := clientset.CoreV1().Pods("apps").UpdateStatus(context.Background(), replica("web-1", corev1.PodPending), metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	errorTests := map[string]string{
+		"1":     "pod apps/web-1 is not running (phase: Pending)",
+		"2":     "StatefulSet apps/web has no ordinal 2 (it has 2 replicas starting at 0)",
+		"web-5": "StatefulSet apps/web has no ordinal 5",
+		"db-0":  `pod "db-0" is not an ordinal or a pod name of StatefulSet apps/web`,
+	}
+	for pod, expected := range errorTests {
+		if _, err := fetch(pod); err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("%s: expected an error containing %q, got %v", pod, expected, err)
+		}
+	}
+
+	// pod only selects StatefulSet replicas
+	source := Source{Type: "Container", Kind: "Deployment", Name: "web", Namespace: "apps", Pod: "0"}
+	if _, err := fetcher.Fetch(context.Background(), clientset, source, t.TempDir()); err == nil || !strings.Contains(err.Error(), "only supported for kind StatefulSet") {
+		t.Errorf("expected pod to be rejected for a Deployment, got %v", err)
+	}
+}
+
+func TestContainerFetcherLimitsConcurrentExecs(t *testing.T) {
+	SetExecConcurrency(2)
+	defer SetExecConcurrency(0)
+
+	var containers []corev1.Container
+	for _, name := range []string{"app", "sidecar", "proxy"} {
+		containers = append(containers, corev1.Container{Name: name})
+	}
+	clientset := fake.NewClientset(&corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "app-0", Namespace: "apps"},
+		Spec:       corev1.PodSpec{Containers: containers},
+		Status:     corev1.PodStatus{Phase: corev1.PodRunning},
+	})
+
+	var mu sync.Mutex
+	running, maxRunning := 0, 0
+	fetcher := &ContainerFetcher{
+		exec: func(_ context.Context, clientset kubernetes.Interface, namespace, podName, containerName string, command []string) (string, string, error) {
+			mu.Lock()
+			running++
+			maxRunning = max(maxRunning, running)
+			mu.Unlock()
+
+			time.Sleep(10 * time.Millisecond)
+
+			mu.Lock()
+			running--
+			mu.Unlock()
+			return "HOST=localhost\n", "", nil
+		},
+	}
+
+	// Several sources fetched at once, each exec'ing into three containers
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			source := Source{Type: "Container", Kind: "Pod", Name: "app-0", Namespace: "apps"}
+			if _, err := fetcher.Fetch(context.Background(), clientset, source, t.TempDir()); err != nil {
+				t.Errorf("Fetch returned error: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if maxRunning > 2 {
+		t.Errorf("expected at most 2 concurrent execs, got %d", maxRunning)
+	}
+	if maxRunning < 2 {
+		t.Errorf("expected execs to run concurrently up to the limit, got at most %d", maxRunning)
+	}
+}
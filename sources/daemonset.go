@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 
+	appsv1 "k8s.io/api/apps/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 )
@@ -12,15 +13,20 @@ type DaemonSetFetcher struct {
 	processor WorkloadProcessor
 }
 
-func (f *DaemonSetFetcher) Fetch(clientset *kubernetes.Clientset, source Source, outputDirectory string) ([]EnvEntry, error) {
+func (f *DaemonSetFetcher) Fetch(ctx context.Context, clientset kubernetes.Interface, source Source, outputDirectory string) ([]EnvEntry, error) {
 	namespace := source.GetNamespace()
-	daemonSet, err := clientset.AppsV1().DaemonSets(namespace).Get(context.Background(), source.Name, metav1.GetOptions{})
+	daemonSet, err := withRetry(ctx, func() (*appsv1.DaemonSet, error) {
+		return clientset.AppsV1().DaemonSets(namespace).Get(ctx, source.Name, metav1.GetOptions{})
+	})
 	if err != nil {
+		if source.skipMissing("daemonset", namespace, err) {
+			return nil, nil
+		}
 		return nil, fmt.Errorf("failed to get daemonset %s/%s: %w", namespace, source.Name, err)
 	}
 
 	return f.processor.ProcessPodSpec(
-		clientset,
+		ctx, clientset,
 		daemonSet.Spec.Template.Spec,
 		source,
 		source.Name,
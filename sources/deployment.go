@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 
+	appsv1 "k8s.io/api/apps/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 )
@@ -12,15 +13,20 @@ type DeploymentFetcher struct {
 	processor WorkloadProcessor
 }
 
-func (f *DeploymentFetcher) Fetch(clientset *kubernetes.Clientset, source Source, outputDirectory string) ([]EnvEntry, error) {
+func (f *DeploymentFetcher) Fetch(ctx context.Context, clientset kubernetes.Interface, source Source, outputDirectory string) ([]EnvEntry, error) {
 	namespace := source.GetNamespace()
-	deployment, err := clientset.AppsV1().Deployments(namespace).Get(context.Background(), source.Name, metav1.GetOptions{})
+	deployment, err := withRetry(ctx, func() (*appsv1.Deployment, error) {
+		return clientset.AppsV1().Deployments(namespace).Get(ctx, source.Name, metav1.GetOptions{})
+	})
 	if err != nil {
+		if source.skipMissing("deployment", namespace, err) {
+			return nil, nil
+		}
 		return nil, fmt.Errorf("failed to get deployment %s/%s: %w", namespace, source.Name, err)
 	}
 
 	return f.processor.ProcessPodSpec(
-		clientset,
+		ctx, clientset,
 		deployment.Spec.Template.Spec,
 		source,
 		source.Name,
@@ -1,9 +1,11 @@
 package sources
 
 import (
-	"bufio"
+	"context"
 	"fmt"
 	"os"
+	"path/filepath"
+	"sort"
 	"strings"
 
 	"enver/transformations"
@@ -13,16 +15,24 @@ import (
 
 type EnvFileFetcher struct{}
 
-func (f *EnvFileFetcher) Fetch(clientset *kubernetes.Clientset, source Source, outputDirectory string) ([]EnvEntry, error) {
+func (f *EnvFileFetcher) Fetch(ctx context.Context, clientset kubernetes.Interface, source Source, outputDirectory string) ([]EnvEntry, error) {
 	if source.Path == "" {
 		return nil, fmt.Errorf("path is required for EnvFile source %q", source.Name)
 	}
 
-	file, err := os.Open(source.Path)
-	if err != nil {
-		return nil, fmt.Errorf("failed to open env file %s: %w", source.Path, err)
+	// A path with wildcards reads every matching file in sorted order
+	paths := []string{source.Path}
+	if strings.ContainsAny(source.Path, "*?[") {
+		matches, err := filepath.Glob(source.Path)
+		if err != nil {
+			return nil, fmt.Errorf("invalid env file pattern %s: %w", source.Path, err)
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("no env files match %s", source.Path)
+		}
+		sort.Strings(matches)
+		paths = matches
 	}
-	defer file.Close()
 
 	// Convert transformation configs
 	var transformConfigs []transformations.Config
@@ -35,30 +45,45 @@ func (f *EnvFileFetcher) Fetch(clientset *kubernetes.Clientset, source Source, o
 			Output:        tc.Output,
 			Key:           tc.Key,
 			BaseDirectory: outputDirectory,
+			Mode:          fileMode(ctx, false),
 		})
 	}
 
 	var entries []EnvEntry
-	scanner := bufio.NewScanner(file)
+	for _, path := range paths {
+		fileEntries, err := f.fetchFile(path, source, transformConfigs)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, fileEntries...)
+	}
+	return entries, nil
+}
 
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
+// fetchFile reads the variables of a single env file
+func (f *EnvFileFetcher) fetchFile(path string, source Source, transformConfigs []transformations.Config) ([]EnvEntry, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open env file %s: %w", path, err)
+	}
 
-		// Skip empty lines and comments
-		if line == "" || strings.HasPrefix(line, "#") {
-			continue
-		}
+	vars, err := parseEnvFile(string(content), !source.KeepInlineComments)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read env file %s: %w", path, err)
+	}
 
-		// Parse key=value
-		parts := strings.SplitN(line, "=", 2)
-		if len(parts) != 2 {
-			continue
-		}
+	// Values read so far, for expansion
+	expanded := make(map[string]string)
 
-		key := strings.TrimSpace(parts[0])
-		value := strings.TrimSpace(parts[1])
+	var entries []EnvEntry
+	for _, v := range vars {
+		key, value := v.key, v.value
+		if source.Expand {
+			value = expandEnvValue(value, expanded)
+			expanded[key] = value
+		}
 
-		if key != "" && !source.ShouldExcludeVariable(key) {
+		if key != "" && !source.ShouldExcludeEntry(key, value) {
 			// Apply transformations
 			transformedKey, transformedValue, err := transformations.ApplyTransformations(key, value, transformConfigs)
 			if err != nil {
@@ -69,15 +94,167 @@ func (f *EnvFileFetcher) Fetch(clientset *kubernetes.Clientset, source Source, o
 				Key:        transformedKey,
 				Value:      transformedValue,
 				SourceType: "EnvFile",
-				Name:       source.Path,
+				Name:       path,
 				Namespace:  "",
+				Comment:    v.comment,
 			})
 		}
 	}
 
-	if err := scanner.Err(); err != nil {
-		return nil, fmt.Errorf("failed to read env file %s: %w", source.Path, err)
+	return entries, nil
+}
+
+// envFileVar is a single KEY=value assignment read from an env file
+type envFileVar struct {
+	key     string
+	value   string
+	comment string // the last comment line above the variable
+}
+
+// envFileEscapes are the escape sequences supported inside double-quoted values
+var envFileEscapes = map[byte]string{
+	'n':  "\n",
+	'r':  "\r",
+	't':  "\t",
+	'"':  `"`,
+	'\\': `\`,
+	'$':  "$",
+	'`':  "`",
+}
+
+// parseEnvFile parses dotenv content. Blank lines and lines starting with # are skipped and an
+// optional export prefix is ignored. Unquoted values are trimmed and, with stripComments, end at a #
+// that starts the value or follows whitespace. Single-quoted
+// values are taken literally, double-quoted values support \n, \r, \t, \" and \\ escapes; both
+// may span multiple lines.
+func parseEnvFile(content string, stripComments bool) ([]envFileVar, error) {
+	var vars []envFileVar
+	var comment string
+	lines := strings.Split(strings.ReplaceAll(content, "\r\n", "\n"), "\n")
+
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
+
+		// Skip empty lines and comments, remembering the comment for the variables below it
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "#") {
+			comment = strings.TrimSpace(strings.TrimPrefix(line, "#"))
+			continue
+		}
+
+		// Parse key=value
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		key = strings.TrimSpace(strings.TrimPrefix(key, "export "))
+		value = strings.TrimSpace(value)
+
+		if value == "" || (value[0] != '"' && value[0] != '\'') {
+			if stripComments {
+				value = stripInlineComment(value)
+			}
+			vars = append(vars, envFileVar{key: key, value: value, comment: comment})
+			continue
+		}
+
+		// Quoted value, continuing on the next lines until the closing quote. The untrimmed line is
+		// used so whitespace inside the quotes is kept.
+		_, rest, _ := strings.Cut(lines[i], "=")
+		rest = strings.TrimLeft(rest, " \t")[1:]
+		startLine := i
+		quote := value[0]
+		var sb strings.Builder
+		for {
+			closed := false
+			for j := 0; j < len(rest); j++ {
+				c := rest[j]
+				if c == quote {
+					closed = true
+					break
+				}
+				if quote == '"' && c == '\\' && j+1 < len(rest) {
+					if escaped, ok := envFileEscapes[rest[j+1]]; ok {
+						sb.WriteString(escaped)
+						j++
+						continue
+					}
+				}
+				sb.WriteByte(c)
+			}
+			if closed {
+				break
+			}
+			i++
+			if i >= len(lines) {
+				return nil, fmt.Errorf("unterminated quoted value for %s starting on line %d", key, startLine+1)
+			}
+			sb.WriteByte('\n')
+			rest = lines[i]
+		}
+		vars = append(vars, envFileVar{key: key, value: sb.String(), comment: comment})
+	}
+
+	return vars, nil
+}
+
+// stripInlineComment removes a trailing comment from an unquoted value. A # only starts a comment at
+// the beginning of the value or after whitespace, so values like a#b are kept.
+func stripInlineComment(value string) string {
+	for i := 0; i < len(value); i++ {
+		if value[i] == '#' && (i == 0 || value[i-1] == ' ' || value[i-1] == '\t') {
+			return strings.TrimSpace(value[:i])
+		}
 	}
+	return value
+}
 
-	return entries, nil
+// expandEnvValue replaces ${VAR} and $VAR with the values of variables read earlier in the file.
+// References to undefined variables are kept as-is and $$ is a literal $.
+func expandEnvValue(value string, vars map[string]string) string {
+	var sb strings.Builder
+	for i := 0; i < len(value); i++ {
+		if value[i] != '$' || i+1 == len(value) {
+			sb.WriteByte(value[i])
+			continue
+		}
+
+		switch next := value[i+1]; {
+		case next == '$':
+			sb.WriteByte('$')
+			i++
+		case next == '{':
+			end := strings.IndexByte(value[i+2:], '}')
+			if end == -1 {
+				sb.WriteByte('$')
+				continue
+			}
+			reference := value[i : i+2+end+1]
+			if v, ok := vars[value[i+2:i+2+end]]; ok {
+				sb.WriteString(v)
+			} else {
+				sb.WriteString(reference)
+			}
+			i += len(reference) - 1
+		default:
+			end := i + 1
+			for end < len(value) && isEnvNameChar(value[end], end == i+1) {
+				end++
+			}
+			if v, ok := vars[value[i+1:end]]; ok && end > i+1 {
+				sb.WriteString(v)
+			} else {
+				sb.WriteString(value[i:end])
+			}
+			i = end - 1
+		}
+	}
+	return sb.String()
+}
+
+// isEnvNameChar returns true if c can appear in a variable name; names can't start with a digit
+func isEnvNameChar(c byte, first bool) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || !first && c >= '0' && c <= '9'
 }
@@ -0,0 +1,183 @@
+package sources
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseEnvFile(t *testing.T) {
+	content := `# comment
+PLAIN=value
+  SPACED = trimmed  
+export EXPORTED=yes
+HASH_IN_DOUBLE="color #fff and more"
+HASH_IN_SINGLE='  keep  # spaces  '
+INLINE_COMMENT=value # comment
+NO_SPACE_HASH=a#b
+ESCAPES="line1\nline2 \"quoted\" back\\slash \$HOME"
+LITERAL='no \n escapes'
+MULTILINE="first line
+  second line  
+last"
+EMPTY=
+EMPTY_QUOTED=""
+TRAILING="value" # comment
+not a variable
+`
+	vars, err := parseEnvFile(content, true)
+	if err != nil {
+		t.Fatalf("parseEnvFile returned error: %v", err)
+	}
+
+	expected := []envFileVar{
+		{key: "PLAIN", value: "value"},
+		{key: "SPACED", value: "trimmed"},
+		{key: "EXPORTED", value: "yes"},
+		{key: "HASH_IN_DOUBLE", value: "color #fff and more"},
+		{key: "HASH_IN_SINGLE", value: "  keep  # spaces  "},
+		{key: "INLINE_COMMENT", value: "value"},
+		{key: "NO_SPACE_HASH", value: "a#b"},
+		{key: "ESCAPES", value: "line1\nline2 \"quoted\" back\\slash $HOME"},
+		{key: "LITERAL", value: `no \n escapes`},
+		{key: "MULTILINE", value: "first line\n  second line  \nlast"},
+		{key: "EMPTY", value: ""},
+		{key: "EMPTY_QUOTED", value: ""},
+		{key: "TRAILING", value: "value"},
+	}
+	if len(vars) != len(expected) {
+		t.Fatalf("expected %d variables, got %d: %+v", len(expected), len(vars), vars)
+	}
+	for i, v := range vars {
+		if v.key != expected[i].key || v.value != expected[i].value {
+			t.Errorf("expected %+v, got %+v", expected[i], v)
+		}
+	}
+}
+
+func TestParseEnvFileInlineComments(t *testing.T) {
+	content := "PORT=8080 # default\nQUOTED=\"a#b\"\nTAB=value\t# comment\nONLY_COMMENT= # nothing\nNO_SPACE=a#b\n"
+
+	tests := []struct {
+		stripComments bool
+		expected      []envFileVar
+	}{
+		{true, []envFileVar{{key: "PORT", value: "8080"}, {key: "QUOTED", value: "a#b"}, {key: "TAB", value: "value"}, {key: "ONLY_COMMENT", value: ""}, {key: "NO_SPACE", value: "a#b"}}},
+		{false, []envFileVar{{key: "PORT", value: "8080 # default"}, {key: "QUOTED", value: "a#b"}, {key: "TAB", value: "value\t# comment"}, {key: "ONLY_COMMENT", value: "# nothing"}, {key: "NO_SPACE", value: "a#b"}}},
+	}
+	for _, tc := range tests {
+		vars, err := parseEnvFile(content, tc.stripComments)
+		if err != nil {
+			t.Fatalf("parseEnvFile returned error: %v", err)
+		}
+		if !slices.Equal(vars, tc.expected) {
+			t.Errorf("stripComments=%v: expected %+v, got %+v", tc.stripComments, tc.expected, vars)
+		}
+	}
+}
+
+func TestParseEnvFileUnterminatedQuote(t *testing.T) {
+	_, err := parseEnvFile("FIRST=ok\nBROKEN=\"never closed\nNEXT=value\n", true)
+	if err == nil || !strings.Contains(err.Error(), "BROKEN starting on line 2") {
+		t.Errorf("expected an unterminated quote error for BROKEN, got %v", err)
+	}
+}
+
+func TestEnvFileFetcherReadsQuotedValues(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "local.env")
+	if err := os.WriteFile(path, []byte("GREETING=\"hello  world # not a comment\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := (&EnvFileFetcher{}).Fetch(context.Background(), nil, Source{Type: "EnvFile", Path: path}, t.TempDir())
+	if err != nil {
+		t.Fatalf("Fetch returned error: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Value != "hello  world # not a comment" {
+		t.Errorf("expected the quoted value to be kept as-is, got %+v", entries)
+	}
+}
+
+func TestEnvFileFetcherExpandsEarlierVariables(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "local.env")
+	content := `BASE=/opt
+APP=${BASE}/app
+BIN=$APP/bin
+PRICE=$$5
+MISSING=${UNDEFINED}/x and $ALSO_UNDEFINED
+LATER=$DEFINED_BELOW
+DEFINED_BELOW=value
+`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	fetch := func(expand bool) map[string]string {
+		entries, err := (&EnvFileFetcher{}).Fetch(context.Background(), nil, Source{Type: "EnvFile", Path: path, Expand: expand}, t.TempDir())
+		if err != nil {
+			t.Fatalf("Fetch returned error: %v", err)
+		}
+		values := make(map[string]string)
+		for _, entry := range entries {
+			values[entry.Key] = entry.Value
+		}
+		return values
+	}
+
+	expected := map[string]string{
+		"APP":     "/opt/app",
+		"BIN":     "/opt/app/bin",
+		"PRICE":   "$5",
+		"MISSING": "${UNDEFINED}/x and $ALSO_UNDEFINED",
+		"LATER":   "$DEFINED_BELOW",
+	}
+	values := fetch(true)
+	for key, value := range expected {
+		if values[key] != value {
+			t.Errorf("%s: expected %q, got %q", key, value, values[key])
+		}
+	}
+
+	// Without expand the values are kept as written
+	if values := fetch(false); values["BIN"] != "$APP/bin" || values["PRICE"] != "$$5" {
+		t.Errorf("expected no expansion by default, got %v", values)
+	}
+}
+
+func TestEnvFileFetcherReadsGlobMatches(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "b.env"), []byte("SECOND=2\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "a.env"), []byte("FIRST=1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("IGNORED=1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	source := Source{Type: "EnvFile", Path: filepath.Join(dir, "*.env")}
+	entries, err := (&EnvFileFetcher{}).Fetch(context.Background(), nil, source, t.TempDir())
+	if err != nil {
+		t.Fatalf("Fetch returned error: %v", err)
+	}
+
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 entries, got %+v", entries)
+	}
+	if entries[0].Key != "FIRST" || entries[0].Name != filepath.Join(dir, "a.env") {
+		t.Errorf("expected FIRST from a.env first, got %+v", entries[0])
+	}
+	if entries[1].Key != "SECOND" || entries[1].Name != filepath.Join(dir, "b.env") {
+		t.Errorf("expected SECOND from b.env second, got %+v", entries[1])
+	}
+
+	source.Path = filepath.Join(dir, "*.missing")
+	_, err = (&EnvFileFetcher{}).Fetch(context.Background(), nil, source, t.TempDir())
+	if err == nil || !strings.Contains(err.Error(), "no env files match") {
+		t.Errorf("expected a no match error, got %v", err)
+	}
+}
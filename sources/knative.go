@@ -0,0 +1,77 @@
+package sources
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+)
+
+var (
+	knativeServiceResource  = schema.GroupVersionResource{Group: "serving.knative.dev", Version: "v1", Resource: "services"}
+	knativeRevisionResource = schema.GroupVersionResource{Group: "serving.knative.dev", Version: "v1", Resource: "revisions"}
+)
+
+// KnativeServiceFetcher extracts environment variables from the pod template of a Knative Service's latest ready revision
+type KnativeServiceFetcher struct {
+	dynamicClient dynamic.Interface
+	processor     WorkloadProcessor
+}
+
+func NewKnativeServiceFetcher(dynamicClient dynamic.Interface) *KnativeServiceFetcher {
+	return &KnativeServiceFetcher{dynamicClient: dynamicClient}
+}
+
+func (f *KnativeServiceFetcher) Fetch(ctx context.Context, clientset kubernetes.Interface, source Source, outputDirectory string) ([]EnvEntry, error) {
+	namespace := source.GetNamespace()
+	service, err := withRetry(ctx, func() (*unstructured.Unstructured, error) {
+		return f.dynamicClient.Resource(knativeServiceResource).Namespace(namespace).Get(ctx, source.Name, metav1.GetOptions{})
+	})
+	if err != nil {
+		if source.skipMissing("knative service", namespace, err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get knative service %s/%s: %w", namespace, source.Name, err)
+	}
+
+	revisionName, _, err := unstructured.NestedString(service.Object, "status", "latestReadyRevisionName")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read latest ready revision of knative service %s/%s: %w", namespace, source.Name, err)
+	}
+	if revisionName == "" {
+		return nil, fmt.Errorf("knative service %s/%s has no ready revision", namespace, source.Name)
+	}
+
+	revision, err := withRetry(ctx, func() (*unstructured.Unstructured, error) {
+		return f.dynamicClient.Resource(knativeRevisionResource).Namespace(namespace).Get(ctx, revisionName, metav1.GetOptions{})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get knative revision %s/%s: %w", namespace, revisionName, err)
+	}
+
+	// A revision spec is a PodSpec with a few Knative specific fields, which are ignored here
+	revisionSpec, _, err := unstructured.NestedMap(revision.Object, "spec")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read spec of knative revision %s/%s: %w", namespace, revisionName, err)
+	}
+	var podSpec corev1.PodSpec
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(revisionSpec, &podSpec); err != nil {
+		return nil, fmt.Errorf("failed to convert spec of knative revision %s/%s: %w", namespace, revisionName, err)
+	}
+
+	return f.processor.ProcessPodSpec(
+		ctx, clientset,
+		podSpec,
+		source,
+		source.Name,
+		"KnativeService",
+		namespace,
+		outputDirectory,
+	)
+}
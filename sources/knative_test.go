@@ -0,0 +1,91 @@
+package sources
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestKnativeServiceFetcherUsesLatestReadyRevision(t *testing.T) {
+	service := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "serving.knative.dev/v1",
+		"kind":       "Service",
+		"metadata":   map[string]interface{}{"name": "hello", "namespace": "apps"},
+		"status": map[string]interface{}{
+			"latestReadyRevisionName":   "hello-00002",
+			"latestCreatedRevisionName": "hello-00003",
+		},
+	}}
+	readyRevision := knativeRevision("hello-00002", []interface{}{
+		map[string]interface{}{"name": "GREETING", "value": "hello"},
+		map[string]interface{}{
+			"name": "TARGET",
+			"valueFrom": map[string]interface{}{
+				"configMapKeyRef": map[string]interface{}{"name": "hello-config", "key": "target"},
+			},
+		},
+	})
+	// The newest revision is not ready yet and must be ignored
+	pendingRevision := knativeRevision("hello-00003", []interface{}{
+		map[string]interface{}{"name": "GREETING", "value": "not ready"},
+	})
+
+	dynamicClient := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), service, readyRevision, pendingRevision)
+	clientset := fake.NewClientset(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "hello-config", Namespace: "apps"},
+		Data:       map[string]string{"target": "world"},
+	})
+
+	fetcher := NewKnativeServiceFetcher(dynamicClient)
+	entries, err := fetcher.Fetch(context.Background(), clientset, Source{Type: "KnativeService", Name: "hello", Namespace: "apps"}, t.TempDir())
+	if err != nil {
+		t.Fatalf("Fetch returned error: %v", err)
+	}
+
+	expected := map[string]string{"GREETING": "hello", "TARGET": "world"}
+	if len(entries) != len(expected) {
+		t.Fatalf("expected %d entries, got %d: %+v", len(expected), len(entries), entries)
+	}
+	for _, entry := range entries {
+		if want, ok := expected[entry.Key]; !ok || entry.Value != want {
+			t.Errorf("unexpected entry %s=%s", entry.Key, entry.Value)
+		}
+		if entry.SourceType != "KnativeService" || entry.Namespace != "apps" {
+			t.Errorf("unexpected source metadata for %s: %+v", entry.Key, entry)
+		}
+	}
+}
+
+func TestKnativeServiceFetcherRequiresReadyRevision(t *testing.T) {
+	service := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "serving.knative.dev/v1",
+		"kind":       "Service",
+		"metadata":   map[string]interface{}{"name": "hello", "namespace": "default"},
+	}}
+	dynamicClient := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), service)
+
+	fetcher := NewKnativeServiceFetcher(dynamicClient)
+	if _, err := fetcher.Fetch(context.Background(), fake.NewClientset(), Source{Type: "KnativeService", Name: "hello"}, t.TempDir()); err == nil {
+		t.Fatal("expected an error for a service without a ready revision")
+	}
+}
+
+func knativeRevision(name string, env []interface{}) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "serving.knative.dev/v1",
+		"kind":       "Revision",
+		"metadata":   map[string]interface{}{"name": name, "namespace": "apps"},
+		"spec": map[string]interface{}{
+			"containerConcurrency": int64(0),
+			"containers": []interface{}{
+				map[string]interface{}{"name": "user-container", "image": "hello:latest", "env": env},
+			},
+		},
+	}}
+}
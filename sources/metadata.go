@@ -0,0 +1,159 @@
+package sources
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"enver/transformations"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// What the Metadata source reads from the object
+const (
+	MetadataAnnotations = "annotations" // the object's annotations (default)
+	MetadataLabels      = "labels"      // the object's labels
+	MetadataAll         = "all"         // labels and annotations, annotations win when a key is both
+)
+
+// MetadataKinds maps the kinds of objects the Metadata source can read to their resource names
+var MetadataKinds = map[string]string{
+	"Pod":         "pods",
+	"Deployment":  "deployments",
+	"StatefulSet": "statefulsets",
+	"DaemonSet":   "daemonsets",
+	"ConfigMap":   "configmaps",
+	"Secret":      "secrets",
+}
+
+// MetadataFetcher turns the annotations or labels of an object into environment variables
+type MetadataFetcher struct{}
+
+func (f *MetadataFetcher) Fetch(ctx context.Context, clientset kubernetes.Interface, source Source, outputDirectory string) ([]EnvEntry, error) {
+	namespace := source.GetNamespace()
+	if _, ok := MetadataKinds[source.Kind]; !ok {
+		return nil, fmt.Errorf("invalid kind %q for Metadata source %q (must be Pod, Deployment, StatefulSet, DaemonSet, ConfigMap or Secret)", source.Kind, source.Name)
+	}
+
+	meta, err := withRetry(ctx, func() (metav1.ObjectMeta, error) {
+		return getObjectMeta(ctx, clientset, source.Kind, namespace, source.Name)
+	})
+	if err != nil {
+		if source.skipMissing(strings.ToLower(source.Kind), namespace, err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get %s %s/%s: %w", strings.ToLower(source.Kind), namespace, source.Name, err)
+	}
+
+	var metadata map[string]string
+	switch source.Metadata {
+	case "", MetadataAnnotations:
+		metadata = meta.Annotations
+	case MetadataLabels:
+		metadata = meta.Labels
+	case MetadataAll:
+		metadata = maps.Clone(meta.Labels)
+		if metadata == nil {
+			metadata = make(map[string]string)
+		}
+		maps.Copy(metadata, meta.Annotations)
+	default:
+		return nil, fmt.Errorf("invalid metadata %q for Metadata source %q (must be annotations, labels or all)", source.Metadata, source.Name)
+	}
+
+	// Convert transformation configs
+	var transformConfigs []transformations.Config
+	for _, tc := range source.Transformations {
+		transformConfigs = append(transformConfigs, transformations.Config{
+			Type:          tc.Type,
+			Target:        tc.Target,
+			Value:         tc.Value,
+			Variables:     tc.Variables,
+			Output:        tc.Output,
+			Key:           tc.Key,
+			BaseDirectory: outputDirectory,
+			Mode:          fileMode(ctx, false),
+		})
+	}
+
+	var entries []EnvEntry
+	for _, key := range slices.Sorted(maps.Keys(metadata)) {
+		value := metadata[key]
+		// Filters match the annotation or label key, e.g. app.kubernetes.io/version
+		if !source.keepValue(ctx, key, value) {
+			continue
+		}
+
+		envKey, mapped := source.KeyMappings[key]
+		if !mapped {
+			envKey = source.KeyPrefix + MetadataKey(key)
+		}
+		transformedKey, transformedValue, err := transformations.ApplyTransformations(envKey, value, transformConfigs)
+		if err != nil {
+			return nil, fmt.Errorf("failed to apply transformation: %w", err)
+		}
+
+		entries = append(entries, EnvEntry{
+			Key:        transformedKey,
+			Value:      transformedValue,
+			SourceType: "Metadata",
+			Name:       fmt.Sprintf("%s/%s", source.Kind, source.Name),
+			Namespace:  namespace,
+		})
+	}
+
+	return entries, nil
+}
+
+// MetadataKey returns the variable name of an annotation or label key, e.g. APP_VERSION for app.version
+func MetadataKey(key string) string {
+	return (&transformations.SanitizeKey{}).Transform(strings.ToUpper(key))
+}
+
+// getObjectMeta returns the metadata of the named object of a kind in MetadataKinds
+func getObjectMeta(ctx context.Context, clientset kubernetes.Interface, kind, namespace, name string) (metav1.ObjectMeta, error) {
+	switch kind {
+	case "Pod":
+		object, err := clientset.CoreV1().Pods(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return metav1.ObjectMeta{}, err
+		}
+		return object.ObjectMeta, nil
+	case "Deployment":
+		object, err := clientset.AppsV1().Deployments(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return metav1.ObjectMeta{}, err
+		}
+		return object.ObjectMeta, nil
+	case "StatefulSet":
+		object, err := clientset.AppsV1().StatefulSets(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return metav1.ObjectMeta{}, err
+		}
+		return object.ObjectMeta, nil
+	case "DaemonSet":
+		object, err := clientset.AppsV1().DaemonSets(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return metav1.ObjectMeta{}, err
+		}
+		return object.ObjectMeta, nil
+	case "ConfigMap":
+		object, err := clientset.CoreV1().ConfigMaps(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return metav1.ObjectMeta{}, err
+		}
+		return object.ObjectMeta, nil
+	case "Secret":
+		object, err := clientset.CoreV1().Secrets(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return metav1.ObjectMeta{}, err
+		}
+		return object.ObjectMeta, nil
+	default:
+		return metav1.ObjectMeta{}, fmt.Errorf("unsupported kind %q", kind)
+	}
+}
@@ -0,0 +1,81 @@
+package sources
+
+import (
+	"context"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestMetadataFetcherExtractsSelectedAnnotations(t *testing.T) {
+	clientset := fake.NewClientset(&appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "my-app",
+			Namespace: "apps",
+			Labels:    map[string]string{"app": "web", "tier": "frontend"},
+			Annotations: map[string]string{
+				"app.version":                                      "1.4.2",
+				"app.kubernetes.io/commit":                         "3f2a9c1",
+				"deployment.kubernetes.io/revision":                "7",
+				"kubectl.kubernetes.io/last-applied-configuration": "{}",
+			},
+		},
+	})
+
+	tests := []struct {
+		name     string
+		source   Source
+		expected map[string]string
+	}{
+		{
+			name: "annotations",
+			source: Source{
+				KeyPrefix: "APP_",
+				Variables: SourceVariables{Include: []string{"app.version", "app.kubernetes.io/commit"}},
+			},
+			expected: map[string]string{"APP_APP_VERSION": "1.4.2", "APP_APP_KUBERNETES_IO_COMMIT": "3f2a9c1"},
+		},
+		{
+			name: "key mappings",
+			source: Source{
+				KeyPrefix:   "APP_",
+				Variables:   SourceVariables{Include: []string{"app.version"}},
+				KeyMappings: map[string]string{"app.version": "VERSION"},
+			},
+			expected: map[string]string{"VERSION": "1.4.2"},
+		},
+		{
+			name:     "labels",
+			source:   Source{Metadata: MetadataLabels, Variables: SourceVariables{Exclude: []string{"tier"}}},
+			expected: map[string]string{"APP": "web"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source := tt.source
+			source.Type, source.Kind, source.Name, source.Namespace = "Metadata", "Deployment", "my-app", "apps"
+
+			entries, err := (&MetadataFetcher{}).Fetch(context.Background(), clientset, source, t.TempDir())
+			if err != nil {
+				t.Fatalf("Fetch returned error: %v", err)
+			}
+			if len(entries) != len(tt.expected) {
+				t.Fatalf("expected %d entries, got %+v", len(tt.expected), entries)
+			}
+			for _, entry := range entries {
+				if value, ok := tt.expected[entry.Key]; !ok || value != entry.Value {
+					t.Errorf("unexpected entry %s=%s", entry.Key, entry.Value)
+				}
+				if entry.SourceType != "Metadata" || entry.Name != "Deployment/my-app" || entry.Namespace != "apps" {
+					t.Errorf("%s: expected source Metadata apps/Deployment/my-app, got %s %s/%s", entry.Key, entry.SourceType, entry.Namespace, entry.Name)
+				}
+			}
+		})
+	}
+
+	if _, err := (&MetadataFetcher{}).Fetch(context.Background(), clientset, Source{Type: "Metadata", Kind: "Job", Name: "my-app"}, t.TempDir()); err == nil {
+		t.Error("expected an error for an unsupported kind")
+	}
+}
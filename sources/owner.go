@@ -0,0 +1,33 @@
+package sources
+
+import (
+	"fmt"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// describeOwner summarizes what manages an object, based on its controlling owner reference,
+// the app.kubernetes.io/managed-by label and the Helm release annotation.
+// Returns an empty string if none of them are set.
+func describeOwner(meta metav1.ObjectMeta) string {
+	var parts []string
+
+	owner := metav1.GetControllerOfNoCopy(&meta)
+	if owner == nil && len(meta.OwnerReferences) > 0 {
+		owner = &meta.OwnerReferences[0]
+	}
+	if owner != nil {
+		parts = append(parts, fmt.Sprintf("owned by %s/%s", owner.Kind, owner.Name))
+	}
+
+	if managedBy := meta.Labels["app.kubernetes.io/managed-by"]; managedBy != "" {
+		parts = append(parts, "managed by "+managedBy)
+	}
+
+	if release := meta.Annotations["meta.helm.sh/release-name"]; release != "" {
+		parts = append(parts, "release "+release)
+	}
+
+	return strings.Join(parts, ", ")
+}
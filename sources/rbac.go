@@ -0,0 +1,201 @@
+package sources
+
+import (
+	"context"
+	"fmt"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// AccessCheck describes a single permission a source needs in order to be fetched
+type AccessCheck struct {
+	Verb        string
+	Group       string
+	Resource    string
+	Subresource string
+	Namespace   string
+	Name        string // the object, empty for all objects of the resource
+}
+
+// String formats the check as e.g. "create pods/exec in namespace default" or "watch configmaps
+// app-config in namespace default"
+func (c AccessCheck) String() string {
+	resource := c.Resource
+	if c.Subresource != "" {
+		resource = resource + "/" + c.Subresource
+	}
+	if c.Group != "" {
+		resource = resource + "." + c.Group
+	}
+	if c.Name != "" {
+		resource = resource + " " + c.Name
+	}
+	return fmt.Sprintf("%s %s in namespace %s", c.Verb, resource, c.Namespace)
+}
+
+// AccessDenial records a permission that was denied for a source
+type AccessDenial struct {
+	Source Source
+	Check  AccessCheck
+	Reason string
+}
+
+// workloadResources maps workload kinds to their apps/v1 resource names
+var workloadResources = map[string]string{
+	"Deployment":  "deployments",
+	"StatefulSet": "statefulsets",
+	"DaemonSet":   "daemonsets",
+}
+
+// RequiredAccess returns the permissions needed to fetch the source
+// Sources that don't talk to Kubernetes return nil
+func (s *Source) RequiredAccess() []AccessCheck {
+	namespace := s.GetNamespace()
+
+	switch s.Type {
+	case "ConfigMap":
+		return []AccessCheck{
+			{Verb: "get", Resource: "configmaps", Namespace: namespace},
+		}
+	case "Secret":
+		return []AccessCheck{
+			{Verb: "get", Resource: "secrets", Namespace: namespace},
+		}
+	case "Deployment", "StatefulSet", "DaemonSet":
+		// Workloads reference ConfigMaps and Secrets through env, envFrom and volumes
+		return []AccessCheck{
+			{Verb: "get", Group: "apps", Resource: workloadResources[s.Type], Namespace: namespace},
+			{Verb: "get", Resource: "configmaps", Namespace: namespace},
+			{Verb: "get", Resource: "secrets", Namespace: namespace},
+		}
+	case "KnativeService":
+		return []AccessCheck{
+			{Verb: "get", Group: "serving.knative.dev", Resource: "services", Namespace: namespace},
+			{Verb: "get", Group: "serving.knative.dev", Resource: "revisions", Namespace: namespace},
+			{Verb: "get", Resource: "configmaps", Namespace: namespace},
+			{Verb: "get", Resource: "secrets", Namespace: namespace},
+		}
+	case "Metadata":
+		resource, ok := MetadataKinds[s.Kind]
+		if !ok {
+			return nil
+		}
+		group := ""
+		if _, ok := workloadResources[s.Kind]; ok {
+			group = "apps"
+		}
+		return []AccessCheck{{Verb: "get", Group: group, Resource: resource, Namespace: namespace}}
+	case AutoType:
+		// Every kind is looked up, and a workload references ConfigMaps and Secrets
+		return []AccessCheck{
+			{Verb: "get", Resource: "configmaps", Namespace: namespace},
+			{Verb: "get", Resource: "secrets", Namespace: namespace},
+			{Verb: "get", Group: "apps", Resource: "deployments", Namespace: namespace},
+			{Verb: "get", Group: "apps", Resource: "statefulsets", Namespace: namespace},
+			{Verb: "get", Group: "apps", Resource: "daemonsets", Namespace: namespace},
+		}
+	case "Container":
+		var checks []AccessCheck
+		if resource, ok := workloadResources[s.Kind]; ok {
+			// The pod is selected by listing the workload's pods, a selected StatefulSet replica is read by name
+			podVerb := "list"
+			if s.Pod != "" {
+				podVerb = "get"
+			}
+			checks = append(checks,
+				AccessCheck{Verb: "get", Group: "apps", Resource: resource, Namespace: namespace},
+				AccessCheck{Verb: podVerb, Resource: "pods", Namespace: namespace},
+			)
+		} else {
+			checks = append(checks, AccessCheck{Verb: "get", Resource: "pods", Namespace: namespace})
+		}
+		if s.Method == ContainerMethodStatic {
+			// The environment is computed from the pod spec and its referenced ConfigMaps and Secrets
+			return append(checks,
+				AccessCheck{Verb: "get", Resource: "configmaps", Namespace: namespace},
+				AccessCheck{Verb: "get", Resource: "secrets", Namespace: namespace},
+			)
+		}
+		return append(checks, AccessCheck{Verb: "create", Resource: "pods", Subresource: "exec", Namespace: namespace})
+	default:
+		return nil
+	}
+}
+
+// RequiredWatchAccess returns the permissions needed to watch the object of a ConfigMap or Secret
+// source. The watch lists and watches the object by name, so access can be limited to it.
+func (s *Source) RequiredWatchAccess() []AccessCheck {
+	var resource string
+	switch s.Type {
+	case "ConfigMap":
+		resource = "configmaps"
+	case "Secret":
+		resource = "secrets"
+	default:
+		return nil
+	}
+	namespace := s.GetNamespace()
+	return []AccessCheck{
+		{Verb: "list", Resource: resource, Namespace: namespace, Name: s.Name},
+		{Verb: "watch", Resource: resource, Namespace: namespace, Name: s.Name},
+	}
+}
+
+// CheckAccess runs a SelfSubjectAccessReview for every permission required by the given sources
+// and returns the permissions that were denied. Identical checks are only reviewed once.
+func CheckAccess(ctx context.Context, clientset kubernetes.Interface, sources []Source) ([]AccessDenial, error) {
+	return checkAccess(ctx, clientset, sources, (*Source).RequiredAccess)
+}
+
+// CheckWatchAccess is CheckAccess for the permissions needed to watch the sources, see RequiredWatchAccess
+func CheckWatchAccess(ctx context.Context, clientset kubernetes.Interface, sources []Source) ([]AccessDenial, error) {
+	return checkAccess(ctx, clientset, sources, (*Source).RequiredWatchAccess)
+}
+
+// checkAccess reviews the permissions that required returns for each source
+func checkAccess(ctx context.Context, clientset kubernetes.Interface, sources []Source, required func(*Source) []AccessCheck) ([]AccessDenial, error) {
+	type result struct {
+		allowed bool
+		reason  string
+	}
+	reviewed := make(map[AccessCheck]result)
+
+	var denials []AccessDenial
+	for _, source := range sources {
+		for _, check := range required(&source) {
+			res, ok := reviewed[check]
+			if !ok {
+				review := &authorizationv1.SelfSubjectAccessReview{
+					Spec: authorizationv1.SelfSubjectAccessReviewSpec{
+						ResourceAttributes: &authorizationv1.ResourceAttributes{
+							Namespace:   check.Namespace,
+							Verb:        check.Verb,
+							Group:       check.Group,
+							Resource:    check.Resource,
+							Subresource: check.Subresource,
+							Name:        check.Name,
+						},
+					},
+				}
+				response, err := clientset.AuthorizationV1().SelfSubjectAccessReviews().Create(ctx, review, metav1.CreateOptions{})
+				if err != nil {
+					return nil, fmt.Errorf("failed to review access for %s: %w", check, err)
+				}
+				res = result{allowed: response.Status.Allowed, reason: response.Status.Reason}
+				reviewed[check] = res
+			}
+
+			if !res.allowed {
+				denials = append(denials, AccessDenial{
+					Source: source,
+					Check:  check,
+					Reason: res.reason,
+				})
+			}
+		}
+	}
+
+	return denials, nil
+}
@@ -0,0 +1,123 @@
+package sources
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestCheckAccessReportsDeniedReviews(t *testing.T) {
+	clientset := fake.NewClientset()
+	reviews := 0
+	clientset.PrependReactor("create", "selfsubjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		reviews++
+		review := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SelfSubjectAccessReview)
+		attrs := review.Spec.ResourceAttributes
+		// Deny secrets in the restricted namespace and pod exec everywhere
+		denied := (attrs.Resource == "secrets" && attrs.Namespace == "restricted") || attrs.Subresource == "exec"
+		review.Status = authorizationv1.SubjectAccessReviewStatus{Allowed: !denied}
+		if denied {
+			review.Status.Reason = "forbidden by test"
+		}
+		return true, review, nil
+	})
+
+	configSources := []Source{
+		{Type: "ConfigMap", Name: "app-config", Namespace: "restricted"},
+		{Type: "Secret", Name: "app-secret", Namespace: "restricted"},
+		{Type: "Secret", Name: "other-secret", Namespace: "restricted"},
+		{Type: "Container", Kind: "Pod", Name: "app-pod"},
+		{Type: "Vars", Name: "inline"},
+	}
+
+	denials, err := CheckAccess(context.Background(), clientset, configSources)
+	if err != nil {
+		t.Fatalf("CheckAccess returned error: %v", err)
+	}
+
+	expected := []struct {
+		source string
+		check  string
+	}{
+		{"app-secret", "get secrets in namespace restricted"},
+		{"other-secret", "get secrets in namespace restricted"},
+		{"app-pod", "create pods/exec in namespace default"},
+	}
+	if len(denials) != len(expected) {
+		t.Fatalf("expected %d denials, got %d: %+v", len(expected), len(denials), denials)
+	}
+	for i, want := range expected {
+		if denials[i].Source.Name != want.source || denials[i].Check.String() != want.check {
+			t.Errorf("denial %d: expected %s (%s), got %s (%s)", i, want.source, want.check, denials[i].Source.Name, denials[i].Check)
+		}
+		if denials[i].Reason != "forbidden by test" {
+			t.Errorf("denial %d: expected reason to be propagated, got %q", i, denials[i].Reason)
+		}
+	}
+
+	// configmaps, secrets, pods and pods/exec are each reviewed once
+	if reviews != 4 {
+		t.Errorf("expected 4 access reviews, got %d", reviews)
+	}
+}
+
+func TestRequiredAccessOfPodSelection(t *testing.T) {
+	testCases := []struct {
+		name     string
+		source   Source
+		expected string
+	}{
+		{name: "pod", source: Source{Type: "Container", Kind: "Pod", Name: "app-0"}, expected: "get pods in namespace default"},
+		{name: "deployment", source: Source{Type: "Container", Kind: "Deployment", Name: "app"}, expected: "list pods in namespace default"},
+		{name: "statefulset", source: Source{Type: "Container", Kind: "StatefulSet", Name: "db"}, expected: "list pods in namespace default"},
+		{name: "statefulset replica", source: Source{Type: "Container", Kind: "StatefulSet", Name: "db", Pod: "1"}, expected: "get pods in namespace default"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var podChecks []string
+			for _, check := range tc.source.RequiredAccess() {
+				if check.Resource == "pods" && check.Subresource == "" {
+					podChecks = append(podChecks, check.String())
+				}
+			}
+			if len(podChecks) != 1 || podChecks[0] != tc.expected {
+				t.Errorf("expected %q, got %v", tc.expected, podChecks)
+			}
+		})
+	}
+}
+
+func TestCheckWatchAccessReviewsTheObject(t *testing.T) {
+	clientset := fake.NewClientset()
+	clientset.PrependReactor("create", "selfsubjectaccessreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		review := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SelfSubjectAccessReview)
+		attrs := review.Spec.ResourceAttributes
+		// Only the settings ConfigMap may be watched
+		review.Status = authorizationv1.SubjectAccessReviewStatus{Allowed: attrs.Name == "settings"}
+		return true, review, nil
+	})
+
+	denials, err := CheckWatchAccess(context.Background(), clientset, []Source{
+		{Type: "ConfigMap", Name: "settings"},
+		{Type: "Secret", Name: "credentials"},
+		{Type: "Vars", Name: "inline"},
+	})
+	if err != nil {
+		t.Fatalf("CheckWatchAccess returned error: %v", err)
+	}
+
+	var denied []string
+	for _, denial := range denials {
+		denied = append(denied, denial.Check.String())
+	}
+	expected := []string{"list secrets credentials in namespace default", "watch secrets credentials in namespace default"}
+	if !slices.Equal(denied, expected) {
+		t.Errorf("expected %v, got %v", expected, denied)
+	}
+}
@@ -0,0 +1,132 @@
+package sources
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// ObjectReference is a ConfigMap or Secret a source reads, in the namespace of the source
+type ObjectReference struct {
+	Kind string // ConfigMap or Secret
+	Name string
+}
+
+// References returns the ConfigMaps and Secrets whose content ends up in the entries of the source:
+// the object of a ConfigMap or Secret source, and the objects a workload's containers reference
+// through envFrom, valueFrom and volumes. Container sources only reference objects with method
+// static, as exec reads the environment the container was started with.
+func References(ctx context.Context, clientset kubernetes.Interface, source Source) ([]ObjectReference, error) {
+	namespace := source.GetNamespace()
+	kind := source.Type
+	switch source.Type {
+	case "ConfigMap", "Secret":
+		return []ObjectReference{{Kind: source.Type, Name: source.Name}}, nil
+	case "Container":
+		if source.Method != ContainerMethodStatic {
+			return nil, nil
+		}
+		kind = source.Kind
+	case "Deployment", "StatefulSet", "DaemonSet":
+	default:
+		return nil, nil
+	}
+
+	var podSpec corev1.PodSpec
+	var err error
+	switch kind {
+	case "Pod":
+		var pod *corev1.Pod
+		if pod, err = clientset.CoreV1().Pods(namespace).Get(ctx, source.Name, metav1.GetOptions{}); err == nil {
+			podSpec = pod.Spec
+		}
+	case "Deployment":
+		var deployment *appsv1.Deployment
+		if deployment, err = clientset.AppsV1().Deployments(namespace).Get(ctx, source.Name, metav1.GetOptions{}); err == nil {
+			podSpec = deployment.Spec.Template.Spec
+		}
+	case "StatefulSet":
+		var statefulSet *appsv1.StatefulSet
+		if statefulSet, err = clientset.AppsV1().StatefulSets(namespace).Get(ctx, source.Name, metav1.GetOptions{}); err == nil {
+			podSpec = statefulSet.Spec.Template.Spec
+		}
+	case "DaemonSet":
+		var daemonSet *appsv1.DaemonSet
+		if daemonSet, err = clientset.AppsV1().DaemonSets(namespace).Get(ctx, source.Name, metav1.GetOptions{}); err == nil {
+			podSpec = daemonSet.Spec.Template.Spec
+		}
+	default:
+		return nil, nil
+	}
+	if err != nil {
+		if source.Optional && apierrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get %s %s/%s: %w", kind, namespace, source.Name, err)
+	}
+	return PodSpecReferences(podSpec, source), nil
+}
+
+// PodSpecReferences returns the ConfigMaps and Secrets the containers of podSpec selected by the
+// source reference through envFrom, valueFrom and mounted volumes, without duplicates
+func PodSpecReferences(podSpec corev1.PodSpec, source Source) []ObjectReference {
+	var references []ObjectReference
+	add := func(kind, name string) {
+		reference := ObjectReference{Kind: kind, Name: name}
+		if name != "" && !slices.Contains(references, reference) {
+			references = append(references, reference)
+		}
+	}
+
+	for _, container := range podContainers(podSpec, nil, source) {
+		for _, envFrom := range container.EnvFrom {
+			if envFrom.ConfigMapRef != nil {
+				add("ConfigMap", envFrom.ConfigMapRef.Name)
+			}
+			if envFrom.SecretRef != nil {
+				add("Secret", envFrom.SecretRef.Name)
+			}
+		}
+		for _, envVar := range container.Env {
+			if envVar.ValueFrom == nil {
+				continue
+			}
+			if envVar.ValueFrom.ConfigMapKeyRef != nil {
+				add("ConfigMap", envVar.ValueFrom.ConfigMapKeyRef.Name)
+			}
+			if envVar.ValueFrom.SecretKeyRef != nil {
+				add("Secret", envVar.ValueFrom.SecretKeyRef.Name)
+			}
+		}
+		for _, volumeMount := range container.VolumeMounts {
+			for _, volume := range podSpec.Volumes {
+				if volume.Name != volumeMount.Name {
+					continue
+				}
+				if volume.ConfigMap != nil {
+					add("ConfigMap", volume.ConfigMap.Name)
+				}
+				if volume.Secret != nil {
+					add("Secret", volume.Secret.SecretName)
+				}
+				if volume.Projected != nil {
+					for _, projection := range volume.Projected.Sources {
+						if projection.ConfigMap != nil {
+							add("ConfigMap", projection.ConfigMap.Name)
+						}
+						if projection.Secret != nil {
+							add("Secret", projection.Secret.Name)
+						}
+					}
+				}
+			}
+		}
+	}
+	return references
+}
@@ -0,0 +1,61 @@
+package sources
+
+import (
+	"slices"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestReferencesOfDeployment(t *testing.T) {
+	clientset := fake.NewClientset(&appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "default"},
+		Spec: appsv1.DeploymentSpec{Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{
+			Containers: []corev1.Container{
+				{
+					Name:    "app",
+					EnvFrom: []corev1.EnvFromSource{{ConfigMapRef: &corev1.ConfigMapEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: "settings"}}}},
+					Env: []corev1.EnvVar{
+						{Name: "PASSWORD", ValueFrom: &corev1.EnvVarSource{SecretKeyRef: &corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "credentials"}, Key: "password"}}},
+						{Name: "REGION", ValueFrom: &corev1.EnvVarSource{ConfigMapKeyRef: &corev1.ConfigMapKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "settings"}, Key: "region"}}},
+					},
+					VolumeMounts: []corev1.VolumeMount{{Name: "tls", MountPath: "/tls"}},
+				},
+				{
+					Name:    "sidecar",
+					EnvFrom: []corev1.EnvFromSource{{SecretRef: &corev1.SecretEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: "sidecar-token"}}}},
+				},
+			},
+			Volumes: []corev1.Volume{{Name: "tls", VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{SecretName: "tls-cert"}}}},
+		}}},
+	})
+
+	references, err := References(t.Context(), clientset, Source{Type: "Deployment", Name: "app", Containers: []string{"app"}})
+	if err != nil {
+		t.Fatalf("References returned error: %v", err)
+	}
+	expected := []ObjectReference{
+		{Kind: "ConfigMap", Name: "settings"},
+		{Kind: "Secret", Name: "credentials"},
+		{Kind: "Secret", Name: "tls-cert"},
+	}
+	if !slices.Equal(references, expected) {
+		t.Errorf("expected %v, got %v", expected, references)
+	}
+
+	// Exec reads the environment the container started with, which a changed ConfigMap doesn't affect
+	references, err = References(t.Context(), clientset, Source{Type: "Container", Kind: "Deployment", Name: "app"})
+	if err != nil || len(references) != 0 {
+		t.Errorf("expected no references for an exec Container source, got %v (%v)", references, err)
+	}
+
+	if _, err := References(t.Context(), clientset, Source{Type: "Deployment", Name: "missing"}); err == nil {
+		t.Error("expected an error for a missing deployment")
+	}
+	if references, err := References(t.Context(), clientset, Source{Type: "Deployment", Name: "missing", Optional: true}); err != nil || len(references) != 0 {
+		t.Errorf("expected an optional missing deployment to have no references, got %v (%v)", references, err)
+	}
+}
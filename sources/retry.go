@@ -0,0 +1,64 @@
+package sources
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	utilnet "k8s.io/apimachinery/pkg/util/net"
+)
+
+// retries is how often a failed API request is retried when the error is transient
+var retries = 0
+
+// retryDelay is the wait before the first retry, doubled for every following one
+var retryDelay = 250 * time.Millisecond
+
+// SetRetries sets how often requests to the API server are retried after a transient error such as
+// a timeout, 429 or 5xx; 0 or less disables retries. It must be called before any source is fetched.
+func SetRetries(n int) {
+	retries = max(n, 0)
+}
+
+// withRetry calls get and retries it with exponential backoff while it fails with a retryable error
+func withRetry[T any](ctx context.Context, get func() (T, error)) (T, error) {
+	delay := retryDelay
+	for attempt := 0; ; attempt++ {
+		result, err := get()
+		if err == nil || attempt >= retries || !isRetryable(err) {
+			return result, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return result, err
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+}
+
+// isRetryable returns true for errors that may go away when the request is repeated. Errors such as
+// NotFound or Forbidden are returned immediately.
+func isRetryable(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	if apierrors.IsTimeout(err) || apierrors.IsServerTimeout(err) || apierrors.IsTooManyRequests(err) ||
+		apierrors.IsInternalError(err) || apierrors.IsServiceUnavailable(err) || apierrors.IsUnexpectedServerError(err) {
+		return true
+	}
+	var status apierrors.APIStatus
+	if errors.As(err, &status) {
+		return status.Status().Code >= 500
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	return utilnet.IsConnectionReset(err) || utilnet.IsConnectionRefused(err) || utilnet.IsProbableEOF(err)
+}
@@ -0,0 +1,100 @@
+package sources
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// failingGets makes the first n gets of the clientset fail with err
+func failingGets(clientset *fake.Clientset, n int, err error) {
+	clientset.PrependReactor("get", "*", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if n > 0 {
+			n--
+			return true, nil, err
+		}
+		return false, nil, nil
+	})
+}
+
+func countGets(clientset *fake.Clientset) int {
+	gets := 0
+	for _, action := range clientset.Actions() {
+		if action.GetVerb() == "get" {
+			gets++
+		}
+	}
+	return gets
+}
+
+func TestFetchRetriesTransientErrors(t *testing.T) {
+	defer func(delay time.Duration) { retryDelay = delay; SetRetries(0) }(retryDelay)
+	retryDelay = 0
+	SetRetries(3)
+
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "default"},
+		Data:       map[string]string{"REGION": "eu"},
+	}
+	source := Source{Type: "ConfigMap", Name: "app"}
+
+	testCases := []struct {
+		name          string
+		failures      int
+		err           error
+		cached        bool
+		expectedGets  int
+		expectSuccess bool
+	}{
+		{name: "server errors", failures: 2, err: apierrors.NewInternalError(errors.New("etcd leader changed")), expectedGets: 3, expectSuccess: true},
+		{name: "throttled", failures: 2, err: apierrors.NewTooManyRequests("slow down", 1), expectedGets: 3, expectSuccess: true},
+		{name: "cached", failures: 2, err: apierrors.NewServiceUnavailable("restarting"), cached: true, expectedGets: 3, expectSuccess: true},
+		{name: "too many failures", failures: 5, err: apierrors.NewInternalError(errors.New("down")), expectedGets: 4},
+		{name: "forbidden", failures: 1, err: apierrors.NewForbidden(corev1.Resource("configmaps"), "app", errors.New("no access")), expectedGets: 1},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fakeClientset := fake.NewClientset(configMap)
+			failingGets(fakeClientset, tc.failures, tc.err)
+
+			var clientset kubernetes.Interface = fakeClientset
+			if tc.cached {
+				clientset = NewCachingClientset(fakeClientset)
+			}
+			entries, err := (&ConfigMapFetcher{}).Fetch(context.Background(), clientset, source, t.TempDir())
+			if tc.expectSuccess && (err != nil || len(entries) != 1) {
+				t.Errorf("expected the fetch to succeed after retrying, got %v, %v", entries, err)
+			}
+			if !tc.expectSuccess && err == nil {
+				t.Error("expected an error")
+			}
+			if gets := countGets(fakeClientset); gets != tc.expectedGets {
+				t.Errorf("expected %d gets, got %d", tc.expectedGets, gets)
+			}
+		})
+	}
+}
+
+func TestWithRetryNotFoundIsNotRetried(t *testing.T) {
+	defer SetRetries(0)
+	SetRetries(3)
+
+	calls := 0
+	_, err := withRetry(context.Background(), func() (*corev1.ConfigMap, error) {
+		calls++
+		return nil, apierrors.NewNotFound(corev1.Resource("configmaps"), "app")
+	})
+	if !apierrors.IsNotFound(err) || calls != 1 {
+		t.Errorf("expected a single NotFound call, got %d calls: %v", calls, err)
+	}
+}
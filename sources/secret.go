@@ -7,16 +7,22 @@ import (
 
 	"enver/transformations"
 
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 )
 
 type SecretFetcher struct{}
 
-func (f *SecretFetcher) Fetch(clientset *kubernetes.Clientset, source Source, outputDirectory string) ([]EnvEntry, error) {
+func (f *SecretFetcher) Fetch(ctx context.Context, clientset kubernetes.Interface, source Source, outputDirectory string) ([]EnvEntry, error) {
 	namespace := source.GetNamespace()
-	secret, err := clientset.CoreV1().Secrets(namespace).Get(context.Background(), source.Name, metav1.GetOptions{})
+	secret, err := withRetry(ctx, func() (*corev1.Secret, error) {
+		return clientset.CoreV1().Secrets(namespace).Get(ctx, source.Name, metav1.GetOptions{})
+	})
 	if err != nil {
+		if source.skipMissing("secret", namespace, err) {
+			return nil, nil
+		}
 		return nil, fmt.Errorf("failed to get secret %s/%s: %w", namespace, source.Name, err)
 	}
 
@@ -31,26 +37,35 @@ func (f *SecretFetcher) Fetch(clientset *kubernetes.Clientset, source Source, ou
 			Output:        tc.Output,
 			Key:           tc.Key,
 			BaseDirectory: outputDirectory,
+			Mode:          fileMode(ctx, true),
 		})
 	}
 
+	// Report what manages the secret if requested
+	var owner string
+	if source.IncludeOwner {
+		owner = describeOwner(secret.ObjectMeta)
+	}
+
 	var entries []EnvEntry
 	for key, value := range secret.Data {
-		if len(value) > 0 && !source.ShouldExcludeVariable(key) {
-			strValue := strings.TrimRight(string(value), "\n\r")
+		strValue := strings.TrimRight(string(value), "\n\r")
+		if source.keepValue(ctx, key, strValue) {
 
-			// Apply transformations
-			transformedKey, transformedValue, err := transformations.ApplyTransformations(key, strValue, transformConfigs)
+			// Apply transformations to the mapped key
+			transformedKey, transformedValue, err := transformations.ApplyTransformations(source.GetKeyMapping(key), strValue, transformConfigs)
 			if err != nil {
 				return nil, fmt.Errorf("failed to apply transformation: %w", err)
 			}
 
 			entries = append(entries, EnvEntry{
-				Key:        transformedKey,
-				Value:      transformedValue,
-				SourceType: "Secret",
-				Name:       source.Name,
-				Namespace:  namespace,
+				Key:             transformedKey,
+				Value:           transformedValue,
+				SourceType:      "Secret",
+				Name:            source.Name,
+				Namespace:       namespace,
+				Owner:           owner,
+				ResourceVersion: secret.ResourceVersion,
 			})
 		}
 	}
@@ -0,0 +1,126 @@
+package sources
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestSecretFetcherIncludesOwner(t *testing.T) {
+	controller := true
+	clientset := fake.NewClientset(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "db-credentials",
+			Namespace: "apps",
+			Labels:    map[string]string{"app.kubernetes.io/managed-by": "Helm"},
+			Annotations: map[string]string{
+				"meta.helm.sh/release-name": "database",
+			},
+			OwnerReferences: []metav1.OwnerReference{
+				{APIVersion: "bitnami.com/v1alpha1", Kind: "SealedSecret", Name: "db-credentials", Controller: &controller},
+			},
+		},
+		Data: map[string][]byte{"PASSWORD": []byte("secret")},
+	})
+
+	source := Source{Type: "Secret", Name: "db-credentials", Namespace: "apps", IncludeOwner: true}
+	entries, err := (&SecretFetcher{}).Fetch(context.Background(), clientset, source, t.TempDir())
+	if err != nil {
+		t.Fatalf("Fetch returned error: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("expected 1 entry, got %d", len(entries))
+	}
+
+	expected := "owned by SealedSecret/db-credentials, managed by Helm, release database"
+	if entries[0].Owner != expected {
+		t.Errorf("expected owner %q, got %q", expected, entries[0].Owner)
+	}
+
+	// Without includeOwner the owner is not reported
+	source.IncludeOwner = false
+	entries, err = (&SecretFetcher{}).Fetch(context.Background(), clientset, source, t.TempDir())
+	if err != nil {
+		t.Fatalf("Fetch returned error: %v", err)
+	}
+	if entries[0].Owner != "" {
+		t.Errorf("expected no owner without includeOwner, got %q", entries[0].Owner)
+	}
+}
+
+func TestSecretFetcherFileTransformationWritesUnderOutputDirectory(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	clientset := fake.NewClientset(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "tls", Namespace: "apps"},
+		Data:       map[string][]byte{"tls.crt": []byte("-----BEGIN CERTIFICATE-----\n")},
+	})
+
+	source := Source{
+		Type:      "Secret",
+		Name:      "tls",
+		Namespace: "apps",
+		Transformations: []TransformationConfig{
+			{Type: "file", Output: "certs/tls.crt", Key: "TLS_CERT_FILE"},
+		},
+	}
+	var fetcher Fetcher = &SecretFetcher{}
+	entries, err := fetcher.Fetch(context.Background(), clientset, source, "generated")
+	if err != nil {
+		t.Fatalf("Fetch returned error: %v", err)
+	}
+
+	expectedPath := filepath.Join("generated", "certs", "tls.crt")
+	if len(entries) != 1 || entries[0].Key != "TLS_CERT_FILE" || entries[0].Value != expectedPath {
+		t.Fatalf("expected TLS_CERT_FILE=%s, got %+v", expectedPath, entries)
+	}
+	content, err := os.ReadFile(expectedPath)
+	if err != nil {
+		t.Fatalf("expected the secret value to be written to %s: %v", expectedPath, err)
+	}
+	if string(content) != "-----BEGIN CERTIFICATE-----" {
+		t.Errorf("unexpected file content %q", string(content))
+	}
+	// Only the owner can read values from a Secret
+	info, err := os.Stat(expectedPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("expected %s to have mode 600, got %o", expectedPath, info.Mode().Perm())
+	}
+}
+
+func TestSecretFetcherAppliesKeyMappings(t *testing.T) {
+	clientset := fake.NewClientset(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "default"},
+		Data: map[string][]byte{
+			"db.password": []byte("secret"),
+			"USERNAME":    []byte("admin"),
+		},
+	})
+
+	source := Source{
+		Type:            "Secret",
+		Name:            "db",
+		KeyMappings:     map[string]string{"db.password": "DATABASE_PASSWORD"},
+		Transformations: []TransformationConfig{{Type: "prefix", Target: "key", Value: "APP_", Variables: []string{"DATABASE_PASSWORD"}}},
+	}
+	entries, err := (&SecretFetcher{}).Fetch(context.Background(), clientset, source, t.TempDir())
+	if err != nil {
+		t.Fatalf("Fetch returned error: %v", err)
+	}
+
+	values := make(map[string]string)
+	for _, entry := range entries {
+		values[entry.Key] = entry.Value
+	}
+	if len(values) != 2 || values["APP_DATABASE_PASSWORD"] != "secret" || values["USERNAME"] != "admin" {
+		t.Errorf("expected the mapped key to be renamed before transformations and the other key kept, got %v", values)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 
+	appsv1 "k8s.io/api/apps/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 )
@@ -12,15 +13,20 @@ type StatefulSetFetcher struct {
 	processor WorkloadProcessor
 }
 
-func (f *StatefulSetFetcher) Fetch(clientset *kubernetes.Clientset, source Source, outputDirectory string) ([]EnvEntry, error) {
+func (f *StatefulSetFetcher) Fetch(ctx context.Context, clientset kubernetes.Interface, source Source, outputDirectory string) ([]EnvEntry, error) {
 	namespace := source.GetNamespace()
-	statefulSet, err := clientset.AppsV1().StatefulSets(namespace).Get(context.Background(), source.Name, metav1.GetOptions{})
+	statefulSet, err := withRetry(ctx, func() (*appsv1.StatefulSet, error) {
+		return clientset.AppsV1().StatefulSets(namespace).Get(ctx, source.Name, metav1.GetOptions{})
+	})
 	if err != nil {
+		if source.skipMissing("statefulset", namespace, err) {
+			return nil, nil
+		}
 		return nil, fmt.Errorf("failed to get statefulset %s/%s: %w", namespace, source.Name, err)
 	}
 
 	return f.processor.ProcessPodSpec(
-		clientset,
+		ctx, clientset,
 		statefulSet.Spec.Template.Spec,
 		source,
 		source.Name,
@@ -1,18 +1,35 @@
 package sources
 
 import (
+	"context"
+	"fmt"
+	"io"
+	"os"
 	"regexp"
+	"strings"
 
+	"enver/transformations"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/client-go/kubernetes"
 )
 
 // EnvEntry represents a single environment variable with its source metadata
 type EnvEntry struct {
-	Key        string
-	Value      string
-	SourceType string
-	Name       string
-	Namespace  string
+	Key             string
+	Value           string
+	SourceType      string
+	Name            string
+	Namespace       string
+	Owner           string // what manages the source object, only set when the source has includeOwner
+	ResourceVersion string // resourceVersion of the ConfigMap or Secret the entry was read from
+	Comment         string // the comment above the variable, only set for EnvFile sources
+	FromSecret      bool   // a workload read the value from a Secret, through envFrom or secretKeyRef
+}
+
+// IsSecret returns true if the value was read from a Secret
+func (e EnvEntry) IsSecret() bool {
+	return e.SourceType == "Secret" || e.FromSecret
 }
 
 // SourceContexts defines context-based filtering for a source
@@ -23,14 +40,31 @@ type SourceContexts struct {
 
 // SourceVariables defines variable-level filtering for a source
 type SourceVariables struct {
-	Include []string `yaml:"include"`
-	Exclude []string `yaml:"exclude"`
+	Include         []string `yaml:"include"`
+	Exclude         []string `yaml:"exclude"`
+	IncludeValues   []string `yaml:"includeValues"`   // only keep variables whose value matches one of these patterns
+	ExcludeValues   []string `yaml:"excludeValues"`   // drop variables whose value matches one of these patterns
+	Mode            string   `yaml:"mode"`            // how patterns match: exact, regex or glob (empty = exact or unanchored regex)
+	CaseInsensitive bool     `yaml:"caseInsensitive"` // match include and exclude name patterns ignoring case
+
+	// CommandLine holds the patterns of --include-var and --exclude-var, which variables must pass
+	// in addition to the patterns above
+	CommandLine *SourceVariables `yaml:"-"`
 }
 
+// Modes for matching variable patterns
+const (
+	VariableModeExact = "exact" // the pattern must equal the name or value
+	VariableModeRegex = "regex" // the pattern is a regex that must match the whole name or value
+	VariableModeGlob  = "glob"  // the pattern is a glob where * matches any characters and ? a single one
+)
+
 // VarEntry defines a single variable for the Vars source type
 type VarEntry struct {
-	Name  string `yaml:"name"`
-	Value string `yaml:"value"`
+	Name         string  `yaml:"name"`
+	Value        string  `yaml:"value"`
+	ValueFromEnv string  `yaml:"valueFromEnv"` // read the value from this environment variable when value is empty
+	Default      *string `yaml:"default"`      // value to use when the valueFromEnv variable is not set
 }
 
 // VolumeMountKeyMapping defines key mappings for volume mounts in Deployment source
@@ -50,7 +84,7 @@ type ContainerFileExtract struct {
 
 // TransformationConfig defines a transformation to apply to variables
 type TransformationConfig struct {
-	Type      string   `yaml:"type"`      // base64_decode, base64_encode, prefix, suffix, file
+	Type      string   `yaml:"type"`      // base64_decode, base64_encode, prefix, suffix, shell_quote, file
 	Target    string   `yaml:"target"`    // key or value
 	Value     string   `yaml:"value"`     // parameter for prefix/suffix
 	Variables []string `yaml:"variables"` // limit to these variable names (empty = apply to all)
@@ -60,18 +94,110 @@ type TransformationConfig struct {
 
 // Source represents a source configuration from .enver.yaml
 type Source struct {
-	Name                   string                  `yaml:"name"`
-	Namespace              string                  `yaml:"namespace"`
-	Type                   string                  `yaml:"type"`
-	Kind                   string                  `yaml:"kind"` // for Container source type: Pod, Deployment, StatefulSet, DaemonSet
-	Path                   string                  `yaml:"path"`
-	Contexts               SourceContexts          `yaml:"contexts"`
-	Variables              SourceVariables         `yaml:"variables"`
-	Transformations        []TransformationConfig  `yaml:"transformations"`
-	Vars                   []VarEntry              `yaml:"vars"`                   // for Vars source type
-	Containers             []string                `yaml:"containers"`             // for Deployment/Container source type
-	VolumeMountKeyMappings []VolumeMountKeyMapping `yaml:"volumeMountKeyMappings"` // for Deployment source type
-	Files                  []ContainerFileExtract  `yaml:"files"`                  // for Container source type
+	Name                       string                  `yaml:"name"`
+	Namespace                  string                  `yaml:"namespace"`
+	Type                       string                  `yaml:"type"`
+	Kind                       string                  `yaml:"kind"` // for Container source type: Pod, Deployment, StatefulSet, DaemonSet; for Metadata also ConfigMap, Secret
+	Path                       string                  `yaml:"path"`
+	Contexts                   SourceContexts          `yaml:"contexts"`
+	Variables                  SourceVariables         `yaml:"variables"`
+	Transformations            []TransformationConfig  `yaml:"transformations"`
+	InheritTransformations     *bool                   `yaml:"inheritTransformations"`     // apply the top-level transformations before the source's own (default true)
+	Vars                       []VarEntry              `yaml:"vars"`                       // for Vars source type
+	Containers                 []string                `yaml:"containers"`                 // for Deployment/Container source type
+	MergeContainers            string                  `yaml:"mergeContainers"`            // for workload source types: keep-all (default), last-wins or prefix
+	VolumeMountKeyMappings     []VolumeMountKeyMapping `yaml:"volumeMountKeyMappings"`     // for Deployment source type
+	KeyMappings                map[string]string       `yaml:"keyMappings"`                // for ConfigMap/Secret/Metadata source type: original key -> new key
+	Files                      []ContainerFileExtract  `yaml:"files"`                      // for Container source type
+	IncludeOwner               bool                    `yaml:"includeOwner"`               // for ConfigMap/Secret source type: report the managing controller
+	IncludeEmpty               bool                    `yaml:"includeEmpty"`               // keep variables with an empty value instead of skipping them
+	Optional                   bool                    `yaml:"optional"`                   // for ConfigMap/Secret and workload source types: contribute nothing when the object doesn't exist
+	Expand                     bool                    `yaml:"expand"`                     // for EnvFile source type: expand ${VAR} and $VAR from earlier lines
+	KeepInlineComments         bool                    `yaml:"keepInlineComments"`         // for EnvFile source type: don't strip # comments after unquoted values
+	CaptureStderr              bool                    `yaml:"captureStderr"`              // for Container source type: log what env writes to stderr
+	Method                     string                  `yaml:"method"`                     // for Container source type: exec (default) or static
+	Metadata                   string                  `yaml:"metadata"`                   // for Metadata source type: annotations (default), labels or all
+	KeyPrefix                  string                  `yaml:"keyPrefix"`                  // for Metadata source type: prefix of the variable names
+	IncludeEphemeralContainers bool                    `yaml:"includeEphemeralContainers"` // for Container source type: also read the pod's ephemeral (debug) containers
+	Pod                        string                  `yaml:"pod"`                        // for Container source type with kind StatefulSet: ordinal (0) or name (web-0) of the pod to read
+	Kubeconfig                 string                  `yaml:"kubeconfig"`                 // fetch from the cluster of this kubeconfig file instead of the execution's
+	KubeContext                string                  `yaml:"kubeContext"`                // fetch from this kube context instead of the execution's
+}
+
+// warnings receives the warnings of fetchers, such as a missing optional source
+var warnings io.Writer = os.Stderr
+
+// quiet suppresses the warnings of fetchers and the stderr captured from containers, see SetQuiet
+var quiet bool
+
+// SetQuiet stops fetchers from printing warnings and the stderr captured with captureStderr
+func SetQuiet(enabled bool) {
+	quiet = enabled
+}
+
+// skipMissing returns true, after printing a warning, if err means that the object of an optional
+// source doesn't exist
+func (s *Source) skipMissing(kind, namespace string, err error) bool {
+	if !s.Optional || !apierrors.IsNotFound(err) {
+		return false
+	}
+	if !quiet {
+		fmt.Fprintf(warnings, "Warning: optional %s %s/%s not found, skipping\n", kind, namespace, s.Name)
+	}
+	return true
+}
+
+// keepValue returns true if a variable passes the source's filters and its value is not empty, or
+// includeEmpty is set. Empty values that pass the filters are recorded in ctx's SkippedEmpty.
+func (s *Source) keepValue(ctx context.Context, key, value string) bool {
+	if s.ShouldExcludeEntry(key, value) {
+		return false
+	}
+	if value == "" && !s.IncludeEmpty {
+		if skipped, ok := ctx.Value(skippedEmptyKey{}).(*SkippedEmpty); ok {
+			skipped.keys = append(skipped.keys, key)
+		}
+		return false
+	}
+	return true
+}
+
+// SkippedEmpty collects the keys of the variables a fetch skipped because their value was empty
+type SkippedEmpty struct {
+	keys []string
+}
+
+type skippedEmptyKey struct{}
+
+// WithSkippedEmpty returns a context in which a fetch records skipped empty values in skipped. A
+// SkippedEmpty is meant for a single fetch and is not safe for concurrent use.
+func WithSkippedEmpty(ctx context.Context, skipped *SkippedEmpty) context.Context {
+	return context.WithValue(ctx, skippedEmptyKey{}, skipped)
+}
+
+// Keys returns the keys of the skipped empty values in the order they were skipped
+func (s *SkippedEmpty) Keys() []string {
+	return s.keys
+}
+
+type fileModeKey struct{}
+
+// WithFileMode returns a context in which files written while fetching, by file transformations,
+// volume mounts and Container file extracts, get mode instead of the default, see fileMode
+func WithFileMode(ctx context.Context, mode os.FileMode) context.Context {
+	return context.WithValue(ctx, fileModeKey{}, mode)
+}
+
+// fileMode returns the permissions of a file written while fetching: the mode set with
+// WithFileMode, otherwise 0600 for values from a Secret and 0644 for others
+func fileMode(ctx context.Context, secret bool) os.FileMode {
+	if mode, ok := ctx.Value(fileModeKey{}).(os.FileMode); ok && mode != 0 {
+		return mode
+	}
+	if secret {
+		return 0600
+	}
+	return 0644
 }
 
 // ShouldExcludeVariable returns true if the variable should be excluded
@@ -79,11 +205,21 @@ type Source struct {
 // If include list is specified, only variables matching include patterns are kept
 // Exclude patterns are applied after include patterns
 func (s *Source) ShouldExcludeVariable(varName string) bool {
+	return s.Variables.excludesName(varName) || s.Variables.CommandLine.excludesName(varName)
+}
+
+// excludesName returns true if the name doesn't match the include patterns or matches an exclude
+// pattern. A nil filter excludes nothing.
+func (v *SourceVariables) excludesName(varName string) bool {
+	if v == nil {
+		return false
+	}
+
 	// If include list is specified, variable must match at least one pattern
-	if len(s.Variables.Include) > 0 {
+	if len(v.Include) > 0 {
 		included := false
-		for _, pattern := range s.Variables.Include {
-			if matchesPattern(varName, pattern) {
+		for _, pattern := range v.Include {
+			if v.matches(varName, pattern, v.CaseInsensitive) {
 				included = true
 				break
 			}
@@ -94,21 +230,117 @@ func (s *Source) ShouldExcludeVariable(varName string) bool {
 	}
 
 	// Check exclude patterns
-	for _, pattern := range s.Variables.Exclude {
-		if matchesPattern(varName, pattern) {
+	for _, pattern := range v.Exclude {
+		if v.matches(varName, pattern, v.CaseInsensitive) {
 			return true
 		}
 	}
 	return false
 }
 
-// matchesPattern returns true if varName matches the pattern (exact or regex)
-func matchesPattern(varName, pattern string) bool {
+// ShouldExcludeEntry returns true if the variable should be excluded based on its name or its value
+// Value patterns work like name patterns: exact matches and regexes, include before exclude
+func (s *Source) ShouldExcludeEntry(varName, value string) bool {
+	if s.ShouldExcludeVariable(varName) {
+		return true
+	}
+
+	if len(s.Variables.IncludeValues) > 0 {
+		included := false
+		for _, pattern := range s.Variables.IncludeValues {
+			if s.Variables.matches(value, pattern, false) {
+				included = true
+				break
+			}
+		}
+		if !included {
+			return true
+		}
+	}
+
+	for _, pattern := range s.Variables.ExcludeValues {
+		if s.Variables.matches(value, pattern, false) {
+			return true
+		}
+	}
+	return false
+}
+
+// matches returns true if the name or value matches the pattern according to the mode
+func (v SourceVariables) matches(s, pattern string, foldCase bool) bool {
+	switch v.Mode {
+	case VariableModeExact:
+		return s == pattern || foldCase && strings.EqualFold(s, pattern)
+	case VariableModeRegex:
+		return matchesPattern(s, "^(?:"+pattern+")$", foldCase)
+	case VariableModeGlob:
+		return matchesPattern(s, globToRegex(pattern), foldCase)
+	default:
+		return matchesPattern(s, pattern, foldCase)
+	}
+}
+
+// globToRegex converts a glob to an anchored regex. It follows path.Match: * matches any
+// characters, ? a single character, [...] a character class ([^...] negated) and \ escapes the
+// next character. Unlike path.Match, * and ? also match slashes so globs work on values such as URLs.
+func globToRegex(glob string) string {
+	var b strings.Builder
+	b.WriteString("^")
+	for i := 0; i < len(glob); i++ {
+		switch c := glob[i]; c {
+		case '*':
+			b.WriteString(".*")
+		case '?':
+			b.WriteString(".")
+		case '\\':
+			if i+1 < len(glob) {
+				i++
+			}
+			b.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		case '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				b.WriteString(`\[`)
+				continue
+			}
+			class := glob[i+1 : i+1+end]
+			negate := strings.HasPrefix(class, "^")
+			class = strings.TrimPrefix(class, "^")
+			b.WriteString("[")
+			if negate {
+				b.WriteString("^")
+			}
+			// Keep ranges, escape everything else that has a meaning inside a regex class
+			b.WriteString(strings.NewReplacer(`\`, `\\`, `[`, `\[`, `^`, `\^`).Replace(class))
+			b.WriteString("]")
+			i += end + 1
+		default:
+			b.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		}
+	}
+	b.WriteString("$")
+	return b.String()
+}
+
+// ValidateVariableMode returns an error if mode is not a supported variable matching mode
+func ValidateVariableMode(mode string) error {
+	switch mode {
+	case "", VariableModeExact, VariableModeRegex, VariableModeGlob:
+		return nil
+	}
+	return fmt.Errorf("variables mode must be exact, regex or glob, got %q", mode)
+}
+
+// matchesPattern returns true if varName matches the pattern (exact or regex), ignoring case if foldCase is set
+func matchesPattern(varName, pattern string, foldCase bool) bool {
 	// First try exact match
-	if pattern == varName {
+	if pattern == varName || foldCase && strings.EqualFold(pattern, varName) {
 		return true
 	}
 	// Then try regex match
+	if foldCase {
+		pattern = "(?i)" + pattern
+	}
 	if re, err := regexp.Compile(pattern); err == nil {
 		if re.MatchString(varName) {
 			return true
@@ -155,6 +387,42 @@ func (s *Source) ShouldInclude(contexts []string) bool {
 	return true
 }
 
+// NeedsKubernetes returns true if fetching the source requires a Kubernetes client
+func (s *Source) NeedsKubernetes() bool {
+	switch s.Type {
+	case "ConfigMap", "Secret", "Deployment", "StatefulSet", "DaemonSet", "Container", "KnativeService", "Metadata", AutoType:
+		return true
+	default:
+		return false
+	}
+}
+
+// UsesOwnCluster returns true if the source is fetched from its own kubeconfig or kube context
+// instead of the cluster of the execution
+func (s *Source) UsesOwnCluster() bool {
+	return s.Kubeconfig != "" || s.KubeContext != ""
+}
+
+// Validate checks the source's variable mode and transformations so that configuration errors surface
+// before anything is fetched
+func (s *Source) Validate() error {
+	// An unknown mode would silently fall back to unanchored regexes
+	if err := ValidateVariableMode(s.Variables.Mode); err != nil {
+		return err
+	}
+	for i, tc := range s.Transformations {
+		err := transformations.Validate(transformations.Config{
+			Type:   tc.Type,
+			Target: tc.Target,
+			Value:  tc.Value,
+		})
+		if err != nil {
+			return fmt.Errorf("invalid transformation %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
 // GetNamespace returns the namespace, defaulting to "default" if not specified
 func (s *Source) GetNamespace() string {
 	if s.Namespace == "" {
@@ -175,7 +443,15 @@ func (s *Source) GetVolumeMountKeyMapping(kind, name, key string) string {
 	return key
 }
 
+// GetKeyMapping returns the mapped key for a ConfigMap or Secret key, or the original key if no mapping exists
+func (s *Source) GetKeyMapping(key string) string {
+	if newKey, ok := s.KeyMappings[key]; ok {
+		return newKey
+	}
+	return key
+}
+
 // Fetcher is the interface that all source types must implement
 type Fetcher interface {
-	Fetch(clientset *kubernetes.Clientset, source Source, outputDirectory string) ([]EnvEntry, error)
+	Fetch(ctx context.Context, clientset kubernetes.Interface, source Source, outputDirectory string) ([]EnvEntry, error)
 }
@@ -0,0 +1,298 @@
+package sources
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestShouldExcludeEntryByValue(t *testing.T) {
+	source := Source{
+		Variables: SourceVariables{
+			Exclude:       []string{"DEBUG"},
+			ExcludeValues: []string{"^CHANGEME$"},
+		},
+	}
+
+	tests := []struct {
+		key, value string
+		excluded   bool
+	}{
+		{"DATABASE_PASSWORD", "CHANGEME", true},
+		{"DATABASE_HOST", "db.internal", false},
+		{"DATABASE_USER", "CHANGEME_TOO", false},
+		{"DEBUG", "true", true},
+	}
+	for _, tc := range tests {
+		if got := source.ShouldExcludeEntry(tc.key, tc.value); got != tc.excluded {
+			t.Errorf("%s=%s: expected excluded=%v, got %v", tc.key, tc.value, tc.excluded, got)
+		}
+	}
+
+	// Include patterns keep only matching values
+	source = Source{Variables: SourceVariables{IncludeValues: []string{`^https://`}}}
+	if source.ShouldExcludeEntry("API_URL", "https://api.example.com") {
+		t.Error("expected https value to be included")
+	}
+	if !source.ShouldExcludeEntry("API_URL", "http://api.example.com") {
+		t.Error("expected http value to be excluded")
+	}
+}
+
+func TestShouldExcludeVariableModes(t *testing.T) {
+	tests := []struct {
+		mode     string
+		pattern  string
+		key      string
+		excluded bool
+	}{
+		// The default mode matches regexes anywhere in the name
+		{"", "DB", "DBG_LEVEL", true},
+		{VariableModeExact, "DB", "DB", true},
+		{VariableModeExact, "DB", "DBG_LEVEL", false},
+		{VariableModeExact, "^DB", "DB_HOST", false},
+		{VariableModeRegex, "DB", "DB", true},
+		{VariableModeRegex, "DB", "DBG_LEVEL", false},
+		{VariableModeRegex, "DB_.*", "DB_HOST", true},
+		{VariableModeRegex, "HOST|PORT", "DB_PORT", false},
+		{VariableModeRegex, ".*DB.*", "DBG_LEVEL", true},
+		{VariableModeGlob, "DB_*", "DB_HOST", true},
+		{VariableModeGlob, "DB_*", "DBG_LEVEL", false},
+		{VariableModeGlob, "DB_?", "DB_1", true},
+		{VariableModeGlob, "DB.*", "DB_HOST", false},
+	}
+	for _, tc := range tests {
+		source := Source{Variables: SourceVariables{Mode: tc.mode, Exclude: []string{tc.pattern}}}
+		if got := source.ShouldExcludeVariable(tc.key); got != tc.excluded {
+			t.Errorf("mode %q, pattern %q, key %s: expected excluded=%v, got %v", tc.mode, tc.pattern, tc.key, tc.excluded, got)
+		}
+	}
+
+	// Globs match values across slashes
+	source := Source{Variables: SourceVariables{Mode: VariableModeGlob, IncludeValues: []string{"https://*"}}}
+	if source.ShouldExcludeEntry("API_URL", "https://api.example.com/v1") {
+		t.Error("expected the https value to be included")
+	}
+}
+
+func TestValidateRejectsUnknownVariableMode(t *testing.T) {
+	source := Source{Type: "ConfigMap", Name: "app", Variables: SourceVariables{Mode: "rgex", Exclude: []string{"DB"}}}
+	if err := source.Validate(); err == nil || !strings.Contains(err.Error(), `got "rgex"`) {
+		t.Errorf("expected an error for the unknown mode, got %v", err)
+	}
+
+	source.Variables.Mode = VariableModeRegex
+	if err := source.Validate(); err != nil {
+		t.Errorf("expected regex mode to be valid, got %v", err)
+	}
+}
+
+func TestShouldExcludeVariableCommandLine(t *testing.T) {
+	source := Source{Variables: SourceVariables{
+		Mode:        VariableModeExact,
+		Exclude:     []string{"DEBUG"},
+		CommandLine: &SourceVariables{Include: []string{"^DB_"}},
+	}}
+
+	// A variable must pass both the source's patterns and the command line patterns
+	tests := map[string]bool{"DB_HOST": false, "HOST": true, "DEBUG": true}
+	for name, excluded := range tests {
+		if got := source.ShouldExcludeVariable(name); got != excluded {
+			t.Errorf("%s: expected excluded=%v, got %v", name, excluded, got)
+		}
+	}
+}
+
+func TestShouldExcludeVariableCaseInsensitive(t *testing.T) {
+	tests := []struct {
+		name     string
+		vars     SourceVariables
+		key      string
+		excluded bool
+	}{
+		{"exact exclude", SourceVariables{CaseInsensitive: true, Exclude: []string{"DB_PASSWORD"}}, "Db_Password", true},
+		{"regex exclude", SourceVariables{CaseInsensitive: true, Exclude: []string{"^db_"}}, "DB_HOST", true},
+		{"exact include", SourceVariables{CaseInsensitive: true, Include: []string{"APP_NAME"}}, "app_name", false},
+		{"regex include", SourceVariables{CaseInsensitive: true, Include: []string{"^APP_"}}, "App_Env", false},
+		{"include misses", SourceVariables{CaseInsensitive: true, Include: []string{"^APP_"}}, "DB_HOST", true},
+		{"exact mode", SourceVariables{CaseInsensitive: true, Mode: VariableModeExact, Exclude: []string{"DB_PASSWORD"}}, "db_password", true},
+		{"glob mode", SourceVariables{CaseInsensitive: true, Mode: VariableModeGlob, Exclude: []string{"*_secret"}}, "API_SECRET", true},
+		{"case-sensitive by default", SourceVariables{Exclude: []string{"DB_PASSWORD"}}, "Db_Password", false},
+		{"case-sensitive include", SourceVariables{Include: []string{"^APP_"}}, "app_name", true},
+	}
+	for _, tc := range tests {
+		source := Source{Variables: tc.vars}
+		if got := source.ShouldExcludeVariable(tc.key); got != tc.excluded {
+			t.Errorf("%s: expected excluded=%v for %s, got %v", tc.name, tc.excluded, tc.key, got)
+		}
+	}
+}
+
+func TestGlobAndRegexModes(t *testing.T) {
+	keys := []string{"DB_SECRET", "API_SECRET", "SECRET_KEY", "DB_HOST", "DB_PORT", "LOG_LEVEL"}
+
+	tests := []struct {
+		name     string
+		glob     string
+		regex    string
+		expected []string
+	}{
+		{"suffix", "*_SECRET", ".*_SECRET", []string{"DB_SECRET", "API_SECRET"}},
+		{"prefix", "DB_*", "DB_.*", []string{"DB_SECRET", "DB_HOST", "DB_PORT"}},
+		{"single character", "DB_????", "DB_.{4}", []string{"DB_HOST", "DB_PORT"}},
+		{"character class", "[AL]*", "[AL].*", []string{"API_SECRET", "LOG_LEVEL"}},
+		{"negated class", "[^D]*_SECRET", "[^D].*_SECRET", []string{"API_SECRET"}},
+		{"exact name", "LOG_LEVEL", "LOG_LEVEL", []string{"LOG_LEVEL"}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			included := func(mode, pattern string) []string {
+				source := Source{Variables: SourceVariables{Mode: mode, Include: []string{pattern}}}
+				var result []string
+				for _, key := range keys {
+					if !source.ShouldExcludeVariable(key) {
+						result = append(result, key)
+					}
+				}
+				return result
+			}
+
+			if got := included(VariableModeGlob, tc.glob); !slices.Equal(got, tc.expected) {
+				t.Errorf("glob %q: expected %v, got %v", tc.glob, tc.expected, got)
+			}
+			if got := included(VariableModeRegex, tc.regex); !slices.Equal(got, tc.expected) {
+				t.Errorf("regex %q: expected %v, got %v", tc.regex, tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestGlobToRegexEscapesRegexCharacters(t *testing.T) {
+	source := Source{Variables: SourceVariables{Mode: VariableModeGlob, IncludeValues: []string{`v1.2+*`, `\*literal`}}}
+	tests := []struct {
+		value    string
+		excluded bool
+	}{
+		{"v1.2+build", false},
+		{"v1x2+build", true},
+		{"*literal", false},
+		{"aliteral", true},
+	}
+	for _, tc := range tests {
+		if got := source.ShouldExcludeEntry("VERSION", tc.value); got != tc.excluded {
+			t.Errorf("%s: expected excluded=%v, got %v", tc.value, tc.excluded, got)
+		}
+	}
+}
+
+func TestConfigMapFetcherExcludesPlaceholderValues(t *testing.T) {
+	clientset := fake.NewClientset(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "app-config", Namespace: "default"},
+		Data: map[string]string{
+			"API_KEY":  "CHANGEME",
+			"API_URL":  "https://api.example.com",
+			"PASSWORD": "CHANGEME",
+		},
+	})
+
+	source := Source{
+		Type:      "ConfigMap",
+		Name:      "app-config",
+		Variables: SourceVariables{ExcludeValues: []string{"CHANGEME"}},
+	}
+	entries, err := (&ConfigMapFetcher{}).Fetch(context.Background(), clientset, source, t.TempDir())
+	if err != nil {
+		t.Fatalf("Fetch returned error: %v", err)
+	}
+
+	if len(entries) != 1 || entries[0].Key != "API_URL" {
+		t.Errorf("expected only API_URL to remain, got %+v", entries)
+	}
+}
+
+func TestConfigMapFetcherIncludeEmpty(t *testing.T) {
+	clientset := fake.NewClientset(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "app-config", Namespace: "default"},
+		Data: map[string]string{
+			"FEATURE_FLAGS": "",
+			"LOG_LEVEL":     "info",
+		},
+	})
+
+	source := Source{Type: "ConfigMap", Name: "app-config"}
+	entries, err := (&ConfigMapFetcher{}).Fetch(context.Background(), clientset, source, t.TempDir())
+	if err != nil {
+		t.Fatalf("Fetch returned error: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Key != "LOG_LEVEL" {
+		t.Errorf("expected the empty value to be skipped by default, got %+v", entries)
+	}
+
+	source.IncludeEmpty = true
+	entries, err = (&ConfigMapFetcher{}).Fetch(context.Background(), clientset, source, t.TempDir())
+	if err != nil {
+		t.Fatalf("Fetch returned error: %v", err)
+	}
+	values := make(map[string]string)
+	for _, entry := range entries {
+		values[entry.Key] = entry.Value
+	}
+	if value, ok := values["FEATURE_FLAGS"]; !ok || value != "" || len(entries) != 2 {
+		t.Errorf("expected FEATURE_FLAGS to be kept with an empty value, got %+v", entries)
+	}
+}
+
+func TestOptionalSourceNotFound(t *testing.T) {
+	var warned bytes.Buffer
+	defer func(w io.Writer) { warnings = w }(warnings)
+	warnings = &warned
+
+	clientset := fake.NewClientset()
+	fetchers := map[string]Fetcher{
+		"ConfigMap":  &ConfigMapFetcher{},
+		"Secret":     &SecretFetcher{},
+		"Deployment": &DeploymentFetcher{},
+	}
+
+	for sourceType, fetcher := range fetchers {
+		t.Run(sourceType, func(t *testing.T) {
+			source := Source{Type: sourceType, Name: "missing"}
+			if _, err := fetcher.Fetch(context.Background(), clientset, source, t.TempDir()); err == nil {
+				t.Error("expected an error for a missing source")
+			}
+
+			warned.Reset()
+			source.Optional = true
+			entries, err := fetcher.Fetch(context.Background(), clientset, source, t.TempDir())
+			if err != nil || len(entries) != 0 {
+				t.Errorf("expected no entries and no error for a missing optional source, got %v, %v", entries, err)
+			}
+			if !strings.Contains(warned.String(), "default/missing not found") {
+				t.Errorf("expected a warning, got %q", warned.String())
+			}
+		})
+	}
+}
+
+func TestQuietSilencesOptionalSourceWarning(t *testing.T) {
+	var warned bytes.Buffer
+	defer func(w io.Writer) { warnings = w }(warnings)
+	warnings = &warned
+	SetQuiet(true)
+	defer SetQuiet(false)
+
+	source := Source{Type: "ConfigMap", Name: "missing", Optional: true}
+	if _, err := (&ConfigMapFetcher{}).Fetch(context.Background(), fake.NewClientset(), source, t.TempDir()); err != nil {
+		t.Fatalf("expected no error for a missing optional source: %v", err)
+	}
+	if warned.Len() != 0 {
+		t.Errorf("expected no warning with quiet, got %q", warned.String())
+	}
+}
@@ -1,6 +1,10 @@
 package sources
 
 import (
+	"context"
+	"fmt"
+	"os"
+
 	"enver/transformations"
 
 	"k8s.io/client-go/kubernetes"
@@ -8,7 +12,7 @@ import (
 
 type VarsFetcher struct{}
 
-func (f *VarsFetcher) Fetch(clientset *kubernetes.Clientset, source Source, outputDirectory string) ([]EnvEntry, error) {
+func (f *VarsFetcher) Fetch(ctx context.Context, clientset kubernetes.Interface, source Source, outputDirectory string) ([]EnvEntry, error) {
 	// Convert transformation configs
 	var transformConfigs []transformations.Config
 	for _, tc := range source.Transformations {
@@ -20,6 +24,7 @@ func (f *VarsFetcher) Fetch(clientset *kubernetes.Clientset, source Source, outp
 			Output:        tc.Output,
 			Key:           tc.Key,
 			BaseDirectory: outputDirectory,
+			Mode:          fileMode(ctx, false),
 		})
 	}
 
@@ -29,12 +34,25 @@ func (f *VarsFetcher) Fetch(clientset *kubernetes.Clientset, source Source, outp
 			continue
 		}
 
-		if source.ShouldExcludeVariable(v.Name) {
+		value := v.Value
+		if value == "" && v.ValueFromEnv != "" {
+			envValue, ok := os.LookupEnv(v.ValueFromEnv)
+			switch {
+			case ok:
+				value = envValue
+			case v.Default != nil:
+				value = *v.Default
+			default:
+				return nil, fmt.Errorf("environment variable %s for %s is not set and has no default", v.ValueFromEnv, v.Name)
+			}
+		}
+
+		if source.ShouldExcludeEntry(v.Name, value) {
 			continue
 		}
 
 		// Apply transformations
-		transformedKey, transformedValue, err := transformations.ApplyTransformations(v.Name, v.Value, transformConfigs)
+		transformedKey, transformedValue, err := transformations.ApplyTransformations(v.Name, value, transformConfigs)
 		if err != nil {
 			return nil, err
 		}
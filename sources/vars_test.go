@@ -0,0 +1,55 @@
+package sources
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestVarsFetcherValueFromEnv(t *testing.T) {
+	defaultHost := "localhost"
+	source := Source{
+		Type: "Vars",
+		Name: "ci",
+		Vars: []VarEntry{
+			{Name: "API_TOKEN", ValueFromEnv: "ENVER_TEST_API_TOKEN"},
+			{Name: "DB_HOST", ValueFromEnv: "ENVER_TEST_DB_HOST", Default: &defaultHost},
+			{Name: "LITERAL", Value: "kept", ValueFromEnv: "ENVER_TEST_API_TOKEN"},
+		},
+	}
+	fetch := func() (map[string]string, error) {
+		entries, err := (&VarsFetcher{}).Fetch(context.Background(), nil, source, t.TempDir())
+		values := make(map[string]string)
+		for _, entry := range entries {
+			values[entry.Key] = entry.Value
+		}
+		return values, err
+	}
+
+	t.Setenv("ENVER_TEST_API_TOKEN", "token-from-ci")
+	t.Setenv("ENVER_TEST_DB_HOST", "db.internal")
+	values, err := fetch()
+	if err != nil {
+		t.Fatalf("Fetch returned error: %v", err)
+	}
+	if values["API_TOKEN"] != "token-from-ci" || values["DB_HOST"] != "db.internal" || values["LITERAL"] != "kept" {
+		t.Errorf("expected the values from the environment, got %v", values)
+	}
+
+	// An unset variable falls back to the default
+	os.Unsetenv("ENVER_TEST_DB_HOST")
+	values, err = fetch()
+	if err != nil {
+		t.Fatalf("Fetch returned error: %v", err)
+	}
+	if values["DB_HOST"] != "localhost" {
+		t.Errorf("expected the default, got %q", values["DB_HOST"])
+	}
+
+	// Without a default an unset variable is an error
+	os.Unsetenv("ENVER_TEST_API_TOKEN")
+	if _, err := fetch(); err == nil || !strings.Contains(err.Error(), "ENVER_TEST_API_TOKEN for API_TOKEN is not set") {
+		t.Errorf("expected an error naming the unset variable, got %v", err)
+	}
+}
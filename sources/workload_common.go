@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"path/filepath"
+	"slices"
 	"strings"
 
 	"enver/transformations"
@@ -13,11 +14,29 @@ import (
 	"k8s.io/client-go/kubernetes"
 )
 
+// How the variables of multiple containers in a workload are combined
+const (
+	ContainerMergeKeepAll  = "keep-all"  // write every container's variables, even when keys repeat (default)
+	ContainerMergeLastWins = "last-wins" // keep only the last container's value for a repeated key
+	ContainerMergePrefix   = "prefix"    // prefix every key with its container name
+)
+
 // WorkloadProcessor handles common logic for processing container specs from Deployments, StatefulSets, and DaemonSets
 type WorkloadProcessor struct{}
 
 // ProcessPodSpec processes containers from a PodSpec and returns environment entries
-func (p *WorkloadProcessor) ProcessPodSpec(clientset *kubernetes.Clientset, podSpec corev1.PodSpec, source Source, workloadName, workloadType, namespace, outputDirectory string) ([]EnvEntry, error) {
+func (p *WorkloadProcessor) ProcessPodSpec(ctx context.Context, clientset kubernetes.Interface, podSpec corev1.PodSpec, source Source, workloadName, workloadType, namespace, outputDirectory string) ([]EnvEntry, error) {
+	return p.processPodSpec(ctx, clientset, podSpec, nil, source, workloadName, workloadType, namespace, outputDirectory)
+}
+
+// ProcessPod processes the containers of a running pod. Unlike ProcessPodSpec it can resolve
+// field references such as status.podIP from the pod's metadata and status.
+func (p *WorkloadProcessor) ProcessPod(ctx context.Context, clientset kubernetes.Interface, pod *corev1.Pod, source Source, workloadType, outputDirectory string) ([]EnvEntry, error) {
+	return p.processPodSpec(ctx, clientset, pod.Spec, pod, source, pod.Name, workloadType, pod.Namespace, outputDirectory)
+}
+
+// processPodSpec processes the containers of podSpec; pod is nil when there is no running pod to resolve field references from
+func (p *WorkloadProcessor) processPodSpec(ctx context.Context, clientset kubernetes.Interface, podSpec corev1.PodSpec, pod *corev1.Pod, source Source, workloadName, workloadType, namespace, outputDirectory string) ([]EnvEntry, error) {
 	// Convert transformation configs
 	var transformConfigs []transformations.Config
 	for _, tc := range source.Transformations {
@@ -29,24 +48,16 @@ func (p *WorkloadProcessor) ProcessPodSpec(clientset *kubernetes.Clientset, podS
 			Output:        tc.Output,
 			Key:           tc.Key,
 			BaseDirectory: outputDirectory,
+			Mode:          fileMode(ctx, false),
 		})
 	}
 
-	// Build set of container names to include
-	containerFilter := make(map[string]bool)
-	for _, name := range source.Containers {
-		containerFilter[name] = true
-	}
-	filterContainers := len(containerFilter) > 0
-
 	var entries []EnvEntry
 
 	// Process each container
-	for _, container := range podSpec.Containers {
-		// Skip if container is not in the filter list
-		if filterContainers && !containerFilter[container.Name] {
-			continue
-		}
+	for _, container := range podContainers(podSpec, pod, source) {
+		// Entries from envFrom and env, deduplicated below
+		var containerEntries []EnvEntry
 
 		// Process envFrom entries first (env entries take priority and come after)
 		for _, envFrom := range container.EnvFrom {
@@ -54,7 +65,7 @@ func (p *WorkloadProcessor) ProcessPodSpec(clientset *kubernetes.Clientset, podS
 			var err error
 
 			if envFrom.ConfigMapRef != nil {
-				envEntries, err = p.fetchFromConfigMap(clientset, namespace, envFrom.ConfigMapRef.Name, envFrom.Prefix, source, workloadName, workloadType, transformConfigs)
+				envEntries, err = p.fetchFromConfigMap(ctx, clientset, namespace, envFrom.ConfigMapRef.Name, envFrom.Prefix, source, workloadName, workloadType, transformConfigs)
 				if err != nil {
 					// Check if optional
 					if envFrom.ConfigMapRef.Optional != nil && *envFrom.ConfigMapRef.Optional {
@@ -63,7 +74,7 @@ func (p *WorkloadProcessor) ProcessPodSpec(clientset *kubernetes.Clientset, podS
 					return nil, err
 				}
 			} else if envFrom.SecretRef != nil {
-				envEntries, err = p.fetchFromSecret(clientset, namespace, envFrom.SecretRef.Name, envFrom.Prefix, source, workloadName, workloadType, transformConfigs)
+				envEntries, err = p.fetchFromSecret(ctx, clientset, namespace, envFrom.SecretRef.Name, envFrom.Prefix, source, workloadName, workloadType, transformConfigs)
 				if err != nil {
 					// Check if optional
 					if envFrom.SecretRef.Optional != nil && *envFrom.SecretRef.Optional {
@@ -73,7 +84,7 @@ func (p *WorkloadProcessor) ProcessPodSpec(clientset *kubernetes.Clientset, podS
 				}
 			}
 
-			entries = append(entries, envEntries...)
+			containerEntries = append(containerEntries, envEntries...)
 		}
 
 		// Process env entries (these take priority over envFrom, so they come last)
@@ -87,45 +98,102 @@ func (p *WorkloadProcessor) ProcessPodSpec(clientset *kubernetes.Clientset, podS
 			} else if envVar.ValueFrom != nil {
 				// Value from reference
 				var err error
-				value, err = p.resolveValueFrom(clientset, namespace, envVar.ValueFrom)
+				value, err = p.resolveValueFrom(ctx, clientset, namespace, pod, envVar.ValueFrom)
 				if err != nil {
 					return nil, fmt.Errorf("failed to resolve env var %s: %w", key, err)
 				}
 			}
 
-			if value != "" && !source.ShouldExcludeVariable(key) {
-				transformedKey, transformedValue, err := transformations.ApplyTransformations(key, value, transformConfigs)
+			if source.keepValue(ctx, key, value) {
+				fromSecret := envVar.ValueFrom != nil && envVar.ValueFrom.SecretKeyRef != nil
+				valueConfigs := transformConfigs
+				if fromSecret {
+					valueConfigs = secretFileMode(ctx, transformConfigs)
+				}
+				transformedKey, transformedValue, err := transformations.ApplyTransformations(key, value, valueConfigs)
 				if err != nil {
 					return nil, fmt.Errorf("failed to apply transformation: %w", err)
 				}
 
-				entries = append(entries, EnvEntry{
+				containerEntries = append(containerEntries, EnvEntry{
 					Key:        transformedKey,
 					Value:      transformedValue,
 					SourceType: workloadType,
-					Name:       fmt.Sprintf("%s/%s", workloadName, container.Name),
+					Name:       fmt.Sprintf("%s/%s", workloadName, container.label),
 					Namespace:  namespace,
+					FromSecret: fromSecret,
 				})
 			}
 		}
 
+		// Like Kubernetes, a later envFrom overrides an earlier one and env overrides all envFrom
+		containerEntries = keepLastPerKey(containerEntries)
+
 		// Process volumeMounts that reference ConfigMaps or Secrets
 		for _, volumeMount := range container.VolumeMounts {
-			volumeEntries, err := p.processVolumeMount(clientset, namespace, volumeMount, podSpec.Volumes, source, workloadName, workloadType, transformConfigs, outputDirectory)
+			volumeEntries, err := p.processVolumeMount(ctx, clientset, namespace, volumeMount, podSpec.Volumes, source, workloadName, workloadType, transformConfigs, outputDirectory)
 			if err != nil {
 				return nil, err
 			}
-			entries = append(entries, volumeEntries...)
+			containerEntries = append(containerEntries, volumeEntries...)
+		}
+
+		if source.MergeContainers == ContainerMergePrefix {
+			prefix := containerKeyPrefix(container.Name)
+			for i := range containerEntries {
+				containerEntries[i].Key = prefix + containerEntries[i].Key
+			}
 		}
+		entries = append(entries, containerEntries...)
+	}
+
+	if source.MergeContainers == ContainerMergeLastWins {
+		entries = keepLastPerKey(entries)
 	}
 
 	return entries, nil
 }
 
-func (p *WorkloadProcessor) resolveValueFrom(clientset *kubernetes.Clientset, namespace string, valueFrom *corev1.EnvVarSource) (string, error) {
+// podContainer is a container to read the environment of, with the name its entries are labelled with
+type podContainer struct {
+	corev1.Container
+	label string // the container name, followed by (ephemeral) for ephemeral containers
+}
+
+// podContainers returns the containers of podSpec that pass the source's container filter. Ephemeral
+// containers are appended with includeEphemeralContainers when there is a live pod, as workload
+// templates can't define them.
+func podContainers(podSpec corev1.PodSpec, pod *corev1.Pod, source Source) []podContainer {
+	var containers []podContainer
+	include := func(container corev1.Container, label string) {
+		if len(source.Containers) > 0 && !slices.Contains(source.Containers, container.Name) {
+			return
+		}
+		containers = append(containers, podContainer{Container: container, label: label})
+	}
+
+	for _, container := range podSpec.Containers {
+		include(container, container.Name)
+	}
+	if source.IncludeEphemeralContainers && pod != nil {
+		for _, ephemeral := range podSpec.EphemeralContainers {
+			include(corev1.Container(ephemeral.EphemeralContainerCommon), ephemeral.Name+" (ephemeral)")
+		}
+	}
+	return containers
+}
+
+// containerKeyPrefix returns the key prefix for a container's variables, e.g. LOG_SHIPPER_ for log-shipper
+func containerKeyPrefix(containerName string) string {
+	return (&transformations.SanitizeKey{}).Transform(strings.ToUpper(containerName)) + "_"
+}
+
+func (p *WorkloadProcessor) resolveValueFrom(ctx context.Context, clientset kubernetes.Interface, namespace string, pod *corev1.Pod, valueFrom *corev1.EnvVarSource) (string, error) {
 	if valueFrom.ConfigMapKeyRef != nil {
 		ref := valueFrom.ConfigMapKeyRef
-		cm, err := clientset.CoreV1().ConfigMaps(namespace).Get(context.Background(), ref.Name, metav1.GetOptions{})
+		cm, err := withRetry(ctx, func() (*corev1.ConfigMap, error) {
+			return clientset.CoreV1().ConfigMaps(namespace).Get(ctx, ref.Name, metav1.GetOptions{})
+		})
 		if err != nil {
 			if ref.Optional != nil && *ref.Optional {
 				return "", nil
@@ -137,7 +205,9 @@ func (p *WorkloadProcessor) resolveValueFrom(clientset *kubernetes.Clientset, na
 
 	if valueFrom.SecretKeyRef != nil {
 		ref := valueFrom.SecretKeyRef
-		secret, err := clientset.CoreV1().Secrets(namespace).Get(context.Background(), ref.Name, metav1.GetOptions{})
+		secret, err := withRetry(ctx, func() (*corev1.Secret, error) {
+			return clientset.CoreV1().Secrets(namespace).Get(ctx, ref.Name, metav1.GetOptions{})
+		})
 		if err != nil {
 			if ref.Optional != nil && *ref.Optional {
 				return "", nil
@@ -150,7 +220,10 @@ func (p *WorkloadProcessor) resolveValueFrom(clientset *kubernetes.Clientset, na
 
 	if valueFrom.FieldRef != nil {
 		// Field references (like metadata.name) cannot be resolved without pod context
-		return "", nil
+		if pod == nil {
+			return "", nil
+		}
+		return resolveFieldRef(pod, valueFrom.FieldRef.FieldPath)
 	}
 
 	if valueFrom.ResourceFieldRef != nil {
@@ -161,8 +234,75 @@ func (p *WorkloadProcessor) resolveValueFrom(clientset *kubernetes.Clientset, na
 	return "", nil
 }
 
-func (p *WorkloadProcessor) fetchFromConfigMap(clientset *kubernetes.Clientset, namespace, name, prefix string, source Source, workloadName, workloadType string, transformConfigs []transformations.Config) ([]EnvEntry, error) {
-	cm, err := clientset.CoreV1().ConfigMaps(namespace).Get(context.Background(), name, metav1.GetOptions{})
+// resolveFieldRef returns the value of a downward API field path such as metadata.name or status.podIP
+func resolveFieldRef(pod *corev1.Pod, fieldPath string) (string, error) {
+	if key, ok := fieldPathKey(fieldPath, "metadata.labels"); ok {
+		return pod.Labels[key], nil
+	}
+	if key, ok := fieldPathKey(fieldPath, "metadata.annotations"); ok {
+		return pod.Annotations[key], nil
+	}
+
+	switch fieldPath {
+	case "metadata.name":
+		return pod.Name, nil
+	case "metadata.namespace":
+		return pod.Namespace, nil
+	case "metadata.uid":
+		return string(pod.UID), nil
+	case "spec.nodeName":
+		return pod.Spec.NodeName, nil
+	case "spec.serviceAccountName":
+		return pod.Spec.ServiceAccountName, nil
+	case "status.hostIP":
+		return pod.Status.HostIP, nil
+	case "status.hostIPs":
+		var ips []string
+		for _, ip := range pod.Status.HostIPs {
+			ips = append(ips, ip.IP)
+		}
+		return strings.Join(ips, ","), nil
+	case "status.podIP":
+		return pod.Status.PodIP, nil
+	case "status.podIPs":
+		var ips []string
+		for _, ip := range pod.Status.PodIPs {
+			ips = append(ips, ip.IP)
+		}
+		return strings.Join(ips, ","), nil
+	default:
+		return "", fmt.Errorf("unsupported field path %q", fieldPath)
+	}
+}
+
+// keepLastPerKey drops every entry whose key occurs again later, keeping the order of the remaining entries
+func keepLastPerKey(entries []EnvEntry) []EnvEntry {
+	last := make(map[string]int, len(entries))
+	for i, entry := range entries {
+		last[entry.Key] = i
+	}
+
+	var result []EnvEntry
+	for i, entry := range entries {
+		if last[entry.Key] == i {
+			result = append(result, entry)
+		}
+	}
+	return result
+}
+
+// fieldPathKey extracts the key from a field path such as metadata.labels['app']
+func fieldPathKey(fieldPath, prefix string) (string, bool) {
+	if !strings.HasPrefix(fieldPath, prefix+"['") || !strings.HasSuffix(fieldPath, "']") {
+		return "", false
+	}
+	return fieldPath[len(prefix)+2 : len(fieldPath)-2], true
+}
+
+func (p *WorkloadProcessor) fetchFromConfigMap(ctx context.Context, clientset kubernetes.Interface, namespace, name, prefix string, source Source, workloadName, workloadType string, transformConfigs []transformations.Config) ([]EnvEntry, error) {
+	cm, err := withRetry(ctx, func() (*corev1.ConfigMap, error) {
+		return clientset.CoreV1().ConfigMaps(namespace).Get(ctx, name, metav1.GetOptions{})
+	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to get configmap %s/%s: %w", namespace, name, err)
 	}
@@ -170,7 +310,7 @@ func (p *WorkloadProcessor) fetchFromConfigMap(clientset *kubernetes.Clientset,
 	var entries []EnvEntry
 	for key, value := range cm.Data {
 		envKey := prefix + key
-		if value != "" && !source.ShouldExcludeVariable(envKey) {
+		if source.keepValue(ctx, envKey, value) {
 			transformedKey, transformedValue, err := transformations.ApplyTransformations(envKey, value, transformConfigs)
 			if err != nil {
 				return nil, fmt.Errorf("failed to apply transformation: %w", err)
@@ -189,8 +329,23 @@ func (p *WorkloadProcessor) fetchFromConfigMap(clientset *kubernetes.Clientset,
 	return entries, nil
 }
 
-func (p *WorkloadProcessor) fetchFromSecret(clientset *kubernetes.Clientset, namespace, name, prefix string, source Source, workloadName, workloadType string, transformConfigs []transformations.Config) ([]EnvEntry, error) {
-	secret, err := clientset.CoreV1().Secrets(namespace).Get(context.Background(), name, metav1.GetOptions{})
+// secretFileMode returns a copy of configs whose file transformations write files with the
+// permissions for values from a Secret
+func secretFileMode(ctx context.Context, configs []transformations.Config) []transformations.Config {
+	secretConfigs := make([]transformations.Config, len(configs))
+	for i, cfg := range configs {
+		cfg.Mode = fileMode(ctx, true)
+		secretConfigs[i] = cfg
+	}
+	return secretConfigs
+}
+
+func (p *WorkloadProcessor) fetchFromSecret(ctx context.Context, clientset kubernetes.Interface, namespace, name, prefix string, source Source, workloadName, workloadType string, transformConfigs []transformations.Config) ([]EnvEntry, error) {
+	transformConfigs = secretFileMode(ctx, transformConfigs)
+
+	secret, err := withRetry(ctx, func() (*corev1.Secret, error) {
+		return clientset.CoreV1().Secrets(namespace).Get(ctx, name, metav1.GetOptions{})
+	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to get secret %s/%s: %w", namespace, name, err)
 	}
@@ -199,7 +354,7 @@ func (p *WorkloadProcessor) fetchFromSecret(clientset *kubernetes.Clientset, nam
 	for key, value := range secret.Data {
 		envKey := prefix + key
 		strValue := strings.TrimRight(string(value), "\n\r")
-		if strValue != "" && !source.ShouldExcludeVariable(envKey) {
+		if source.keepValue(ctx, envKey, strValue) {
 			transformedKey, transformedValue, err := transformations.ApplyTransformations(envKey, strValue, transformConfigs)
 			if err != nil {
 				return nil, fmt.Errorf("failed to apply transformation: %w", err)
@@ -211,6 +366,7 @@ func (p *WorkloadProcessor) fetchFromSecret(clientset *kubernetes.Clientset, nam
 				SourceType: workloadType,
 				Name:       fmt.Sprintf("%s (Secret: %s)", workloadName, name),
 				Namespace:  namespace,
+				FromSecret: true,
 			})
 		}
 	}
@@ -218,7 +374,7 @@ func (p *WorkloadProcessor) fetchFromSecret(clientset *kubernetes.Clientset, nam
 	return entries, nil
 }
 
-func (p *WorkloadProcessor) processVolumeMount(clientset *kubernetes.Clientset, namespace string, volumeMount corev1.VolumeMount, volumes []corev1.Volume, source Source, workloadName, workloadType string, transformConfigs []transformations.Config, outputDirectory string) ([]EnvEntry, error) {
+func (p *WorkloadProcessor) processVolumeMount(ctx context.Context, clientset kubernetes.Interface, namespace string, volumeMount corev1.VolumeMount, volumes []corev1.Volume, source Source, workloadName, workloadType string, transformConfigs []transformations.Config, outputDirectory string) ([]EnvEntry, error) {
 	// Find the volume that matches this volumeMount
 	var volume *corev1.Volume
 	for i := range volumes {
@@ -236,7 +392,7 @@ func (p *WorkloadProcessor) processVolumeMount(clientset *kubernetes.Clientset,
 
 	// Handle ConfigMap volume
 	if volume.ConfigMap != nil {
-		cmEntries, err := p.processConfigMapVolume(clientset, namespace, volume.ConfigMap, volumeMount, source, workloadName, workloadType, transformConfigs, outputDirectory)
+		cmEntries, err := p.processConfigMapVolume(ctx, clientset, namespace, volume.ConfigMap, volumeMount, source, workloadName, workloadType, transformConfigs, outputDirectory)
 		if err != nil {
 			if volume.ConfigMap.Optional != nil && *volume.ConfigMap.Optional {
 				return nil, nil
@@ -248,7 +404,7 @@ func (p *WorkloadProcessor) processVolumeMount(clientset *kubernetes.Clientset,
 
 	// Handle Secret volume
 	if volume.Secret != nil {
-		secretEntries, err := p.processSecretVolume(clientset, namespace, volume.Secret, volumeMount, source, workloadName, workloadType, transformConfigs, outputDirectory)
+		secretEntries, err := p.processSecretVolume(ctx, clientset, namespace, volume.Secret, volumeMount, source, workloadName, workloadType, transformConfigs, outputDirectory)
 		if err != nil {
 			if volume.Secret.Optional != nil && *volume.Secret.Optional {
 				return nil, nil
@@ -262,7 +418,7 @@ func (p *WorkloadProcessor) processVolumeMount(clientset *kubernetes.Clientset,
 	if volume.Projected != nil {
 		for _, projSource := range volume.Projected.Sources {
 			if projSource.ConfigMap != nil {
-				cmEntries, err := p.processProjectedConfigMap(clientset, namespace, projSource.ConfigMap, volumeMount, source, workloadName, workloadType, transformConfigs, outputDirectory)
+				cmEntries, err := p.processProjectedConfigMap(ctx, clientset, namespace, projSource.ConfigMap, volumeMount, source, workloadName, workloadType, transformConfigs, outputDirectory)
 				if err != nil {
 					if projSource.ConfigMap.Optional != nil && *projSource.ConfigMap.Optional {
 						continue
@@ -272,7 +428,7 @@ func (p *WorkloadProcessor) processVolumeMount(clientset *kubernetes.Clientset,
 				entries = append(entries, cmEntries...)
 			}
 			if projSource.Secret != nil {
-				secretEntries, err := p.processProjectedSecret(clientset, namespace, projSource.Secret, volumeMount, source, workloadName, workloadType, transformConfigs, outputDirectory)
+				secretEntries, err := p.processProjectedSecret(ctx, clientset, namespace, projSource.Secret, volumeMount, source, workloadName, workloadType, transformConfigs, outputDirectory)
 				if err != nil {
 					if projSource.Secret.Optional != nil && *projSource.Secret.Optional {
 						continue
@@ -287,8 +443,10 @@ func (p *WorkloadProcessor) processVolumeMount(clientset *kubernetes.Clientset,
 	return entries, nil
 }
 
-func (p *WorkloadProcessor) processConfigMapVolume(clientset *kubernetes.Clientset, namespace string, cmVolume *corev1.ConfigMapVolumeSource, volumeMount corev1.VolumeMount, source Source, workloadName, workloadType string, transformConfigs []transformations.Config, outputDirectory string) ([]EnvEntry, error) {
-	cm, err := clientset.CoreV1().ConfigMaps(namespace).Get(context.Background(), cmVolume.Name, metav1.GetOptions{})
+func (p *WorkloadProcessor) processConfigMapVolume(ctx context.Context, clientset kubernetes.Interface, namespace string, cmVolume *corev1.ConfigMapVolumeSource, volumeMount corev1.VolumeMount, source Source, workloadName, workloadType string, transformConfigs []transformations.Config, outputDirectory string) ([]EnvEntry, error) {
+	cm, err := withRetry(ctx, func() (*corev1.ConfigMap, error) {
+		return clientset.CoreV1().ConfigMaps(namespace).Get(ctx, cmVolume.Name, metav1.GetOptions{})
+	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to get configmap %s/%s: %w", namespace, cmVolume.Name, err)
 	}
@@ -310,7 +468,7 @@ func (p *WorkloadProcessor) processConfigMapVolume(clientset *kubernetes.Clients
 			}
 		}
 
-		if source.ShouldExcludeVariable(key) {
+		if source.ShouldExcludeEntry(key, value) {
 			continue
 		}
 
@@ -330,6 +488,7 @@ func (p *WorkloadProcessor) processConfigMapVolume(clientset *kubernetes.Clients
 			Output:        outputPath,
 			Key:           mappedKey,
 			BaseDirectory: outputDirectory,
+			Mode:          fileMode(ctx, false),
 		})
 
 		transformedKey, transformedValue, err := transformations.ApplyTransformations(key, value, fileTransformConfigs)
@@ -349,8 +508,12 @@ func (p *WorkloadProcessor) processConfigMapVolume(clientset *kubernetes.Clients
 	return entries, nil
 }
 
-func (p *WorkloadProcessor) processSecretVolume(clientset *kubernetes.Clientset, namespace string, secretVolume *corev1.SecretVolumeSource, volumeMount corev1.VolumeMount, source Source, workloadName, workloadType string, transformConfigs []transformations.Config, outputDirectory string) ([]EnvEntry, error) {
-	secret, err := clientset.CoreV1().Secrets(namespace).Get(context.Background(), secretVolume.SecretName, metav1.GetOptions{})
+func (p *WorkloadProcessor) processSecretVolume(ctx context.Context, clientset kubernetes.Interface, namespace string, secretVolume *corev1.SecretVolumeSource, volumeMount corev1.VolumeMount, source Source, workloadName, workloadType string, transformConfigs []transformations.Config, outputDirectory string) ([]EnvEntry, error) {
+	transformConfigs = secretFileMode(ctx, transformConfigs)
+
+	secret, err := withRetry(ctx, func() (*corev1.Secret, error) {
+		return clientset.CoreV1().Secrets(namespace).Get(ctx, secretVolume.SecretName, metav1.GetOptions{})
+	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to get secret %s/%s: %w", namespace, secretVolume.SecretName, err)
 	}
@@ -372,7 +535,7 @@ func (p *WorkloadProcessor) processSecretVolume(clientset *kubernetes.Clientset,
 			}
 		}
 
-		if source.ShouldExcludeVariable(key) {
+		if source.ShouldExcludeEntry(key, string(value)) {
 			continue
 		}
 
@@ -394,6 +557,7 @@ func (p *WorkloadProcessor) processSecretVolume(clientset *kubernetes.Clientset,
 			Output:        outputPath,
 			Key:           mappedKey,
 			BaseDirectory: outputDirectory,
+			Mode:          fileMode(ctx, true),
 		})
 
 		transformedKey, transformedValue, err := transformations.ApplyTransformations(key, strValue, fileTransformConfigs)
@@ -413,8 +577,10 @@ func (p *WorkloadProcessor) processSecretVolume(clientset *kubernetes.Clientset,
 	return entries, nil
 }
 
-func (p *WorkloadProcessor) processProjectedConfigMap(clientset *kubernetes.Clientset, namespace string, cmProjection *corev1.ConfigMapProjection, volumeMount corev1.VolumeMount, source Source, workloadName, workloadType string, transformConfigs []transformations.Config, outputDirectory string) ([]EnvEntry, error) {
-	cm, err := clientset.CoreV1().ConfigMaps(namespace).Get(context.Background(), cmProjection.Name, metav1.GetOptions{})
+func (p *WorkloadProcessor) processProjectedConfigMap(ctx context.Context, clientset kubernetes.Interface, namespace string, cmProjection *corev1.ConfigMapProjection, volumeMount corev1.VolumeMount, source Source, workloadName, workloadType string, transformConfigs []transformations.Config, outputDirectory string) ([]EnvEntry, error) {
+	cm, err := withRetry(ctx, func() (*corev1.ConfigMap, error) {
+		return clientset.CoreV1().ConfigMaps(namespace).Get(ctx, cmProjection.Name, metav1.GetOptions{})
+	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to get configmap %s/%s: %w", namespace, cmProjection.Name, err)
 	}
@@ -436,7 +602,7 @@ func (p *WorkloadProcessor) processProjectedConfigMap(clientset *kubernetes.Clie
 			}
 		}
 
-		if source.ShouldExcludeVariable(key) {
+		if source.ShouldExcludeEntry(key, value) {
 			continue
 		}
 
@@ -456,6 +622,7 @@ func (p *WorkloadProcessor) processProjectedConfigMap(clientset *kubernetes.Clie
 			Output:        outputPath,
 			Key:           mappedKey,
 			BaseDirectory: outputDirectory,
+			Mode:          fileMode(ctx, false),
 		})
 
 		transformedKey, transformedValue, err := transformations.ApplyTransformations(key, value, fileTransformConfigs)
@@ -475,8 +642,12 @@ func (p *WorkloadProcessor) processProjectedConfigMap(clientset *kubernetes.Clie
 	return entries, nil
 }
 
-func (p *WorkloadProcessor) processProjectedSecret(clientset *kubernetes.Clientset, namespace string, secretProjection *corev1.SecretProjection, volumeMount corev1.VolumeMount, source Source, workloadName, workloadType string, transformConfigs []transformations.Config, outputDirectory string) ([]EnvEntry, error) {
-	secret, err := clientset.CoreV1().Secrets(namespace).Get(context.Background(), secretProjection.Name, metav1.GetOptions{})
+func (p *WorkloadProcessor) processProjectedSecret(ctx context.Context, clientset kubernetes.Interface, namespace string, secretProjection *corev1.SecretProjection, volumeMount corev1.VolumeMount, source Source, workloadName, workloadType string, transformConfigs []transformations.Config, outputDirectory string) ([]EnvEntry, error) {
+	transformConfigs = secretFileMode(ctx, transformConfigs)
+
+	secret, err := withRetry(ctx, func() (*corev1.Secret, error) {
+		return clientset.CoreV1().Secrets(namespace).Get(ctx, secretProjection.Name, metav1.GetOptions{})
+	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to get secret %s/%s: %w", namespace, secretProjection.Name, err)
 	}
@@ -498,7 +669,7 @@ func (p *WorkloadProcessor) processProjectedSecret(clientset *kubernetes.Clients
 			}
 		}
 
-		if source.ShouldExcludeVariable(key) {
+		if source.ShouldExcludeEntry(key, string(value)) {
 			continue
 		}
 
@@ -520,6 +691,7 @@ func (p *WorkloadProcessor) processProjectedSecret(clientset *kubernetes.Clients
 			Output:        outputPath,
 			Key:           mappedKey,
 			BaseDirectory: outputDirectory,
+			Mode:          fileMode(ctx, true),
 		})
 
 		transformedKey, transformedValue, err := transformations.ApplyTransformations(key, strValue, fileTransformConfigs)
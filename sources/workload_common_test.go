@@ -0,0 +1,150 @@
+package sources
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestDeploymentFetcherLaterEnvFromOverridesEarlier(t *testing.T) {
+	configMapRef := func(name string) corev1.EnvFromSource {
+		return corev1.EnvFromSource{ConfigMapRef: &corev1.ConfigMapEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: name}}}
+	}
+	clientset := fake.NewClientset(
+		&appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "default"},
+			Spec: appsv1.DeploymentSpec{Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{
+				Containers: []corev1.Container{{
+					Name:    "app",
+					EnvFrom: []corev1.EnvFromSource{configMapRef("defaults"), configMapRef("overrides")},
+					Env:     []corev1.EnvVar{{Name: "LOG_LEVEL", Value: "warn"}},
+				}},
+			}}},
+		},
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "defaults", Namespace: "default"},
+			Data:       map[string]string{"REGION": "eu", "LOG_LEVEL": "info"},
+		},
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "overrides", Namespace: "default"},
+			Data:       map[string]string{"REGION": "us", "LOG_LEVEL": "debug"},
+		},
+	)
+
+	entries, err := (&DeploymentFetcher{}).Fetch(context.Background(), clientset, Source{Type: "Deployment", Name: "app"}, t.TempDir())
+	if err != nil {
+		t.Fatalf("Fetch returned error: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("expected one entry per key, got %+v", entries)
+	}
+
+	values := make(map[string]EnvEntry)
+	for _, entry := range entries {
+		values[entry.Key] = entry
+	}
+	if entry := values["REGION"]; entry.Value != "us" || entry.Name != "app (ConfigMap: overrides)" {
+		t.Errorf("expected REGION from the later envFrom, got %+v", entry)
+	}
+	if entry := values["LOG_LEVEL"]; entry.Value != "warn" || entry.Name != "app/app" {
+		t.Errorf("expected env to override envFrom, got %+v", entry)
+	}
+}
+
+func TestDeploymentFetcherMergeContainers(t *testing.T) {
+	clientset := fake.NewClientset(&appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "default"},
+		Spec: appsv1.DeploymentSpec{Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{
+			Containers: []corev1.Container{
+				{Name: "app", Env: []corev1.EnvVar{{Name: "LOG_LEVEL", Value: "debug"}, {Name: "PORT", Value: "8080"}}},
+				{Name: "log-shipper", Env: []corev1.EnvVar{{Name: "LOG_LEVEL", Value: "warn"}}},
+			},
+		}}},
+	})
+
+	tests := []struct {
+		mergeContainers string
+		expected        []string
+	}{
+		{"", []string{"LOG_LEVEL=debug", "PORT=8080", "LOG_LEVEL=warn"}},
+		{ContainerMergeKeepAll, []string{"LOG_LEVEL=debug", "PORT=8080", "LOG_LEVEL=warn"}},
+		{ContainerMergeLastWins, []string{"PORT=8080", "LOG_LEVEL=warn"}},
+		{ContainerMergePrefix, []string{"APP_LOG_LEVEL=debug", "APP_PORT=8080", "LOG_SHIPPER_LOG_LEVEL=warn"}},
+	}
+	for _, tc := range tests {
+		source := Source{Type: "Deployment", Name: "app", MergeContainers: tc.mergeContainers}
+		entries, err := (&DeploymentFetcher{}).Fetch(context.Background(), clientset, source, t.TempDir())
+		if err != nil {
+			t.Fatalf("%q: Fetch returned error: %v", tc.mergeContainers, err)
+		}
+
+		var got []string
+		for _, entry := range entries {
+			got = append(got, entry.Key+"="+entry.Value)
+		}
+		if !slices.Equal(got, tc.expected) {
+			t.Errorf("%q: expected %v, got %v", tc.mergeContainers, tc.expected, got)
+		}
+	}
+}
+
+func TestDeploymentFetcherVolumeFileModes(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	clientset := fake.NewClientset(
+		&appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "default"},
+			Spec: appsv1.DeploymentSpec{Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{
+				Containers: []corev1.Container{{
+					Name:         "app",
+					VolumeMounts: []corev1.VolumeMount{{Name: "config", MountPath: "/config"}, {Name: "tls", MountPath: "/tls"}},
+				}},
+				Volumes: []corev1.Volume{
+					{Name: "config", VolumeSource: corev1.VolumeSource{ConfigMap: &corev1.ConfigMapVolumeSource{LocalObjectReference: corev1.LocalObjectReference{Name: "settings"}}}},
+					{Name: "tls", VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{SecretName: "certs"}}},
+				},
+			}}},
+		},
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "settings", Namespace: "default"},
+			Data:       map[string]string{"app.yaml": "debug: true"},
+		},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "certs", Namespace: "default"},
+			Data:       map[string][]byte{"tls.key": []byte("private")},
+		},
+	)
+
+	tests := []struct {
+		name           string
+		ctx            context.Context
+		config, secret os.FileMode
+	}{
+		{name: "default", ctx: context.Background(), config: 0644, secret: 0600},
+		{name: "output mode", ctx: WithFileMode(context.Background(), 0640), config: 0640, secret: 0640},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			directory := filepath.Join("generated", tt.name)
+			if _, err := (&DeploymentFetcher{}).Fetch(tt.ctx, clientset, Source{Type: "Deployment", Name: "app"}, directory); err != nil {
+				t.Fatalf("Fetch returned error: %v", err)
+			}
+			for path, expected := range map[string]os.FileMode{"config/app.yaml": tt.config, "tls/tls.key": tt.secret} {
+				info, err := os.Stat(filepath.Join(directory, path))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if info.Mode().Perm() != expected {
+					t.Errorf("expected %s to have mode %o, got %o", path, expected, info.Mode().Perm())
+				}
+			}
+		})
+	}
+}
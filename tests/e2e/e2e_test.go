@@ -2,12 +2,12 @@ package e2e
 
 import (
 	"bytes"
+	"errors"
 	"flag"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
-	"sort"
 	"strings"
 	"testing"
 	"time"
@@ -334,6 +334,7 @@ func TestExecuteCommand(t *testing.T) {
 		{"deployment", "output/deployment.env", "golden/deployment.env"},
 		{"statefulset", "output/statefulset.env", "golden/statefulset.env"},
 		{"daemonset", "output/daemonset.env", "golden/daemonset.env"},
+		{"export", "output/export.env", "golden/export.env"},
 	}
 
 	for _, tc := range tests {
@@ -355,13 +356,59 @@ func TestExecuteCommand(t *testing.T) {
 				t.Fatalf("Failed to read golden file %s: %v", tc.goldenFile, err)
 			}
 
-			if !compareEnvFiles(string(expected), string(actual)) {
+			if !bytes.Equal(expected, actual) {
 				t.Errorf("Output mismatch for %s\nExpected:\n%s\nActual:\n%s", tc.name, expected, actual)
 			}
 		})
 	}
 }
 
+func TestGenerateExport(t *testing.T) {
+	// Change to testdata directory
+	origDir, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Failed to get working directory: %v", err)
+	}
+	defer os.Chdir(origDir)
+
+	if err := os.Chdir("testdata"); err != nil {
+		t.Fatalf("Failed to change to testdata directory: %v", err)
+	}
+
+	// Clean up output directory
+	os.RemoveAll("output")
+	defer os.RemoveAll("output")
+
+	// Run generate command with --export
+	cmd := exec.Command(binaryPath, "generate",
+		"--context", "configmap-only",
+		"--kube-context", "kind-kind",
+		"--output-name", "export.env",
+		"--output-directory", "output",
+		"--export")
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("Generate command failed: %v\nstdout: %s\nstderr: %s", err, stdout.String(), stderr.String())
+	}
+
+	actual, err := os.ReadFile("output/export.env")
+	if err != nil {
+		t.Fatalf("Failed to read output file: %v", err)
+	}
+
+	expected, err := os.ReadFile("golden/export.env")
+	if err != nil {
+		t.Fatalf("Failed to read golden file: %v", err)
+	}
+
+	if !bytes.Equal(expected, actual) {
+		t.Errorf("Output mismatch\nExpected:\n%s\nActual:\n%s", expected, actual)
+	}
+}
+
 func TestExecuteByName(t *testing.T) {
 	// Change to testdata directory
 	origDir, err := os.Getwd()
@@ -399,6 +446,44 @@ func TestExecuteByName(t *testing.T) {
 	}
 }
 
+func TestRunCommand(t *testing.T) {
+	// Change to testdata directory
+	origDir, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Failed to get working directory: %v", err)
+	}
+	defer os.Chdir(origDir)
+
+	if err := os.Chdir("testdata"); err != nil {
+		t.Fatalf("Failed to change to testdata directory: %v", err)
+	}
+
+	// Clean up output directory
+	os.RemoveAll("output")
+	defer os.RemoveAll("output")
+
+	// Run a command with the environment of configmap-test and forward its exit code
+	cmd := exec.Command(binaryPath, "run", "--name", "configmap-test", "--", "sh", "-c", "echo \"$CONFIG_KEY1\"; exit 3")
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err = cmd.Run()
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) || exitErr.ExitCode() != 3 {
+		t.Fatalf("Expected exit code 3, got %v\nstdout: %s\nstderr: %s", err, stdout.String(), stderr.String())
+	}
+
+	if strings.TrimSpace(stdout.String()) != "config-value-1" {
+		t.Errorf("Expected CONFIG_KEY1 to be injected, got stdout: %s", stdout.String())
+	}
+
+	// No file is written
+	if _, err := os.Stat("output/configmap.env"); !os.IsNotExist(err) {
+		t.Error("Expected output/configmap.env to not exist")
+	}
+}
+
 func TestExecuteInteractiveSelection(t *testing.T) {
 	// Change to testdata directory
 	origDir, err := os.Getwd()
@@ -427,6 +512,8 @@ func TestExecuteInteractiveSelection(t *testing.T) {
 	cmd.Stdin = console.Tty()
 	cmd.Stdout = console.Tty()
 	cmd.Stderr = console.Tty()
+	// CI=true would disable the prompts under test
+	cmd.Env = append(os.Environ(), "CI=false")
 
 	done := make(chan error, 1)
 	go func() {
@@ -491,6 +578,8 @@ func TestGenerateInteractiveKubeContextSelection(t *testing.T) {
 	cmd.Stdin = console.Tty()
 	cmd.Stdout = console.Tty()
 	cmd.Stderr = console.Tty()
+	// CI=true would disable the prompts under test
+	cmd.Env = append(os.Environ(), "CI=false")
 
 	done := make(chan error, 1)
 	go func() {
@@ -565,6 +654,57 @@ func TestGenerateWithTransformations(t *testing.T) {
 	}
 }
 
+func TestGenerateConfigMapFileTransformation(t *testing.T) {
+	// Change to testdata directory
+	origDir, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Failed to get working directory: %v", err)
+	}
+	defer os.Chdir(origDir)
+
+	if err := os.Chdir("testdata"); err != nil {
+		t.Fatalf("Failed to change to testdata directory: %v", err)
+	}
+
+	// Clean up output directory
+	os.RemoveAll("output")
+	defer os.RemoveAll("output")
+	defer os.RemoveAll("enver-e2e-test")
+
+	cmd := exec.Command(binaryPath, "generate",
+		"--context", "file-transform",
+		"--kube-context", "kind-kind",
+		"--output-name", "file-transform.env",
+		"--output-directory", "output")
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		t.Fatalf("Generate command failed: %v\nstdout: %s\nstderr: %s", err, stdout.String(), stderr.String())
+	}
+
+	// The file must be written under the output directory, not under a folder named after the namespace
+	fileContent, err := os.ReadFile("output/files/config.json")
+	if err != nil {
+		t.Fatalf("Expected output/files/config.json to be created by file transformation: %v", err)
+	}
+	if !strings.Contains(string(fileContent), `"database": "postgres"`) {
+		t.Errorf("Expected extracted file to contain the ConfigMap value, got: %s", string(fileContent))
+	}
+	if _, err := os.Stat("enver-e2e-test"); !os.IsNotExist(err) {
+		t.Error("Expected no directory named after the namespace to be created")
+	}
+
+	actual, err := os.ReadFile("output/file-transform.env")
+	if err != nil {
+		t.Fatalf("Failed to read output file: %v", err)
+	}
+	if !strings.Contains(string(actual), "CONFIG_JSON_PATH=output/files/config.json") {
+		t.Errorf("Expected output to point CONFIG_JSON_PATH at the written file, got:\n%s", actual)
+	}
+}
+
 func runGenerateTest(t *testing.T, context, goldenFile string) {
 	t.Helper()
 
@@ -622,78 +762,7 @@ func runGenerateTest(t *testing.T, context, goldenFile string) {
 	}
 
 	// Compare
-	if !compareEnvFiles(string(expected), string(actual)) {
+	if !bytes.Equal(expected, actual) {
 		t.Errorf("Output mismatch\nExpected:\n%s\nActual:\n%s", expected, actual)
 	}
 }
-
-// compareEnvFiles compares two env files, ignoring order of variables within sections
-// since map iteration order in Go is non-deterministic
-func compareEnvFiles(expected, actual string) bool {
-	expectedSections := parseEnvSections(expected)
-	actualSections := parseEnvSections(actual)
-
-	if len(expectedSections) != len(actualSections) {
-		return false
-	}
-
-	for i := range expectedSections {
-		if expectedSections[i].header != actualSections[i].header {
-			return false
-		}
-		if !equalSorted(expectedSections[i].vars, actualSections[i].vars) {
-			return false
-		}
-	}
-
-	return true
-}
-
-type envSection struct {
-	header string
-	vars   []string
-}
-
-func parseEnvSections(content string) []envSection {
-	var sections []envSection
-	var current *envSection
-
-	lines := strings.Split(strings.TrimSpace(content), "\n")
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if line == "" {
-			continue
-		}
-		if strings.HasPrefix(line, "#") {
-			if current != nil {
-				sections = append(sections, *current)
-			}
-			current = &envSection{header: line}
-		} else if current != nil {
-			current.vars = append(current.vars, line)
-		}
-	}
-	if current != nil {
-		sections = append(sections, *current)
-	}
-
-	return sections
-}
-
-func equalSorted(a, b []string) bool {
-	if len(a) != len(b) {
-		return false
-	}
-	aCopy := make([]string, len(a))
-	bCopy := make([]string, len(b))
-	copy(aCopy, a)
-	copy(bCopy, b)
-	sort.Strings(aCopy)
-	sort.Strings(bCopy)
-	for i := range aCopy {
-		if aCopy[i] != bCopy[i] {
-			return false
-		}
-	}
-	return true
-}
@@ -4,14 +4,33 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sync"
 
 	"enver/gitutil"
 )
 
+// fileWriteMu serializes file writes so concurrently fetched sources writing the same output don't
+// interleave
+var fileWriteMu sync.Mutex
+
+// dryRun makes file transformations return the path they would write without writing it
+var dryRun bool
+
+// SetDryRun enables or disables dry-run mode for everything that writes files while fetching sources
+func SetDryRun(enabled bool) {
+	dryRun = enabled
+}
+
+// IsDryRun returns true if files must not be written, see SetDryRun
+func IsDryRun() bool {
+	return dryRun
+}
+
 // FileTransformation writes the value to a file and returns the file path
 type FileTransformation struct {
 	Output string
 	Key    string
+	Mode   os.FileMode // 0644 if zero
 }
 
 // TransformKeyValue writes the value to the output file and returns the new key and file path
@@ -23,16 +42,31 @@ func (t *FileTransformation) TransformKeyValue(key, value string) (string, strin
 		return key, value, fmt.Errorf("key is required for file transformation")
 	}
 
+	// Report the intended path without touching the filesystem or .gitignore
+	if dryRun {
+		return t.Key, t.Output, nil
+	}
+
+	fileWriteMu.Lock()
+	defer fileWriteMu.Unlock()
+
 	// Create output directory if it doesn't exist
 	outputDir := filepath.Dir(t.Output)
 	if err := os.MkdirAll(outputDir, 0755); err != nil {
 		return key, value, fmt.Errorf("failed to create output directory: %w", err)
 	}
 
-	// Write value to file
-	if err := os.WriteFile(t.Output, []byte(value), 0644); err != nil {
+	// Write value to file, os.WriteFile keeps the permissions of an existing file
+	perm := t.Mode
+	if perm == 0 {
+		perm = 0644
+	}
+	if err := os.WriteFile(t.Output, []byte(value), perm); err != nil {
 		return key, value, fmt.Errorf("failed to write file %s: %w", t.Output, err)
 	}
+	if err := os.Chmod(t.Output, perm); err != nil {
+		return key, value, fmt.Errorf("failed to set permissions of %s: %w", t.Output, err)
+	}
 
 	// Check if output file should be added to .gitignore
 	if err := gitutil.EnsureGitignored(t.Output); err != nil {
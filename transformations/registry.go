@@ -2,6 +2,7 @@ package transformations
 
 import (
 	"fmt"
+	"os"
 	"path/filepath"
 )
 
@@ -13,7 +14,8 @@ type Config struct {
 	Variables     []string
 	Output        string
 	Key           string
-	BaseDirectory string // base directory for relative paths in file transformation
+	BaseDirectory string      // base directory for relative paths in file transformation
+	Mode          os.FileMode // permissions of the file written by file transformation, 0644 if zero
 }
 
 // BuildTransformation creates a Transformation from a config
@@ -32,16 +34,42 @@ func BuildTransformation(cfg Config) (Transformation, Target, error) {
 		return &Prefix{Value: cfg.Value}, target, nil
 	case "suffix":
 		return &Suffix{Value: cfg.Value}, target, nil
+	case "shell_quote":
+		if target == TargetKey {
+			return nil, target, fmt.Errorf("shell_quote transformation can only be applied to values")
+		}
+		return &ShellQuote{}, target, nil
 	case "absolute_path":
 		if target == TargetKey {
 			return nil, target, fmt.Errorf("absolute_path transformation can only be applied to values")
 		}
 		return &AbsolutePath{}, target, nil
+	case "sanitize_key":
+		// Applies to keys even without target: key
+		if cfg.Target == "value" {
+			return nil, target, fmt.Errorf("sanitize_key transformation can only be applied to keys")
+		}
+		return &SanitizeKey{}, TargetKey, nil
 	default:
 		return nil, target, fmt.Errorf("unknown transformation type: %s", cfg.Type)
 	}
 }
 
+// Validate checks that a transformation config can be applied, without applying it
+func Validate(cfg Config) error {
+	// file and output_directory are handled by ApplyTransformations itself
+	switch cfg.Type {
+	case "file", "output_directory":
+		if cfg.Target != "" && cfg.Target != "value" {
+			return fmt.Errorf("%s transformation can only be applied to values", cfg.Type)
+		}
+		return nil
+	}
+
+	_, _, err := BuildTransformation(cfg)
+	return err
+}
+
 // shouldApplyToVariable checks if the transformation should apply to the given variable
 func shouldApplyToVariable(varName string, variables []string) bool {
 	// If no variables specified, apply to all
@@ -76,7 +104,7 @@ func ApplyTransformations(key, value string, configs []Config) (string, string,
 			if !filepath.IsAbs(outputPath) && cfg.BaseDirectory != "" {
 				outputPath = filepath.Join(cfg.BaseDirectory, outputPath)
 			}
-			ft := &FileTransformation{Output: outputPath, Key: cfg.Key}
+			ft := &FileTransformation{Output: outputPath, Key: cfg.Key, Mode: cfg.Mode}
 			newKey, newValue, err := ft.TransformKeyValue(key, value)
 			if err != nil {
 				return key, value, err
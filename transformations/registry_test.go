@@ -0,0 +1,35 @@
+package transformations
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		cfg     Config
+		wantErr string
+	}{
+		{Config{Type: "prefix", Value: "APP_", Target: "key"}, ""},
+		{Config{Type: "file", Output: "cert.pem"}, ""},
+		{Config{Type: "output_directory"}, ""},
+		{Config{Type: "upper_case"}, "unknown transformation type: upper_case"},
+		{Config{Type: "absolute_path", Target: "key"}, "absolute_path transformation can only be applied to values"},
+		{Config{Type: "file", Target: "key"}, "file transformation can only be applied to values"},
+		{Config{Type: "sanitize_key"}, ""},
+		{Config{Type: "sanitize_key", Target: "value"}, "sanitize_key transformation can only be applied to keys"},
+	}
+
+	for _, tc := range tests {
+		err := Validate(tc.cfg)
+		if tc.wantErr == "" {
+			if err != nil {
+				t.Errorf("%s: expected no error, got %v", tc.cfg.Type, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+			t.Errorf("%s: expected error %q, got %v", tc.cfg.Type, tc.wantErr, err)
+		}
+	}
+}
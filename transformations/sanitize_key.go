@@ -0,0 +1,22 @@
+package transformations
+
+import (
+	"strings"
+)
+
+// SanitizeKey turns the input into a valid shell identifier so the env file can be sourced
+// Characters outside [A-Za-z0-9_] become _ and a leading digit is prefixed with _
+type SanitizeKey struct{}
+
+func (t *SanitizeKey) Transform(input string) string {
+	sanitized := strings.Map(func(r rune) rune {
+		if r == '_' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+			return r
+		}
+		return '_'
+	}, input)
+	if sanitized != "" && sanitized[0] >= '0' && sanitized[0] <= '9' {
+		sanitized = "_" + sanitized
+	}
+	return sanitized
+}
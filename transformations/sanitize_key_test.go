@@ -0,0 +1,35 @@
+package transformations
+
+import (
+	"testing"
+)
+
+func TestSanitizeKey(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"my.config-key", "my_config_key"},
+		{"app.db.host", "app_db_host"},
+		{"feature-flags", "feature_flags"},
+		{"VALID_NAME_1", "VALID_NAME_1"},
+		{"1st-key", "_1st_key"},
+		{"héllo", "h_llo"},
+	}
+
+	for _, tc := range tests {
+		if got := (&SanitizeKey{}).Transform(tc.input); got != tc.expected {
+			t.Errorf("SanitizeKey(%q) = %q, expected %q", tc.input, got, tc.expected)
+		}
+	}
+}
+
+func TestSanitizeKeyDefaultsToKeyTarget(t *testing.T) {
+	key, value, err := ApplyTransformations("my.config-key", "a.b-c", []Config{{Type: "sanitize_key"}})
+	if err != nil {
+		t.Fatalf("ApplyTransformations returned error: %v", err)
+	}
+	if key != "my_config_key" || value != "a.b-c" {
+		t.Errorf("expected only the key to be sanitized, got %q=%q", key, value)
+	}
+}
@@ -0,0 +1,13 @@
+package transformations
+
+import (
+	"strings"
+)
+
+// ShellQuote wraps the input in POSIX single quotes so it can be embedded in shell scripts
+// Embedded single quotes are closed, escaped with a backslash and reopened
+type ShellQuote struct{}
+
+func (t *ShellQuote) Transform(input string) string {
+	return "'" + strings.ReplaceAll(input, "'", `'\''`) + "'"
+}
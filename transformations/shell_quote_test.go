@@ -0,0 +1,24 @@
+package transformations
+
+import (
+	"testing"
+)
+
+func TestShellQuote(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"simple", `'simple'`},
+		{"a'b", `'a'\''b'`},
+		{"two words", `'two words'`},
+		{`$HOME "x"`, `'$HOME "x"'`},
+		{"", `''`},
+	}
+
+	for _, tc := range tests {
+		if got := (&ShellQuote{}).Transform(tc.input); got != tc.expected {
+			t.Errorf("ShellQuote(%q) = %q, expected %q", tc.input, got, tc.expected)
+		}
+	}
+}